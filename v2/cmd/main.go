@@ -15,6 +15,7 @@ import (
 	"github.com/forestnode-io/oneshot/v2/pkg/net/webrtc/signallingserver"
 	"github.com/forestnode-io/oneshot/v2/pkg/output"
 	"github.com/forestnode-io/oneshot/v2/pkg/sys"
+	"github.com/forestnode-io/oneshot/v2/pkg/version"
 )
 
 func main() {
@@ -23,6 +24,8 @@ func main() {
 		err    error
 	)
 
+	version.Check()
+
 	//lint:ignore SA1019 the issues that plague this implementation are not relevant to this project
 	rand.Seed(time.Now().UnixNano())
 
@@ -47,6 +50,7 @@ func main() {
 			if ec := events.GetExitCode(ctx); -1 < ec {
 				status = ec
 			}
+			output.Exit(ctx, status)
 			os.Exit(status)
 		}
 	}()
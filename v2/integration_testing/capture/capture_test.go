@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	itest "github.com/forestnode-io/oneshot/v2/integration_testing"
+	"github.com/forestnode-io/oneshot/v2/pkg/output"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestBasicTestSuite(t *testing.T) {
+	suite.Run(t, new(ts))
+}
+
+type ts struct {
+	itest.TestSuite
+}
+
+func (suite *ts) Test_HumanOutput() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"capture", "--status-code", "202", "--body", "thanks", "-H", "X-Captured=yes", "-H", "X-Captured=again"}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	client := itest.RetryClient{}
+	resp, err := client.Post("http://127.0.0.1:8080/hooks/push?delivery=1", "application/json",
+		strings.NewReader(`{"ref":"refs/heads/main","commits":[{"id":"abc"}]}`))
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusAccepted, resp.StatusCode)
+	suite.Assert().Equal([]string{"yes", "again"}, resp.Header.Values("X-Captured"))
+	body, err := io.ReadAll(resp.Body)
+	suite.Require().NoError(err)
+	resp.Body.Close()
+	suite.Assert().Equal("thanks", string(body))
+
+	oneshot.Wait()
+	stdout := string(oneshot.Stdout.(*bytes.Buffer).Bytes())
+	suite.Assert().Contains(stdout, "POST /hooks/push?delivery=1 HTTP/1.1\n")
+	suite.Assert().Contains(stdout, "Content-Type: application/json\n")
+	suite.Assert().Contains(stdout, `{
+  "ref": "refs/heads/main",
+  "commits": [
+    {
+      "id": "abc"
+    }
+  ]
+}`)
+}
+
+func (suite *ts) Test_Multipart_JSON() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"capture", "--output", "json"}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	var (
+		buf = bytes.NewBuffer(nil)
+		mw  = multipart.NewWriter(buf)
+	)
+	suite.Require().NoError(mw.WriteField("event", "upload"))
+	fw, err := mw.CreateFormFile("attachment", "notes.txt")
+	suite.Require().NoError(err)
+	_, err = fw.Write([]byte("some notes"))
+	suite.Require().NoError(err)
+	suite.Require().NoError(mw.Close())
+
+	client := itest.RetryClient{}
+	resp, err := client.Post("http://127.0.0.1:8080/anything", mw.FormDataContentType(), buf)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	oneshot.Wait()
+	stdout := oneshot.Stdout.(*bytes.Buffer).Bytes()
+	var report output.Report
+	suite.Require().NoError(json.Unmarshal(stdout, &report))
+	suite.Require().NotNil(report.Success)
+	suite.Assert().Empty(report.Attempts)
+
+	req := report.Success.Request
+	suite.Require().NotNil(req)
+	suite.Assert().Equal("POST", req.Method)
+	suite.Assert().Equal(map[string][]string{"event": {"upload"}}, req.Form)
+	suite.Require().Len(req.Parts, 2)
+	suite.Assert().Equal("event", req.Parts[0].Name)
+	suite.Assert().Equal("upload", req.Parts[0].Content)
+	suite.Assert().Equal("attachment", req.Parts[1].Name)
+	suite.Assert().Equal("notes.txt", req.Parts[1].FileName)
+	suite.Assert().Equal(int64(len("some notes")), req.Parts[1].Size)
+	suite.Assert().Equal("some notes", req.Parts[1].Content)
+}
+
+func (suite *ts) Test_HMAC_GitHub() {
+	const secret = "It's a Secret to Everybody"
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"capture", "--output", "json", "--hmac-secret", secret}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	payload := `{"action":"opened"}`
+	client := itest.RetryClient{}
+
+	// unsigned and badly signed requests are turned away and oneshot keeps waiting
+	resp, err := client.Post("http://127.0.0.1:8080/", "application/json", strings.NewReader(payload))
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusUnauthorized, resp.StatusCode)
+	resp.Body.Close()
+
+	req, err := http.NewRequest(http.MethodPost, "http://127.0.0.1:8080/", strings.NewReader(payload))
+	suite.Require().NoError(err)
+	req.Header.Set("X-Hub-Signature-256", "sha256="+sign(secret, "tampered"))
+	resp, err = client.Do(req)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusUnauthorized, resp.StatusCode)
+	resp.Body.Close()
+
+	req, err = http.NewRequest(http.MethodPost, "http://127.0.0.1:8080/", strings.NewReader(payload))
+	suite.Require().NoError(err)
+	req.Header.Set("X-Hub-Signature-256", "sha256="+sign(secret, payload))
+	resp, err = client.Do(req)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	oneshot.Wait()
+	stdout := oneshot.Stdout.(*bytes.Buffer).Bytes()
+	var report output.Report
+	suite.Require().NoError(json.Unmarshal(stdout, &report))
+	suite.Require().NotNil(report.Success)
+	suite.Require().Len(report.Attempts, 2)
+	suite.Assert().Equal("missing signature", report.Attempts[0].Error)
+	suite.Assert().Equal("invalid signature", report.Attempts[1].Error)
+	suite.Assert().NotContains(string(stdout), secret)
+}
+
+func (suite *ts) Test_HMAC_Stripe() {
+	const secret = "whsec_test"
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"capture", "--hmac-secret", "env:WEBHOOK_SECRET", "--hmac-header", "Stripe-Signature"}
+	oneshot.Env = []string{"WEBHOOK_SECRET=" + secret}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	var (
+		payload   = `{"type":"charge.succeeded"}`
+		timestamp = fmt.Sprint(time.Now().Unix())
+	)
+	req, err := http.NewRequest(http.MethodPost, "http://127.0.0.1:8080/stripe", strings.NewReader(payload))
+	suite.Require().NoError(err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Stripe-Signature", fmt.Sprintf("t=%s,v1=%s,v0=ignored", timestamp, sign(secret, timestamp+"."+payload)))
+
+	client := itest.RetryClient{}
+	resp, err := client.Do(req)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	oneshot.Wait()
+	stdout := string(oneshot.Stdout.(*bytes.Buffer).Bytes())
+	suite.Assert().Contains(stdout, "POST /stripe HTTP/1.1\n")
+	suite.Assert().Contains(stdout, `"type": "charge.succeeded"`)
+}
+
+func (suite *ts) Test_HMAC_Stripe_Stale() {
+	const secret = "whsec_test"
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"capture", "--output", "json", "--hmac-secret", secret, "--hmac-header", "Stripe-Signature", "--hmac-tolerance", "1m"}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	client := itest.RetryClient{}
+	payload := `{"type":"charge.succeeded"}`
+	// a signature captured earlier is turned away, as is one from the future, before a fresh one is accepted
+	for _, at := range []time.Time{
+		time.Now().Add(-time.Hour),
+		time.Now().Add(time.Hour),
+		time.Now(),
+	} {
+		timestamp := fmt.Sprint(at.Unix())
+		req, err := http.NewRequest(http.MethodPost, "http://127.0.0.1:8080/stripe", strings.NewReader(payload))
+		suite.Require().NoError(err)
+		req.Header.Set("Stripe-Signature", fmt.Sprintf("t=%s,v1=%s", timestamp, sign(secret, timestamp+"."+payload)))
+		resp, err := client.Do(req)
+		suite.Require().NoError(err)
+		resp.Body.Close()
+	}
+
+	oneshot.Wait()
+	stdout := oneshot.Stdout.(*bytes.Buffer).Bytes()
+	var report output.Report
+	suite.Require().NoError(json.Unmarshal(stdout, &report))
+	suite.Require().NotNil(report.Success)
+	suite.Require().Len(report.Attempts, 2)
+	for _, attempt := range report.Attempts {
+		suite.Assert().Equal("signature timestamp is outside of the tolerance", attempt.Error)
+	}
+}
+
+func sign(secret, payload string) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte(payload))
+	return hex.EncodeToString(m.Sum(nil))
+}
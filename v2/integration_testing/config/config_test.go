@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	itest "github.com/forestnode-io/oneshot/v2/integration_testing"
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/yaml.v3"
+)
+
+func TestBasicTestSuite(t *testing.T) {
+	suite.Run(t, new(ts))
+}
+
+type ts struct {
+	itest.TestSuite
+}
+
+const baseConfig = `server:
+  port: 9000
+`
+
+func (suite *ts) runConfig(oneshot *itest.Oneshot, args ...string) (int, string) {
+	oneshot.Args = append([]string{"config"}, args...)
+	if oneshot.Files == nil {
+		oneshot.Files = itest.FilesMap{"./config.yaml": []byte(baseConfig)}
+	}
+	oneshot.Env = append(oneshot.Env, "ONESHOT_CONFIG=./config.yaml")
+	oneshot.Start()
+	defer oneshot.Cleanup()
+	oneshot.Wait()
+
+	return oneshot.Cmd.ProcessState.ExitCode(), oneshot.Stderr.(*bytes.Buffer).String()
+}
+
+func (suite *ts) readConfig(oneshot *itest.Oneshot) map[string]any {
+	content, err := os.ReadFile(filepath.Join(oneshot.WorkingDir, "config.yaml"))
+	suite.Require().NoError(err)
+
+	var config map[string]any
+	suite.Require().NoError(yaml.Unmarshal(content, &config))
+	return config
+}
+
+func (suite *ts) Test_List() {
+	var oneshot = suite.NewOneshot()
+	code, _ := suite.runConfig(oneshot, "list")
+	suite.Require().Equal(0, code)
+
+	stdout := oneshot.Stdout.(*bytes.Buffer).String()
+	var found bool
+	for _, line := range strings.Split(stdout, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 5 && fields[0] == "server.port" {
+			found = true
+			suite.Assert().Equal([]string{"server.port", "int", "8080", "ONESHOT_SERVER_PORT", "--port"}, fields)
+		}
+	}
+	suite.Assert().True(found, "server.port is not listed")
+}
+
+func (suite *ts) Test_Set() {
+	var oneshot = suite.NewOneshot()
+	code, stderr := suite.runConfig(oneshot, "set", "server.timeout", "90s")
+	suite.Require().Equal(0, code, stderr)
+
+	server := suite.readConfig(oneshot)["server"].(map[string]any)
+	suite.Assert().Equal("1m30s", server["timeout"])
+	suite.Assert().Equal(9000, server["port"])
+}
+
+func (suite *ts) Test_Set_Invalid() {
+	for _, args := range [][]string{
+		{"server.port", "abc"},
+		{"server.port", "99999"},
+		{"server.allowbots", "maybe"},
+		{"server.nope", "1"},
+	} {
+		var oneshot = suite.NewOneshot()
+		code, _ := suite.runConfig(oneshot, append([]string{"set"}, args...)...)
+		suite.Assert().NotEqual(0, code, args)
+
+		// the file is left alone
+		server := suite.readConfig(oneshot)["server"].(map[string]any)
+		suite.Assert().Equal(map[string]any{"port": 9000}, server, args)
+	}
+}
+
+func (suite *ts) Test_Unset() {
+	var oneshot = suite.NewOneshot()
+	code, stderr := suite.runConfig(oneshot, "unset", "server.port")
+	suite.Require().Equal(0, code, stderr)
+
+	config := suite.readConfig(oneshot)
+	suite.Assert().NotContains(config, "server")
+}
+
+func (suite *ts) Test_Init() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Files = itest.FilesMap{
+		"./config.yaml": []byte(baseConfig),
+		"./cert.pem":    []byte("cert"),
+		"./key.pem":     []byte("key"),
+	}
+	oneshot.Stdin = strings.NewReader(strings.Join([]string{
+		"discovery.example.com", // discovery host
+		"-",                     // discovery key
+		"cert.pem",              // tls cert
+		"key.pem",               // tls key
+		"oneshot",               // username
+		"hunter2",               // password
+	}, "\n") + "\n")
+	code, stderr := suite.runConfig(oneshot, "init")
+	suite.Require().Equal(0, code, stderr)
+
+	config := suite.readConfig(oneshot)
+	suite.Assert().Equal(map[string]any{
+		"port":    9000,
+		"tlscert": "cert.pem",
+		"tlskey":  "key.pem",
+	}, config["server"])
+	suite.Assert().Equal(map[string]any{
+		"username": "oneshot",
+		"password": "hunter2",
+	}, config["basicauth"])
+	suite.Assert().Equal(map[string]any{
+		"host": "discovery.example.com",
+	}, config["discovery"])
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"io"
+	"net"
 	"net/http"
 	"runtime"
 	"strings"
@@ -124,6 +125,46 @@ func (suite *ts) Test_JSON() {
 	suite.Assert().Regexp(`listening on http://.*\n`, string(stderr))
 }
 
+func (suite *ts) Test_ClientCert_CGIEnv() {
+	ca, err := itest.NewCA()
+	suite.Require().NoError(err)
+	serverCert, serverKey, err := ca.Issue("oneshot", net.ParseIP("127.0.0.1"))
+	suite.Require().NoError(err)
+	clientCert, clientKey, err := ca.Issue("backup")
+	suite.Require().NoError(err)
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"exec",
+		"--tls-cert", "./server.pem",
+		"--tls-key", "./server.key",
+		"--tls-client-ca", "./ca.pem",
+		"--", "sh", "-c", `echo "$HTTPS $SSL_CLIENT_VERIFY $SSL_CLIENT_S_DN_CN $SSL_CLIENT_S_DN"`,
+	}
+	oneshot.Files = itest.FilesMap{
+		"./server.pem": serverCert,
+		"./server.key": serverKey,
+		"./ca.pem":     ca.PEM,
+	}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	// ---
+
+	tlsConfig, err := ca.ClientTLSConfig(clientCert, clientKey)
+	suite.Require().NoError(err)
+	client := itest.NewRetryClient(&http.Transport{TLSClientConfig: tlsConfig})
+	resp, err := client.Get("https://127.0.0.1:8080")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	suite.Assert().NoError(err)
+	resp.Body.Close()
+	suite.Assert().Equal("on SUCCESS backup CN=backup,O=oneshot\n", string(body))
+
+	oneshot.Wait()
+}
+
 func (suite *ts) Test_MultipleClients() {
 	var oneshot = suite.NewOneshot()
 	oneshot.Args = []string{"exec", "go", "env", "GOOS"}
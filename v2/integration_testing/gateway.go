@@ -0,0 +1,177 @@
+package itest
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+	"time"
+)
+
+// GatewayRequest is a port mapping request received by a FakeGateway.
+type GatewayRequest struct {
+	// Protocol is either natpmp or pcp.
+	Protocol     string
+	InternalPort int
+	ExternalPort int
+	Lifetime     time.Duration
+	// ClientIP is the client address carried by PCP requests.
+	ClientIP net.IP
+}
+
+// FakeGateway is a NAT-PMP and PCP server that grants every mapping it is asked for.
+type FakeGateway struct {
+	// ExternalIP is handed out as the external address of NAT-PMP and IPv4 PCP mappings.
+	ExternalIP net.IP
+	// DisableNATPMP answers NAT-PMP requests with an unsupported version error, like PCP only gateways do.
+	DisableNATPMP bool
+	// DisablePCP answers PCP requests the way NAT-PMP only gateways do.
+	DisablePCP bool
+
+	conn *net.UDPConn
+
+	mu       sync.Mutex
+	requests []GatewayRequest
+}
+
+// NewFakeGateway starts a gateway listening on addr, use 127.0.0.1:0 for a random port.
+func NewFakeGateway(addr string) (*FakeGateway, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	g := FakeGateway{
+		ExternalIP: net.IPv4(127, 0, 0, 1),
+		conn:       conn,
+	}
+	go g.serve()
+
+	return &g, nil
+}
+
+func (g *FakeGateway) Addr() string {
+	return g.conn.LocalAddr().String()
+}
+
+func (g *FakeGateway) Close() error {
+	return g.conn.Close()
+}
+
+// Requests returns the mapping requests received so far.
+func (g *FakeGateway) Requests() []GatewayRequest {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]GatewayRequest(nil), g.requests...)
+}
+
+func (g *FakeGateway) serve() {
+	buf := make([]byte, 1100)
+	for {
+		n, addr, err := g.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+
+		var resp []byte
+		switch req := buf[:n]; {
+		case 2 <= n && req[0] == 0:
+			resp = g.natpmp(req)
+		case 24 <= n && req[0] == 2:
+			resp = g.pcp(req)
+		}
+		if resp != nil {
+			_, _ = g.conn.WriteToUDP(resp, addr)
+		}
+	}
+}
+
+func (g *FakeGateway) natpmp(req []byte) []byte {
+	op := req[1]
+	if g.DisableNATPMP {
+		resp := make([]byte, 8)
+		resp[1] = 128 + op
+		binary.BigEndian.PutUint16(resp[2:4], 1)
+		return resp
+	}
+
+	switch {
+	case op == 0:
+		resp := make([]byte, 12)
+		resp[1] = 128
+		copy(resp[8:12], g.ExternalIP.To4())
+		return resp
+	case op == 2 && len(req) == 12:
+		var (
+			internalPort = binary.BigEndian.Uint16(req[4:6])
+			externalPort = binary.BigEndian.Uint16(req[6:8])
+			lifetime     = binary.BigEndian.Uint32(req[8:12])
+		)
+		g.record(GatewayRequest{
+			Protocol:     "natpmp",
+			InternalPort: int(internalPort),
+			ExternalPort: int(externalPort),
+			Lifetime:     time.Duration(lifetime) * time.Second,
+		})
+
+		resp := make([]byte, 16)
+		resp[1] = 128 + op
+		binary.BigEndian.PutUint16(resp[8:10], internalPort)
+		binary.BigEndian.PutUint16(resp[10:12], externalPort)
+		binary.BigEndian.PutUint32(resp[12:16], lifetime)
+		return resp
+	}
+
+	return nil
+}
+
+func (g *FakeGateway) pcp(req []byte) []byte {
+	op := req[1] & 0x7f
+	if g.DisablePCP {
+		// NAT-PMP only gateways answer newer versions with their own
+		resp := make([]byte, 8)
+		resp[1] = 128 + op
+		binary.BigEndian.PutUint16(resp[2:4], 1)
+		return resp
+	}
+	if op != 1 || len(req) < 60 {
+		return nil
+	}
+
+	var (
+		lifetime     = binary.BigEndian.Uint32(req[4:8])
+		clientIP     = net.IP(append([]byte(nil), req[8:24]...))
+		payload      = req[24:60]
+		internalPort = binary.BigEndian.Uint16(payload[16:18])
+		externalPort = binary.BigEndian.Uint16(payload[18:20])
+	)
+	g.record(GatewayRequest{
+		Protocol:     "pcp",
+		InternalPort: int(internalPort),
+		ExternalPort: int(externalPort),
+		Lifetime:     time.Duration(lifetime) * time.Second,
+		ClientIP:     clientIP,
+	})
+
+	resp := make([]byte, 60)
+	resp[0] = 2
+	resp[1] = 0x80 | op
+	binary.BigEndian.PutUint32(resp[4:8], lifetime)
+	copy(resp[24:60], payload)
+	// IPv6 mappings are pinholes, the external address is the clients own
+	externalIP := clientIP
+	if clientIP.To4() != nil {
+		externalIP = g.ExternalIP
+	}
+	copy(resp[44:60], externalIP.To16())
+	return resp
+}
+
+func (g *FakeGateway) record(r GatewayRequest) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.requests = append(g.requests, r)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	itest "github.com/forestnode-io/oneshot/v2/integration_testing"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestBasicTestSuite(t *testing.T) {
+	suite.Run(t, new(ts))
+}
+
+type ts struct {
+	itest.TestSuite
+}
+
+func (suite *ts) Test_TO_Stdout() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("SUCCESS"))
+	}))
+	defer server.Close()
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"get", server.URL}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	oneshot.Wait()
+	suite.Assert().Equal(0, oneshot.Cmd.ProcessState.ExitCode())
+
+	stdout := oneshot.Stdout.(*bytes.Buffer).Bytes()
+	suite.Assert().Equal("SUCCESS", string(stdout))
+}
+
+func (suite *ts) Test_TO_File_FollowsRedirect_BasicAuth() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/file", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/file", func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != "username" || p != "password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Disposition", `attachment; filename="test.txt"`)
+		_, _ = w.Write([]byte("SUCCESS"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"get", "--username", "username", "--password", "password", server.URL, "./test.txt"}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	oneshot.Wait()
+	suite.Assert().Equal(0, oneshot.Cmd.ProcessState.ExitCode())
+
+	fileContents, err := os.ReadFile(filepath.Join(oneshot.WorkingDir, "test.txt"))
+	suite.Require().NoError(err)
+	suite.Assert().Equal("SUCCESS", string(fileContents))
+}
+
+func (suite *ts) Test_CrossHostRedirect_DropsBasicAuth() {
+	var gotAuth = make(chan bool, 1)
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _, ok := r.BasicAuth()
+		gotAuth <- ok
+		_, _ = w.Write([]byte("SUCCESS"))
+	}))
+	defer other.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL+"/file", http.StatusTemporaryRedirect)
+	}))
+	defer server.Close()
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"get", "--username", "username", "--password", "password", server.URL}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	oneshot.Wait()
+	suite.Assert().Equal(0, oneshot.Cmd.ProcessState.ExitCode())
+	suite.Assert().False(<-gotAuth, "credentials were sent to another host")
+}
+
+func (suite *ts) Test_CrossHostRedirect_DropsCredentialHeaders() {
+	var gotHeader = make(chan http.Header, 1)
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader <- r.Header.Clone()
+		_, _ = w.Write([]byte("SUCCESS"))
+	}))
+	defer other.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL+"/file", http.StatusTemporaryRedirect)
+	}))
+	defer server.Close()
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"get", server.URL,
+		"-H", "authorization=Bearer secret",
+		"-H", "Cookie=session\\=secret",
+		"-H", "X-Other=kept",
+	}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	oneshot.Wait()
+	suite.Assert().Equal(0, oneshot.Cmd.ProcessState.ExitCode())
+	header := <-gotHeader
+	suite.Assert().Empty(header.Get("Authorization"), "credentials were sent to another host")
+	suite.Assert().Empty(header.Get("Cookie"), "cookies were sent to another host")
+	suite.Assert().Equal("kept", header.Get("X-Other"))
+}
+
+func (suite *ts) Test_ErrorStatus() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"get", server.URL}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	oneshot.Wait()
+	suite.Assert().NotEqual(0, oneshot.Cmd.ProcessState.ExitCode())
+
+	stderr := oneshot.Stderr.(*bytes.Buffer).Bytes()
+	suite.Assert().Contains(string(stderr), "404")
+}
@@ -0,0 +1,424 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	itest "github.com/forestnode-io/oneshot/v2/integration_testing"
+	"github.com/forestnode-io/oneshot/v2/pkg/events"
+	"github.com/forestnode-io/oneshot/v2/pkg/oneshot"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestBasicTestSuite(t *testing.T) {
+	suite.Run(t, new(ts))
+}
+
+type ts struct {
+	itest.TestSuite
+}
+
+func (suite *ts) listener() (net.Listener, string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.Require().NoError(err)
+	return l, "http://" + l.Addr().String()
+}
+
+type outcome struct {
+	result *oneshot.Result
+	err    error
+}
+
+func (suite *ts) Test_Send() {
+	var (
+		l, url  = suite.listener()
+		evs     = make(chan oneshot.Event, 16)
+		done    = make(chan outcome, 1)
+		content = []byte("SUCCESS")
+	)
+
+	go func() {
+		result, err := oneshot.Send(context.Background(), oneshot.SendOptions{
+			Options: oneshot.Options{
+				Listener: l,
+				Events:   evs,
+			},
+			Name: "test.txt",
+			MIME: "text/plain",
+			Size: int64(len(content)),
+		}, bytes.NewReader(content))
+		done <- outcome{result, err}
+	}()
+
+	resp, err := http.Get(url)
+	suite.Require().NoError(err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	suite.Assert().Equal(content, body)
+	suite.Assert().Equal("text/plain", resp.Header.Get("Content-Type"))
+	suite.Assert().Equal(`attachment; filename=test.txt`, resp.Header.Get("Content-Disposition"))
+
+	o := <-done
+	suite.Require().NoError(o.err)
+	suite.Assert().True(o.result.Succeeded())
+	suite.Require().NotNil(o.result.Request)
+	suite.Assert().Equal("GET", o.result.Request.Method)
+	suite.Require().NotNil(o.result.File)
+	suite.Assert().Equal(int64(len(content)), o.result.File.TransferSize)
+
+	var got []oneshot.Event
+	for e := range evs {
+		got = append(got, e)
+	}
+	suite.Require().NotEmpty(got)
+	listening, ok := got[0].(*events.Listening)
+	suite.Require().True(ok, "first event should be listening, got %T", got[0])
+	suite.Assert().Equal(url, listening.Address)
+	shutdown, ok := got[len(got)-1].(*events.Shutdown)
+	suite.Require().True(ok, "last event should be shutdown, got %T", got[len(got)-1])
+	suite.Assert().Equal(events.ShutdownSuccess, shutdown.Reason)
+}
+
+func (suite *ts) Test_Send_ReaderConsumed() {
+	var (
+		l, url      = suite.listener()
+		done        = make(chan outcome, 1)
+		pr, pw      = io.Pipe()
+		ctx, cancel = context.WithCancel(context.Background())
+	)
+	defer cancel()
+
+	go func() {
+		result, err := oneshot.Send(ctx, oneshot.SendOptions{
+			Options: oneshot.Options{
+				Listener: l,
+			},
+		}, pr)
+		done <- outcome{result, err}
+	}()
+
+	// the first client gives up part way through
+	go func() {
+		_, _ = pw.Write([]byte("partial"))
+	}()
+	conn, err := net.Dial("tcp", l.Addr().String())
+	suite.Require().NoError(err)
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	suite.Require().NoError(err)
+	buf := make([]byte, 1024)
+	_, err = conn.Read(buf)
+	suite.Require().NoError(err)
+	conn.Close()
+	pw.CloseWithError(io.ErrUnexpectedEOF)
+
+	var resp *http.Response
+	suite.Require().Eventually(func() bool {
+		resp, err = http.Get(url)
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusGone
+	}, 5*time.Second, 50*time.Millisecond)
+
+	cancel()
+	o := <-done
+	suite.Require().NoError(o.err)
+	suite.Assert().Equal(events.ShutdownInterrupted, o.result.Reason)
+}
+
+func (suite *ts) Test_Receive_Body() {
+	var (
+		l, url  = suite.listener()
+		ctx     = context.Background()
+		content = "SUCCESS"
+	)
+
+	receiver, err := oneshot.Receive(ctx, oneshot.ReceiveOptions{
+		Options: oneshot.Options{
+			Listener: l,
+		},
+	})
+	suite.Require().NoError(err)
+
+	respChan := make(chan *http.Response, 1)
+	go func() {
+		req, _ := http.NewRequest("POST", url, strings.NewReader(content))
+		req.Header.Set("Content-Disposition", `attachment; filename="test.txt"`)
+		req.Header.Set("Content-Type", "text/plain")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			respChan <- nil
+			return
+		}
+		resp.Body.Close()
+		respChan <- resp
+	}()
+
+	f := <-receiver.Files
+	suite.Require().NotNil(f)
+	suite.Assert().Equal("test.txt", f.Name)
+	suite.Assert().Equal("text/plain", f.MIME)
+	suite.Assert().Equal(int64(len(content)), f.Size)
+	body, err := io.ReadAll(f.Body)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(content, string(body))
+
+	resp := <-respChan
+	suite.Require().NotNil(resp)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+
+	// the channel closes once the server shuts down
+	_, ok := <-receiver.Files
+	suite.Assert().False(ok)
+
+	result, err := receiver.Wait()
+	suite.Require().NoError(err)
+	suite.Assert().True(result.Succeeded())
+	suite.Require().NotNil(result.File)
+	suite.Assert().Equal(int64(len(content)), result.File.TransferSize)
+}
+
+func (suite *ts) Test_Receive_Location() {
+	var (
+		l, url  = suite.listener()
+		ctx     = context.Background()
+		content = "SUCCESS"
+		dir     = suite.NewOneshot().WorkingDir
+	)
+
+	receiver, err := oneshot.Receive(ctx, oneshot.ReceiveOptions{
+		Options: oneshot.Options{
+			Listener: l,
+		},
+		Location: dir,
+	})
+	suite.Require().NoError(err)
+
+	req, err := http.NewRequest("POST", url, strings.NewReader(content))
+	suite.Require().NoError(err)
+	req.Header.Set("Content-Disposition", `attachment; filename="test.txt"`)
+	resp, err := http.DefaultClient.Do(req)
+	suite.Require().NoError(err)
+	resp.Body.Close()
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+
+	f := <-receiver.Files
+	suite.Require().NotNil(f)
+	suite.Assert().Nil(f.Body)
+	suite.Assert().Equal(filepath.Join(dir, "test.txt"), f.Path)
+	body, err := os.ReadFile(f.Path)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(content, string(body))
+
+	_, ok := <-receiver.Files
+	suite.Assert().False(ok)
+
+	result, err := receiver.Wait()
+	suite.Require().NoError(err)
+	suite.Assert().True(result.Succeeded())
+	suite.Require().NotNil(result.File)
+	suite.Assert().Equal(f.Path, result.File.Path)
+}
+
+func (suite *ts) Test_Receive_Location_PathTraversal() {
+	var (
+		l, url  = suite.listener()
+		content = "SUCCESS"
+		parent  = suite.NewOneshot().WorkingDir
+		dir     = filepath.Join(parent, "location")
+	)
+	suite.Require().NoError(os.Mkdir(dir, 0700))
+
+	for _, name := range []string{"../escaped.txt", ".."} {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		receiver, err := oneshot.Receive(ctx, oneshot.ReceiveOptions{
+			Options: oneshot.Options{
+				Listener: l,
+			},
+			Location: dir,
+		})
+		suite.Require().NoError(err)
+
+		req, err := http.NewRequest("POST", url, strings.NewReader(content))
+		suite.Require().NoError(err)
+		req.Header.Set("Content-Disposition", `attachment; filename="`+name+`"`)
+		resp, err := http.DefaultClient.Do(req)
+		suite.Require().NoError(err)
+		resp.Body.Close()
+		suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+
+		f := <-receiver.Files
+		suite.Require().NotNil(f)
+		// the file stays in the location whatever the client calls it
+		suite.Assert().Equal(dir, filepath.Dir(f.Path), name)
+		_, err = receiver.Wait()
+		suite.Require().NoError(err)
+
+		l, url = suite.listener()
+	}
+	l.Close()
+
+	_, err := os.Stat(filepath.Join(parent, "escaped.txt"))
+	suite.Assert().ErrorIs(err, os.ErrNotExist)
+	_, err = os.Stat(filepath.Join(dir, "escaped.txt"))
+	suite.Assert().NoError(err)
+}
+
+func (suite *ts) Test_Receive_Location_Timeout() {
+	var (
+		l, _ = suite.listener()
+		dir  = suite.NewOneshot().WorkingDir
+	)
+
+	receiver, err := oneshot.Receive(context.Background(), oneshot.ReceiveOptions{
+		Options: oneshot.Options{
+			Listener: l,
+			Timeout:  100 * time.Millisecond,
+		},
+		Location: dir,
+	})
+	suite.Require().NoError(err)
+
+	_, ok := <-receiver.Files
+	suite.Assert().False(ok)
+
+	result, err := receiver.Wait()
+	suite.Require().NoError(err)
+	suite.Assert().False(result.Succeeded())
+	suite.Assert().Equal(events.ShutdownTimeout, result.Reason)
+}
+
+func (suite *ts) Test_Serve() {
+	var (
+		l, url = suite.listener()
+		done   = make(chan outcome, 1)
+	)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/favicon.ico":
+			oneshot.IgnoreRequest(w)
+			w.WriteHeader(http.StatusNotFound)
+		case "/fail":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			_, _ = w.Write([]byte("hello"))
+		}
+	})
+	go func() {
+		result, err := oneshot.Serve(context.Background(), oneshot.Options{
+			Listener: l,
+		}, handler)
+		done <- outcome{result, err}
+	}()
+
+	for _, path := range []string{"/favicon.ico", "/fail"} {
+		resp, err := http.Get(url + path)
+		suite.Require().NoError(err)
+		resp.Body.Close()
+	}
+	select {
+	case o := <-done:
+		suite.Failf("serve returned early", "result: %+v, error: %v", o.result, o.err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	resp, err := http.Get(url + "/hello")
+	suite.Require().NoError(err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	suite.Require().NoError(err)
+	suite.Assert().Equal("hello", string(body))
+
+	o := <-done
+	suite.Require().NoError(o.err)
+	suite.Assert().True(o.result.Succeeded())
+	suite.Require().NotNil(o.result.Request)
+	suite.Assert().Equal("/hello", o.result.Request.Path)
+}
+
+func (suite *ts) Test_Serve_BasicAuth() {
+	var (
+		l, url = suite.listener()
+		evs    = make(chan oneshot.Event, 16)
+		done   = make(chan outcome, 1)
+	)
+
+	go func() {
+		result, err := oneshot.Serve(context.Background(), oneshot.Options{
+			Listener: l,
+			Username: "user",
+			Password: "pass",
+			Events:   evs,
+		}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("hello"))
+		}))
+		done <- outcome{result, err}
+	}()
+
+	resp, err := http.Get(url)
+	suite.Require().NoError(err)
+	resp.Body.Close()
+	suite.Assert().Equal(http.StatusUnauthorized, resp.StatusCode)
+
+	req, err := http.NewRequest("GET", url, nil)
+	suite.Require().NoError(err)
+	req.SetBasicAuth("user", "pass")
+	resp, err = http.DefaultClient.Do(req)
+	suite.Require().NoError(err)
+	resp.Body.Close()
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+
+	o := <-done
+	suite.Require().NoError(o.err)
+	suite.Assert().True(o.result.Succeeded())
+	suite.Assert().Equal("user", o.result.Request.User)
+
+	var authFailures int
+	for e := range evs {
+		if _, ok := e.(*events.AuthFailure); ok {
+			authFailures++
+		}
+	}
+	suite.Assert().Equal(1, authFailures)
+}
+
+func (suite *ts) Test_Timeout() {
+	l, _ := suite.listener()
+
+	result, err := oneshot.Serve(context.Background(), oneshot.Options{
+		Listener: l,
+		Timeout:  100 * time.Millisecond,
+	}, http.NotFoundHandler())
+	suite.Require().NoError(err)
+	suite.Assert().False(result.Succeeded())
+	suite.Assert().Equal(events.ShutdownTimeout, result.Reason)
+}
+
+func (suite *ts) Test_Cancel() {
+	var (
+		l, _        = suite.listener()
+		ctx, cancel = context.WithCancel(context.Background())
+	)
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	result, err := oneshot.Send(ctx, oneshot.SendOptions{
+		Options: oneshot.Options{
+			Listener: l,
+		},
+	}, strings.NewReader("never sent"))
+	suite.Require().NoError(err)
+	suite.Assert().Equal(events.ShutdownInterrupted, result.Reason)
+}
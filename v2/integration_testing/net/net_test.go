@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+
+	itest "github.com/forestnode-io/oneshot/v2/integration_testing"
+	"github.com/pion/stun"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestBasicTestSuite(t *testing.T) {
+	suite.Run(t, new(ts))
+}
+
+type ts struct {
+	itest.TestSuite
+}
+
+// report holds the parts of the net doctor report the tests look at.
+type report struct {
+	Interfaces struct {
+		Addresses []string
+	}
+	NAT struct {
+		Type    string
+		Servers []struct {
+			Server        string
+			MappedAddress string
+			Error         string
+		}
+	}
+	ICE struct {
+		Configured bool
+		Candidates []struct {
+			Type string
+		}
+		Error string
+	}
+	Discovery struct {
+		Enabled   bool
+		Reachable bool
+		Error     string
+	}
+}
+
+func (suite *ts) Test_Doctor_JSON() {
+	var (
+		stun1 = suite.newSTUNServer()
+		stun2 = suite.newSTUNServer()
+	)
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"net", "doctor", "--output", "json", "--probe-timeout", "2s",
+		"--stun-server", "stun:" + stun1, "--stun-server", "stun:" + stun2,
+	}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+	oneshot.Wait()
+
+	suite.Require().Equal(0, oneshot.Cmd.ProcessState.ExitCode())
+	stdout := oneshot.Stdout.(*bytes.Buffer).Bytes()
+	var r report
+	suite.Require().NoError(json.Unmarshal(stdout, &r))
+
+	suite.Assert().Contains(r.Interfaces.Addresses, "127.0.0.1")
+
+	// the servers see the loopback address, so there is no NAT in the way
+	suite.Assert().Equal("none", r.NAT.Type)
+	suite.Require().Len(r.NAT.Servers, 2)
+	suite.Assert().Empty(r.NAT.Servers[0].Error)
+	suite.Assert().Equal(r.NAT.Servers[0].MappedAddress, r.NAT.Servers[1].MappedAddress)
+
+	suite.Assert().False(r.ICE.Configured)
+	suite.Assert().False(r.Discovery.Enabled)
+}
+
+func (suite *ts) Test_Doctor_WebRTCConfiguration() {
+	stunServer := suite.newSTUNServer()
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"net", "doctor", "--output", "json", "--probe-timeout", "2s",
+		"--p2p-webrtc-config-file", "./webrtc.yaml",
+	}
+	oneshot.Files = itest.FilesMap{
+		"./webrtc.yaml": []byte(fmt.Sprintf("iceServers:\n  - urls:\n      - stun:%s\n", stunServer)),
+	}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+	oneshot.Wait()
+
+	stdout := oneshot.Stdout.(*bytes.Buffer).Bytes()
+	var r report
+	suite.Require().NoError(json.Unmarshal(stdout, &r))
+
+	// the stun servers of the configuration are used to tell the NAT type
+	suite.Require().Len(r.NAT.Servers, 1)
+	suite.Assert().Equal("stun:"+stunServer, r.NAT.Servers[0].Server)
+
+	suite.Assert().True(r.ICE.Configured)
+	suite.Require().NotEmpty(r.ICE.Candidates)
+	suite.Assert().Equal("host", r.ICE.Candidates[0].Type)
+}
+
+func (suite *ts) Test_Doctor_DiscoveryUnreachable() {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.Require().NoError(err)
+	addr := l.Addr().String()
+	l.Close()
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"net", "doctor", "--probe-timeout", "1s", "--stun-server", "stun:" + suite.newSTUNServer(),
+		"--discovery-enabled", "--discovery-url", addr, "--discovery-key", "key", "--discovery-insecure",
+	}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+	oneshot.Wait()
+
+	suite.Require().Equal(0, oneshot.Cmd.ProcessState.ExitCode())
+	stdout := oneshot.Stdout.(*bytes.Buffer).String()
+	suite.Assert().Contains(stdout, "\nInterfaces\n")
+	suite.Assert().Contains(stdout, "\nNAT\n  type:         none\n")
+	suite.Assert().Contains(stdout, "\nDiscovery server\n  url:          "+addr+"\n  reachable:    no\n")
+}
+
+// newSTUNServer starts a STUN server answering binding requests on the loopback interface.
+func (suite *ts) newSTUNServer() string {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	suite.Require().NoError(err)
+	suite.T().Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+
+			req := stun.Message{Raw: append([]byte(nil), buf[:n]...)}
+			if err := req.Decode(); err != nil || req.Type != stun.BindingRequest {
+				continue
+			}
+			resp, err := stun.Build(&req, stun.BindingSuccess, &stun.XORMappedAddress{
+				IP:   addr.IP,
+				Port: addr.Port,
+			}, stun.Fingerprint)
+			if err != nil {
+				continue
+			}
+			_, _ = conn.WriteToUDP(resp.Raw, addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	itest "github.com/forestnode-io/oneshot/v2/integration_testing"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestBasicTestSuite(t *testing.T) {
+	suite.Run(t, new(ts))
+}
+
+type ts struct {
+	itest.TestSuite
+}
+
+type upload struct {
+	method string
+	header http.Header
+	body   []byte
+	file   []byte
+}
+
+func newServer(uploads chan<- upload) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u := upload{
+			method: r.Method,
+			header: r.Header.Clone(),
+		}
+		if mr, err := r.MultipartReader(); err == nil {
+			for {
+				part, err := mr.NextPart()
+				if err != nil {
+					break
+				}
+				if part.FileName() != "" {
+					u.file, _ = io.ReadAll(part)
+				}
+			}
+		} else {
+			u.body, _ = io.ReadAll(r.Body)
+		}
+		uploads <- u
+	}))
+}
+
+func (suite *ts) Test_FROM_File() {
+	uploads := make(chan upload, 1)
+	server := newServer(uploads)
+	defer server.Close()
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"put", "--method", "PUT", "--csrf-token", "token", server.URL, "./test.txt"}
+	oneshot.Files = itest.FilesMap{"./test.txt": []byte("SUCCESS")}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	oneshot.Wait()
+	suite.Assert().Equal(0, oneshot.Cmd.ProcessState.ExitCode())
+
+	u := <-uploads
+	suite.Assert().Equal(http.MethodPut, u.method)
+	suite.Assert().Equal("token", u.header.Get("X-CSRF-Token"))
+	suite.Assert().Contains(u.header.Get("Content-Disposition"), "test.txt")
+	suite.Assert().Equal("SUCCESS", string(u.body))
+}
+
+func (suite *ts) Test_FROM_Stdin_Multipart() {
+	uploads := make(chan upload, 1)
+	server := newServer(uploads)
+	defer server.Close()
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"put", "--multipart", "--name", "test.txt", server.URL}
+	oneshot.Stdin = itest.EOFReader([]byte("SUCCESS"))
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	oneshot.Wait()
+	suite.Assert().Equal(0, oneshot.Cmd.ProcessState.ExitCode())
+
+	u := <-uploads
+	suite.Assert().Equal(http.MethodPost, u.method)
+	suite.Assert().Contains(u.header.Get("Content-Type"), "multipart/form-data")
+	suite.Assert().Equal("SUCCESS", string(u.file))
+
+	stdout := oneshot.Stdout.(*bytes.Buffer).Bytes()
+	suite.Assert().Equal("", string(stdout))
+}
+
+func (suite *ts) Test_SeeOther_SwitchesToGET() {
+	uploads := make(chan upload, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		http.Redirect(w, r, "/done", http.StatusSeeOther)
+	})
+	mux.HandleFunc("/done", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		uploads <- upload{
+			method: r.Method,
+			header: r.Header.Clone(),
+			body:   body,
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"put", server.URL, "./test.txt"}
+	oneshot.Files = itest.FilesMap{"./test.txt": []byte("SUCCESS")}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	oneshot.Wait()
+	suite.Assert().Equal(0, oneshot.Cmd.ProcessState.ExitCode())
+
+	u := <-uploads
+	suite.Assert().Equal(http.MethodGet, u.method)
+	suite.Assert().Empty(u.body)
+	suite.Assert().Empty(u.header.Get("Content-Type"))
+}
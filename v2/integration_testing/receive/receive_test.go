@@ -1,11 +1,15 @@
 package main
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -282,6 +286,252 @@ func (suite *ts) Test_FROM_ANY_TO_Stdout__JSON() {
 	suite.Assert().Regexp(`listening on http://.*\n`, string(stderr))
 }
 
+func (suite *ts) Test_Extract_TarGz__JSON() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"receive", "--extract", "./out", "--output", "json"}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	archive := tarball(suite, true, map[string]string{
+		"testDir/test.txt":  "SUCCESS",
+		"testDir/test2.txt": "SUCCESS2",
+	})
+
+	client := itest.RetryClient{
+		Suite: &suite.Suite,
+	}
+	resp, err := client.Post("http://127.0.0.1:8080", "application/gzip", bytes.NewReader(archive))
+	suite.Require().NoError(err)
+	suite.Require().NotNil(resp)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	oneshot.Wait()
+
+	fileContents, err := os.ReadFile(filepath.Join(oneshot.WorkingDir, "out", "testDir", "test.txt"))
+	suite.Require().NoError(err)
+	suite.Assert().Equal("SUCCESS", string(fileContents))
+	fileContents, err = os.ReadFile(filepath.Join(oneshot.WorkingDir, "out", "testDir", "test2.txt"))
+	suite.Require().NoError(err)
+	suite.Assert().Equal("SUCCESS2", string(fileContents))
+
+	stdout := oneshot.Stdout.(*bytes.Buffer).Bytes()
+	var report output.Report
+	err = json.Unmarshal(stdout, &report)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(report.Success)
+	suite.Require().NotNil(report.Success.File)
+	suite.Assert().Equal(int64(len(archive)), report.Success.File.TransferSize)
+	suite.Require().Len(report.Success.File.Extracted, 2)
+	for _, f := range report.Success.File.Extracted {
+		suite.Assert().Contains([]string{"testDir/test.txt", "testDir/test2.txt"}, f.Name)
+		suite.Assert().NotEmpty(f.Path)
+	}
+}
+
+func (suite *ts) Test_Extract_PathTraversal() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"receive", "--extract", "./out"}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	archive := tarball(suite, false, map[string]string{
+		"../escaped.txt": "FAIL",
+	})
+
+	client := itest.RetryClient{
+		Suite: &suite.Suite,
+	}
+	resp, err := client.Post("http://127.0.0.1:8080", "application/x-tar", bytes.NewReader(archive))
+	suite.Require().NoError(err)
+	suite.Require().NotNil(resp)
+	suite.Assert().Equal(http.StatusBadRequest, resp.StatusCode)
+	resp.Body.Close()
+
+	oneshot.Signal(os.Interrupt)
+	oneshot.Wait()
+
+	_, err = os.Stat(filepath.Join(oneshot.WorkingDir, "escaped.txt"))
+	suite.Assert().ErrorIs(err, os.ErrNotExist)
+}
+
+func (suite *ts) Test_Extract_MaxEntries() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"receive", "--extract", "./out", "--extract-max-entries", "1"}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	archive := tarball(suite, true, map[string]string{
+		"test.txt":  "SUCCESS",
+		"test2.txt": "SUCCESS2",
+	})
+
+	client := itest.RetryClient{
+		Suite: &suite.Suite,
+	}
+	resp, err := client.Post("http://127.0.0.1:8080", "application/gzip", bytes.NewReader(archive))
+	suite.Require().NoError(err)
+	suite.Require().NotNil(resp)
+	suite.Assert().Equal(http.StatusBadRequest, resp.StatusCode)
+	resp.Body.Close()
+
+	oneshot.Signal(os.Interrupt)
+	oneshot.Wait()
+}
+
+func (suite *ts) Test_Extract_MaxSize_TrailingData() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"receive", "--extract", "./out", "--extract-max-size", "64KB"}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	archive := tarball(suite, false, map[string]string{
+		"test.txt": "SUCCESS",
+	})
+	// what comes after the end of the archive counts against the limit too
+	archive = append(archive, make([]byte, 1<<20)...)
+
+	client := itest.RetryClient{
+		Suite: &suite.Suite,
+	}
+	resp, err := client.Post("http://127.0.0.1:8080", "application/x-tar", bytes.NewReader(archive))
+	suite.Require().NoError(err)
+	suite.Require().NotNil(resp)
+	suite.Assert().Equal(http.StatusBadRequest, resp.StatusCode)
+	resp.Body.Close()
+
+	oneshot.Signal(os.Interrupt)
+	oneshot.Wait()
+}
+
+func (suite *ts) Test_Hooks() {
+	var (
+		mu       sync.Mutex
+		webhooks []string
+	)
+	webhookServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event struct {
+			Type string
+		}
+		suite.Assert().NoError(json.NewDecoder(r.Body).Decode(&event))
+		mu.Lock()
+		webhooks = append(webhooks, event.Type)
+		mu.Unlock()
+	}))
+	defer webhookServer.Close()
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"receive", "./test.txt",
+		"--on-request", "echo $ONESHOT_EVENT $ONESHOT_REQUEST_METHOD > request.txt",
+		"--on-success", "echo $ONESHOT_EVENT $ONESHOT_FILE_PATH $ONESHOT_FILE_SIZE > success.txt; cat > success.json",
+		"--webhook", webhookServer.URL,
+	}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	client := itest.RetryClient{
+		Suite: &suite.Suite,
+	}
+	resp, err := client.Post("http://127.0.0.1:8080", "text/plain", bytes.NewReader([]byte("SUCCESS")))
+	suite.Require().NoError(err)
+	suite.Require().NotNil(resp)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	// oneshot waits for its hooks before exiting
+	oneshot.Wait()
+
+	request, err := os.ReadFile(filepath.Join(oneshot.WorkingDir, "request.txt"))
+	suite.Require().NoError(err)
+	suite.Assert().Equal("request POST\n", string(request))
+
+	path := filepath.Join(oneshot.WorkingDir, "test.txt")
+	success, err := os.ReadFile(filepath.Join(oneshot.WorkingDir, "success.txt"))
+	suite.Require().NoError(err)
+	suite.Assert().Equal(fmt.Sprintf("success %s 7\n", path), string(success))
+
+	successJSON, err := os.ReadFile(filepath.Join(oneshot.WorkingDir, "success.json"))
+	suite.Require().NoError(err)
+	var event struct {
+		Type string
+		File struct {
+			Path         string
+			TransferSize int64
+		}
+	}
+	suite.Require().NoError(json.Unmarshal(successJSON, &event))
+	suite.Assert().Equal("success", event.Type)
+	suite.Assert().Equal(path, event.File.Path)
+	suite.Assert().Equal(int64(7), event.File.TransferSize)
+
+	mu.Lock()
+	defer mu.Unlock()
+	suite.Assert().ElementsMatch([]string{"request", "success"}, webhooks)
+}
+
+func (suite *ts) Test_Hooks_Failure() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"receive", "--extract", "./out",
+		"--on-request", "exit 3",
+		"--on-failure", "echo $ONESHOT_EVENT $ONESHOT_ERROR > failure.txt",
+	}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	archive := tarball(suite, false, map[string]string{
+		"../escaped.txt": "FAIL",
+	})
+
+	client := itest.RetryClient{
+		Suite: &suite.Suite,
+	}
+	resp, err := client.Post("http://127.0.0.1:8080", "application/x-tar", bytes.NewReader(archive))
+	suite.Require().NoError(err)
+	suite.Require().NotNil(resp)
+	suite.Assert().Equal(http.StatusBadRequest, resp.StatusCode)
+	resp.Body.Close()
+
+	oneshot.Signal(os.Interrupt)
+	oneshot.Wait()
+
+	failure, err := os.ReadFile(filepath.Join(oneshot.WorkingDir, "failure.txt"))
+	suite.Require().NoError(err)
+	suite.Assert().Regexp(`^failure .+\n$`, string(failure))
+
+	// failing hooks are reported without stopping oneshot
+	stderr := oneshot.Stderr.(*bytes.Buffer).Bytes()
+	suite.Assert().Contains(string(stderr), `request hook "exit 3" failed: exit status 3`)
+}
+
+func tarball(suite *ts, compress bool, files map[string]string) []byte {
+	buf := bytes.NewBuffer(nil)
+	var w io.Writer = buf
+	var gw *gzip.Writer
+	if compress {
+		gw = gzip.NewWriter(buf)
+		w = gw
+	}
+
+	tw := tar.NewWriter(w)
+	for name, content := range files {
+		err := tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0600,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		})
+		suite.Require().NoError(err)
+		_, err = tw.Write([]byte(content))
+		suite.Require().NoError(err)
+	}
+	suite.Require().NoError(tw.Close())
+	if gw != nil {
+		suite.Require().NoError(gw.Close())
+	}
+
+	return buf.Bytes()
+}
+
 func (suite *ts) Test_MultipleClients() {
 	var oneshot = suite.NewOneshot()
 	oneshot.Args = []string{"receive", "./test.txt"}
@@ -161,3 +161,103 @@ func (suite *ts) Test_MultipleClients() {
 	stderr := oneshot.Stderr.(*bytes.Buffer).Bytes()
 	suite.Assert().Regexp(`listening on http://.*\n`, string(stderr))
 }
+
+func (suite *ts) Test_TemplatedTarget() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"redirect", `http://localhost:9000{{ .Path }}?code={{ .Query.Get "code" | urlquery }}&agent={{ .Header.Get "X-Agent" }}`}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	req, err := http.NewRequest(http.MethodGet, "http://127.0.0.1:8080/callback?code=a%20b&state=s", nil)
+	suite.Require().NoError(err)
+	req.Header.Set("X-Agent", "cli")
+
+	client := itest.RetryClient{}
+	resp, err := client.Do(req)
+	suite.Require().NoError(err)
+	resp.Body.Close()
+	suite.Assert().Equal(http.StatusTemporaryRedirect, resp.StatusCode)
+	suite.Assert().Equal("http://localhost:9000/callback?code=a+b&agent=cli", resp.Header.Get("Location"))
+
+	oneshot.Wait()
+}
+
+func (suite *ts) Test_OAuthCallback() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"redirect", "--oauth-callback", "--oauth-state", "xyz", "--output", "json"}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	client := itest.RetryClient{}
+
+	// requests other than the callback are ignored
+	resp, err := client.Get("http://127.0.0.1:8080/favicon.ico")
+	suite.Require().NoError(err)
+	resp.Body.Close()
+	suite.Assert().Equal(http.StatusNotFound, resp.StatusCode)
+
+	resp, err = client.Get("http://127.0.0.1:8080/callback?code=abc&state=forged")
+	suite.Require().NoError(err)
+	resp.Body.Close()
+	suite.Assert().Equal(http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = client.Get("http://127.0.0.1:8080/callback?code=abc&state=xyz")
+	suite.Require().NoError(err)
+	body, err := io.ReadAll(resp.Body)
+	suite.Require().NoError(err)
+	resp.Body.Close()
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	suite.Assert().Contains(string(body), "You can close this tab")
+
+	oneshot.Wait()
+	stdout := oneshot.Stdout.(*bytes.Buffer).Bytes()
+	var report output.Report
+	suite.Require().NoError(json.Unmarshal(stdout, &report))
+	suite.Require().NotNil(report.Success)
+	suite.Require().NotNil(report.Success.OAuthCallback)
+	suite.Assert().Equal("abc", report.Success.OAuthCallback.Code)
+	suite.Assert().Equal("xyz", report.Success.OAuthCallback.State)
+	suite.Require().Len(report.Attempts, 1)
+	suite.Assert().Equal("oauth callback state does not match", report.Attempts[0].Error)
+}
+
+func (suite *ts) Test_OAuthCallback_Human() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"redirect", "--oauth-callback"}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	client := itest.RetryClient{}
+	resp, err := client.Get("http://127.0.0.1:8080/?error=access_denied&error_description=nope")
+	suite.Require().NoError(err)
+	resp.Body.Close()
+	suite.Assert().Equal(http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = client.Get("http://127.0.0.1:8080/?code=abc&state=xyz")
+	suite.Require().NoError(err)
+	resp.Body.Close()
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+
+	oneshot.Wait()
+	stdout := oneshot.Stdout.(*bytes.Buffer).Bytes()
+	suite.Assert().Equal("code=abc\nstate=xyz\n", string(stdout))
+}
+
+func (suite *ts) Test_QRHandoff() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"redirect", "--qr-handoff", "https://example.com/device?code={{ .Query.Get \"code\" }}"}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	client := itest.RetryClient{}
+	resp, err := client.Get("http://127.0.0.1:8080/?code=123")
+	suite.Require().NoError(err)
+	body, err := io.ReadAll(resp.Body)
+	suite.Require().NoError(err)
+	resp.Body.Close()
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	suite.Assert().Contains(string(body), `src="data:image/png;base64,`)
+	suite.Assert().Contains(string(body), `href="https://example.com/device?code=123"`)
+
+	oneshot.Wait()
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	itest "github.com/forestnode-io/oneshot/v2/integration_testing"
+	"github.com/forestnode-io/oneshot/v2/pkg/output"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestBasicTestSuite(t *testing.T) {
+	suite.Run(t, new(ts))
+}
+
+type ts struct {
+	itest.TestSuite
+}
+
+const spec = `
+rules:
+  - name: get user
+    match:
+      method: GET
+      path: ^/users/(?P<id>\d+)$
+      headers:
+        Accept: json
+    response:
+      status: 200
+      headers:
+        Content-Type: application/json
+        X-Rule: '{{ .Rule }}'
+      body: '{"id": {{ .Params.id }}, "verbose": {{ json (.Query.Get "verbose") }}}'
+  - name: create user
+    match:
+      method: POST
+      path: ^/users$
+      json:
+        $.user.name: ^[a-z]+$
+        $.user.tags[0]: admin
+    response:
+      status: '{{ if eq (jsonPath "$.user.name" .JSON) "root" }}409{{ else }}201{{ end }}'
+      body: 'created {{ jsonPath "$.user.name" .JSON | upper }}'
+    times: 1
+  - name: echo
+    match:
+      body: ^ping$
+    response:
+      body: pong
+`
+
+func (suite *ts) Test_Rules() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"respond", "--spec", "spec.yaml", "--matches", "3", "--output", "json"}
+	oneshot.Files = itest.FilesMap{"spec.yaml": []byte(spec)}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	client := itest.RetryClient{}
+
+	// unmatched requests are answered but don't count towards --matches
+	resp, err := client.Get("http://127.0.0.1:8080/nothing")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusNotFound, resp.StatusCode)
+	resp.Body.Close()
+
+	req, err := http.NewRequest(http.MethodGet, "http://127.0.0.1:8080/users/42?verbose=yes", nil)
+	suite.Require().NoError(err)
+	req.Header.Set("Accept", "application/json")
+	resp, err = client.Do(req)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	suite.Assert().Equal("application/json", resp.Header.Get("Content-Type"))
+	suite.Assert().Equal("get user", resp.Header.Get("X-Rule"))
+	body, err := io.ReadAll(resp.Body)
+	suite.Require().NoError(err)
+	resp.Body.Close()
+	suite.Assert().JSONEq(`{"id": 42, "verbose": "yes"}`, string(body))
+
+	resp, err = client.Post("http://127.0.0.1:8080/users", "application/json",
+		strings.NewReader(`{"user": {"name": "alice", "tags": ["admin"]}}`))
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusCreated, resp.StatusCode)
+	body, err = io.ReadAll(resp.Body)
+	suite.Require().NoError(err)
+	resp.Body.Close()
+	suite.Assert().Equal("created ALICE", string(body))
+
+	// the create user rule only answers once
+	resp, err = client.Post("http://127.0.0.1:8080/users", "application/json",
+		strings.NewReader(`{"user": {"name": "bob", "tags": ["admin"]}}`))
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusNotFound, resp.StatusCode)
+	resp.Body.Close()
+
+	resp, err = client.Post("http://127.0.0.1:8080/anything", "text/plain", strings.NewReader("ping"))
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	body, err = io.ReadAll(resp.Body)
+	suite.Require().NoError(err)
+	resp.Body.Close()
+	suite.Assert().Equal("pong", string(body))
+
+	oneshot.Wait()
+	stdout := oneshot.Stdout.(*bytes.Buffer).Bytes()
+	var report output.Report
+	suite.Require().NoError(json.Unmarshal(stdout, &report))
+
+	suite.Require().NotNil(report.Success)
+	suite.Require().NotNil(report.Success.Request)
+	suite.Assert().Equal("/anything", report.Success.Request.Path)
+	suite.Require().NotNil(report.Success.Response)
+	suite.Assert().Equal(http.StatusOK, report.Success.Response.StatusCode)
+	suite.Assert().Equal("pong", decodeBody(report.Success.Response.Body))
+
+	suite.Require().Len(report.Attempts, 4)
+	var (
+		paths    []string
+		statuses []int
+		errs     []string
+	)
+	for _, attempt := range report.Attempts {
+		suite.Require().NotNil(attempt.Request)
+		suite.Require().NotNil(attempt.Response)
+		paths = append(paths, attempt.Request.Path)
+		statuses = append(statuses, attempt.Response.StatusCode)
+		errs = append(errs, attempt.Error)
+	}
+	suite.Assert().Equal([]string{"/nothing", "/users/42", "/users", "/users"}, paths)
+	suite.Assert().Equal([]int{http.StatusNotFound, http.StatusOK, http.StatusCreated, http.StatusNotFound}, statuses)
+	suite.Assert().Equal([]string{"request matched no rule", "", "", "request matched no rule"}, errs)
+	suite.Assert().Equal(`{"user": {"name": "alice", "tags": ["admin"]}}`, decodeBody(report.Attempts[2].Request.Body))
+}
+
+func (suite *ts) Test_Default() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"respond", "--spec", "spec.yaml", "--unmatched-status-code", "418"}
+	oneshot.Files = itest.FilesMap{"spec.yaml": []byte(`
+rules:
+  - match:
+      query:
+        done: ^true$
+    response:
+      bodyFile: body.txt
+default:
+  status: 400
+  body: no rule for {{ .Method }} {{ .Path }}
+`),
+		"body.txt": []byte("done {{ .Query.Get \"done\" }}"),
+	}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	client := itest.RetryClient{}
+
+	resp, err := client.Get("http://127.0.0.1:8080/a")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusBadRequest, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	suite.Require().NoError(err)
+	resp.Body.Close()
+	suite.Assert().Equal("no rule for GET /a", string(body))
+
+	resp, err = client.Get("http://127.0.0.1:8080/b?done=true")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	body, err = io.ReadAll(resp.Body)
+	suite.Require().NoError(err)
+	resp.Body.Close()
+	suite.Assert().Equal("done true", string(body))
+
+	oneshot.Wait()
+	stderr := oneshot.Stderr.(*bytes.Buffer).Bytes()
+	suite.Assert().Regexp(`listening on http://.*\n`, string(stderr))
+}
+
+func (suite *ts) Test_RenderError() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"respond", "--spec", "spec.yaml", "--matches", "1"}
+	oneshot.Files = itest.FilesMap{"spec.yaml": []byte(`
+rules:
+  - name: status from query
+    match:
+      path: ^/status$
+    response:
+      status: '{{ .Query.Get "code" }}'
+    times: 1
+`)}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	client := itest.RetryClient{}
+
+	// a response that fails to render doesn't use up the rule or count towards --matches
+	resp, err := client.Get("http://127.0.0.1:8080/status")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusInternalServerError, resp.StatusCode)
+	resp.Body.Close()
+
+	resp, err = client.Get("http://127.0.0.1:8080/status?code=202")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusAccepted, resp.StatusCode)
+	resp.Body.Close()
+
+	oneshot.Wait()
+	suite.Assert().Equal(0, oneshot.Cmd.ProcessState.ExitCode())
+}
+
+func (suite *ts) Test_InvalidSpec() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"respond", "--spec", "spec.yaml"}
+	oneshot.Files = itest.FilesMap{"spec.yaml": []byte(`
+rules:
+  - match:
+      path: ^/(unclosed$
+`)}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	oneshot.Wait()
+	suite.Assert().NotEqual(0, oneshot.Cmd.ProcessState.ExitCode())
+	stderr := oneshot.Stderr.(*bytes.Buffer).Bytes()
+	suite.Assert().Contains(string(stderr), "invalid match for rule 0")
+}
+
+// decodeBody returns a body from the json report, where bytes are base64 encoded.
+func decodeBody(body any) string {
+	s, _ := body.(string)
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return s
+	}
+	return string(b)
+}
@@ -1,19 +1,28 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"crypto/sha1"
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	itest "github.com/forestnode-io/oneshot/v2/integration_testing"
+	"github.com/forestnode-io/oneshot/v2/pkg/events"
 	"github.com/forestnode-io/oneshot/v2/pkg/output"
 	"github.com/stretchr/testify/suite"
 )
@@ -328,3 +337,396 @@ func (suite *ts) Test_MultipleClients() {
 	stderr := oneshot.Stderr.(*bytes.Buffer).Bytes()
 	suite.Assert().Regexp(`listening on http://.*\n`, string(stderr))
 }
+
+func (suite *ts) Test_PathPrefixRouting() {
+	var paths = make(chan string, 2)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths <- r.URL.Path
+		w.Write([]byte("API"))
+	}))
+	defer api.Close()
+	frontend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths <- r.URL.Path
+		w.Write([]byte("FRONTEND"))
+	}))
+	defer frontend.Close()
+
+	for _, tc := range []struct {
+		path, body string
+	}{
+		{"/api/users", "API"},
+		{"/apis", "FRONTEND"},
+	} {
+		var oneshot = suite.NewOneshot()
+		oneshot.Args = []string{"rproxy", "/api=" + api.URL + ",/=" + frontend.URL}
+		oneshot.Start()
+
+		client := itest.RetryClient{
+			Suite: &suite.Suite,
+		}
+		resp, err := client.Get("http://127.0.0.1:8080" + tc.path)
+		suite.Require().NoError(err)
+		body, err := io.ReadAll(resp.Body)
+		suite.Require().NoError(err)
+		resp.Body.Close()
+		suite.Assert().Equal(tc.body, string(body))
+		suite.Assert().Equal(tc.path, <-paths)
+
+		oneshot.Wait()
+		oneshot.Cleanup()
+	}
+}
+
+func (suite *ts) Test_ServerSentEvents() {
+	var next = make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: first\n\n"))
+		w.(http.Flusher).Flush()
+		// only send the second event once the first has made it through the proxy
+		<-next
+		w.Write([]byte("data: second\n\n"))
+	}))
+	defer upstream.Close()
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"--output", "json", "rproxy", upstream.URL}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	client := itest.RetryClient{
+		Suite: &suite.Suite,
+	}
+	resp, err := client.Get("http://127.0.0.1:8080")
+	suite.Require().NoError(err)
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	suite.Require().NoError(err)
+	suite.Assert().Equal("data: first\n", line)
+	close(next)
+
+	_, err = io.ReadAll(resp.Body)
+	suite.Require().NoError(err)
+	resp.Body.Close()
+
+	oneshot.Wait()
+
+	var report output.Report
+	err = json.Unmarshal(oneshot.Stdout.(*bytes.Buffer).Bytes(), &report)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(report.Success)
+	bodyBytes, err := base64.StdEncoding.DecodeString(report.Success.Response.Body.(string))
+	suite.Require().NoError(err)
+	suite.Assert().Equal("data: first\n\ndata: second\n\n", string(bodyBytes))
+}
+
+func (suite *ts) Test_LargeResponse_CaptureTruncated() {
+	const size = 33 << 20
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(bytes.Repeat([]byte("a"), size))
+	}))
+	defer upstream.Close()
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"--output", "json", "rproxy", upstream.URL}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	client := itest.RetryClient{
+		Suite: &suite.Suite,
+	}
+	resp, err := client.Get("http://127.0.0.1:8080")
+	suite.Require().NoError(err)
+	// the client still gets all of it
+	n, err := io.Copy(io.Discard, resp.Body)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(int64(size), n)
+	resp.Body.Close()
+
+	oneshot.Wait()
+
+	var report output.Report
+	err = json.Unmarshal(oneshot.Stdout.(*bytes.Buffer).Bytes(), &report)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(report.Success)
+	suite.Assert().True(report.Success.Response.BodyTruncated)
+	bodyBytes, err := base64.StdEncoding.DecodeString(report.Success.Response.Body.(string))
+	suite.Require().NoError(err)
+	suite.Assert().Equal(32<<20, len(bodyBytes))
+}
+
+func (suite *ts) Test_WebSocket() {
+	// upstream echoes back the first message it gets in upper case
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		suite.Assert().Empty(r.Header.Get("Sec-WebSocket-Extensions"))
+
+		conn, brw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		fmt.Fprintf(brw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+			webSocketAccept(r.Header.Get("Sec-WebSocket-Key")))
+		brw.Flush()
+
+		msg, err := readWebSocketFrame(brw.Reader)
+		if err != nil {
+			return
+		}
+		conn.Write(webSocketFrame(strings.ToUpper(string(msg)), nil))
+	}))
+	defer upstream.Close()
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"--output", "json", "rproxy", strings.Replace(upstream.URL, "http", "ws", 1)}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	var conn net.Conn
+	var err error
+	for i := 0; i < 50; i++ {
+		if conn, err = net.Dial("tcp", "127.0.0.1:8080"); err == nil {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	suite.Require().NoError(err)
+	defer conn.Close()
+
+	const key = "dGhlIHNhbXBsZSBub25jZQ=="
+	fmt.Fprintf(conn, "GET /chat HTTP/1.1\r\nHost: 127.0.0.1:8080\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\nSec-WebSocket-Extensions: permessage-deflate\r\n\r\n", key)
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	suite.Require().NoError(err)
+	suite.Require().Equal(http.StatusSwitchingProtocols, resp.StatusCode)
+	suite.Assert().Equal(webSocketAccept(key), resp.Header.Get("Sec-WebSocket-Accept"))
+
+	_, err = conn.Write(webSocketFrame("hello", []byte{1, 2, 3, 4}))
+	suite.Require().NoError(err)
+	msg, err := readWebSocketFrame(br)
+	suite.Require().NoError(err)
+	suite.Assert().Equal("HELLO", string(msg))
+	conn.Close()
+
+	oneshot.Wait()
+
+	var report output.Report
+	err = json.Unmarshal(oneshot.Stdout.(*bytes.Buffer).Bytes(), &report)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(report.Success)
+	suite.Assert().Equal(http.StatusSwitchingProtocols, report.Success.Response.StatusCode)
+
+	messages := report.Success.WebSocketMessages
+	suite.Require().Len(messages, 2)
+	suite.Assert().Equal(events.WebSocketFromClient, messages[0].From)
+	suite.Assert().Equal(events.WebSocketText, messages[0].Type)
+	suite.Assert().Equal("hello", messages[0].Data)
+	suite.Assert().Equal(events.WebSocketFromUpstream, messages[1].From)
+	suite.Assert().Equal("HELLO", messages[1].Data)
+}
+
+func webSocketAccept(key string) string {
+	sum := sha1.Sum([]byte(key + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// webSocketFrame returns a single text frame holding msg, masked if mask is not nil.
+func webSocketFrame(msg string, mask []byte) []byte {
+	frame := []byte{0x81, byte(len(msg))}
+	payload := []byte(msg)
+	if mask != nil {
+		frame[1] |= 0x80
+		frame = append(frame, mask...)
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return append(frame, payload...)
+}
+
+// readWebSocketFrame reads a single short frame and returns its unmasked payload.
+func readWebSocketFrame(r *bufio.Reader) ([]byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	var mask []byte
+	if header[1]&0x80 != 0 {
+		mask = make([]byte, 4)
+		if _, err := io.ReadFull(r, mask); err != nil {
+			return nil, err
+		}
+	}
+	payload := make([]byte, header[1]&0x7f)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	for i := range payload {
+		if mask != nil {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return payload, nil
+}
+
+func (suite *ts) Test_HAR_RecordAndReplay() {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Upstream", "yes")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"rproxy", "--har", "./out.har", "--har-max-body-size", "16B", upstream.URL}
+	oneshot.Start()
+
+	client := itest.RetryClient{
+		Suite: &suite.Suite,
+	}
+	const payload = "a payload bigger than the cap"
+	resp, err := client.Post("http://127.0.0.1:8080/hook?a=1", "text/plain", strings.NewReader(payload))
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusCreated, resp.StatusCode)
+	resp.Body.Close()
+
+	oneshot.Wait()
+	oneshot.Cleanup()
+	upstream.Close()
+
+	harPath := filepath.Join(oneshot.WorkingDir, "out.har")
+	data, err := os.ReadFile(harPath)
+	suite.Require().NoError(err)
+
+	var har struct {
+		Log struct {
+			Version string
+			Creator struct{ Name string }
+			Entries []struct {
+				Request struct {
+					Method      string
+					URL         string
+					QueryString []struct{ Name, Value string }
+					PostData    struct{ Text, Comment string }
+					BodySize    int64
+				}
+				Response struct {
+					Status  int
+					Headers []struct{ Name, Value string }
+					Content struct {
+						Size     int64
+						MimeType string
+						Text     string
+					}
+				}
+				Timings struct{ Send, Wait, Receive float64 }
+			}
+		}
+	}
+	err = json.Unmarshal(data, &har)
+	suite.Require().NoError(err)
+	suite.Assert().Equal("1.2", har.Log.Version)
+	suite.Assert().Equal("oneshot", har.Log.Creator.Name)
+	suite.Require().Len(har.Log.Entries, 1)
+
+	entry := har.Log.Entries[0]
+	suite.Assert().Equal("POST", entry.Request.Method)
+	suite.Assert().Equal("http://127.0.0.1:8080/hook?a=1", entry.Request.URL)
+	suite.Assert().Equal([]struct{ Name, Value string }{{"a", "1"}}, entry.Request.QueryString)
+	suite.Assert().Equal(payload[:16], entry.Request.PostData.Text)
+	suite.Assert().Equal(fmt.Sprintf("truncated to 16 of %d bytes", len(payload)), entry.Request.PostData.Comment)
+	suite.Assert().Equal(int64(len(payload)), entry.Request.BodySize)
+	suite.Assert().Equal(http.StatusCreated, entry.Response.Status)
+	suite.Assert().Contains(entry.Response.Headers, struct{ Name, Value string }{"X-Upstream", "yes"})
+	suite.Assert().Equal("application/json", entry.Response.Content.MimeType)
+	suite.Assert().Equal(`{"ok":true}`, entry.Response.Content.Text)
+	suite.Assert().Equal(int64(len(`{"ok":true}`)), entry.Response.Content.Size)
+	suite.Assert().LessOrEqual(0.0, entry.Timings.Wait)
+
+	oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"rproxy", "--output", "json", "--replay", harPath}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	// nothing was recorded for this one, so the stub stays up
+	resp, err = client.Get("http://127.0.0.1:8080/other")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusNotFound, resp.StatusCode)
+	resp.Body.Close()
+
+	resp, err = client.Post("http://127.0.0.1:8080/hook?a=1", "text/plain", strings.NewReader(payload))
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusCreated, resp.StatusCode)
+	suite.Assert().Equal("yes", resp.Header.Get("X-Upstream"))
+	body, err := io.ReadAll(resp.Body)
+	suite.Require().NoError(err)
+	resp.Body.Close()
+	suite.Assert().Equal(`{"ok":true}`, string(body))
+
+	oneshot.Wait()
+
+	var report output.Report
+	err = json.Unmarshal(oneshot.Stdout.(*bytes.Buffer).Bytes(), &report)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(report.Success)
+	suite.Assert().Equal("/hook", report.Success.Request.Path)
+	suite.Assert().Equal(http.StatusCreated, report.Success.Response.StatusCode)
+	suite.Require().Len(report.Attempts, 1)
+	suite.Assert().Equal("/other", report.Attempts[0].Request.Path)
+}
+
+func (suite *ts) Test_HAR_AppendsAndRedactsCookies() {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Set-Cookie", "echo=hunter2")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	var (
+		harPath = filepath.Join(suite.NewOneshot().WorkingDir, "out.har")
+		client  = itest.RetryClient{
+			Suite: &suite.Suite,
+		}
+	)
+	for i := 0; i < 2; i++ {
+		var oneshot = suite.NewOneshot()
+		oneshot.Args = []string{"rproxy", "--har", harPath, "--password", "hunter2", upstream.URL}
+		oneshot.Start()
+
+		req, err := http.NewRequest("GET", "http://127.0.0.1:8080/", nil)
+		suite.Require().NoError(err)
+		req.SetBasicAuth("alice", "hunter2")
+		req.AddCookie(&http.Cookie{Name: "session", Value: "hunter2"})
+		resp, err := client.Do(req)
+		suite.Require().NoError(err)
+		suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+		resp.Body.Close()
+
+		oneshot.Wait()
+		oneshot.Cleanup()
+	}
+
+	data, err := os.ReadFile(harPath)
+	suite.Require().NoError(err)
+	suite.Assert().NotContains(string(data), "hunter2")
+
+	type nameValue struct{ Name, Value string }
+	var har struct {
+		Log struct {
+			Entries []struct {
+				Request  struct{ Cookies []nameValue }
+				Response struct{ Cookies []nameValue }
+			}
+		}
+	}
+	err = json.Unmarshal(data, &har)
+	suite.Require().NoError(err)
+	// the second run appends to what the first one recorded
+	suite.Require().Len(har.Log.Entries, 2)
+	for _, e := range har.Log.Entries {
+		suite.Assert().Equal([]nameValue{{"session", "<redacted>"}}, e.Request.Cookies)
+		suite.Assert().Equal([]nameValue{{"echo", "<redacted>"}}, e.Response.Cookies)
+	}
+}
@@ -1,14 +1,34 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"io"
+	"math/big"
+	"net"
 	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
 	"syscall"
 	"testing"
 	"time"
 
 	itest "github.com/forestnode-io/oneshot/v2/integration_testing"
+	"github.com/forestnode-io/oneshot/v2/pkg/output"
+	"github.com/golang-jwt/jwt"
 	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestBasicTestSuite(t *testing.T) {
@@ -78,3 +98,1189 @@ func (suite *ts) Test_Basic_Auth() {
 
 	oneshot.Wait()
 }
+
+func (suite *ts) Test_Basic_Auth_SecretReference() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send", "--username", "oneshot", "--password", "env:ONESHOT_TEST_PASSWORD", "--output", "json", "./test.txt"}
+	oneshot.Files = itest.FilesMap{
+		"./test.txt": []byte("SUCCESS"),
+	}
+	oneshot.Env = []string{
+		"ONESHOT_TEST_PASSWORD=hunter2",
+	}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	client := itest.RetryClient{}
+	req, err := http.NewRequest("GET", "http://127.0.0.1:8080", nil)
+	suite.Require().NoError(err)
+	req.SetBasicAuth("oneshot", "hunter2")
+	resp, err := client.Do(req)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(resp.StatusCode, http.StatusOK)
+	resp.Body.Close()
+
+	oneshot.Wait()
+
+	var report output.Report
+	err = json.Unmarshal(oneshot.Stdout.(*bytes.Buffer).Bytes(), &report)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(report.Success)
+	suite.Assert().Equal([]string{"Basic <redacted>"}, report.Success.Request.Header["Authorization"])
+}
+
+func (suite *ts) Test_Basic_Auth_KeyringReference() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send", "--username", "oneshot", "--password-file", "keyring:oneshot/alice", "./test.txt"}
+	oneshot.Files = itest.FilesMap{
+		"./test.txt":     []byte("SUCCESS"),
+		"./keyring.yaml": []byte("oneshot/alice: hunter2\n"),
+	}
+	oneshot.Env = []string{
+		"ONESHOT_KEYRING_FILE=./keyring.yaml",
+	}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	client := itest.RetryClient{}
+	req, err := http.NewRequest("GET", "http://127.0.0.1:8080", nil)
+	suite.Require().NoError(err)
+	req.SetBasicAuth("oneshot", "hunter2")
+	resp, err := client.Do(req)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(resp.StatusCode, http.StatusOK)
+
+	body, err := io.ReadAll(resp.Body)
+	suite.Assert().NoError(err)
+	resp.Body.Close()
+	suite.Assert().Equal(string(body), "SUCCESS")
+
+	oneshot.Wait()
+}
+
+func (suite *ts) Test_Basic_Auth_LiteralReference() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send", "--username", "oneshot", "--password", "literal:cmd:touch ./pwned", "./test.txt"}
+	oneshot.Files = itest.FilesMap{
+		"./test.txt": []byte("SUCCESS"),
+	}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	client := itest.RetryClient{}
+	req, err := http.NewRequest("GET", "http://127.0.0.1:8080", nil)
+	suite.Require().NoError(err)
+	req.SetBasicAuth("oneshot", "cmd:touch ./pwned")
+	resp, err := client.Do(req)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(resp.StatusCode, http.StatusOK)
+	resp.Body.Close()
+
+	oneshot.Wait()
+	suite.Assert().NoFileExists(filepath.Join(oneshot.WorkingDir, "pwned"))
+}
+
+func (suite *ts) Test_Basic_Auth_SecretReference_Unresolvable() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send", "--username", "oneshot", "--password", "env:ONESHOT_TEST_UNSET_PASSWORD", "./test.txt"}
+	oneshot.Files = itest.FilesMap{
+		"./test.txt": []byte("SUCCESS"),
+	}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	oneshot.Wait()
+	suite.Assert().NotEqual(0, oneshot.Cmd.ProcessState.ExitCode())
+	stderr := oneshot.Stderr.(*bytes.Buffer).String()
+	suite.Assert().Contains(stderr, "environment variable ONESHOT_TEST_UNSET_PASSWORD is not set")
+}
+
+func htpasswdEntries(suite *ts) []byte {
+	bHash, err := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+	suite.Require().NoError(err)
+
+	salt := []byte("oneshot-salt")
+	aHash := argon2.IDKey([]byte("swordfish"), salt, 1, 64*1024, 1, 32)
+	aEntry := fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, 64*1024, 1, 1,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(aHash),
+	)
+
+	return []byte(fmt.Sprintf("alice:%s:download\nbob:%s:upload\n", bHash, aEntry))
+}
+
+func (suite *ts) Test_HTPasswd() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send", "--htpasswd", "./htpasswd", "--output", "json", "./test.txt"}
+	oneshot.Files = itest.FilesMap{
+		"./test.txt": []byte("SUCCESS"),
+		"./htpasswd": htpasswdEntries(suite),
+	}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	client := itest.RetryClient{}
+	req, err := http.NewRequest("GET", "http://127.0.0.1:8080", nil)
+	suite.Require().NoError(err)
+	req.SetBasicAuth("alice", "swordfish")
+	resp, err := client.Do(req)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusUnauthorized, resp.StatusCode)
+	resp.Body.Close()
+
+	// bob may only upload
+	req.SetBasicAuth("bob", "swordfish")
+	resp, err = client.Do(req)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusForbidden, resp.StatusCode)
+	resp.Body.Close()
+
+	req.SetBasicAuth("alice", "hunter2")
+	resp, err = client.Do(req)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	oneshot.Wait()
+
+	var report output.Report
+	err = json.Unmarshal(oneshot.Stdout.(*bytes.Buffer).Bytes(), &report)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(report.Success)
+	suite.Assert().Equal("alice", report.Success.Request.User)
+	suite.Assert().Equal([]string{"Basic <redacted>"}, report.Success.Request.Header["Authorization"])
+}
+
+func (suite *ts) Test_HTPasswd_Argon2_Upload() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"receive", "--htpasswd", "./htpasswd", "./test.txt"}
+	oneshot.Files = itest.FilesMap{
+		"./htpasswd": htpasswdEntries(suite),
+	}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	client := itest.RetryClient{}
+	// bob may only upload, which is enough to load the page to upload from
+	req, err := http.NewRequest("GET", "http://127.0.0.1:8080", nil)
+	suite.Require().NoError(err)
+	req.SetBasicAuth("bob", "swordfish")
+	resp, err := client.Do(req)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	req, err = http.NewRequest("POST", "http://127.0.0.1:8080", bytes.NewReader([]byte("SUCCESS")))
+	suite.Require().NoError(err)
+	req.SetBasicAuth("alice", "hunter2")
+	resp, err = client.Do(req)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusForbidden, resp.StatusCode)
+	resp.Body.Close()
+
+	req, err = http.NewRequest("POST", "http://127.0.0.1:8080", bytes.NewReader([]byte("SUCCESS")))
+	suite.Require().NoError(err)
+	req.SetBasicAuth("bob", "swordfish")
+	resp, err = client.Do(req)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	oneshot.Wait()
+	fileContents, err := os.ReadFile(filepath.Join(oneshot.WorkingDir, "test.txt"))
+	suite.Require().NoError(err)
+	suite.Assert().Equal("SUCCESS", string(fileContents))
+}
+
+func (suite *ts) Test_HTPasswd_WithPassword() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send", "--htpasswd", "./htpasswd", "--password", "hunter2", "./test.txt"}
+	oneshot.Files = itest.FilesMap{
+		"./test.txt": []byte("SUCCESS"),
+		"./htpasswd": htpasswdEntries(suite),
+	}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	oneshot.Wait()
+	suite.Assert().NotEqual(0, oneshot.Cmd.ProcessState.ExitCode())
+	stderr := oneshot.Stderr.(*bytes.Buffer).String()
+	suite.Assert().Contains(stderr, "htpasswd cannot be used with a username or password")
+}
+
+// mockOIDCProvider is an OpenID Connect provider that logs in whoever it is told to without asking.
+type mockOIDCProvider struct {
+	*httptest.Server
+	key   *rsa.PrivateKey
+	email string
+	// unverified leaves the email_verified claim out of the ID token
+	unverified bool
+
+	mu    sync.Mutex
+	codes map[string]url.Values
+}
+
+func newMockOIDCProvider(suite *ts, email string) *mockOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	suite.Require().NoError(err)
+
+	p := mockOIDCProvider{
+		key:   key,
+		email: email,
+		codes: make(map[string]url.Values),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		code := fmt.Sprintf("code-%d", time.Now().UnixNano())
+		p.mu.Lock()
+		p.codes[code] = q
+		p.mu.Unlock()
+
+		redirect := q.Get("redirect_uri") + "?" + url.Values{
+			"code":  {code},
+			"state": {q.Get("state")},
+		}.Encode()
+		http.Redirect(w, r, redirect, http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		p.mu.Lock()
+		auth, ok := p.codes[r.Form.Get("code")]
+		delete(p.codes, r.Form.Get("code"))
+		p.mu.Unlock()
+
+		challenge := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(challenge[:]) != auth.Get("code_challenge") {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		claims := jwt.MapClaims{
+			"iss":            p.URL,
+			"aud":            auth.Get("client_id"),
+			"sub":            "1234",
+			"email":          p.email,
+			"email_verified": true,
+			"nonce":          auth.Get("nonce"),
+			"iat":            time.Now().Unix(),
+			"exp":            time.Now().Add(time.Minute).Unix(),
+		}
+		if p.unverified {
+			delete(claims, "email_verified")
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "test"
+		idToken, err := token.SignedString(key)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     idToken,
+		})
+	})
+	p.Server = httptest.NewServer(mux)
+
+	return &p
+}
+
+func (suite *ts) Test_OIDC() {
+	provider := newMockOIDCProvider(suite, "alice@example.com")
+	defer provider.Close()
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send",
+		"--oidc-issuer", provider.URL,
+		"--oidc-client-id", "oneshot",
+		"--oidc-allowed-domains", "example.com",
+		"--output", "json",
+		"./test.txt",
+	}
+	oneshot.Files = itest.FilesMap{
+		"./test.txt": []byte("SUCCESS"),
+	}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	// requests that can't be redirected to log in are turned away
+	client := itest.RetryClient{}
+	req, err := http.NewRequest("POST", "http://127.0.0.1:8080", nil)
+	suite.Require().NoError(err)
+	resp, err := client.Do(req)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusUnauthorized, resp.StatusCode)
+	resp.Body.Close()
+
+	jar, err := cookiejar.New(nil)
+	suite.Require().NoError(err)
+	browser := http.Client{Jar: jar}
+	resp, err = browser.Get("http://127.0.0.1:8080/")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	suite.Assert().NoError(err)
+	resp.Body.Close()
+	suite.Assert().Equal("SUCCESS", string(body))
+
+	oneshot.Wait()
+
+	var report output.Report
+	err = json.Unmarshal(oneshot.Stdout.(*bytes.Buffer).Bytes(), &report)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(report.Success)
+	suite.Assert().Equal("alice@example.com", report.Success.Request.User)
+	// the session cookie is as good as alices credentials
+	suite.Assert().Equal([]string{"oneshot-session=<redacted>"}, report.Success.Request.Header["Cookie"])
+}
+
+func (suite *ts) Test_OIDC_OpenRedirect() {
+	provider := newMockOIDCProvider(suite, "alice@example.com")
+	defer provider.Close()
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send",
+		"--oidc-issuer", provider.URL,
+		"--oidc-client-id", "oneshot",
+		"--oidc-allowed-domains", "example.com",
+		"./test.txt",
+	}
+	oneshot.Files = itest.FilesMap{
+		"./test.txt": []byte("SUCCESS"),
+	}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	jar, err := cookiejar.New(nil)
+	suite.Require().NoError(err)
+	browser := http.Client{
+		Jar: jar,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if host := req.URL.Hostname(); host != "127.0.0.1" {
+				return fmt.Errorf("redirected to %s", host)
+			}
+			return nil
+		},
+	}
+	// whatever page the login started from, the browser is never sent to another host after it
+	resp, err := browser.Get("http://127.0.0.1:8080//evil.example/")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	suite.Assert().NoError(err)
+	resp.Body.Close()
+	suite.Assert().Equal("SUCCESS", string(body))
+
+	oneshot.Wait()
+}
+
+func (suite *ts) Test_OIDC_NotAllowed() {
+	provider := newMockOIDCProvider(suite, "mallory@example.org")
+	defer provider.Close()
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send",
+		"--oidc-issuer", provider.URL,
+		"--oidc-client-id", "oneshot",
+		"--oidc-allowed-domains", "example.com",
+		"./test.txt",
+	}
+	oneshot.Files = itest.FilesMap{
+		"./test.txt": []byte("SUCCESS"),
+	}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	client := itest.RetryClient{}
+	resp, err := client.Get("http://127.0.0.1:8080")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusFound, resp.StatusCode)
+	resp.Body.Close()
+
+	jar, err := cookiejar.New(nil)
+	suite.Require().NoError(err)
+	browser := http.Client{Jar: jar}
+	resp, err = browser.Get("http://127.0.0.1:8080/")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusUnauthorized, resp.StatusCode)
+	resp.Body.Close()
+
+	err = oneshot.Cmd.Process.Signal(syscall.SIGINT)
+	suite.Require().NoError(err)
+	oneshot.Wait()
+}
+
+func (suite *ts) Test_OIDC_UnverifiedEmail() {
+	// the email is in an allowed domain but the provider doesn't vouch for it
+	provider := newMockOIDCProvider(suite, "mallory@example.com")
+	provider.unverified = true
+	defer provider.Close()
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send",
+		"--oidc-issuer", provider.URL,
+		"--oidc-client-id", "oneshot",
+		"--oidc-allowed-domains", "example.com",
+		"./test.txt",
+	}
+	oneshot.Files = itest.FilesMap{
+		"./test.txt": []byte("SUCCESS"),
+	}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	client := itest.RetryClient{}
+	resp, err := client.Get("http://127.0.0.1:8080")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusFound, resp.StatusCode)
+	resp.Body.Close()
+
+	jar, err := cookiejar.New(nil)
+	suite.Require().NoError(err)
+	browser := http.Client{Jar: jar}
+	resp, err = browser.Get("http://127.0.0.1:8080/")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusUnauthorized, resp.StatusCode)
+	resp.Body.Close()
+
+	err = oneshot.Cmd.Process.Signal(syscall.SIGINT)
+	suite.Require().NoError(err)
+	oneshot.Wait()
+}
+
+func (suite *ts) Test_ClientCert() {
+	ca, err := itest.NewCA()
+	suite.Require().NoError(err)
+	serverCert, serverKey, err := ca.Issue("oneshot", net.ParseIP("127.0.0.1"))
+	suite.Require().NoError(err)
+	backupCert, backupKey, err := ca.Issue("backup")
+	suite.Require().NoError(err)
+	intruderCert, intruderKey, err := ca.Issue("intruder")
+	suite.Require().NoError(err)
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send",
+		"--tls-cert", "./server.pem",
+		"--tls-key", "./server.key",
+		"--tls-client-ca", "./ca.pem",
+		"--tls-client-allowed-subjects", "backup",
+		"--output", "json",
+		"./test.txt",
+	}
+	oneshot.Files = itest.FilesMap{
+		"./test.txt":   []byte("SUCCESS"),
+		"./server.pem": serverCert,
+		"./server.key": serverKey,
+		"./ca.pem":     ca.PEM,
+	}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	// no client certificate, no connection
+	tlsConfig, err := ca.ClientTLSConfig(nil, nil)
+	suite.Require().NoError(err)
+	client := itest.NewRetryClient(&http.Transport{TLSClientConfig: tlsConfig})
+	_, err = client.Get("https://127.0.0.1:8080")
+	suite.Assert().Error(err)
+
+	// signed by the CA but not allowed
+	tlsConfig, err = ca.ClientTLSConfig(intruderCert, intruderKey)
+	suite.Require().NoError(err)
+	client = itest.NewRetryClient(&http.Transport{TLSClientConfig: tlsConfig})
+	resp, err := client.Get("https://127.0.0.1:8080")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusForbidden, resp.StatusCode)
+	resp.Body.Close()
+
+	tlsConfig, err = ca.ClientTLSConfig(backupCert, backupKey)
+	suite.Require().NoError(err)
+	client = itest.NewRetryClient(&http.Transport{TLSClientConfig: tlsConfig})
+	resp, err = client.Get("https://127.0.0.1:8080")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	suite.Assert().NoError(err)
+	resp.Body.Close()
+	suite.Assert().Equal("SUCCESS", string(body))
+
+	oneshot.Wait()
+
+	var report output.Report
+	err = json.Unmarshal(oneshot.Stdout.(*bytes.Buffer).Bytes(), &report)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(report.Success)
+	suite.Assert().Equal("backup", report.Success.Request.User)
+	suite.Require().NotNil(report.Success.Request.ClientCertificate)
+	suite.Assert().Equal("CN=backup,O=oneshot", report.Success.Request.ClientCertificate.Subject)
+	suite.Assert().Equal("CN=oneshot test CA", report.Success.Request.ClientCertificate.Issuer)
+}
+
+func (suite *ts) Test_ClientCert_RequiresTLS() {
+	ca, err := itest.NewCA()
+	suite.Require().NoError(err)
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send", "--tls-client-ca", "./ca.pem", "./test.txt"}
+	oneshot.Files = itest.FilesMap{
+		"./test.txt": []byte("SUCCESS"),
+		"./ca.pem":   ca.PEM,
+	}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	err = oneshot.Cmd.Wait()
+	suite.Assert().Error(err)
+	suite.Assert().Contains(oneshot.Stderr.(*bytes.Buffer).String(), "tls-cert and tls-key are required when tls-client-ca is set")
+}
+
+const profilesConfig = `server:
+  port: 8090
+profiles:
+  lan:
+    server:
+      port: 8081
+`
+
+const profilesProjectConfig = `basicAuth:
+  username: oneshot
+profiles:
+  lan:
+    basicAuth:
+      password: hunter2
+`
+
+func (suite *ts) Test_Profile() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send", "--profile", "lan", "--trust-project-config", "./test.txt"}
+	oneshot.Files = itest.FilesMap{
+		"./test.txt":      []byte("SUCCESS"),
+		"./config.yaml":   []byte(profilesConfig),
+		"./.oneshot.yaml": []byte(profilesProjectConfig),
+	}
+	oneshot.Env = []string{
+		"ONESHOT_CONFIG=./config.yaml",
+	}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	client := itest.RetryClient{}
+	req, err := http.NewRequest("GET", "http://127.0.0.1:8081", nil)
+	suite.Require().NoError(err)
+	req.SetBasicAuth("oneshot", "hunter2")
+	resp, err := client.Do(req)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(resp.StatusCode, http.StatusOK)
+
+	body, err := io.ReadAll(resp.Body)
+	suite.Assert().NoError(err)
+	resp.Body.Close()
+	suite.Assert().Equal(string(body), "SUCCESS")
+
+	oneshot.Wait()
+}
+
+func (suite *ts) Test_ProjectConfig_Untrusted() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send", "./test.txt"}
+	oneshot.Files = itest.FilesMap{
+		"./test.txt":      []byte("SUCCESS"),
+		"./config.yaml":   []byte(""),
+		"./.oneshot.yaml": []byte("basicAuth:\n  password: hunter2\n"),
+	}
+	oneshot.Env = []string{
+		"ONESHOT_CONFIG=./config.yaml",
+	}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	// the project configuration file isn't trusted so no credentials are needed
+	client := itest.RetryClient{}
+	resp, err := client.Get("http://127.0.0.1:8080")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	oneshot.Wait()
+}
+
+func (suite *ts) Test_ProjectConfig_CommandSecret() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send", "--trust-project-config", "./test.txt"}
+	oneshot.Files = itest.FilesMap{
+		"./test.txt":      []byte("SUCCESS"),
+		"./config.yaml":   []byte(""),
+		"./.oneshot.yaml": []byte("basicAuth:\n  password: cmd:touch ./pwned\n"),
+	}
+	oneshot.Env = []string{
+		"ONESHOT_CONFIG=./config.yaml",
+	}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	oneshot.Wait()
+	suite.Assert().NotEqual(0, oneshot.Cmd.ProcessState.ExitCode())
+	stderr := oneshot.Stderr.(*bytes.Buffer).String()
+	suite.Assert().Contains(stderr, "basicauth.password: cmd secret references are only allowed")
+	suite.Assert().NoFileExists(filepath.Join(oneshot.WorkingDir, "pwned"))
+}
+
+func (suite *ts) Test_Profile_Unknown() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send", "--profile", "wan", "./test.txt"}
+	oneshot.Files = itest.FilesMap{
+		"./test.txt":    []byte("SUCCESS"),
+		"./config.yaml": []byte(profilesConfig),
+	}
+	oneshot.Env = []string{
+		"ONESHOT_CONFIG=./config.yaml",
+	}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	oneshot.Wait()
+	suite.Assert().NotEqual(0, oneshot.Cmd.ProcessState.ExitCode())
+	stderr := oneshot.Stderr.(*bytes.Buffer).String()
+	suite.Assert().Contains(stderr, "no such profile: wan")
+}
+
+func (suite *ts) Test_ConfigShowEffective() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"config", "show", "--effective", "--timeout", "5s"}
+	oneshot.Files = itest.FilesMap{
+		"./config.yaml":   []byte(profilesConfig + "trustedProjectConfigs:\n  - .\n"),
+		"./.oneshot.yaml": []byte(profilesProjectConfig),
+	}
+	oneshot.Env = []string{
+		"ONESHOT_CONFIG=./config.yaml",
+		"ONESHOT_PROFILE=lan",
+		"ONESHOT_SERVER_HOST=127.0.0.1",
+	}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	oneshot.Wait()
+	suite.Require().Equal(0, oneshot.Cmd.ProcessState.ExitCode())
+
+	projectConfig, err := filepath.Abs(filepath.Join(oneshot.WorkingDir, ".oneshot.yaml"))
+	suite.Require().NoError(err)
+	projectConfig, err = filepath.EvalSymlinks(projectConfig)
+	suite.Require().NoError(err)
+
+	stdout := oneshot.Stdout.(*bytes.Buffer).String()
+	suite.Assert().Contains(stdout, "port: 8081 # ./config.yaml (profile lan)\n")
+	suite.Assert().Contains(stdout, "username: oneshot # "+projectConfig+"\n")
+	suite.Assert().Contains(stdout, "password: hunter2 # "+projectConfig+" (profile lan)\n")
+	suite.Assert().Contains(stdout, "timeout: 5s # flag --timeout\n")
+	suite.Assert().Contains(stdout, "host: 127.0.0.1 # env ONESHOT_SERVER_HOST\n")
+	suite.Assert().Contains(stdout, "allowBots: false # default\n")
+}
+
+func (suite *ts) Test_ConfigShowEffective_SecretReference() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"config", "show", "--effective", "--password", "env:ONESHOT_TEST_PASSWORD"}
+	oneshot.Env = []string{
+		"ONESHOT_TEST_PASSWORD=hunter2",
+	}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	oneshot.Wait()
+	suite.Require().Equal(0, oneshot.Cmd.ProcessState.ExitCode())
+
+	stdout := oneshot.Stdout.(*bytes.Buffer).String()
+	suite.Assert().Contains(stdout, "password: env:ONESHOT_TEST_PASSWORD # flag --password\n")
+	suite.Assert().NotContains(stdout, "hunter2")
+}
+
+func (suite *ts) Test_Listen_Multiple() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send", "--output", "json",
+		"--listen", "tcp://127.0.0.1:8080",
+		"--listen", "127.0.0.1:8081",
+		"./test.txt",
+	}
+	oneshot.Files = itest.FilesMap{"./test.txt": []byte("SUCCESS")}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	client := itest.RetryClient{}
+	resp, err := client.Get("http://127.0.0.1:8081")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	suite.Assert().NoError(err)
+	resp.Body.Close()
+	suite.Assert().Equal("SUCCESS", string(body))
+
+	oneshot.Wait()
+	stdout := oneshot.Stdout.(*bytes.Buffer).Bytes()
+	var report output.Report
+	suite.Require().NoError(json.Unmarshal(stdout, &report))
+	suite.Require().NotNil(report.Success)
+
+	stderr := oneshot.Stderr.(*bytes.Buffer).String()
+	suite.Assert().Contains(stderr, "listening on http://127.0.0.1:8080\n")
+	suite.Assert().Contains(stderr, "listening on http://127.0.0.1:8081\n")
+}
+
+func (suite *ts) Test_Listen_IPv6() {
+	l, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		suite.T().Skip("IPv6 loopback is not available")
+	}
+	l.Close()
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send", "--listen", "tcp://[::]:8080", "./test.txt"}
+	oneshot.Files = itest.FilesMap{"./test.txt": []byte("SUCCESS")}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	// dual-stack listeners are reachable over IPv6 and IPv4
+	client := itest.RetryClient{}
+	resp, err := client.Get("http://[::1]:8080")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	oneshot.Wait()
+}
+
+func (suite *ts) Test_Listen_UnixSocket() {
+	var oneshot = suite.NewOneshot()
+	socket := filepath.Join(oneshot.WorkingDir, "oneshot.sock")
+	oneshot.Args = []string{"send", "--listen", "unix://" + socket, "--output", "json", "./test.txt"}
+	oneshot.Files = itest.FilesMap{"./test.txt": []byte("SUCCESS")}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	for i := 0; i < 50; i++ {
+		if _, err := os.Stat(socket); err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	client := itest.NewRetryClient(&http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	})
+	resp, err := client.Get("http://oneshot/")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	suite.Assert().NoError(err)
+	resp.Body.Close()
+	suite.Assert().Equal("SUCCESS", string(body))
+
+	oneshot.Wait()
+	stderr := oneshot.Stderr.(*bytes.Buffer).String()
+	suite.Assert().Contains(stderr, "listening on unix://"+socket+"\n")
+
+	// unix socket clients share an address, so each connection is told apart by its id
+	var report output.Report
+	err = json.Unmarshal(oneshot.Stdout.(*bytes.Buffer).Bytes(), &report)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(report.Success)
+	suite.Assert().Regexp(`^unix#\d+$`, report.Success.Request.RemoteAddr)
+
+	_, err = os.Stat(socket)
+	suite.Assert().True(os.IsNotExist(err), "socket should be removed on exit")
+}
+
+func (suite *ts) newFakeGateway(addr string) *itest.FakeGateway {
+	gw, err := itest.NewFakeGateway(addr)
+	if err != nil {
+		suite.T().Skipf("unable to start fake gateway on %s: %v", addr, err)
+	}
+	suite.T().Cleanup(func() { gw.Close() })
+	return gw
+}
+
+func (suite *ts) Test_PortMapping_NATPMP() {
+	gw := suite.newFakeGateway("127.0.0.1:0")
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send", "./test.txt",
+		"--map-port", "--port-mapping-duration", "1m", "--external-port", "9090",
+		"--port-mapping-protocols", "natpmp", "--port-mapping-gateway", gw.Addr(),
+	}
+	oneshot.Files = itest.FilesMap{"./test.txt": []byte("SUCCESS")}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	client := itest.RetryClient{}
+	resp, err := client.Get("http://127.0.0.1:8080")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	oneshot.Wait()
+	stderr := oneshot.Stderr.(*bytes.Buffer).String()
+	suite.Assert().Contains(stderr, "listening on http://127.0.0.1:9090\n")
+
+	requests := gw.Requests()
+	suite.Require().Len(requests, 2)
+	suite.Assert().Equal(itest.GatewayRequest{
+		Protocol:     "natpmp",
+		InternalPort: 8080,
+		ExternalPort: 9090,
+		Lifetime:     time.Minute,
+	}, requests[0])
+	// the mapping is deleted on exit
+	suite.Assert().Equal(8080, requests[1].InternalPort)
+	suite.Assert().Zero(requests[1].Lifetime)
+}
+
+func (suite *ts) Test_PortMapping_FallbackToPCP() {
+	gw := suite.newFakeGateway("127.0.0.1:0")
+	gw.DisableNATPMP = true
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send", "./test.txt",
+		"--map-port", "--port-mapping-duration", "1m",
+		"--port-mapping-protocols", "natpmp,pcp", "--port-mapping-gateway", gw.Addr(),
+	}
+	oneshot.Files = itest.FilesMap{"./test.txt": []byte("SUCCESS")}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	client := itest.RetryClient{}
+	resp, err := client.Get("http://127.0.0.1:8080")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	oneshot.Wait()
+	stderr := oneshot.Stderr.(*bytes.Buffer).String()
+	suite.Assert().Contains(stderr, "listening on http://127.0.0.1:8080\n")
+
+	requests := gw.Requests()
+	suite.Require().Len(requests, 2)
+	suite.Assert().Equal("pcp", requests[0].Protocol)
+	suite.Assert().Equal(8080, requests[0].ExternalPort)
+	suite.Assert().True(net.IPv4(127, 0, 0, 1).Equal(requests[0].ClientIP))
+	suite.Assert().Zero(requests[1].Lifetime)
+}
+
+func (suite *ts) Test_PortMapping_RenewedWhileConnected() {
+	gw := suite.newFakeGateway("127.0.0.1:0")
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send", "./test.txt",
+		"--map-port", "--port-mapping-duration", "2s",
+		"--port-mapping-protocols", "natpmp", "--port-mapping-gateway", gw.Addr(),
+	}
+	oneshot.Files = itest.FilesMap{"./test.txt": []byte("SUCCESS")}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	// hold a connection open past the lifetime of the mapping
+	var (
+		conn net.Conn
+		err  error
+	)
+	for i := 0; i < 50; i++ {
+		if conn, err = net.Dial("tcp", "127.0.0.1:8080"); err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	suite.Require().NoError(err)
+	time.Sleep(3 * time.Second)
+	conn.Close()
+
+	client := itest.RetryClient{}
+	resp, err := client.Get("http://127.0.0.1:8080")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	oneshot.Wait()
+	var renewals int
+	for _, r := range gw.Requests()[1:] {
+		if 0 < r.Lifetime {
+			renewals++
+		}
+	}
+	suite.Assert().LessOrEqual(2, renewals)
+}
+
+func (suite *ts) Test_PortMapping_Expires() {
+	gw := suite.newFakeGateway("127.0.0.1:0")
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send", "./test.txt",
+		"--map-port", "--port-mapping-duration", "1s",
+		"--port-mapping-protocols", "natpmp", "--port-mapping-gateway", gw.Addr(),
+	}
+	oneshot.Files = itest.FilesMap{"./test.txt": []byte("SUCCESS")}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	// nobody connects, so oneshot exits once the mapping expires
+	timer := time.AfterFunc(5*time.Second, func() {
+		_ = oneshot.Cmd.Process.Signal(syscall.SIGINT)
+		suite.Fail("oneshot did not exit after the port mapping expired")
+	})
+	defer timer.Stop()
+
+	oneshot.Wait()
+}
+
+func (suite *ts) Test_PortMapping_IPv6Pinhole() {
+	gw := suite.newFakeGateway("127.0.0.1:0")
+	gw6 := suite.newFakeGateway("[::1]:0")
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send", "./test.txt",
+		"--map-port", "--port-mapping-duration", "1m",
+		"--port-mapping-protocols", "pcp", "--port-mapping-gateway", gw.Addr(),
+		"--port-mapping-ipv6", "--port-mapping-ipv6-gateway", gw6.Addr(),
+	}
+	oneshot.Files = itest.FilesMap{"./test.txt": []byte("SUCCESS")}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	client := itest.RetryClient{}
+	resp, err := client.Get("http://127.0.0.1:8080")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	oneshot.Wait()
+	stderr := oneshot.Stderr.(*bytes.Buffer).String()
+	suite.Assert().Contains(stderr, "listening on http://127.0.0.1:8080\n")
+	suite.Assert().Contains(stderr, "listening on http://[::1]:8080\n")
+
+	requests := gw6.Requests()
+	suite.Require().Len(requests, 2)
+	suite.Assert().True(net.IPv6loopback.Equal(requests[0].ClientIP))
+	suite.Assert().Equal(8080, requests[0].InternalPort)
+	suite.Assert().Zero(requests[1].Lifetime)
+}
+
+func (suite *ts) Test_Tunnel_SSH() {
+	sshd, err := itest.NewFakeSSHServer("127.0.0.1:0")
+	suite.Require().NoError(err)
+	defer sshd.Close()
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send", "./test.txt",
+		"--tunnel", "ssh", "--tunnel-ssh-host", "oneshot@" + sshd.Addr(),
+		"--tunnel-ssh-identity-file", "./id_ed25519",
+		"--tunnel-ssh-known-hosts-file", "./known_hosts",
+	}
+	oneshot.Files = itest.FilesMap{
+		"./test.txt":    []byte("SUCCESS"),
+		"./id_ed25519":  sshd.ClientKey,
+		"./known_hosts": sshd.KnownHosts(),
+	}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	suite.Require().Eventually(func() bool {
+		return 0 < len(sshd.Forwarded())
+	}, 10*time.Second, 50*time.Millisecond)
+	tunnelURL := "http://" + sshd.Forwarded()[0]
+
+	client := itest.RetryClient{}
+	resp, err := client.Get(tunnelURL)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	suite.Require().NoError(err)
+	resp.Body.Close()
+	suite.Assert().Equal("SUCCESS", string(body))
+
+	oneshot.Wait()
+	stderr := oneshot.Stderr.(*bytes.Buffer).String()
+	suite.Assert().Contains(stderr, "listening on "+tunnelURL+"\n")
+}
+
+func (suite *ts) Test_Tunnel_SSH_UnknownHostKey() {
+	sshd, err := itest.NewFakeSSHServer("127.0.0.1:0")
+	suite.Require().NoError(err)
+	defer sshd.Close()
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send", "./test.txt",
+		"--tunnel", "ssh", "--tunnel-ssh-host", "oneshot@" + sshd.Addr(),
+		"--tunnel-ssh-identity-file", "./id_ed25519",
+		"--tunnel-ssh-known-hosts-file", "./known_hosts",
+	}
+	oneshot.Files = itest.FilesMap{
+		"./test.txt":    []byte("SUCCESS"),
+		"./id_ed25519":  sshd.ClientKey,
+		"./known_hosts": []byte{},
+	}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+	oneshot.Wait()
+
+	suite.Assert().NotEqual(0, oneshot.Cmd.ProcessState.ExitCode())
+	stderr := oneshot.Stderr.(*bytes.Buffer).String()
+	suite.Assert().Contains(stderr, "failed to open tunnel")
+	suite.Assert().Empty(sshd.Forwarded())
+}
+
+func (suite *ts) Test_Tunnel_PublicURL() {
+	sshd, err := itest.NewFakeSSHServer("127.0.0.1:0")
+	suite.Require().NoError(err)
+	defer sshd.Close()
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send", "./test.txt",
+		"--tunnel", "ssh", "--tunnel-ssh-host", "oneshot@" + sshd.Addr(),
+		"--tunnel-ssh-identity-file", "./id_ed25519", "--tunnel-ssh-insecure",
+		"--tunnel-public-url", "https://oneshot.example.com",
+	}
+	oneshot.Files = itest.FilesMap{
+		"./test.txt":   []byte("SUCCESS"),
+		"./id_ed25519": sshd.ClientKey,
+	}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	suite.Require().Eventually(func() bool {
+		return 0 < len(sshd.Forwarded())
+	}, 10*time.Second, 50*time.Millisecond)
+
+	client := itest.RetryClient{}
+	resp, err := client.Get("http://" + sshd.Forwarded()[0])
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	oneshot.Wait()
+	stderr := oneshot.Stderr.(*bytes.Buffer).String()
+	suite.Assert().Contains(stderr, "listening on https://oneshot.example.com\n")
+}
+
+func (suite *ts) Test_Tunnel_DiscoveryRelay() {
+	var addrs [2]string
+	for i := range addrs {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		suite.Require().NoError(err)
+		addrs[i] = l.Addr().String()
+		l.Close()
+	}
+	apiAddr, httpAddr := addrs[0], addrs[1]
+	_, httpPort, err := net.SplitHostPort(httpAddr)
+	suite.Require().NoError(err)
+
+	var ds = suite.NewOneshot()
+	ds.Args = []string{"discovery-server", "--host", "127.0.0.1", "--port", httpPort,
+		"--p2p-webrtc-config-file", "./webrtc.yaml",
+	}
+	ds.Env = []string{
+		"ONESHOT_CMD_DISCOVERYSERVER_SERVER_ADDR=" + apiAddr,
+		"ONESHOT_CMD_DISCOVERYSERVER_REQUIREDKEY_VALUE=secret",
+	}
+	ds.Files = itest.FilesMap{"./webrtc.yaml": []byte("iceServers:\n  - urls:\n      - stun:127.0.0.1:3478\n")}
+	ds.Start()
+	defer ds.Cleanup()
+	defer func() {
+		ds.Signal(os.Interrupt)
+		ds.Wait()
+	}()
+
+	relayURL := "http://" + httpAddr + "/relay"
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send", "./test.txt",
+		"--tunnel", "discovery",
+		"--discovery-url", apiAddr, "--discovery-key", "secret", "--discovery-insecure",
+		"--discovery-required-url", relayURL,
+		// the relay is a stream of its own, oneshot does not need to arrive at the discovery server as well
+		"--discovery-enabled=false",
+	}
+	oneshot.Files = itest.FilesMap{"./test.txt": []byte("SUCCESS")}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	client := itest.RetryClient{}
+	resp, err := client.Get(relayURL + "/test.txt")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	suite.Require().NoError(err)
+	resp.Body.Close()
+	suite.Assert().Equal("SUCCESS", string(body))
+
+	oneshot.Wait()
+	stderr := oneshot.Stderr.(*bytes.Buffer).String()
+	suite.Assert().Contains(stderr, "listening on "+relayURL+"\n")
+
+	// once oneshot is gone, so is the relay
+	resp, err = http.Get(relayURL)
+	suite.Require().NoError(err)
+	resp.Body.Close()
+	suite.Assert().Equal(http.StatusNotFound, resp.StatusCode)
+}
+
+func (suite *ts) Test_Tunnel_DiscoveryRelay_WrongKey() {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.Require().NoError(err)
+	apiAddr := l.Addr().String()
+	l.Close()
+
+	var ds = suite.NewOneshot()
+	ds.Args = []string{"discovery-server", "--host", "127.0.0.1", "--port", "0",
+		"--p2p-webrtc-config-file", "./webrtc.yaml",
+	}
+	ds.Env = []string{
+		"ONESHOT_CMD_DISCOVERYSERVER_SERVER_ADDR=" + apiAddr,
+		"ONESHOT_CMD_DISCOVERYSERVER_REQUIREDKEY_VALUE=secret",
+	}
+	ds.Files = itest.FilesMap{"./webrtc.yaml": []byte("iceServers:\n  - urls:\n      - stun:127.0.0.1:3478\n")}
+	ds.Start()
+	defer ds.Cleanup()
+	defer func() {
+		ds.Signal(os.Interrupt)
+		ds.Wait()
+	}()
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send", "./test.txt",
+		"--tunnel", "discovery",
+		"--discovery-url", apiAddr, "--discovery-key", "wrong", "--discovery-insecure",
+		"--discovery-enabled=false",
+	}
+	oneshot.Files = itest.FilesMap{"./test.txt": []byte("SUCCESS")}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+	oneshot.Wait()
+
+	suite.Assert().NotEqual(0, oneshot.Cmd.ProcessState.ExitCode())
+	stderr := oneshot.Stderr.(*bytes.Buffer).String()
+	suite.Assert().Contains(stderr, "failed to open relay")
+}
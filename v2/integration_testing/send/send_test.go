@@ -1,7 +1,9 @@
 package main
 
 import (
+	"archive/tar"
 	"archive/zip"
+	"bufio"
 	"bytes"
 	"crypto/rand"
 	"encoding/json"
@@ -17,7 +19,9 @@ import (
 	"time"
 
 	itest "github.com/forestnode-io/oneshot/v2/integration_testing"
+	"github.com/forestnode-io/oneshot/v2/pkg/events"
 	"github.com/forestnode-io/oneshot/v2/pkg/output"
+	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -251,6 +255,161 @@ func (suite *ts) Test_FROM_File_TO_ANY__JSON() {
 	suite.Assert().Regexp(`listening on http://.*\n`, string(stderr))
 }
 
+func (suite *ts) Test_FROM_File_TO_ANY__NDJSON() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send", "--output", "ndjson", "./test.txt"}
+	oneshot.Files = itest.FilesMap{"./test.txt": []byte("SUCCESS")}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	// ---
+
+	client := itest.RetryClient{}
+	resp, err := client.Get("http://127.0.0.1:8080")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(resp.StatusCode, http.StatusOK)
+
+	body, err := io.ReadAll(resp.Body)
+	suite.Assert().NoError(err)
+	resp.Body.Close()
+	suite.Assert().Equal(string(body), "SUCCESS")
+
+	oneshot.Wait()
+
+	// every line of stdout is a json object
+	var (
+		types   []string
+		lines   = map[string]json.RawMessage{}
+		scanner = bufio.NewScanner(oneshot.Stdout.(*bytes.Buffer))
+	)
+	for scanner.Scan() {
+		var line struct {
+			V    int
+			Type string
+			Data json.RawMessage
+		}
+		suite.Require().NoError(json.Unmarshal(scanner.Bytes(), &line), scanner.Text())
+		suite.Assert().Equal(output.NDJSONSchemaVersion, line.V)
+		lines[line.Type] = line.Data
+		// progress lines are written on their own schedule
+		if line.Type != output.NDJSONProgress {
+			types = append(types, line.Type)
+		}
+	}
+	suite.Assert().Equal([]string{
+		output.NDJSONStart,
+		output.NDJSONListening,
+		output.NDJSONRequest,
+		output.NDJSONFile,
+		output.NDJSONShutdown,
+		output.NDJSONExit,
+	}, types)
+
+	var progress events.Progress
+	suite.Require().NoError(json.Unmarshal(lines[output.NDJSONProgress], &progress))
+	suite.Assert().True(progress.Done)
+	suite.Assert().Equal(int64(len("SUCCESS")), progress.Bytes)
+
+	var file struct {
+		Size         int64
+		TransferSize int64
+	}
+	suite.Require().NoError(json.Unmarshal(lines[output.NDJSONFile], &file))
+	suite.Assert().Equal(int64(len("SUCCESS")), file.Size)
+	suite.Assert().Equal(file.Size, file.TransferSize)
+
+	var shutdown events.Shutdown
+	suite.Require().NoError(json.Unmarshal(lines[output.NDJSONShutdown], &shutdown))
+	suite.Assert().Equal(events.ShutdownSuccess, shutdown.Reason)
+
+	var exit output.NDJSONExitData
+	suite.Require().NoError(json.Unmarshal(lines[output.NDJSONExit], &exit))
+	suite.Assert().Equal(0, exit.Code)
+}
+
+func (suite *ts) Test_FROM_Stdin_Stream() {
+	stdinR, stdinW := io.Pipe()
+	defer stdinW.Close()
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send", "--stream"}
+	oneshot.Stdin = stdinR
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	// ---
+
+	_, err := stdinW.Write([]byte("FIRST"))
+	suite.Require().NoError(err)
+
+	client := itest.RetryClient{}
+	resp, err := client.Get("http://127.0.0.1:8080")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	suite.Assert().Equal(int64(-1), resp.ContentLength)
+
+	// the start of stdin arrives before stdin is closed
+	first := make([]byte, len("FIRST"))
+	_, err = io.ReadFull(resp.Body, first)
+	suite.Require().NoError(err)
+	suite.Assert().Equal("FIRST", string(first))
+
+	_, err = stdinW.Write([]byte("SECOND"))
+	suite.Require().NoError(err)
+	stdinW.Close()
+
+	rest, err := io.ReadAll(resp.Body)
+	suite.Require().NoError(err)
+	resp.Body.Close()
+	suite.Assert().Equal("SECOND", string(rest))
+
+	oneshot.Wait()
+}
+
+func (suite *ts) Test_FROM_Stdin_Stream_Spool() {
+	stdinR, stdinW := io.Pipe()
+	defer stdinW.Close()
+
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send", "--stream", "--stream-spool", "--stream-memory", "4B"}
+	oneshot.Stdin = stdinR
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	// ---
+
+	_, err := stdinW.Write([]byte("FIRST"))
+	suite.Require().NoError(err)
+
+	// the first client gives up part way through the stream
+	client := itest.RetryClient{}
+	resp, err := client.Get("http://127.0.0.1:8080")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	first := make([]byte, len("FIRST"))
+	_, err = io.ReadFull(resp.Body, first)
+	suite.Require().NoError(err)
+	suite.Assert().Equal("FIRST", string(first))
+	resp.Body.Close()
+
+	// enough content for the server to notice the first client is gone
+	rest := bytes.Repeat([]byte("SECOND"), 1<<20)
+	_, err = stdinW.Write(rest)
+	suite.Require().NoError(err)
+	stdinW.Close()
+
+	// the next client receives all of stdin from the spool
+	resp, err = client.Get("http://127.0.0.1:8080")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	suite.Require().NoError(err)
+	resp.Body.Close()
+	suite.Assert().Equal(append([]byte("FIRST"), rest...), body)
+
+	oneshot.Wait()
+}
+
 func (suite *ts) Test_StatusCode() {
 	var oneshot = suite.NewOneshot()
 	oneshot.Args = []string{"send", "--status-code", "418"}
@@ -269,6 +428,46 @@ func (suite *ts) Test_StatusCode() {
 	suite.Assert().Equal(resp.StatusCode, http.StatusTeapot)
 }
 
+func (suite *ts) Test_TUI_StderrNONTTY() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send", "--tui", "./test.txt"}
+	oneshot.Files = itest.FilesMap{"./test.txt": []byte("SUCCESS")}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	// ---
+
+	client := itest.RetryClient{}
+	resp, err := client.Get("http://127.0.0.1:8080")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(resp.StatusCode, http.StatusOK)
+
+	body, err := io.ReadAll(resp.Body)
+	suite.Assert().NoError(err)
+	resp.Body.Close()
+	suite.Assert().Equal(string(body), "SUCCESS")
+
+	oneshot.Wait()
+	suite.Assert().Equal(0, oneshot.Cmd.ProcessState.ExitCode())
+
+	// without a terminal the dashboard is skipped for the regular output
+	stderr := oneshot.Stderr.(*bytes.Buffer).Bytes()
+	suite.Assert().NotContains(string(stderr), "\x1b[?1049h")
+	suite.Assert().Regexp(`listening on http://.*\n`, string(stderr))
+	suite.Assert().Contains(string(stderr), "success\n")
+}
+
+func (suite *ts) Test_TUI_WithOutputFormat() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send", "--tui", "--output", "json", "./test.txt"}
+	oneshot.Files = itest.FilesMap{"./test.txt": []byte("SUCCESS")}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	oneshot.Wait()
+	suite.Assert().NotEqual(0, oneshot.Cmd.ProcessState.ExitCode())
+}
+
 func (suite *ts) Test_Send_Directory_targz() {
 	var oneshot = suite.NewOneshot()
 	oneshot.Args = []string{"send", "./testDir"}
@@ -359,6 +558,122 @@ func (suite *ts) Test_Send_Directory_zip() {
 	oneshot.Wait()
 }
 
+func (suite *ts) Test_Send_Directory_tarzst_Exclude() {
+	var oneshot = suite.NewOneshot()
+	oneshot.Args = []string{"send", "-a", "tar.zst", "--exclude", "*.o", "--gitignore", "./testDir"}
+	oneshot.Files = itest.FilesMap{
+		"./testDir/test.txt":      []byte("SUCCESS"),
+		"./testDir/test.o":        []byte("EXCLUDED"),
+		"./testDir/.gitignore":    []byte("*.log\n"),
+		"./testDir/sub/test2.txt": []byte("SUCCESS2"),
+		"./testDir/sub/test.log":  []byte("IGNORED"),
+		"./testDir/.git/config":   []byte("IGNORED"),
+	}
+	oneshot.Start()
+	defer oneshot.Cleanup()
+
+	// ---
+
+	client := itest.RetryClient{}
+	resp, err := client.Get("http://127.0.0.1:8080")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+
+	zr, err := zstd.NewReader(resp.Body)
+	suite.Require().NoError(err)
+	defer zr.Close()
+
+	files := map[string]string{}
+	tr := tar.NewReader(zr)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		suite.Require().NoError(err)
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		suite.Require().NoError(err)
+		files[header.Name] = string(content)
+	}
+
+	suite.Assert().Equal(map[string]string{
+		"testDir/test.txt":      "SUCCESS",
+		"testDir/.gitignore":    "*.log\n",
+		"testDir/sub/test2.txt": "SUCCESS2",
+	}, files)
+
+	oneshot.Wait()
+}
+
+func (suite *ts) Test_Send_Directory_Deterministic() {
+	var archives [][]byte
+	for i := 0; i < 2; i++ {
+		var oneshot = suite.NewOneshot()
+		oneshot.Args = []string{"send", "-a", "tar.gz", "--deterministic", "./testDir"}
+		files := itest.FilesMap{
+			"./testDir/test.txt":      []byte("SUCCESS"),
+			"./testDir/sub/test2.txt": []byte("SUCCESS2"),
+		}
+		suite.Require().NoError(files.ProjectInto(oneshot.WorkingDir))
+
+		// give the files a different modification time for each run
+		mtime := time.Now().Add(time.Duration(i) * time.Hour)
+		err := os.Chtimes(filepath.Join(oneshot.WorkingDir, "testDir", "test.txt"), mtime, mtime)
+		suite.Require().NoError(err)
+
+		oneshot.Start()
+
+		client := itest.RetryClient{}
+		resp, err := client.Get("http://127.0.0.1:8080")
+		suite.Require().NoError(err)
+		suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+
+		body, err := io.ReadAll(resp.Body)
+		suite.Require().NoError(err)
+		resp.Body.Close()
+		archives = append(archives, body)
+
+		oneshot.Wait()
+		oneshot.Cleanup()
+	}
+
+	suite.Require().NotEmpty(archives[0])
+	suite.Assert().Equal(archives[0], archives[1])
+}
+
+func (suite *ts) Test_Send_Directory_ContentLength() {
+	for _, archiveArgs := range [][]string{
+		{"-a", "tar"},
+		{"-a", "zip", "--compression-level", "0"},
+		{"-a", "tar.gz", "--compute-size"},
+	} {
+		var oneshot = suite.NewOneshot()
+		oneshot.Args = append(append([]string{"send"}, archiveArgs...), "./testDir")
+		oneshot.Files = itest.FilesMap{
+			"./testDir/test.txt":      []byte("SUCCESS"),
+			"./testDir/sub/test2.txt": []byte("SUCCESS2"),
+		}
+		oneshot.Start()
+
+		client := itest.RetryClient{}
+		resp, err := client.Get("http://127.0.0.1:8080")
+		suite.Require().NoError(err)
+		suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+
+		body, err := io.ReadAll(resp.Body)
+		suite.Require().NoError(err)
+		resp.Body.Close()
+		suite.Assert().NotZero(len(body), archiveArgs)
+		suite.Assert().Equal(int64(len(body)), resp.ContentLength, archiveArgs)
+
+		oneshot.Wait()
+		oneshot.Cleanup()
+	}
+}
+
 func (suite *ts) Test_MultipleClients() {
 	var oneshot = suite.NewOneshot()
 	oneshot.Args = []string{"send"}
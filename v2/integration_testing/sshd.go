@@ -0,0 +1,222 @@
+package itest
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// FakeSSHServer is an ssh server that only does remote port forwarding, the way sshd does for ssh -R.
+// Forwarded ports always listen on the loopback interface.
+type FakeSSHServer struct {
+	// ClientKey is the private key of the only client allowed in, PEM encoded.
+	ClientKey []byte
+
+	l       net.Listener
+	hostKey ssh.Signer
+	config  *ssh.ServerConfig
+
+	mu        sync.Mutex
+	forwarded []string
+}
+
+// NewFakeSSHServer starts an ssh server listening on addr, use 127.0.0.1:0 for a random port.
+func NewFakeSSHServer(addr string) (*FakeSSHServer, error) {
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	hostKey, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		return nil, err
+	}
+
+	clientPub, clientPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	clientDER, err := x509.MarshalPKCS8PrivateKey(clientPriv)
+	if err != nil {
+		return nil, err
+	}
+	clientKey, err := ssh.NewPublicKey(clientPub)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := FakeSSHServer{
+		ClientKey: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: clientDER}),
+		l:         l,
+		hostKey:   hostKey,
+	}
+	s.config = &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(clientKey.Marshal()) {
+				return nil, ssh.ErrNoAuth
+			}
+			return nil, nil
+		},
+		ServerVersion: "SSH-2.0-oneshot-itest",
+	}
+	s.config.AddHostKey(hostKey)
+	go s.serve()
+
+	return &s, nil
+}
+
+func (s *FakeSSHServer) Addr() string {
+	return s.l.Addr().String()
+}
+
+// KnownHosts returns a known_hosts file holding the servers host key.
+func (s *FakeSSHServer) KnownHosts() []byte {
+	return []byte(knownhosts.Line([]string{knownhosts.Normalize(s.Addr())}, s.hostKey.PublicKey()) + "\n")
+}
+
+// Forwarded returns the addresses of the ports forwarded so far.
+func (s *FakeSSHServer) Forwarded() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.forwarded...)
+}
+
+func (s *FakeSSHServer) Close() error {
+	return s.l.Close()
+}
+
+func (s *FakeSSHServer) serve() {
+	for {
+		conn, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *FakeSSHServer) handle(conn net.Conn) {
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	defer sconn.Close()
+
+	go func() {
+		for nc := range chans {
+			nc.Reject(ssh.Prohibited, "only remote port forwarding is supported")
+		}
+	}()
+
+	var (
+		mu        sync.Mutex
+		listeners = make(map[string]net.Listener)
+	)
+	defer func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, l := range listeners {
+			l.Close()
+		}
+	}()
+
+	for req := range reqs {
+		switch req.Type {
+		case "tcpip-forward":
+			var payload struct {
+				Addr string
+				Port uint32
+			}
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			l, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(int(payload.Port))))
+			if err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			port := uint32(l.Addr().(*net.TCPAddr).Port)
+
+			mu.Lock()
+			listeners[net.JoinHostPort(payload.Addr, strconv.Itoa(int(payload.Port)))] = l
+			mu.Unlock()
+			s.mu.Lock()
+			s.forwarded = append(s.forwarded, l.Addr().String())
+			s.mu.Unlock()
+
+			var reply []byte
+			if payload.Port == 0 {
+				reply = ssh.Marshal(struct{ Port uint32 }{port})
+			}
+			req.Reply(true, reply)
+
+			go forward(sconn, l, payload.Addr, port)
+		case "cancel-tcpip-forward":
+			var payload struct {
+				Addr string
+				Port uint32
+			}
+			if err := ssh.Unmarshal(req.Payload, &payload); err == nil {
+				key := net.JoinHostPort(payload.Addr, strconv.Itoa(int(payload.Port)))
+				mu.Lock()
+				if l, ok := listeners[key]; ok {
+					l.Close()
+					delete(listeners, key)
+				}
+				mu.Unlock()
+			}
+			req.Reply(true, nil)
+		default:
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+		}
+	}
+}
+
+// forward hands each connection made to l to the client over a forwarded-tcpip channel.
+func forward(sconn *ssh.ServerConn, l net.Listener, addr string, port uint32) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			defer conn.Close()
+
+			origin := conn.RemoteAddr().(*net.TCPAddr)
+			ch, reqs, err := sconn.OpenChannel("forwarded-tcpip", ssh.Marshal(struct {
+				Addr       string
+				Port       uint32
+				OriginAddr string
+				OriginPort uint32
+			}{addr, port, origin.IP.String(), uint32(origin.Port)}))
+			if err != nil {
+				return
+			}
+			defer ch.Close()
+			go ssh.DiscardRequests(reqs)
+
+			go func() {
+				_, _ = io.Copy(ch, conn)
+				_ = ch.CloseWrite()
+			}()
+			_, _ = io.Copy(conn, ch)
+		}()
+	}
+}
@@ -61,6 +61,11 @@ type RetryClient struct {
 	Suite  *suite.Suite
 }
 
+// NewRetryClient returns a RetryClient that makes its requests with transport.
+func NewRetryClient(transport http.RoundTripper) *RetryClient {
+	return &RetryClient{client: transport}
+}
+
 func (rc *RetryClient) Post(url, mime string, body io.Reader) (*http.Response, error) {
 	var response *http.Response
 
@@ -1,6 +1,8 @@
 package cgi
 
 import (
+	"crypto/tls"
+	"encoding/pem"
 	"fmt"
 	"net"
 	"net/http"
@@ -65,6 +67,7 @@ func AddRequest(env []string, r *http.Request) []string {
 
 	if r.TLS != nil {
 		newEnv = append(newEnv, "HTTPS=on")
+		newEnv = append(newEnv, tlsEnv(r.TLS)...)
 	}
 
 	for k, v := range r.Header {
@@ -105,6 +108,61 @@ func AddRequest(env []string, r *http.Request) []string {
 	return removeLeadingDuplicates(newEnv)
 }
 
+// tlsEnv returns the mod_ssl style SSL_* variables describing the connection
+// and the verified client certificate, if there is one.
+func tlsEnv(cs *tls.ConnectionState) []string {
+	env := []string{
+		"SSL_PROTOCOL=" + tlsVersionName(cs.Version),
+		"SSL_CIPHER=" + tls.CipherSuiteName(cs.CipherSuite),
+	}
+
+	if len(cs.VerifiedChains) == 0 || len(cs.VerifiedChains[0]) == 0 {
+		return append(env, "SSL_CLIENT_VERIFY=NONE")
+	}
+
+	const certTimeFormat = "Jan _2 15:04:05 2006 GMT"
+	cert := cs.VerifiedChains[0][0]
+	env = append(env,
+		"SSL_CLIENT_VERIFY=SUCCESS",
+		"SSL_CLIENT_S_DN="+cert.Subject.String(),
+		"SSL_CLIENT_S_DN_CN="+cert.Subject.CommonName,
+		"SSL_CLIENT_I_DN="+cert.Issuer.String(),
+		"SSL_CLIENT_I_DN_CN="+cert.Issuer.CommonName,
+		"SSL_CLIENT_M_SERIAL="+strings.ToUpper(cert.SerialNumber.Text(16)),
+		"SSL_CLIENT_V_START="+cert.NotBefore.UTC().Format(certTimeFormat),
+		"SSL_CLIENT_V_END="+cert.NotAfter.UTC().Format(certTimeFormat),
+		"SSL_CLIENT_CERT="+string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})),
+	)
+	for i, name := range cert.DNSNames {
+		env = append(env, fmt.Sprintf("SSL_CLIENT_SAN_DNS_%d=%s", i, name))
+	}
+	for i, email := range cert.EmailAddresses {
+		env = append(env, fmt.Sprintf("SSL_CLIENT_SAN_Email_%d=%s", i, email))
+	}
+	for i, ip := range cert.IPAddresses {
+		env = append(env, fmt.Sprintf("SSL_CLIENT_SAN_IPaddr_%d=%s", i, ip))
+	}
+	for i, uri := range cert.URIs {
+		env = append(env, fmt.Sprintf("SSL_CLIENT_SAN_URI_%d=%s", i, uri))
+	}
+
+	return env
+}
+
+func tlsVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLSv1"
+	case tls.VersionTLS11:
+		return "TLSv1.1"
+	case tls.VersionTLS12:
+		return "TLSv1.2"
+	case tls.VersionTLS13:
+		return "TLSv1.3"
+	}
+	return fmt.Sprintf("0x%04x", version)
+}
+
 func removeLeadingDuplicates(env []string) (ret []string) {
 	for i, e := range env {
 		found := false
@@ -0,0 +1,209 @@
+package capture
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/commands"
+	"github.com/forestnode-io/oneshot/v2/pkg/commands/capture/configuration"
+	rootconfig "github.com/forestnode-io/oneshot/v2/pkg/configuration"
+	"github.com/forestnode-io/oneshot/v2/pkg/events"
+	oneshothttp "github.com/forestnode-io/oneshot/v2/pkg/net/http"
+	"github.com/forestnode-io/oneshot/v2/pkg/output"
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+)
+
+func New(config *rootconfig.Root) *Cmd {
+	return &Cmd{
+		config: config,
+	}
+}
+
+type Cmd struct {
+	cobraCommand *cobra.Command
+	config       *rootconfig.Root
+	verifier     *verifier
+	// captured counts the accepted requests, requests are handled one at a time.
+	captured int
+}
+
+func (c *Cmd) Cobra() *cobra.Command {
+	if c.cobraCommand != nil {
+		return c.cobraCommand
+	}
+
+	c.cobraCommand = &cobra.Command{
+		Use:   "capture",
+		Short: "Capture and print incoming requests, such as webhooks",
+		Long: `Capture and print incoming requests, such as webhooks.
+Requests of any method to any path are accepted and recorded in full: headers, query, body,
+form fields and multipart parts. JSON bodies are pretty-printed.
+
+Senders that sign their requests can be verified with --hmac-secret:
+
+	oneshot capture --hmac-secret env:GITHUB_WEBHOOK_SECRET
+	oneshot capture --hmac-secret env:STRIPE_WEBHOOK_SECRET --hmac-header Stripe-Signature
+`,
+		RunE: c.setHandlerFunc,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if 0 < len(args) {
+				return output.UsageErrorF("capture takes no arguments")
+			}
+			return nil
+		},
+	}
+
+	c.cobraCommand.SetUsageTemplate(usageTemplate)
+	configuration.SetFlags(c.cobraCommand)
+
+	return c.cobraCommand
+}
+
+func (c *Cmd) setHandlerFunc(cmd *cobra.Command, args []string) error {
+	var ctx = cmd.Context()
+
+	output.IncludeBody(ctx)
+
+	c.verifier = newVerifier(c.config.Subcommands.Capture)
+
+	commands.SetHTTPHandlerFunc(ctx, c.ServeHTTP)
+	return nil
+}
+
+func (c *Cmd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var (
+		ctx    = c.cobraCommand.Context()
+		log    = zerolog.Ctx(ctx)
+		config = c.config.Subcommands.Capture
+	)
+
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+
+	requestEvent := events.NewHTTPRequest(r)
+	if 0 < len(body) {
+		requestEvent.Body = body
+		if err := parseForm(requestEvent, r.Header.Get("Content-Type"), body); err != nil {
+			log.Debug().Err(err).
+				Msg("unable to parse form body")
+		}
+	}
+	events.Raise(ctx, requestEvent)
+
+	if err != nil {
+		events.Raise(ctx, events.ClientDisconnected{Err: err})
+		return
+	}
+
+	if c.verifier != nil {
+		if err := c.verifier.verify(r.Header.Get(c.verifier.header), body); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			events.Raise(ctx, &events.HTTPResponse{
+				StatusCode: http.StatusUnauthorized,
+				Header:     w.Header().Clone(),
+			})
+			events.Raise(ctx, events.ClientDisconnected{Err: err})
+			return
+		}
+	}
+
+	var header = http.Header(config.Header.Inflate())
+	for key, values := range header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	if config.Body != "" {
+		w.Header().Set("Content-Length", strconv.Itoa(len(config.Body)))
+	}
+	w.WriteHeader(config.StatusCode)
+	if config.Body != "" {
+		if _, err := io.WriteString(w, config.Body); err != nil {
+			log.Error().Err(err).
+				Msg("error writing response")
+		}
+	}
+
+	responseEvent := events.HTTPResponse{
+		StatusCode: config.StatusCode,
+		Header:     w.Header().Clone(),
+	}
+	if config.Body != "" {
+		responseEvent.Body = []byte(config.Body)
+	}
+	events.Raise(ctx, &responseEvent)
+
+	c.captured++
+	if 0 < config.Count && config.Count <= c.captured {
+		events.Success(ctx)
+		return
+	}
+
+	// the request was captured but more are expected
+	w.(oneshothttp.ResponseWriter).IgnoreOutcome()
+	events.Raise(ctx, events.ClientDisconnected{})
+}
+
+// parseForm fills in the form fields and multipart parts of a url encoded or multipart body.
+func parseForm(e *events.HTTPRequest, contentType string, body []byte) error {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return err
+		}
+		e.Form = form
+	case strings.HasPrefix(mediaType, "multipart/"):
+		mr := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+		form := make(url.Values)
+		for {
+			p, err := mr.NextRawPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+
+			content, err := io.ReadAll(p)
+			if err != nil {
+				return err
+			}
+
+			part := events.HTTPRequestPart{
+				Name:     p.FormName(),
+				FileName: p.FileName(),
+				Header:   p.Header,
+				Size:     int64(len(content)),
+			}
+			if utf8.Valid(content) {
+				part.Content = string(content)
+			} else {
+				part.Content = content
+			}
+			e.Parts = append(e.Parts, &part)
+
+			if part.FileName == "" && part.Name != "" {
+				form.Add(part.Name, string(content))
+			}
+		}
+		if 0 < len(form) {
+			e.Form = form
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package configuration
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/flagargs"
+	"github.com/forestnode-io/oneshot/v2/pkg/flags"
+	"github.com/forestnode-io/oneshot/v2/pkg/secrets"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// supported hmac algorithms
+const (
+	HMACSHA1   = "sha1"
+	HMACSHA256 = "sha256"
+	HMACSHA512 = "sha512"
+)
+
+type Configuration struct {
+	StatusCode    int                 `mapstructure:"status" yaml:"status"`
+	Header        flagargs.HTTPHeader `mapstructure:"header" yaml:"header"`
+	Body          string              `mapstructure:"body" yaml:"body"`
+	Count         int                 `mapstructure:"count" yaml:"count"`
+	HMACSecret    string              `mapstructure:"hmacSecret" yaml:"hmacSecret" json:"-" secret:"true"`
+	HMACHeader    string              `mapstructure:"hmacHeader" yaml:"hmacHeader"`
+	HMACAlgorithm string              `mapstructure:"hmacAlgorithm" yaml:"hmacAlgorithm"`
+	HMACTolerance time.Duration       `mapstructure:"hmacTolerance" yaml:"hmacTolerance"`
+}
+
+func (c *Configuration) Validate() error {
+	if t := http.StatusText(c.StatusCode); t == "" {
+		return fmt.Errorf("invalid status code")
+	}
+	if c.Count < 0 {
+		return fmt.Errorf("invalid count: %d", c.Count)
+	}
+	if c.HMACSecret != "" {
+		switch strings.ToLower(c.HMACAlgorithm) {
+		case HMACSHA1, HMACSHA256, HMACSHA512:
+		default:
+			return fmt.Errorf("invalid hmac algorithm %q, must be one of %s, %s or %s", c.HMACAlgorithm, HMACSHA1, HMACSHA256, HMACSHA512)
+		}
+		if c.HMACHeader == "" {
+			return fmt.Errorf("hmac header required")
+		}
+		if c.HMACTolerance < 0 {
+			return fmt.Errorf("invalid hmac tolerance: %s", c.HMACTolerance)
+		}
+	}
+	return nil
+}
+
+func (c *Configuration) Hydrate() error {
+	c.HMACAlgorithm = strings.ToLower(c.HMACAlgorithm)
+	if c.HMACSecret == "" {
+		return nil
+	}
+
+	secret, err := secrets.Resolve(c.HMACSecret)
+	if err != nil {
+		return fmt.Errorf("failed to read hmac secret: %w", err)
+	}
+	c.HMACSecret = secret
+
+	return nil
+}
+
+func SetFlags(cmd *cobra.Command) {
+	fs := pflag.NewFlagSet("capture flags", pflag.ContinueOnError)
+	defer cmd.Flags().AddFlagSet(fs)
+
+	flags.Int(fs, "cmd.capture.status", "status-code", "HTTP status code to send to client.")
+	flags.StringSliceP(fs, "cmd.capture.header", "header", "H", `Header to send to client. Can be specified multiple times.
+Format: <HEADER NAME>=<HEADER VALUE>`)
+	flags.String(fs, "cmd.capture.body", "body", `Body to send to client.`)
+	flags.Int(fs, "cmd.capture.count", "count", `Number of requests to capture before oneshot exits.
+A value of 0 will cause oneshot to keep capturing until it is interrupted or times out.`)
+	flags.String(fs, "cmd.capture.hmacsecret", "hmac-secret", `Secret the sender signs request bodies with.
+Requests without a valid signature are rejected with a 401 and don't count as captured.
+`+secrets.ReferenceHelp("secret"))
+	flags.String(fs, "cmd.capture.hmacheader", "hmac-header", `Header holding the request signature.
+Signatures may be hex or base64 encoded and prefixed with the algorithm, like GitHub's sha256=<hex>.
+Stripe style t=<timestamp>,v1=<hex> signatures over <timestamp>.<body> are understood as well.`)
+	flags.String(fs, "cmd.capture.hmacalgorithm", "hmac-algorithm", `Hash algorithm of the signature, one of sha1, sha256 or sha512.`)
+	flags.Duration(fs, "cmd.capture.hmactolerance", "hmac-tolerance", `How far the timestamp of a Stripe style signature may be from now before the request is rejected.
+This keeps captured requests from being replayed later on. 0 accepts any timestamp.`)
+
+	cobra.AddTemplateFunc("captureFlags", func() *pflag.FlagSet {
+		return fs
+	})
+}
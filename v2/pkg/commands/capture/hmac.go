@@ -0,0 +1,155 @@
+package capture
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/commands/capture/configuration"
+)
+
+var (
+	errMissingSignature = errors.New("missing signature")
+	errInvalidSignature = errors.New("invalid signature")
+	errStaleSignature   = errors.New("signature timestamp is outside of the tolerance")
+)
+
+type verifier struct {
+	secret    []byte
+	algorithm string
+	header    string
+	newHash   func() hash.Hash
+	// tolerance is how far a signatures timestamp may be from now, 0 accepts any
+	tolerance time.Duration
+}
+
+func newVerifier(config *configuration.Configuration) *verifier {
+	if config.HMACSecret == "" {
+		return nil
+	}
+
+	v := verifier{
+		secret:    []byte(config.HMACSecret),
+		algorithm: config.HMACAlgorithm,
+		header:    config.HMACHeader,
+		tolerance: config.HMACTolerance,
+	}
+	switch config.HMACAlgorithm {
+	case configuration.HMACSHA1:
+		v.newHash = sha1.New
+	case configuration.HMACSHA512:
+		v.newHash = sha512.New
+	default:
+		v.newHash = sha256.New
+	}
+
+	return &v
+}
+
+// verify checks the signature given in value against body.
+// value may be a plain hex or base64 encoded mac, optionally prefixed with the algorithm
+// like GitHub's sha256=<hex>, or a Stripe style t=<timestamp>,v1=<hex>[,v1=<hex>...] list
+// where the mac covers <timestamp>.<body> and the timestamp must be within the tolerance of now.
+func (v *verifier) verify(value string, body []byte) error {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return errMissingSignature
+	}
+
+	if timestamp, sigs, ok := parseTimestamped(value); ok {
+		signed := make([]byte, 0, len(timestamp)+1+len(body))
+		signed = append(signed, timestamp...)
+		signed = append(signed, '.')
+		signed = append(signed, body...)
+
+		expected := v.mac(signed)
+		for _, sig := range sigs {
+			if got, err := hex.DecodeString(sig); err == nil && hmac.Equal(got, expected) {
+				return v.checkTimestamp(timestamp)
+			}
+		}
+		return errInvalidSignature
+	}
+
+	if algorithm, sig, ok := strings.Cut(value, "="); ok && isAlgorithm(algorithm) {
+		if !strings.EqualFold(algorithm, v.algorithm) {
+			return errInvalidSignature
+		}
+		value = sig
+	}
+
+	expected := v.mac(body)
+	for _, decode := range []func(string) ([]byte, error){
+		hex.DecodeString,
+		base64.StdEncoding.DecodeString,
+		base64.URLEncoding.DecodeString,
+		base64.RawURLEncoding.DecodeString,
+	} {
+		if got, err := decode(value); err == nil && hmac.Equal(got, expected) {
+			return nil
+		}
+	}
+
+	return errInvalidSignature
+}
+
+// checkTimestamp rejects unix timestamps too far in the past or future,
+// so that a captured signature can't be replayed later on.
+func (v *verifier) checkTimestamp(timestamp string) error {
+	if v.tolerance == 0 {
+		return nil
+	}
+	secs, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errInvalidSignature
+	}
+	skew := time.Since(time.Unix(secs, 0))
+	if skew < -v.tolerance || v.tolerance < skew {
+		return errStaleSignature
+	}
+	return nil
+}
+
+func (v *verifier) mac(data []byte) []byte {
+	m := hmac.New(v.newHash, v.secret)
+	m.Write(data)
+	return m.Sum(nil)
+}
+
+// parseTimestamped splits a t=<timestamp>,v1=<sig>,... signature list.
+func parseTimestamped(value string) (string, []string, bool) {
+	var (
+		timestamp string
+		sigs      []string
+	)
+	for _, item := range strings.Split(value, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok {
+			return "", nil, false
+		}
+		switch k {
+		case "t":
+			timestamp = v
+		case "v1":
+			sigs = append(sigs, v)
+		}
+	}
+
+	return timestamp, sigs, timestamp != "" && 0 < len(sigs)
+}
+
+func isAlgorithm(s string) bool {
+	switch strings.ToLower(s) {
+	case configuration.HMACSHA1, configuration.HMACSHA256, configuration.HMACSHA512:
+		return true
+	}
+	return false
+}
@@ -2,8 +2,12 @@ package config
 
 import (
 	"github.com/forestnode-io/oneshot/v2/pkg/commands/config/get"
+	"github.com/forestnode-io/oneshot/v2/pkg/commands/config/initialize"
+	"github.com/forestnode-io/oneshot/v2/pkg/commands/config/list"
 	"github.com/forestnode-io/oneshot/v2/pkg/commands/config/path"
 	"github.com/forestnode-io/oneshot/v2/pkg/commands/config/set"
+	"github.com/forestnode-io/oneshot/v2/pkg/commands/config/show"
+	"github.com/forestnode-io/oneshot/v2/pkg/commands/config/unset"
 	"github.com/forestnode-io/oneshot/v2/pkg/configuration"
 	"github.com/spf13/cobra"
 )
@@ -42,6 +46,10 @@ func subCommands(config *configuration.Root) []*cobra.Command {
 	return []*cobra.Command{
 		get.New(config).Cobra(),
 		set.New(config).Cobra(),
+		unset.New().Cobra(),
+		list.New().Cobra(),
 		path.New().Cobra(),
+		show.New(config).Cobra(),
+		initialize.New().Cobra(),
 	}
 }
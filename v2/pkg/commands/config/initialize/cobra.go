@@ -0,0 +1,216 @@
+package initialize
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/configuration"
+	"github.com/forestnode-io/oneshot/v2/pkg/output"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+func New() *Cmd {
+	return &Cmd{}
+}
+
+type Cmd struct {
+	cobraCommand *cobra.Command
+}
+
+func (c *Cmd) Cobra() *cobra.Command {
+	if c.cobraCommand != nil {
+		return c.cobraCommand
+	}
+
+	c.cobraCommand = &cobra.Command{
+		Use:   "init",
+		Short: "Interactively create a oneshot configuration file.",
+		Long: `Interactively create a oneshot configuration file.
+Asks for a discovery server, a default TLS certificate and basic authentication credentials
+and writes the answers to the configuration file.
+Values already in the file are offered as the defaults and everything else in the file is left alone.`,
+		RunE: c.run,
+		Args: cobra.NoArgs,
+	}
+
+	flags := c.cobraCommand.Flags()
+	flags.Bool("project", false, "Write to "+configuration.ProjectConfigName+" in the working directory instead of the user configuration file.")
+
+	c.cobraCommand.SetUsageTemplate(usageTemplate)
+
+	return c.cobraCommand
+}
+
+func (c *Cmd) run(cmd *cobra.Command, args []string) error {
+	path := configuration.ConfigPath()
+	if project, _ := cmd.Flags().GetBool("project"); project {
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		path = filepath.Join(wd, configuration.ProjectConfigName)
+	}
+
+	file, err := configuration.OpenFile(path)
+	if err != nil {
+		return output.UsageErrorF("%w", err)
+	}
+
+	p := prompter{
+		in:  bufio.NewReader(os.Stdin),
+		out: os.Stderr,
+		tty: term.IsTerminal(int(os.Stdin.Fd())),
+	}
+	fmt.Fprintf(p.out, "Configuring %s\nLeave an answer blank to keep the value in brackets.\n\n", path)
+
+	host, err := p.ask(file, "discovery.host", "Discovery server host, '-' for none", nil)
+	if err != nil {
+		return err
+	}
+	if host != "" {
+		if _, err := p.ask(file, "discovery.keypath", "Path to the discovery server key, '-' for none", fileExists); err != nil {
+			return err
+		}
+	}
+
+	cert, err := p.ask(file, "server.tlscert", "Path to the default TLS certificate, '-' to serve plain http", fileExists)
+	if err != nil {
+		return err
+	}
+	if cert != "" {
+		if _, err := p.ask(file, "server.tlskey", "Path to the TLS key", fileExists); err != nil {
+			return err
+		}
+	} else {
+		file.Unset("server.tlskey")
+	}
+
+	username, err := p.ask(file, "basicauth.username", "Basic authentication username, '-' for no authentication", nil)
+	if err != nil {
+		return err
+	}
+	if username != "" {
+		if err := p.askSecret(file, "basicauth.password", "Basic authentication password"); err != nil {
+			return err
+		}
+	} else {
+		file.Unset("basicauth.password")
+	}
+
+	if err := file.Validate(); err != nil {
+		return output.UsageErrorF("invalid configuration: %w", err)
+	}
+	if err := file.Write(); err != nil {
+		return output.UsageErrorF("failed to write configuration file: %w", err)
+	}
+	fmt.Fprintf(p.out, "\nWrote %s\n", path)
+
+	return nil
+}
+
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+	tty bool
+}
+
+// ask asks the question until it gets a valid answer, which is stored in the file under key.
+// A blank answer keeps the value already in the file and '-' removes it.
+// It returns the value the file ends up with.
+func (p *prompter) ask(file *configuration.File, key, question string, validate func(string) error) (string, error) {
+	current, _ := file.Get(key)
+	currentString, _ := current.(string)
+
+	for {
+		if currentString != "" {
+			fmt.Fprintf(p.out, "%s [%s]: ", question, currentString)
+		} else {
+			fmt.Fprintf(p.out, "%s: ", question)
+		}
+
+		answer, err := p.readLine()
+		if err != nil {
+			return "", err
+		}
+
+		switch answer {
+		case "":
+			return currentString, nil
+		case "-":
+			file.Unset(key)
+			return "", nil
+		}
+
+		if validate != nil {
+			if err := validate(answer); err != nil {
+				fmt.Fprintf(p.out, "%s\n", err)
+				if !p.tty {
+					return "", output.UsageErrorF("%w", err)
+				}
+				continue
+			}
+		}
+
+		file.Set(key, answer)
+		return answer, nil
+	}
+}
+
+// askSecret asks for a value without echoing it back when reading from a terminal.
+// A blank answer keeps the value already in the file.
+func (p *prompter) askSecret(file *configuration.File, key, question string) error {
+	current, _ := file.Get(key)
+	if current, _ := current.(string); current != "" {
+		fmt.Fprintf(p.out, "%s [keep current]: ", question)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", question)
+	}
+
+	var (
+		answer string
+		err    error
+	)
+	if p.tty {
+		var b []byte
+		b, err = term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(p.out)
+		answer = string(b)
+	} else {
+		answer, err = p.readLine()
+	}
+	if err != nil {
+		return err
+	}
+
+	if answer != "" {
+		file.Set(key, answer)
+	}
+	return nil
+}
+
+func (p *prompter) readLine() (string, error) {
+	line, err := p.in.ReadString('\n')
+	if err != nil {
+		if !errors.Is(err, io.EOF) || line == "" {
+			return "", output.UsageErrorF("no answer given: %w", err)
+		}
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func fileExists(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("unable to use %s: %w", path, err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("unable to use %s: is a directory", path)
+	}
+	return nil
+}
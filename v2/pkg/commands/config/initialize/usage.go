@@ -0,0 +1,8 @@
+package initialize
+
+const usageTemplate = `init options:
+{{ .LocalFlags | wrappedFlagUsages | trimTrailingWhitespaces }}
+
+Usage:
+  {{ .UseLine }}
+`
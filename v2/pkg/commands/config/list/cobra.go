@@ -0,0 +1,79 @@
+package list
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/configuration"
+	"github.com/spf13/cobra"
+)
+
+func New() *Cmd {
+	return &Cmd{}
+}
+
+type Cmd struct {
+	cobraCommand *cobra.Command
+}
+
+func (c *Cmd) Cobra() *cobra.Command {
+	if c.cobraCommand != nil {
+		return c.cobraCommand
+	}
+
+	c.cobraCommand = &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls", "keys"},
+		Short:   "List every configuration key.",
+		Long: `List every configuration key along with its type, default value,
+the environment variable that sets it and the flag that sets it.`,
+		RunE: c.run,
+		Args: cobra.NoArgs,
+	}
+
+	c.cobraCommand.SetUsageTemplate(usageTemplate)
+
+	return c.cobraCommand
+}
+
+func (c *Cmd) run(cmd *cobra.Command, args []string) error {
+	tw := tabwriter.NewWriter(os.Stdout, 4, 2, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tTYPE\tDEFAULT\tENV\tFLAG")
+	for _, key := range configuration.Keys() {
+		flag := "-"
+		if key.Flag != "" {
+			flag = "--" + key.Flag
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			key.Name,
+			key.TypeName(),
+			formatDefault(key.Default),
+			key.Env,
+			flag,
+		)
+	}
+	return tw.Flush()
+}
+
+func formatDefault(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "-"
+	case string:
+		if v == "" {
+			return `""`
+		}
+		return v
+	case []byte:
+		if len(v) == 0 {
+			return "[]"
+		}
+		return string(v)
+	case map[string][]string:
+		if len(v) == 0 {
+			return "{}"
+		}
+	}
+	return fmt.Sprintf("%v", v)
+}
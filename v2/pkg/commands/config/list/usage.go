@@ -0,0 +1,8 @@
+package list
+
+const usageTemplate = `list options:
+{{ .LocalFlags | wrappedFlagUsages | trimTrailingWhitespaces }}
+
+Usage:
+  {{ .UseLine }}
+`
@@ -1,13 +1,9 @@
 package set
 
 import (
-	"strconv"
-	"strings"
-
 	"github.com/forestnode-io/oneshot/v2/pkg/configuration"
 	"github.com/forestnode-io/oneshot/v2/pkg/output"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 func New(config *configuration.Root) *Cmd {
@@ -29,9 +25,13 @@ func (c *Cmd) Cobra() *cobra.Command {
 	c.cobraCommand = &cobra.Command{
 		Use:   "set path value...",
 		Short: "Set an individual value in a oneshot configuration file.",
-		Long:  "Set an individual value in a oneshot configuration file.",
-		RunE:  c.run,
-		Args:  cobra.MinimumNArgs(2),
+		Long: `Set an individual value in a oneshot configuration file.
+The value is checked against the type of the key and the file is only written if the resulting configuration is valid.
+List values may be given as several arguments or a single comma separated argument,
+header values are given as name=value arguments.
+Run 'oneshot config list' to see every key and its type.`,
+		RunE: c.run,
+		Args: cobra.MinimumNArgs(2),
 	}
 
 	c.cobraCommand.SetUsageTemplate(usageTemplate)
@@ -40,53 +40,25 @@ func (c *Cmd) Cobra() *cobra.Command {
 }
 
 func (c *Cmd) run(cmd *cobra.Command, args []string) error {
-	if configuration.ConfigPath() == "" {
-		return output.UsageErrorF("no configuration file found")
-	}
-
-	v := viper.Get(args[0])
-	if v == nil {
+	key, ok := configuration.LookupKey(args[0])
+	if !ok {
 		return output.UsageErrorF("no such key: %s", args[0])
 	}
-	switch v.(type) {
-	case string:
-		viper.Set(args[0], args[1])
-	case int:
-		x, err := strconv.Atoi(args[1])
-		if err != nil {
-			return output.UsageErrorF("failed to convert value to int: %w", err)
-		}
-		viper.Set(args[0], x)
-	case bool:
-		x, err := strconv.ParseBool(args[1])
-		if err != nil {
-			return output.UsageErrorF("failed to convert value to bool: %w", err)
-		}
-		viper.Set(args[0], x)
-	case []string:
-		if 3 <= len(args) {
-			viper.Set(args[0], args[1:])
-		} else {
-			viper.Set(args[0], strings.Split(args[1], ","))
-		}
-	case []int:
-		if 3 <= len(args) {
-			ints := make([]int, len(args)-1)
-			var err error
-			for i := range args[1:] {
-				ints[i], err = strconv.Atoi(args[i+1])
-				if err != nil {
-					return output.UsageErrorF("failed to convert value to int: %w", err)
-				}
-			}
-			viper.Set(args[0], ints)
-		}
-	default:
-		return output.UsageErrorF("unsupported type: %T", v)
+	value, err := key.Parse(args[1:])
+	if err != nil {
+		return output.UsageErrorF("%w", err)
 	}
 
-	err := viper.WriteConfig()
+	file, err := configuration.OpenFile(configuration.ConfigPath())
 	if err != nil {
+		return output.UsageErrorF("%w", err)
+	}
+	file.Set(key.Name, value)
+	if err := file.Validate(); err != nil {
+		return output.UsageErrorF("invalid value for %s: %w", key.Name, err)
+	}
+
+	if err := file.Write(); err != nil {
 		return output.UsageErrorF("failed to write configuration file: %w", err)
 	}
 
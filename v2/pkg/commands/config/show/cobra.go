@@ -0,0 +1,133 @@
+package show
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/configuration"
+	"github.com/forestnode-io/oneshot/v2/pkg/output"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+func New(config *configuration.Root) *Cmd {
+	return &Cmd{
+		config: config,
+	}
+}
+
+type Cmd struct {
+	cobraCommand *cobra.Command
+	config       *configuration.Root
+}
+
+func (c *Cmd) Cobra() *cobra.Command {
+	if c.cobraCommand != nil {
+		return c.cobraCommand
+	}
+
+	c.cobraCommand = &cobra.Command{
+		Use:   "show",
+		Short: "Show the oneshot configuration.",
+		Long: `Show the oneshot configuration.
+By default the contents of the user configuration file are shown.
+With --effective, the configuration oneshot runs with is shown instead,
+with the source of each value next to it.`,
+		RunE: c.run,
+		Args: cobra.NoArgs,
+	}
+
+	c.cobraCommand.Flags().Bool("effective", false, `Show the configuration after merging the configuration files, profile, environment and flags.
+Each value is followed by a comment saying where it came from.`)
+
+	c.cobraCommand.SetUsageTemplate(usageTemplate)
+
+	return c.cobraCommand
+}
+
+func (c *Cmd) run(cmd *cobra.Command, args []string) error {
+	effective, _ := cmd.Flags().GetBool("effective")
+	if !effective {
+		return showConfigFile()
+	}
+
+	var node yaml.Node
+	if err := node.Encode(c.config); err != nil {
+		return fmt.Errorf("failed to encode configuration: %w", err)
+	}
+
+	known := map[string]struct{}{}
+	for _, key := range viper.AllKeys() {
+		known[key] = struct{}{}
+	}
+	annotate(&node, "", known)
+
+	var header []string
+	for _, l := range configuration.Layers() {
+		header = append(header, "from "+l.Source)
+	}
+	if p := configuration.Profile(); p != "" {
+		header = append(header, "profile "+p)
+	}
+	node.HeadComment = strings.Join(header, "\n")
+
+	enc := yaml.NewEncoder(os.Stdout)
+	enc.SetIndent(2)
+	defer enc.Close()
+	return enc.Encode(&node)
+}
+
+func showConfigFile() error {
+	path := configuration.ConfigPath()
+	if path == "" {
+		return output.UsageErrorF("no configuration file found")
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return output.UsageErrorF("failed to read configuration file: %w", err)
+	}
+	_, err = os.Stdout.Write(content)
+	return err
+}
+
+// annotate comments each value in the mapping node n with where it came from.
+// Keys are matched case insensitively against the configuration keys, same as viper.
+func annotate(n *yaml.Node, path string, known map[string]struct{}) {
+	if n.Kind == yaml.DocumentNode {
+		for _, c := range n.Content {
+			annotate(c, path, known)
+		}
+		return
+	}
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		key := strings.ToLower(k.Value)
+		if path != "" {
+			key = path + "." + key
+		}
+
+		if _, ok := known[key]; !ok && v.Kind == yaml.MappingNode && 0 < len(v.Content) {
+			annotate(v, key, known)
+			continue
+		}
+
+		// secrets are shown as configured so that resolved references never are
+		if ck, ok := configuration.LookupKey(key); ok && ck.Secret && v.Kind == yaml.ScalarNode {
+			v.Value = viper.GetString(key)
+			v.Style = 0
+		}
+
+		source := configuration.Source(key)
+		if v.Kind == yaml.ScalarNode || len(v.Content) == 0 {
+			v.LineComment = source
+		} else {
+			k.LineComment = source
+		}
+	}
+}
@@ -0,0 +1,8 @@
+package show
+
+const usageTemplate = `show options:
+{{ .LocalFlags | wrappedFlagUsages | trimTrailingWhitespaces }}
+
+Usage:
+  {{ .UseLine }}
+`
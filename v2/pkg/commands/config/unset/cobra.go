@@ -0,0 +1,53 @@
+package unset
+
+import (
+	"github.com/forestnode-io/oneshot/v2/pkg/configuration"
+	"github.com/forestnode-io/oneshot/v2/pkg/output"
+	"github.com/spf13/cobra"
+)
+
+func New() *Cmd {
+	return &Cmd{}
+}
+
+type Cmd struct {
+	cobraCommand *cobra.Command
+}
+
+func (c *Cmd) Cobra() *cobra.Command {
+	if c.cobraCommand != nil {
+		return c.cobraCommand
+	}
+
+	c.cobraCommand = &cobra.Command{
+		Use:   "unset path",
+		Short: "Remove an individual value from a oneshot configuration file.",
+		Long: `Remove an individual value from a oneshot configuration file.
+The key goes back to its default value, or whatever a profile, environment variable or flag sets it to.`,
+		RunE: c.run,
+		Args: cobra.ExactArgs(1),
+	}
+
+	c.cobraCommand.SetUsageTemplate(usageTemplate)
+
+	return c.cobraCommand
+}
+
+func (c *Cmd) run(cmd *cobra.Command, args []string) error {
+	file, err := configuration.OpenFile(configuration.ConfigPath())
+	if err != nil {
+		return output.UsageErrorF("%w", err)
+	}
+	if !file.Unset(args[0]) {
+		return output.UsageErrorF("%s is not set in %s", args[0], file.Path())
+	}
+	if err := file.Validate(); err != nil {
+		return output.UsageErrorF("unable to unset %s: %w", args[0], err)
+	}
+
+	if err := file.Write(); err != nil {
+		return output.UsageErrorF("failed to write configuration file: %w", err)
+	}
+
+	return nil
+}
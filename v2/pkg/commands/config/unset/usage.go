@@ -0,0 +1,8 @@
+package unset
+
+const usageTemplate = `unset options:
+{{ .LocalFlags | wrappedFlagUsages | trimTrailingWhitespaces }}
+
+Usage:
+  {{ .UseLine }}
+`
@@ -2,7 +2,8 @@ package configuration
 
 import (
 	"fmt"
-	"os"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/secrets"
 )
 
 type Configuration struct {
@@ -34,24 +35,32 @@ func (c *Configuration) Hydrate() error {
 }
 
 type Secret struct {
-	Path  string `mapstructure:"path" yaml:"path"`
-	Value string `mapstructure:"value" yaml:"value"`
+	Path  string `mapstructure:"path" yaml:"path" secret:"true"`
+	Value string `mapstructure:"value" yaml:"value" json:"-" secret:"true"`
 }
 
 func (s *Secret) hydrate() error {
+	if s == nil {
+		return nil
+	}
 	if s.Value != "" {
+		value, err := secrets.Resolve(s.Value)
+		if err != nil {
+			return fmt.Errorf("failed to read secret: %w", err)
+		}
+		s.Value = value
 		return nil
 	}
 	if s.Path == "" {
 		return nil
 	}
 
-	data, err := os.ReadFile(s.Path)
+	value, err := secrets.ResolvePath(s.Path)
 	if err != nil {
 		return fmt.Errorf("failed to read secret from file: %w", err)
 	}
 
-	s.Value = string(data)
+	s.Value = value
 
 	return nil
 }
@@ -1,8 +1,8 @@
 package discoveryserver
 
 import (
-	"bytes"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -16,7 +16,9 @@ import (
 	"github.com/google/uuid"
 	"github.com/forestnode-io/oneshot/v2/pkg/commands/discovery-server/template"
 	"github.com/forestnode-io/oneshot/v2/pkg/log"
+	oneshothttp "github.com/forestnode-io/oneshot/v2/pkg/net/http"
 	"github.com/forestnode-io/oneshot/v2/pkg/net/webrtc/sdp"
+	"github.com/forestnode-io/oneshot/v2/pkg/net/webrtc/signallingserver/messages"
 	"github.com/rs/zerolog"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -47,6 +49,11 @@ func (s *server) handleHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	addrString := strings.TrimSuffix(addrURL.String(), "/")
 
+	if relay := s.relayFor(addrString); relay != nil {
+		relay.ServeHTTP(w, r)
+		return
+	}
+
 	if s.assignedURL == "" || s.assignedURL != addrString || s.os == nil {
 		s.error(w, r, http.StatusNotFound,
 			"No pending oneshot found",
@@ -91,10 +98,17 @@ func (s *server) handleGET_HTML(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// user is handed to the oneshot server along with the session,
+	// which enforces their permissions on every request they make
+	var user string
 	if ba := s.os.Arrival.BasicAuth; ba != nil {
 		log.Debug().Msg("checking basic auth")
 
-		user, pass, ok := r.BasicAuth()
+		var (
+			pass string
+			ok   bool
+		)
+		user, pass, ok = r.BasicAuth()
 		if !ok {
 			w.Header().Set("WWW-Authenticate", `Basic realm="oneshot"`)
 			http.Error(w, "unauthorized", http.StatusUnauthorized)
@@ -103,28 +117,20 @@ func (s *server) handleGET_HTML(w http.ResponseWriter, r *http.Request) {
 
 		log.Debug().Msg("request contained basic auth credentials")
 
-		uHash := sha256.Sum256([]byte(user))
-		if !bytes.Equal(uHash[:], ba.UsernameHash) {
-			w.Header().Set("WWW-Authenticate", `Basic realm="oneshot"`)
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
-			return
-		}
-
-		log.Debug().Msg("username hash matched")
-
-		if bcrypt.CompareHashAndPassword(ba.PasswordHash, []byte(pass)) != nil {
+		if !authenticate(ba, user, pass) {
 			w.Header().Set("WWW-Authenticate", `Basic realm="oneshot"`)
 			http.Error(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		log.Debug().Msg("password hash matched")
+		log.Debug().Msg("credentials matched")
 	}
 
 	sessionID := uuid.NewString()
 	expirationTime := time.Now().Add(10 * time.Second)
 	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"session_id": sessionID,
+		"user":       user,
 		"expires":    expirationTime.Unix(),
 	}).SignedString([]byte(config.JWT.Value))
 	if err != nil {
@@ -300,7 +306,10 @@ func (s *server) handleAcceptJSON_GET(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	done, err := s.queueRequest(sessionID, w, r)
+	// the user claim is absent when no credentials are needed
+	user, _ := claims["user"].(string)
+
+	done, err := s.queueRequest(sessionID, user, w, r)
 	if err != nil {
 		log.Error().Err(err).
 			Msg("error queueing request")
@@ -389,7 +398,7 @@ func (s *server) worker() {
 			s.pendingSessionID = sessionID
 
 			ctx := r.Context()
-			offer, err := s.os.RequestOffer(ctx, sessionID, s.rtcConfig)
+			offer, err := s.os.RequestOffer(ctx, sessionID, bundle.user, s.rtcConfig)
 			if err != nil {
 				log.Error().Err(err).
 					Str("session_id", sessionID).
@@ -476,3 +485,33 @@ func (s *server) error(w http.ResponseWriter, r *http.Request, status int, title
 		}
 	}
 }
+
+// authenticate checks user and pass against the credentials the oneshot forwarded.
+// Username hashes are compared in constant time and every password hash is
+// either bcrypt or, for htpasswd users, bcrypt or argon2.
+// A password hash is checked whether or not the username matches so that
+// unknown users take just as long to turn away.
+// Permissions are not checked here, the oneshot server checks them against
+// the authenticated user on every request made over the session.
+func authenticate(ba *messages.BasicAuth, user, pass string) bool {
+	uHash := sha256.Sum256([]byte(user))
+
+	if len(ba.Users) == 0 {
+		uOK := subtle.ConstantTimeCompare(uHash[:], ba.UsernameHash) == 1
+		pOK := bcrypt.CompareHashAndPassword(ba.PasswordHash, []byte(pass)) == nil
+		return uOK && pOK
+	}
+
+	var match *messages.BasicAuthUser
+	for _, u := range ba.Users {
+		if subtle.ConstantTimeCompare(uHash[:], u.UsernameHash) == 1 {
+			match = u
+		}
+	}
+	if match == nil {
+		// checking against a real hash costs the same as checking a known user
+		_ = oneshothttp.VerifyPassword(string(ba.Users[0].PasswordHash), pass)
+		return false
+	}
+	return oneshothttp.VerifyPassword(string(match.PasswordHash), pass)
+}
@@ -2,6 +2,7 @@ package discoveryserver
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
 	"net"
 	"net/url"
@@ -32,26 +33,17 @@ type oneshotServer struct {
 	stream proto.SignallingServer_ConnectServer
 }
 
-func newOneshotServer(ctx context.Context, requiredID string, stream proto.SignallingServer_ConnectServer, resetPending func(), requestURL func(string, bool) (string, error)) (*oneshotServer, error) {
-	var (
-		log   = zerolog.Ctx(ctx)
-		md, _ = metadata.FromIncomingContext(ctx)
-		o     = oneshotServer{
-			done:         make(chan struct{}),
-			stream:       stream,
-			msgChan:      make(chan messages.Message, 1),
-			errChan:      make(chan error, 1),
-			resetPending: resetPending,
-		}
-	)
+// handshake exchanges version info with the oneshot server on the other end of stream
+// and makes sure it presented the required id, if there is one.
+func handshake(ctx context.Context, requiredID string, stream proto.SignallingServer_ConnectServer) error {
+	log := zerolog.Ctx(ctx)
 
-	// exchange version info
 	handshake, err := receive[*messages.Handshake](stream)
 	if err != nil {
-		return nil, fmt.Errorf("unable to read handshake: %w", err)
+		return fmt.Errorf("unable to read handshake: %w", err)
 	}
 	if handshake.Error != "" {
-		return nil, fmt.Errorf("error from remote: %s", handshake.Error)
+		return fmt.Errorf("error from remote: %s", handshake.Error)
 	}
 
 	log.Info().
@@ -68,18 +60,19 @@ func newOneshotServer(ctx context.Context, requiredID string, stream proto.Signa
 		},
 	}
 
-	if responseHandshake.ID != requiredID && requiredID != "" {
+	// the id the oneshot server presented is the key it was given
+	if requiredID != "" && subtle.ConstantTimeCompare([]byte(handshake.ID), []byte(requiredID)) != 1 {
 		responseHandshake.Error = "unauthorized"
 		if err := send(stream, &responseHandshake); err != nil {
 			log.Error().Err(err).
 				Msg("unable to write handshake")
 		}
 
-		return nil, fmt.Errorf("invalid id")
+		return fmt.Errorf("invalid id")
 	}
 
 	if err = send(stream, &responseHandshake); err != nil {
-		return nil, fmt.Errorf("unable to write handshake: %w", err)
+		return fmt.Errorf("unable to write handshake: %w", err)
 	}
 
 	log.Debug().
@@ -88,11 +81,22 @@ func newOneshotServer(ctx context.Context, requiredID string, stream proto.Signa
 		Str("id", responseHandshake.ID).
 		Msg("sent handshake")
 
-	// grab the arrival request and store it
-	arrival, err := receive[*messages.ServerArrivalRequest](stream)
-	if err != nil {
-		return nil, fmt.Errorf("unable to read arrival request: %w", err)
-	}
+	return nil
+}
+
+func newOneshotServer(ctx context.Context, arrival *messages.ServerArrivalRequest, stream proto.SignallingServer_ConnectServer, resetPending func(), requestURL func(string, bool) (string, error)) (*oneshotServer, error) {
+	var (
+		log   = zerolog.Ctx(ctx)
+		md, _ = metadata.FromIncomingContext(ctx)
+		o     = oneshotServer{
+			done:         make(chan struct{}),
+			stream:       stream,
+			msgChan:      make(chan messages.Message, 1),
+			errChan:      make(chan error, 1),
+			resetPending: resetPending,
+		}
+		err error
+	)
 
 	if arrival.Redirect != "" {
 		// make sure the redirect url is valid
@@ -169,10 +173,11 @@ func newOneshotServer(ctx context.Context, requiredID string, stream proto.Signa
 	return &o, nil
 }
 
-func (o *oneshotServer) RequestOffer(ctx context.Context, sessionID string, conf *pionwebrtc.Configuration) (sdp.Offer, error) {
+func (o *oneshotServer) RequestOffer(ctx context.Context, sessionID, user string, conf *pionwebrtc.Configuration) (sdp.Offer, error) {
 	req := messages.GetOfferRequest{
 		SessionID:     sessionID,
 		Configuration: conf,
+		User:          user,
 	}
 
 	if err := send(o.stream, &req); err != nil {
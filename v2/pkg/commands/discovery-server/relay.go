@@ -0,0 +1,263 @@
+package discoveryserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/net/webrtc/signallingserver/messages"
+	"github.com/forestnode-io/oneshot/v2/pkg/net/webrtc/signallingserver/proto"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+// relayBufferSize is the most a relayed connection sends to oneshot in a single message.
+const relayBufferSize = 32 * 1024
+
+type remoteAddrKey struct{}
+
+// relay carries the requests made to its url over the stream of the oneshot server that asked for it.
+// Each request is proxied to oneshot over a relayed connection of its own,
+// oneshot doesn't need to be reachable from outside.
+type relay struct {
+	url    string
+	stream proto.SignallingServer_ConnectServer
+	proxy  *httputil.ReverseProxy
+
+	sendMu sync.Mutex
+
+	mu     sync.Mutex
+	conns  map[string]net.Conn
+	nextID uint64
+	closed bool
+}
+
+func newRelay(ctx context.Context, url string, stream proto.SignallingServer_ConnectServer) *relay {
+	r := relay{
+		url:    url,
+		stream: stream,
+		conns:  make(map[string]net.Conn),
+	}
+	r.proxy = &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL.Scheme = "http"
+			pr.Out.URL.Host = pr.In.Host
+			pr.Out.Host = pr.In.Host
+			pr.SetXForwarded()
+		},
+		Transport: &http.Transport{
+			DialContext: r.dial,
+			// every request gets a connection of its own so that oneshot knows who made it
+			DisableKeepAlives: true,
+		},
+		// stream responses to the client as oneshot writes them
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, req *http.Request, err error) {
+			zerolog.Ctx(ctx).Error().Err(err).
+				Str("url", url).
+				Msg("error relaying request")
+			w.WriteHeader(http.StatusBadGateway)
+		},
+	}
+	return &r
+}
+
+func (r *relay) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	ctx := context.WithValue(req.Context(), remoteAddrKey{}, req.RemoteAddr)
+	r.proxy.ServeHTTP(w, req.WithContext(ctx))
+}
+
+// serve hands what oneshot sends over the stream to the relayed connections until the stream is closed.
+func (r *relay) serve() {
+	defer r.close()
+	for {
+		m, err := receive[messages.Message](r.stream)
+		if err != nil {
+			return
+		}
+
+		switch m := m.(type) {
+		case *messages.RelayData:
+			r.mu.Lock()
+			conn := r.conns[m.ConnectionID]
+			r.mu.Unlock()
+			if conn != nil {
+				_, _ = conn.Write(m.Data)
+			}
+		case *messages.RelayClose:
+			if conn := r.remove(m.ConnectionID); conn != nil {
+				conn.Close()
+			}
+		}
+	}
+}
+
+// dial opens a new relayed connection to oneshot.
+func (r *relay) dial(ctx context.Context, _, _ string) (net.Conn, error) {
+	client, conn := net.Pipe()
+
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil, net.ErrClosed
+	}
+	r.nextID++
+	id := strconv.FormatUint(r.nextID, 10)
+	r.conns[id] = conn
+	r.mu.Unlock()
+
+	remoteAddr, _ := ctx.Value(remoteAddrKey{}).(string)
+	if err := r.send(&messages.RelayOpen{ConnectionID: id, RemoteAddr: remoteAddr}); err != nil {
+		r.remove(id)
+		conn.Close()
+		return nil, fmt.Errorf("unable to open relayed connection: %w", err)
+	}
+	go r.pump(id, conn)
+
+	return client, nil
+}
+
+// pump sends what is written to the relayed connection with id to oneshot until either end closes it.
+func (r *relay) pump(id string, conn net.Conn) {
+	buf := make([]byte, relayBufferSize)
+	for {
+		n, err := conn.Read(buf)
+		if 0 < n {
+			data := append([]byte(nil), buf[:n]...)
+			if serr := r.send(&messages.RelayData{ConnectionID: id, Data: data}); serr != nil {
+				err = serr
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	conn.Close()
+	// oneshot only needs to be told if it didn't close the connection itself
+	if r.remove(id) != nil {
+		_ = r.send(&messages.RelayClose{ConnectionID: id})
+	}
+}
+
+func (r *relay) send(m messages.Message) error {
+	r.sendMu.Lock()
+	defer r.sendMu.Unlock()
+	return send(r.stream, m)
+}
+
+// remove forgets the relayed connection with id and returns it, if it was still open.
+func (r *relay) remove(id string) net.Conn {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	conn := r.conns[id]
+	delete(r.conns, id)
+	return conn
+}
+
+func (r *relay) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	for id, conn := range r.conns {
+		conn.Close()
+		delete(r.conns, id)
+	}
+}
+
+// serveRelay relays the requests made to a url assigned to the oneshot server on the other end of stream
+// until the stream is closed.
+func (s *server) serveRelay(ctx context.Context, stream proto.SignallingServer_ConnectServer, rr *messages.RelayRequest) error {
+	log := zerolog.Ctx(ctx)
+
+	s.mu.Lock()
+	rurl, err := s.relayURL(rr.URL)
+	if err != nil {
+		s.mu.Unlock()
+		log.Error().Err(err).
+			Msg("unable to assign relay url")
+		return send(stream, &messages.RelayResponse{Error: err.Error()})
+	}
+	r := newRelay(ctx, rurl, stream)
+	s.relays[rurl] = r
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.relays, rurl)
+		s.mu.Unlock()
+		log.Info().
+			Str("url", rurl).
+			Msg("relay closed")
+	}()
+
+	if err := send(stream, &messages.RelayResponse{URL: rurl}); err != nil {
+		return fmt.Errorf("unable to write relay response: %w", err)
+	}
+
+	log.Info().
+		Str("url", rurl).
+		Msg("relaying")
+
+	r.serve()
+
+	return nil
+}
+
+// relayURL assigns a url to a new relay, s.mu must be held.
+// Unless another url is requested, each relay is given a path of its own
+// so that it doesn't get in the way of other relays or an arriving oneshot.
+func (s *server) relayURL(req *messages.SessionURLRequest) (string, error) {
+	var (
+		config   = s.config.Subcommands.DiscoveryServer
+		uaConfig = config.URLAssignment
+		u        = url.URL{
+			Scheme: s.scheme,
+			Host:   uaConfig.Domain + fmt.Sprintf(":%d", uaConfig.Port),
+		}
+		taken = func(rurl string) bool {
+			_, ok := s.relays[rurl]
+			return ok || rurl == s.assignedURL
+		}
+	)
+
+	if req != nil && req.URL != "" {
+		ru, err := url.Parse(req.URL)
+		if err != nil {
+			return "", fmt.Errorf("invalid url: %w", err)
+		}
+		u.Path = path.Join(uaConfig.PathPrefix, ru.Path)
+		rurl := strings.TrimSuffix(u.String(), "/")
+
+		available := !taken(rurl)
+		if req.Required && (!available || rurl != strings.TrimSuffix(req.URL, "/")) {
+			return "", errors.New("requested url is not available")
+		}
+		if available {
+			return rurl, nil
+		}
+	}
+
+	u.Path = path.Join(uaConfig.PathPrefix, uaConfig.Path, uuid.NewString())
+	return u.String(), nil
+}
+
+// relayFor returns the relay that requests to addr are made to, if any.
+func (s *server) relayFor(addr string) *relay {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for rurl, r := range s.relays {
+		if addr == rurl || strings.HasPrefix(addr, rurl+"/") {
+			return r
+		}
+	}
+	return nil
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/forestnode-io/oneshot/v2/pkg/configuration"
 	"github.com/forestnode-io/oneshot/v2/pkg/events"
 	"github.com/forestnode-io/oneshot/v2/pkg/log"
+	"github.com/forestnode-io/oneshot/v2/pkg/net/webrtc/signallingserver/messages"
 	"github.com/forestnode-io/oneshot/v2/pkg/net/webrtc/signallingserver/proto"
 	"github.com/forestnode-io/oneshot/v2/pkg/output"
 	oneshotfmt "github.com/forestnode-io/oneshot/v2/pkg/output/fmt"
@@ -42,7 +43,9 @@ type requestBundle struct {
 	w         http.ResponseWriter
 	r         *http.Request
 	sessionID string
-	done      chan struct{}
+	// user is who the client authenticated as, if anyone
+	user string
+	done chan struct{}
 }
 
 type server struct {
@@ -59,6 +62,8 @@ type server struct {
 
 	queue chan requestBundle
 	mu    sync.Mutex
+	// relays are keyed by their url and guarded by mu
+	relays map[string]*relay
 
 	proto.UnimplementedSignallingServerServer
 }
@@ -81,6 +86,7 @@ func newServer(c *configuration.Root) (*server, error) {
 
 	s := server{
 		queue:     make(chan requestBundle, config.MaxClientQueueSize),
+		relays:    make(map[string]*relay),
 		rtcConfig: rc,
 		config:    c,
 		scheme:    config.URLAssignment.Scheme,
@@ -219,7 +225,7 @@ func (s *server) run(ctx context.Context) error {
 	return nil
 }
 
-func (s *server) queueRequest(sessionID string, w http.ResponseWriter, r *http.Request) (<-chan struct{}, error) {
+func (s *server) queueRequest(sessionID, user string, w http.ResponseWriter, r *http.Request) (<-chan struct{}, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -236,6 +242,7 @@ func (s *server) queueRequest(sessionID string, w http.ResponseWriter, r *http.R
 		w:         w,
 		r:         r,
 		sessionID: sessionID,
+		user:      user,
 		done:      done,
 	}
 
@@ -289,6 +296,30 @@ func (s *server) Connect(stream proto.SignallingServer_ConnectServer) error {
 
 	log.Debug().Msg("new connection")
 
+	if err := handshake(ctx, config.RequiredKey.Value, stream); err != nil {
+		log.Error().Err(err).
+			Msg("error during handshake")
+		return err
+	}
+
+	// oneshot either arrives or asks for its connections to be relayed
+	m, err := receive[messages.Message](stream)
+	if err != nil {
+		log.Error().Err(err).
+			Msg("error reading arrival request")
+		return err
+	}
+	if rr, ok := m.(*messages.RelayRequest); ok {
+		return s.serveRelay(ctx, stream, rr)
+	}
+	arrival, ok := m.(*messages.ServerArrivalRequest)
+	if !ok {
+		err = fmt.Errorf("invalid message type, expected %T or %T, got %T", arrival, &messages.RelayRequest{}, m)
+		log.Error().Err(err).
+			Msg("error reading arrival request")
+		return err
+	}
+
 	if s.os != nil {
 		log.Debug().
 			Msg("got connection while another is in progress")
@@ -296,15 +327,12 @@ func (s *server) Connect(stream proto.SignallingServer_ConnectServer) error {
 		return errors.New("already connected")
 	}
 
-	var (
-		resetPending = func() {
-			s.mu.Lock()
-			defer s.mu.Unlock()
-			s.pendingSessionID = ""
-		}
-		err error
-	)
-	s.os, err = newOneshotServer(ctx, config.RequiredKey.Value, stream, resetPending, s.handleURLRequest)
+	resetPending := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.pendingSessionID = ""
+	}
+	s.os, err = newOneshotServer(ctx, arrival, stream, resetPending, s.handleURLRequest)
 	if err != nil {
 		log.Error().Err(err).
 			Msg("error creating oneshot server")
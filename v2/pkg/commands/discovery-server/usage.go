@@ -16,6 +16,9 @@ NAT Traversal options:
 {{ "P2P options:" | indent 2 }}
 {{ "--p2p-webrtc-config-file string   Path to the configuration file for the underlying WebRTC transport." | indent 4 }}
 
+Configuration options:
+{{ configFlags | wrappedFlagUsages | trimTrailingWhitespaces }}
+
 Usage:
   {{.UseLine}}
 `
@@ -0,0 +1,222 @@
+package get
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/commands/get/configuration"
+	rootconfig "github.com/forestnode-io/oneshot/v2/pkg/configuration"
+	"github.com/forestnode-io/oneshot/v2/pkg/events"
+	"github.com/forestnode-io/oneshot/v2/pkg/file"
+	oneshothttp "github.com/forestnode-io/oneshot/v2/pkg/net/http"
+	"github.com/forestnode-io/oneshot/v2/pkg/output"
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+)
+
+func New(config *rootconfig.Root) *Cmd {
+	return &Cmd{
+		config: config,
+	}
+}
+
+type Cmd struct {
+	cobraCommand *cobra.Command
+	config       *rootconfig.Root
+}
+
+func (c *Cmd) Cobra() *cobra.Command {
+	if c.cobraCommand != nil {
+		return c.cobraCommand
+	}
+
+	c.cobraCommand = &cobra.Command{
+		Use:   "get url [file]",
+		Short: "Download from a oneshot server",
+		Long: `Download from a oneshot server. If file is not specified, the content will be sent to stdout.
+
+The name and type of the downloaded file are taken from the servers response headers.
+If the server is a discovery server that is redirecting clients, get will follow the redirect to the oneshot server.
+Credentials given with the basic authentication flags, and Authorization or Cookie headers given with --header,
+are only sent to the host in the url. They are left out when a redirect leads to another host,
+so to download from a password protected oneshot that a discovery server redirects to, use the oneshots own url.
+
+If the --extract flag is given and the downloaded file is an archive, the archive will be extracted into the given directory.
+`,
+		RunE: c.get,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return output.UsageErrorF("url required")
+			}
+			if 2 < len(args) {
+				return output.UsageErrorF("too many arguments")
+			}
+			return nil
+		},
+	}
+
+	c.cobraCommand.SetUsageTemplate(usageTemplate)
+	configuration.SetFlags(c.cobraCommand)
+
+	return c.cobraCommand
+}
+
+func (c *Cmd) get(cmd *cobra.Command, args []string) error {
+	var (
+		ctx = cmd.Context()
+		log = zerolog.Ctx(ctx)
+
+		config   = c.config.Subcommands.Get
+		baConfig = c.config.BasicAuth
+		header   = http.Header(config.Header.Inflate())
+
+		location string
+		req      *http.Request
+	)
+
+	if 1 < len(args) {
+		if config.Extract != "" {
+			return output.UsageErrorF("a file may not be given when extracting")
+		}
+		location = args[1]
+	}
+
+	client := oneshothttp.NewClient()
+	client.Username = baConfig.Username
+	client.Password = baConfig.Password
+
+	resp, err := client.Do(ctx, args[0], func(url string) (*http.Request, error) {
+		r, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range header {
+			r.Header[k] = v
+		}
+		req = r
+		return r, nil
+	})
+	if err != nil {
+		log.Error().Err(err).
+			Msg("failed to get response from server")
+
+		return fmt.Errorf("failed to get response from server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	events.Raise(ctx, output.NewHTTPRequest(req))
+	events.Raise(ctx, &events.HTTPResponse{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+	})
+
+	if resp.StatusCode < 200 || 300 <= resp.StatusCode {
+		err := fmt.Errorf("server responded with %s", resp.Status)
+		events.Raise(ctx, events.ClientDisconnected{Err: err})
+		return err
+	}
+
+	var (
+		size     = resp.ContentLength
+		mimeType = resp.Header.Get("Content-Type")
+		name     = ""
+	)
+	if size < 0 {
+		size = 0
+	}
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		name = params["filename"]
+	}
+
+	fileReport := events.File{
+		Name: name,
+		MIME: mimeType,
+		Size: size,
+	}
+
+	var (
+		progress       atomic.Int64
+		dst            io.Writer
+		getBuf         func() []byte
+		extractionPipe *io.PipeWriter
+		extractionDone chan error
+	)
+	if config.Extract != "" {
+		pr, pw := io.Pipe()
+		defer pw.Close()
+		dst = pw
+		extractionPipe = pw
+
+		extractionDone = make(chan error, 1)
+		go func() {
+			// an empty format lets Extract sniff it from the content
+			format := file.ArchiveFormat(name)
+			err := file.Extract(ctx, file.ExtractConfig{Dir: config.Extract}, format, pr, func(f *events.File) {
+				fileReport.Extracted = append(fileReport.Extracted, f)
+			})
+			if err == nil {
+				// archives may be padded past their end
+				_, _ = io.Copy(io.Discard, pr)
+			}
+			pr.CloseWithError(err)
+			extractionDone <- err
+		}()
+		fileReport.Path = config.Extract
+	} else {
+		wtc, err := file.NewWriteTransferConfig(ctx, location)
+		if err != nil {
+			return fmt.Errorf("failed to create file transfer config: %w", err)
+		}
+		wts, err := wtc.NewWriteTransferSession(ctx, name, mimeType)
+		if err != nil {
+			return fmt.Errorf("failed to create write transfer session: %w", err)
+		}
+		defer wts.Close()
+
+		dst = wts
+		fileReport.Path = wts.Path()
+	}
+	dst, getBuf = output.NewBufferedWriter(ctx, dst)
+
+	cancelProgDisp := output.DisplayProgress(
+		ctx,
+		&progress,
+		125*time.Millisecond,
+		resp.Request.URL.Host,
+		size,
+	)
+	defer cancelProgDisp()
+
+	body := &file.ProgressReader{
+		R:        resp.Body,
+		Progress: &progress,
+	}
+	fileReport.TransferStartTime = time.Now()
+	fileReport.TransferSize, err = io.Copy(dst, body)
+	fileReport.TransferEndTime = time.Now()
+	if extractionPipe != nil {
+		extractionPipe.Close()
+		if eerr := <-extractionDone; eerr != nil {
+			err = fmt.Errorf("failed to extract archive: %w", eerr)
+		}
+	}
+	if err != nil {
+		log.Error().Err(err).
+			Msg("failed to copy response body")
+
+		events.Raise(ctx, &fileReport)
+		events.Raise(ctx, events.ClientDisconnected{Err: err})
+		return fmt.Errorf("failed to copy response body after %d bytes: %w", fileReport.TransferSize, err)
+	}
+
+	fileReport.Content = getBuf
+	events.Raise(ctx, &fileReport)
+	events.Success(ctx)
+	events.Stop(ctx)
+
+	return nil
+}
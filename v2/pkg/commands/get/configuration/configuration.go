@@ -0,0 +1,35 @@
+package configuration
+
+import (
+	"github.com/forestnode-io/oneshot/v2/pkg/flagargs"
+	"github.com/forestnode-io/oneshot/v2/pkg/flags"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+type Configuration struct {
+	Extract string              `mapstructure:"extract" yaml:"extract"`
+	Header  flagargs.HTTPHeader `mapstructure:"header" yaml:"header"`
+}
+
+func (c *Configuration) Validate() error {
+	return nil
+}
+
+func (c *Configuration) Hydrate() error {
+	return nil
+}
+
+func SetFlags(cmd *cobra.Command) {
+	fs := pflag.NewFlagSet("get flags", pflag.ExitOnError)
+	defer cmd.Flags().AddFlagSet(fs)
+
+	flags.StringP(fs, "cmd.get.extract", "extract", "x", `Extract the downloaded archive into this directory instead of saving the archive itself.
+Recognized archive formats are "zip", "tar" and "tar.gz".`)
+	flags.StringSliceP(fs, "cmd.get.header", "header", "H", `Header to send to the server. Can be specified multiple times.
+Format: <HEADER NAME>=<HEADER VALUE>`)
+
+	cobra.AddTemplateFunc("getFlags", func() *pflag.FlagSet {
+		return fs
+	})
+}
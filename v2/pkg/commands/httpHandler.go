@@ -16,3 +16,17 @@ func SetHTTPHandlerFunc(ctx context.Context, h http.HandlerFunc) {
 		*hp = h
 	}
 }
+
+type uploadPageKey struct{}
+
+func WithUploadPageSetter(ctx context.Context, b *bool) context.Context {
+	return context.WithValue(ctx, uploadPageKey{}, b)
+}
+
+// SetUploadPage marks the GET, HEAD and OPTIONS requests served by the handler
+// as only serving a page to upload from, not a download.
+func SetUploadPage(ctx context.Context) {
+	if bp, ok := ctx.Value(uploadPageKey{}).(*bool); ok {
+		*bp = true
+	}
+}
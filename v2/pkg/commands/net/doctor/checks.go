@@ -0,0 +1,301 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	oneshotnet "github.com/forestnode-io/oneshot/v2/pkg/net"
+	upnpigd "github.com/forestnode-io/oneshot/v2/pkg/net/upnp-igd"
+	"github.com/forestnode-io/oneshot/v2/pkg/net/webrtc/signallingserver"
+	"github.com/jackpal/gateway"
+	"github.com/pion/stun"
+	"github.com/pion/webrtc/v3"
+	"golang.org/x/mod/semver"
+)
+
+var defaultSTUNServers = []string{
+	"stun:stun.l.google.com:19302",
+	"stun:stun1.l.google.com:19302",
+}
+
+func checkInterfaces() Interfaces {
+	var report Interfaces
+
+	addrs, err := oneshotnet.HostAddresses()
+	if err != nil {
+		report.Error = err.Error()
+	}
+	report.Addresses = addrs
+
+	if ip, err := oneshotnet.GetSourceIP("", 80); err == nil {
+		report.SourceIP = ip
+	}
+	if ip, err := oneshotnet.GetSourceIP6(); err == nil {
+		report.SourceIP6 = ip
+	}
+
+	return report
+}
+
+func checkGateway() Gateway {
+	ip, err := gateway.DiscoverGateway()
+	if err != nil {
+		return Gateway{Error: err.Error()}
+	}
+	return Gateway{Address: ip.String()}
+}
+
+func checkUPnP(ctx context.Context, timeout time.Duration) UPnP {
+	var report UPnP
+
+	devChan, err := upnpigd.Discover(ctx, "oneshot", timeout, http.DefaultClient)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+
+	var devs []*upnpigd.Device
+	for dev := range devChan {
+		devs = append(devs, dev)
+	}
+	if len(devs) == 0 {
+		report.Error = "no UPnP IGD devices found"
+		return report
+	}
+
+	for _, dev := range devs {
+		d := UPnPDevice{
+			Name: dev.Name,
+			UUID: dev.UUID,
+		}
+		if dev.URL != nil {
+			d.URL = dev.URL.String()
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		ip, err := dev.GetExternalIP(ctx)
+		cancel()
+		if err != nil {
+			d.Error = err.Error()
+		} else if ip != nil {
+			d.ExternalIP = ip.String()
+		}
+
+		report.Devices = append(report.Devices, d)
+	}
+
+	return report
+}
+
+// checkNAT asks each STUN server for this hosts address from the same socket.
+// Getting the same address back from different servers means the NAT maps endpoint independently,
+// which is what p2p connections need to work without a relay.
+func checkNAT(ctx context.Context, servers []string, localAddrs []string, timeout time.Duration) NAT {
+	var report NAT
+
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		report.Type = NATTypeUnknown
+		report.Error = err.Error()
+		return report
+	}
+	defer conn.Close()
+
+	var mapped []string
+	for _, server := range servers {
+		result := STUNResult{Server: server}
+		addr, err := stunBinding(ctx, conn, server, timeout)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.MappedAddress = addr
+			mapped = append(mapped, addr)
+		}
+		report.Servers = append(report.Servers, result)
+	}
+
+	report.Type = NATTypeUnknown
+	switch {
+	case len(mapped) == 0:
+		report.Error = "no STUN servers responded, UDP may be blocked"
+	case isLocal(mapped[0], localAddrs):
+		report.Type = NATTypeNone
+	case len(mapped) == 1:
+		report.Error = "at least 2 STUN servers need to respond to tell the NAT type"
+	default:
+		report.Type = NATTypeEndpointIndependent
+		for _, addr := range mapped[1:] {
+			if addr != mapped[0] {
+				report.Type = NATTypeSymmetric
+				break
+			}
+		}
+	}
+
+	return report
+}
+
+func stunBinding(ctx context.Context, conn *net.UDPConn, server string, timeout time.Duration) (string, error) {
+	uri, err := stun.ParseURI(server)
+	if err != nil {
+		return "", err
+	}
+	if uri.Scheme != stun.SchemeTypeSTUN {
+		return "", fmt.Errorf("unsupported scheme: %s", uri.Scheme)
+	}
+
+	raddr, err := (&net.Resolver{}).LookupIP(ctx, "ip4", uri.Host)
+	if err != nil {
+		return "", err
+	}
+	if len(raddr) == 0 {
+		return "", fmt.Errorf("no IPv4 address for %s", uri.Host)
+	}
+	to := &net.UDPAddr{IP: raddr[0], Port: uri.Port}
+
+	req, err := stun.Build(stun.TransactionID, stun.BindingRequest)
+	if err != nil {
+		return "", err
+	}
+	if _, err := conn.WriteToUDP(req.Raw, to); err != nil {
+		return "", err
+	}
+
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetReadDeadline(deadline)
+
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				return "", errors.New("no response")
+			}
+			return "", err
+		}
+
+		resp := stun.Message{Raw: append([]byte(nil), buf[:n]...)}
+		if err := resp.Decode(); err != nil || resp.TransactionID != req.TransactionID {
+			continue
+		}
+
+		var xor stun.XORMappedAddress
+		if err := xor.GetFrom(&resp); err != nil {
+			return "", fmt.Errorf("response has no mapped address: %w", err)
+		}
+		return net.JoinHostPort(xor.IP.String(), strconv.Itoa(xor.Port)), nil
+	}
+}
+
+func isLocal(addr string, localAddrs []string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	for _, local := range localAddrs {
+		if local == host {
+			return true
+		}
+	}
+	return false
+}
+
+// checkICE gathers ICE candidates the same way a p2p session would.
+func checkICE(ctx context.Context, config *webrtc.Configuration, configured bool, timeout time.Duration) ICE {
+	report := ICE{Configured: configured}
+
+	pc, err := webrtc.NewPeerConnection(*config)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	defer pc.Close()
+
+	var mu sync.Mutex
+	pc.OnICECandidate(func(c *webrtc.ICECandidate) {
+		if c == nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		report.Candidates = append(report.Candidates, ICECandidate{
+			Type:     c.Typ.String(),
+			Protocol: c.Protocol.String(),
+			Address:  c.Address,
+			Port:     c.Port,
+		})
+	})
+
+	// there needs to be something to negotiate for candidates to be gathered
+	if _, err := pc.CreateDataChannel("doctor", nil); err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	offer, err := pc.CreateOffer(nil)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	gatherComplete := webrtc.GatheringCompletePromise(pc)
+	if err := pc.SetLocalDescription(offer); err != nil {
+		report.Error = err.Error()
+		return report
+	}
+
+	select {
+	case <-gatherComplete:
+	case <-time.After(timeout):
+		mu.Lock()
+		report.Error = "timed out gathering candidates"
+		mu.Unlock()
+	case <-ctx.Done():
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	return ICE{
+		Configured: report.Configured,
+		Candidates: append([]ICECandidate(nil), report.Candidates...),
+		Error:      report.Error,
+	}
+}
+
+func checkDiscovery(ctx context.Context, config signallingserver.DiscoveryServerConfig, timeout time.Duration) Discovery {
+	report := Discovery{
+		Enabled: config.Enabled && config.URL != "",
+		URL:     config.URL,
+	}
+	if !report.Enabled {
+		return report
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	vi, err := signallingserver.Handshake(ctx, config)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+
+	report.Reachable = true
+	report.Version = vi.Version
+	report.APIVersion = vi.APIVersion
+	// the discovery server is backwards compatible with older clients
+	report.Compatible = 0 <= semver.Compare(vi.APIVersion, config.VersionInfo.APIVersion)
+	if !report.Compatible {
+		report.Error = fmt.Sprintf("discovery server is running an older version of the API (%s) than this client (%s)", vi.APIVersion, config.VersionInfo.APIVersion)
+	}
+
+	return report
+}
@@ -0,0 +1,179 @@
+package doctor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/configuration"
+	"github.com/forestnode-io/oneshot/v2/pkg/net/webrtc/signallingserver"
+	"github.com/forestnode-io/oneshot/v2/pkg/net/webrtc/signallingserver/messages"
+	"github.com/forestnode-io/oneshot/v2/pkg/version"
+	"github.com/pion/webrtc/v3"
+	"github.com/spf13/cobra"
+)
+
+func New(config *configuration.Root) *Cmd {
+	return &Cmd{
+		config: config,
+	}
+}
+
+type Cmd struct {
+	cobraCommand *cobra.Command
+	config       *configuration.Root
+}
+
+func (c *Cmd) Cobra() *cobra.Command {
+	if c.cobraCommand != nil {
+		return c.cobraCommand
+	}
+
+	c.cobraCommand = &cobra.Command{
+		Use:   "doctor",
+		Short: "Diagnose why sharing over the network might not work.",
+		Long: `Diagnose why sharing over the network might not work.
+The interfaces and addresses of this host, the default gateway, UPnP-IGD devices,
+the type of NAT in front of this host, the ICE candidates a p2p session would gather
+and whether the discovery server is reachable and compatible are all reported.
+
+Use --output json for a report to attach to bug reports.`,
+		RunE: c.run,
+		Args: cobra.NoArgs,
+	}
+
+	fs := c.cobraCommand.Flags()
+	fs.Duration("probe-timeout", 5*time.Second, "How long to wait on each network probe, such as UPnP discovery and STUN requests.")
+	fs.StringSlice("stun-server", nil, `STUN servers used to tell the NAT type, e.g. stun:stun.l.google.com:19302.
+Defaults to the STUN servers in the p2p WebRTC configuration, or public STUN servers if there are none.`)
+
+	c.cobraCommand.SetUsageTemplate(usageTemplate)
+
+	return c.cobraCommand
+}
+
+func (c *Cmd) run(cmd *cobra.Command, args []string) error {
+	var (
+		ctx            = cmd.Context()
+		timeout, _     = cmd.Flags().GetDuration("probe-timeout")
+		stunServers, _ = cmd.Flags().GetStringSlice("stun-server")
+	)
+
+	// a broken p2p configuration is one of the things being diagnosed, report it instead of failing
+	iceConfig, configured, iceConfigErr := c.webRTCConfiguration()
+	if iceConfigErr != nil {
+		iceConfig, configured = &webrtc.Configuration{}, false
+	}
+	if len(stunServers) == 0 {
+		stunServers = stunURLs(iceConfig)
+	}
+	if !configured {
+		iceConfig.ICEServers = []webrtc.ICEServer{{URLs: stunServers}}
+	}
+
+	report := Report{
+		Version:    version.Version,
+		APIVersion: version.APIVersion,
+		OS:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+	}
+	report.Interfaces = checkInterfaces()
+
+	// the probes each wait on the network, run them side by side
+	var wg sync.WaitGroup
+	probe := func(f func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f()
+		}()
+	}
+	probe(func() { report.Gateway = checkGateway() })
+	probe(func() { report.UPnP = checkUPnP(ctx, timeout) })
+	probe(func() { report.NAT = checkNAT(ctx, stunServers, report.Interfaces.Addresses, timeout) })
+	probe(func() {
+		if iceConfigErr != nil {
+			report.ICE = ICE{
+				Configured: true,
+				Error:      fmt.Sprintf("invalid p2p configuration: %v", iceConfigErr),
+			}
+			return
+		}
+		report.ICE = checkICE(ctx, iceConfig, configured, timeout)
+	})
+	probe(func() { report.Discovery = checkDiscovery(ctx, c.discoveryConfig(), timeout) })
+	wg.Wait()
+
+	return c.write(&report)
+}
+
+func (c *Cmd) write(report *Report) error {
+	format, opts, _ := strings.Cut(c.config.Output.Format, "=")
+	switch format {
+	case "json", "ndjson":
+		enc := json.NewEncoder(os.Stdout)
+		if format == "json" && !slices.Contains(strings.Split(opts, ","), "compact") {
+			enc.SetIndent("", "  ")
+		}
+		if err := enc.Encode(report); err != nil {
+			return fmt.Errorf("failed to encode report: %w", err)
+		}
+	default:
+		report.writeHuman(os.Stdout)
+	}
+	return nil
+}
+
+// webRTCConfiguration returns the WebRTC configuration p2p sessions use, if there is one.
+func (c *Cmd) webRTCConfiguration() (*webrtc.Configuration, bool, error) {
+	p2p := c.config.NATTraversal.P2P
+	if len(p2p.WebRTCConfiguration) == 0 {
+		return &webrtc.Configuration{}, false, nil
+	}
+
+	wc, err := p2p.ParseConfig()
+	if err != nil {
+		return nil, false, err
+	}
+
+	config, err := wc.WebRTCConfiguration()
+	if err != nil {
+		return nil, false, err
+	}
+	return config, true, nil
+}
+
+func (c *Cmd) discoveryConfig() signallingserver.DiscoveryServerConfig {
+	dc := c.config.Discovery
+	return signallingserver.DiscoveryServerConfig{
+		Enabled:  dc.Enabled,
+		URL:      dc.Host,
+		Key:      dc.Key,
+		Insecure: dc.Insecure,
+		VersionInfo: messages.VersionInfo{
+			Version:    version.Version,
+			APIVersion: version.APIVersion,
+		},
+	}
+}
+
+// stunURLs returns the stun: urls in config, or the default STUN servers if there are none.
+func stunURLs(config *webrtc.Configuration) []string {
+	var urls []string
+	for _, s := range config.ICEServers {
+		for _, u := range s.URLs {
+			if strings.HasPrefix(u, "stun:") {
+				urls = append(urls, u)
+			}
+		}
+	}
+	if len(urls) == 0 {
+		urls = defaultSTUNServers
+	}
+	return urls
+}
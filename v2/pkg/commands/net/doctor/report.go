@@ -0,0 +1,197 @@
+package doctor
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Report is everything net doctor found out about the network.
+// Each section records the error that stopped it, if any, so that a report is always complete.
+type Report struct {
+	Version    string
+	APIVersion string
+	OS         string
+	Arch       string
+
+	Interfaces Interfaces
+	Gateway    Gateway
+	UPnP       UPnP
+	NAT        NAT
+	ICE        ICE
+	Discovery  Discovery
+}
+
+type Interfaces struct {
+	Addresses []string `json:",omitempty"`
+	// SourceIP is the address used to reach the default gateway.
+	SourceIP string `json:",omitempty"`
+	// SourceIP6 is the address used to reach the IPv6 internet.
+	SourceIP6 string `json:",omitempty"`
+	Error     string `json:",omitempty"`
+}
+
+type Gateway struct {
+	Address string `json:",omitempty"`
+	Error   string `json:",omitempty"`
+}
+
+type UPnP struct {
+	Devices []UPnPDevice `json:",omitempty"`
+	Error   string       `json:",omitempty"`
+}
+
+type UPnPDevice struct {
+	Name       string `json:",omitempty"`
+	UUID       string `json:",omitempty"`
+	URL        string `json:",omitempty"`
+	ExternalIP string `json:",omitempty"`
+	Error      string `json:",omitempty"`
+}
+
+const (
+	NATTypeNone                = "none"
+	NATTypeEndpointIndependent = "endpoint-independent"
+	NATTypeSymmetric           = "symmetric"
+	NATTypeUnknown             = "unknown"
+)
+
+type NAT struct {
+	// Type is one of none, endpoint-independent, symmetric or unknown.
+	Type    string
+	Servers []STUNResult `json:",omitempty"`
+	Error   string       `json:",omitempty"`
+}
+
+type STUNResult struct {
+	Server string
+	// MappedAddress is this hosts address as seen by the server.
+	MappedAddress string `json:",omitempty"`
+	Error         string `json:",omitempty"`
+}
+
+type ICE struct {
+	// Configured is false when no WebRTC configuration is set and the STUN servers were used instead.
+	Configured bool
+	Candidates []ICECandidate `json:",omitempty"`
+	Error      string         `json:",omitempty"`
+}
+
+type ICECandidate struct {
+	Type     string
+	Protocol string
+	Address  string
+	Port     uint16
+}
+
+type Discovery struct {
+	Enabled    bool
+	URL        string `json:",omitempty"`
+	Reachable  bool
+	Version    string `json:",omitempty"`
+	APIVersion string `json:",omitempty"`
+	Compatible bool
+	Error      string `json:",omitempty"`
+}
+
+// candidateTypes summarizes the candidates as a count per type, e.g. host (2), srflx (1).
+func (i *ICE) candidateTypes() string {
+	var (
+		types  []string
+		counts = make(map[string]int)
+	)
+	for _, c := range i.Candidates {
+		if _, ok := counts[c.Type]; !ok {
+			types = append(types, c.Type)
+		}
+		counts[c.Type]++
+	}
+
+	parts := make([]string, 0, len(types))
+	for _, t := range types {
+		parts = append(parts, fmt.Sprintf("%s (%d)", t, counts[t]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (r *Report) writeHuman(w io.Writer) {
+	section := func(title string) {
+		fmt.Fprintf(w, "%s\n", title)
+	}
+	field := func(name, value string) {
+		if value == "" {
+			value = "none"
+		}
+		fmt.Fprintf(w, "  %-13s %s\n", name+":", value)
+	}
+	errField := func(err string) {
+		if err != "" {
+			field("error", err)
+		}
+	}
+
+	section("oneshot")
+	field("version", r.Version)
+	field("api version", r.APIVersion)
+	field("platform", r.OS+"/"+r.Arch)
+
+	section("\nInterfaces")
+	field("addresses", strings.Join(r.Interfaces.Addresses, ", "))
+	field("source ip", r.Interfaces.SourceIP)
+	field("source ipv6", r.Interfaces.SourceIP6)
+	errField(r.Interfaces.Error)
+
+	section("\nGateway")
+	field("address", r.Gateway.Address)
+	errField(r.Gateway.Error)
+
+	section("\nUPnP-IGD")
+	if len(r.UPnP.Devices) == 0 && r.UPnP.Error == "" {
+		field("devices", "")
+	}
+	for _, d := range r.UPnP.Devices {
+		field("device", fmt.Sprintf("%s (%s)", d.Name, d.URL))
+		field("external ip", d.ExternalIP)
+		errField(d.Error)
+	}
+	errField(r.UPnP.Error)
+
+	section("\nNAT")
+	field("type", r.NAT.Type)
+	for _, s := range r.NAT.Servers {
+		if s.Error != "" {
+			field(s.Server, "error: "+s.Error)
+		} else {
+			field(s.Server, s.MappedAddress)
+		}
+	}
+	errField(r.NAT.Error)
+
+	section("\nICE")
+	if !r.ICE.Configured {
+		field("config", "none, using the STUN servers")
+	}
+	field("candidates", r.ICE.candidateTypes())
+	errField(r.ICE.Error)
+
+	section("\nDiscovery server")
+	if !r.Discovery.Enabled {
+		field("enabled", "no")
+		return
+	}
+	field("url", r.Discovery.URL)
+	field("reachable", yesNo(r.Discovery.Reachable))
+	if r.Discovery.Reachable {
+		field("version", r.Discovery.Version)
+		field("api version", r.Discovery.APIVersion)
+		field("compatible", yesNo(r.Discovery.Compatible))
+	}
+	errField(r.Discovery.Error)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,8 @@
+package doctor
+
+const usageTemplate = `doctor options:
+{{ .LocalFlags | wrappedFlagUsages | trimTrailingWhitespaces }}
+
+Usage:
+  {{ .UseLine }}
+`
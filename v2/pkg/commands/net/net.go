@@ -0,0 +1,43 @@
+package net
+
+import (
+	"github.com/forestnode-io/oneshot/v2/pkg/commands/net/doctor"
+	"github.com/forestnode-io/oneshot/v2/pkg/configuration"
+	"github.com/spf13/cobra"
+)
+
+func New(config *configuration.Root) *Cmd {
+	return &Cmd{
+		config: config,
+	}
+}
+
+type Cmd struct {
+	cobraCommand *cobra.Command
+	config       *configuration.Root
+}
+
+func (c *Cmd) Cobra() *cobra.Command {
+	if c.cobraCommand != nil {
+		return c.cobraCommand
+	}
+
+	c.cobraCommand = &cobra.Command{
+		Use:     "net",
+		Aliases: []string{"network"},
+		Short:   "Network commands",
+		Long:    "Network commands",
+	}
+
+	c.cobraCommand.SetUsageTemplate(usageTemplate)
+
+	c.cobraCommand.AddCommand(subCommands(c.config)...)
+
+	return c.cobraCommand
+}
+
+func subCommands(config *configuration.Root) []*cobra.Command {
+	return []*cobra.Command{
+		doctor.New(config).Cobra(),
+	}
+}
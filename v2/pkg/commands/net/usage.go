@@ -0,0 +1,8 @@
+package net
+
+const usageTemplate = `Usage:
+	{{ .CommandPath }} [command]
+
+Available Commands: {{ range .Commands }}{{if (or .IsAvailableCommand (eq .Name "help"))}}
+	{{rpad .Name .NamePadding }} {{.Short}}{{end}}{{end}}
+`
@@ -15,4 +15,7 @@ Basic Authentication options:
 NAT Traversal options:
 {{ "P2P options:" | indent 2 }}
 {{ p2pClientFlags | wrappedFlagUsages | trimTrailingWhitespaces | indent 4 }}
+
+Configuration options:
+{{ configFlags | wrappedFlagUsages | trimTrailingWhitespaces }}
 `
@@ -186,7 +186,10 @@ func (c *Cmd) send(cmd *cobra.Command, args []string) error {
 	}()
 	defer signaller.Shutdown()
 
-	rtc, err := file.NewReadTransferConfig(config.ArchiveMethod, args...)
+	rtc, err := file.NewReadTransferConfig(file.ArchiveOptions{
+		Format:           config.ArchiveMethod,
+		CompressionLevel: file.DefaultCompressionLevel,
+	}, args...)
 	if err != nil {
 		log.Error().Err(err).
 			Msg("failed to create read transfer config")
@@ -21,7 +21,7 @@ func SetFlags(cmd *cobra.Command) {
 
 	flags.StringP(fs, "cmd.p2p.client.send.name", "name", "n", "Name of file presented to the server.")
 	flags.StringP(fs, "cmd.p2p.client.send.archivemethod", "archive-method", "a", `Which archive method to use when sending directories.
-Recognized values are "zip", "tar" and "tar.gz".`)
+Recognized values are "tar", "tar.gz", "tar.zst", "tar.xz" and "zip".`)
 
 	cobra.AddTemplateFunc("sendFlags", func() *pflag.FlagSet {
 		return fs
@@ -0,0 +1,291 @@
+package put
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/commands/put/configuration"
+	rootconfig "github.com/forestnode-io/oneshot/v2/pkg/configuration"
+	"github.com/forestnode-io/oneshot/v2/pkg/events"
+	"github.com/forestnode-io/oneshot/v2/pkg/file"
+	oneshothttp "github.com/forestnode-io/oneshot/v2/pkg/net/http"
+	"github.com/forestnode-io/oneshot/v2/pkg/output"
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+)
+
+func New(config *rootconfig.Root) *Cmd {
+	return &Cmd{
+		config: config,
+	}
+}
+
+type Cmd struct {
+	cobraCommand *cobra.Command
+	config       *rootconfig.Root
+}
+
+func (c *Cmd) Cobra() *cobra.Command {
+	if c.cobraCommand != nil {
+		return c.cobraCommand
+	}
+
+	c.cobraCommand = &cobra.Command{
+		Use:   "put url [file|dir]...",
+		Short: "Upload to a receiving oneshot server",
+		Long: `Upload to a receiving oneshot server. If no files or directories are given, the content of stdin will be uploaded.
+If multiple files or a directory are given, they will be archived before being uploaded.
+
+If the server is a discovery server that is redirecting clients, put will follow the redirect to the oneshot server.
+Credentials given with the basic authentication flags, and Authorization or Cookie headers given with --header,
+are only sent to the host in the url. They are left out when a redirect leads to another host,
+so to upload to a password protected oneshot that a discovery server redirects to, use the oneshots own url.
+`,
+		RunE: c.put,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return output.UsageErrorF("url required")
+			}
+			return nil
+		},
+	}
+
+	c.cobraCommand.SetUsageTemplate(usageTemplate)
+	configuration.SetFlags(c.cobraCommand)
+
+	return c.cobraCommand
+}
+
+func (c *Cmd) put(cmd *cobra.Command, args []string) error {
+	var (
+		ctx   = cmd.Context()
+		log   = zerolog.Ctx(ctx)
+		paths = args[1:]
+
+		config   = c.config.Subcommands.Put
+		baConfig = c.config.BasicAuth
+		header   = http.Header(config.Header.Inflate())
+
+		fileName = config.Name
+		mimeType = config.MIME
+		method   = strings.ToUpper(config.Method)
+	)
+
+	rtc, err := file.NewReadTransferConfig(config.ArchiveOptions(), paths...)
+	if err != nil {
+		log.Error().Err(err).
+			Msg("failed to create read transfer config")
+
+		return fmt.Errorf("failed to create read transfer config: %w", err)
+	}
+
+	if len(paths) == 1 && fileName == "" {
+		fileName = filepath.Base(paths[0])
+	}
+	if file.IsArchive(rtc) {
+		if fileName == "" {
+			fileName = "oneshot"
+		}
+		fileName += "." + config.ArchiveMethod
+	}
+	if mimeType == "" && fileName != "" {
+		mimeType = mime.TypeByExtension(filepath.Ext(fileName))
+	}
+
+	if file.IsTTY(rtc) {
+		fmt.Fprintln(cmd.ErrOrStderr(), "Reading from stdin, press Ctrl+D when done.")
+	}
+
+	rts, err := rtc.NewReaderTransferSession(ctx)
+	if err != nil {
+		log.Error().Err(err).
+			Msg("failed to create reader transfer session")
+
+		return fmt.Errorf("failed to create reader transfer session: %w", err)
+	}
+	defer func() {
+		rts.Close()
+	}()
+
+	var (
+		req      *http.Request
+		buf      *bytes.Buffer
+		size     int64
+		progress atomic.Int64
+		attempts int
+	)
+	if s, err := rts.Size(); err == nil {
+		size = s
+	}
+
+	client := oneshothttp.NewClient()
+	client.Username = baConfig.Username
+	client.Password = baConfig.Password
+
+	fileReport := events.File{
+		Name:              fileName,
+		MIME:              mimeType,
+		Size:              size,
+		TransferStartTime: time.Now(),
+	}
+
+	cancelProgDisp := output.DisplayProgress(
+		ctx,
+		&progress,
+		125*time.Millisecond,
+		args[0],
+		size,
+	)
+	defer cancelProgDisp()
+
+	resp, err := client.Do(ctx, args[0], func(url string) (*http.Request, error) {
+		// a redirect means the server never read the previous body,
+		// start a new session so the whole file is sent to the next server.
+		if 0 < attempts {
+			rts.Close()
+			if rts, err = rtc.NewReaderTransferSession(ctx); err != nil {
+				return nil, fmt.Errorf("failed to create reader transfer session: %w", err)
+			}
+		}
+		attempts++
+
+		progress.Store(0)
+		var body io.Reader = &file.ProgressReader{
+			R:        rts,
+			Progress: &progress,
+		}
+		body, buf = output.NewBufferedReader(ctx, body)
+
+		r, err := c.newRequest(method, url, body, fileName, mimeType, size)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range header {
+			r.Header[k] = v
+		}
+		req = r
+		return r, nil
+	})
+	if err != nil {
+		log.Error().Err(err).
+			Msg("failed to send file")
+
+		return fmt.Errorf("failed to send file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	events.Raise(ctx, output.NewHTTPRequest(req))
+
+	respBody, _ := io.ReadAll(resp.Body)
+	fileReport.TransferEndTime = time.Now()
+	fileReport.TransferSize = progress.Load()
+	if buf != nil {
+		fileReport.Content = buf.Bytes()
+	}
+
+	events.Raise(ctx, &events.HTTPResponse{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+	})
+
+	if resp.StatusCode < 200 || 300 <= resp.StatusCode {
+		err := fmt.Errorf("failed to send file: %s", resp.Status)
+		if msg := strings.TrimSpace(string(respBody)); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		events.Raise(ctx, events.ClientDisconnected{Err: err})
+		return err
+	}
+
+	events.Raise(ctx, &fileReport)
+	events.Success(ctx)
+	events.Stop(ctx)
+
+	return nil
+}
+
+func (c *Cmd) newRequest(method, url string, body io.Reader, name, mimeType string, size int64) (*http.Request, error) {
+	config := c.config.Subcommands.Put
+
+	if !config.Multipart {
+		req, err := http.NewRequest(method, url, body)
+		if err != nil {
+			return nil, err
+		}
+		if 0 < size {
+			req.ContentLength = size
+		}
+		if name != "" {
+			req.Header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+				"filename": name,
+			}))
+		}
+		if mimeType != "" {
+			req.Header.Set("Content-Type", mimeType)
+		}
+		if config.CSRFToken != "" {
+			req.Header.Set("X-CSRF-Token", config.CSRFToken)
+		}
+		req.Header.Set("Expect", "100-continue")
+		return req, nil
+	}
+
+	pr, pw := io.Pipe()
+	mpw := multipart.NewWriter(pw)
+	go func() {
+		err := writeMultipart(mpw, body, config.CSRFToken, name, mimeType)
+		if cerr := mpw.Close(); err == nil {
+			err = cerr
+		}
+		pw.CloseWithError(err)
+	}()
+
+	req, err := http.NewRequest(method, url, pr)
+	if err != nil {
+		pr.Close()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", mpw.FormDataContentType())
+	if 0 < size && name != "" {
+		req.Header.Set("X-Oneshot-Multipart-Content-Lengths", fmt.Sprintf("%s=%d", name, size))
+	}
+	req.Header.Set("Expect", "100-continue")
+
+	return req, nil
+}
+
+func writeMultipart(mpw *multipart.Writer, body io.Reader, csrfToken, name, mimeType string) error {
+	if csrfToken != "" {
+		if err := mpw.WriteField("csrf-token", csrfToken); err != nil {
+			return err
+		}
+	}
+
+	if name == "" {
+		name = "oneshot"
+	}
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	h := make(textproto.MIMEHeader)
+	// the receiving oneshot expects the filename to be the last parameter,
+	// mime.FormatMediaType would sort it in front of the name.
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="oneshot"; filename=%q`, name))
+	h.Set("Content-Type", mimeType)
+	part, err := mpw.CreatePart(h)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(part, body)
+	return err
+}
@@ -0,0 +1,90 @@
+package configuration
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/file"
+	"github.com/forestnode-io/oneshot/v2/pkg/flagargs"
+	"github.com/forestnode-io/oneshot/v2/pkg/flags"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+type Configuration struct {
+	ArchiveMethod    string              `mapstructure:"archivemethod" yaml:"archivemethod"`
+	CompressionLevel int                 `mapstructure:"compressionlevel" yaml:"compressionlevel"`
+	Exclude          []string            `mapstructure:"exclude" yaml:"exclude"`
+	GitIgnore        bool                `mapstructure:"gitignore" yaml:"gitignore"`
+	Deterministic    bool                `mapstructure:"deterministic" yaml:"deterministic"`
+	ComputeSize      bool                `mapstructure:"computesize" yaml:"computesize"`
+	Name             string              `mapstructure:"name" yaml:"name"`
+	MIME             string              `mapstructure:"mime" yaml:"mime"`
+	Method           string              `mapstructure:"method" yaml:"method"`
+	CSRFToken        string              `mapstructure:"csrftoken" yaml:"csrftoken"`
+	Multipart        bool                `mapstructure:"multipart" yaml:"multipart"`
+	Header           flagargs.HTTPHeader `mapstructure:"header" yaml:"header"`
+}
+
+// ArchiveOptions returns how directories should be archived.
+func (c *Configuration) ArchiveOptions() file.ArchiveOptions {
+	return file.ArchiveOptions{
+		Format:           c.ArchiveMethod,
+		CompressionLevel: c.CompressionLevel,
+		Exclude:          c.Exclude,
+		GitIgnore:        c.GitIgnore,
+		Deterministic:    c.Deterministic,
+		ComputeSize:      c.ComputeSize,
+	}
+}
+
+func (c *Configuration) Validate() error {
+	if err := file.ValidateArchiveFormat(c.ArchiveMethod); err != nil {
+		return err
+	}
+	if err := file.ValidateCompressionLevel(c.CompressionLevel); err != nil {
+		return err
+	}
+
+	switch strings.ToUpper(c.Method) {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return fmt.Errorf("invalid method: %s", c.Method)
+	}
+
+	return nil
+}
+
+func (c *Configuration) Hydrate() error {
+	return nil
+}
+
+func SetFlags(cmd *cobra.Command) {
+	fs := pflag.NewFlagSet("put flags", pflag.ExitOnError)
+	defer cmd.Flags().AddFlagSet(fs)
+
+	flags.StringP(fs, "cmd.put.archivemethod", "archive-method", "a", `Which archive method to use when sending directories.
+Recognized values are "tar", "tar.gz", "tar.zst", "tar.xz" and "zip".`)
+	flags.Int(fs, "cmd.put.compressionlevel", "compression-level", `Compression level to use when archiving, from 0 (none) to 9 (best).
+A zip archive with a compression level of 0 only stores its files.
+-1 uses the default level of the archive method.`)
+	flags.StringSlice(fs, "cmd.put.exclude", "exclude", `Glob pattern of files to leave out when archiving. Can be specified multiple times.
+Patterns without a slash are matched against file names, e.g. '*.o'.`)
+	flags.Bool(fs, "cmd.put.gitignore", "gitignore", "Leave out files ignored by .gitignore files when archiving.")
+	flags.Bool(fs, "cmd.put.deterministic", "deterministic", `Create deterministic archives.
+Archive entries get fixed modification times and no owner information so that uploading the same files produces identical bytes.`)
+	flags.Bool(fs, "cmd.put.computesize", "compute-size", `Archive compressed formats once before they are uploaded to learn their size.
+The size of tar and uncompressed zip archives is always known up front.`)
+	flags.StringP(fs, "cmd.put.name", "name", "n", `Name of file presented to the server.`)
+	flags.StringP(fs, "cmd.put.mime", "mime", "m", `MIME type of file presented to the server.`)
+	flags.String(fs, "cmd.put.method", "method", `HTTP method to upload with. Recognized values are "POST", "PUT" and "PATCH".`)
+	flags.String(fs, "cmd.put.csrftoken", "csrf-token", `CSRF token expected by the receiving oneshot.`)
+	flags.Bool(fs, "cmd.put.multipart", "multipart", `Upload the file as multipart/form-data, the same way the oneshot browser upload client does.`)
+	flags.StringSliceP(fs, "cmd.put.header", "header", "H", `Header to send to the server. Can be specified multiple times.
+Format: <HEADER NAME>=<HEADER VALUE>`)
+
+	cobra.AddTemplateFunc("putFlags", func() *pflag.FlagSet {
+		return fs
+	})
+}
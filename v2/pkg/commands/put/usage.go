@@ -0,0 +1,17 @@
+package put
+
+const usageTemplate = `Put options:
+{{ .LocalFlags | wrappedFlagUsages | trimTrailingWhitespaces }}
+
+Output options:
+{{ outputClientFlags | wrappedFlagUsages | trimTrailingWhitespaces }}
+
+Basic Authentication options:
+{{ basicAuthClientFlags | wrappedFlagUsages | trimTrailingWhitespaces }}
+
+Configuration options:
+{{ configFlags | wrappedFlagUsages | trimTrailingWhitespaces }}
+
+Usage:
+  {{.UseLine}}
+`
@@ -77,6 +77,10 @@ In order to display dynamic transfer information, oneshot needs to know the tota
 Web interfaces can provide this information by setting the Content-Length header on the POST request.
 If a file is being uploaded as a multipart form, the content length can be provided by setting the ` + "`X-Oneshot-Multipart-Content-Lengths`" + ` header in the request.
 Values in the ` + "`X-Oneshot-Multipart-Content-Lengths`" + ` header should be of the form <FILE NAME>=<CONTENT LENGTH>.
+
+If the --extract flag is given, uploaded tar, tar.gz, tar.zst, tar.xz and zip archives are extracted into the given directory as they arrive.
+Entries that would be written outside of the directory, links and special files are never extracted.
+The --extract-max-size and --extract-max-entries flags guard against archives that expand to more than expected.
 `,
 		Args: func(cmd *cobra.Command, args []string) error {
 			if len(args) > 1 {
@@ -109,6 +113,9 @@ func (c *Cmd) setHandlerFunc(cmd *cobra.Command, args []string) error {
 
 	var location string
 	if 0 < len(args) {
+		if config.Extract != "" {
+			return output.UsageErrorF("a file may not be given when extracting")
+		}
 		location = args[0]
 	}
 	c.fileTransferConfig, err = file.NewWriteTransferConfig(ctx, location)
@@ -172,6 +179,7 @@ func (c *Cmd) setHandlerFunc(cmd *cobra.Command, args []string) error {
 	}
 
 	commands.SetHTTPHandlerFunc(ctx, c.ServeHTTP)
+	commands.SetUploadPage(ctx)
 	return nil
 }
 
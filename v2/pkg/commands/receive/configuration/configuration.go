@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"os"
 
+	"github.com/forestnode-io/oneshot/v2/pkg/flagargs"
 	"github.com/forestnode-io/oneshot/v2/pkg/flags"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -17,6 +18,13 @@ type Configuration struct {
 	DecodeBase64 bool   `mapstructure:"decodeb64" yaml:"decodeb64"`
 	StatusCode   int    `mapstructure:"status" yaml:"status"`
 	IncludeBody  bool   `mapstructure:"includebody" yaml:"includebody"`
+
+	Extract           string `mapstructure:"extract" yaml:"extract"`
+	ExtractMaxSize    string `mapstructure:"extractmaxsize" yaml:"extractmaxsize"`
+	ExtractMaxEntries int    `mapstructure:"extractmaxentries" yaml:"extractmaxentries"`
+
+	// ExtractMaxSizeBytes is ExtractMaxSize parsed into bytes.
+	ExtractMaxSizeBytes int64 `mapstructure:"-" yaml:"-"`
 }
 
 func (c *Configuration) Validate() error {
@@ -38,10 +46,28 @@ func (c *Configuration) Validate() error {
 		return fmt.Errorf("invalid status code: %d", c.StatusCode)
 	}
 
+	if c.ExtractMaxSize != "" {
+		if _, err := flagargs.ParseSize(c.ExtractMaxSize); err != nil {
+			return fmt.Errorf("invalid extract max size: %w", err)
+		}
+	}
+
+	if c.ExtractMaxEntries < 0 {
+		return fmt.Errorf("invalid extract max entries: %d", c.ExtractMaxEntries)
+	}
+
 	return nil
 }
 
 func (c *Configuration) Hydrate() error {
+	if c.ExtractMaxSize != "" {
+		size, err := flagargs.ParseSize(c.ExtractMaxSize)
+		if err != nil {
+			return err
+		}
+		c.ExtractMaxSizeBytes = size
+	}
+
 	return nil
 }
 
@@ -56,6 +82,13 @@ Acceptable values are 'unix' and 'dos'; 'unix': '\n', 'dos': '\r\n'.`)
 	flags.Bool(fs, "cmd.receive.decodeb64", "decode-b64", "Decode base-64.")
 	flags.Int(fs, "cmd.receive.status", "status-code", "HTTP status code sent to client.")
 	flags.Bool(fs, "cmd.receive.includebody", "include-body", "Include the request body in the report. If not using json output, this will be ignored.")
+	flags.StringP(fs, "cmd.receive.extract", "extract", "x", `Extract uploaded archives into this directory as they arrive.
+Recognized archive formats are tar, tar.gz, tar.zst, tar.xz and zip.
+Links and special files in the archive are not extracted.`)
+	flags.String(fs, "cmd.receive.extractmaxsize", "extract-max-size", `Maximum number of bytes that will be extracted from an archive, e.g. 500MB or 2GiB.
+Leave empty for no limit.`)
+	flags.Int(fs, "cmd.receive.extractmaxentries", "extract-max-entries", `Maximum number of files and directories that will be extracted from an archive.
+0 means no limit.`)
 
 	cobra.AddTemplateFunc("receiveFlags", func() *pflag.FlagSet {
 		return fs
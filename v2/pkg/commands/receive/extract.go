@@ -0,0 +1,105 @@
+package receive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/events"
+	"github.com/forestnode-io/oneshot/v2/pkg/file"
+	"github.com/forestnode-io/oneshot/v2/pkg/output"
+	"github.com/rs/zerolog"
+)
+
+// extract streams an uploaded archive into the extraction directory.
+// The upload is reported as a single file with an entry for each extracted file.
+func (c *Cmd) extract(ctx context.Context, w http.ResponseWriter, r *http.Request, rb *requestBody, src io.Reader, size int64) {
+	var (
+		log    = zerolog.Ctx(ctx)
+		config = c.config.Subcommands.Receive
+
+		progress atomic.Int64
+	)
+
+	cancelProgDisp := output.DisplayProgress(
+		ctx,
+		&progress,
+		125*time.Millisecond,
+		r.RemoteAddr,
+		size,
+	)
+	defer cancelProgDisp()
+
+	fileReport := events.File{
+		MIME:              rb.mime,
+		Size:              size,
+		Name:              rb.name,
+		Path:              config.Extract,
+		TransferStartTime: time.Now(),
+	}
+
+	extractConfig := file.ExtractConfig{
+		Dir:        config.Extract,
+		MaxSize:    config.ExtractMaxSizeBytes,
+		MaxEntries: config.ExtractMaxEntries,
+	}
+	body := &file.ProgressReader{
+		R:        src,
+		Progress: &progress,
+	}
+	// an empty format lets Extract sniff it from the content
+	err := file.Extract(ctx, extractConfig, file.ArchiveFormat(rb.name), body, func(f *events.File) {
+		fileReport.Extracted = append(fileReport.Extracted, f)
+	})
+	if err == nil {
+		err = drainTrailing(body, extractConfig.MaxSize, progress.Load())
+	}
+	fileReport.TransferEndTime = time.Now()
+	fileReport.TransferSize = progress.Load()
+
+	if err != nil {
+		log.Error().Err(err).
+			Msg("error extracting archive from request")
+
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, file.ErrIllegalArchivePath),
+			errors.Is(err, file.ErrExtractLimitExceeded),
+			errors.Is(err, file.ErrUnknownArchiveFormat):
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+
+		events.Raise(ctx, &fileReport)
+		events.Raise(ctx, events.ClientDisconnected{
+			Err: err,
+		})
+		return
+	}
+
+	w.WriteHeader(config.StatusCode)
+
+	events.Raise(ctx, &fileReport)
+
+	events.Success(ctx)
+}
+
+// drainTrailing reads what is left of the request after the end of the archive, archives may be padded past their end.
+// Unless maxSize is 0, no more than what is left of it once read bytes have been counted against it is read.
+func drainTrailing(r io.Reader, maxSize, read int64) error {
+	if maxSize <= 0 {
+		_, err := io.Copy(io.Discard, r)
+		return err
+	}
+
+	budget := max(maxSize-read, 0)
+	n, err := io.Copy(io.Discard, io.LimitReader(r, budget+1))
+	if err == nil && budget < n {
+		err = fmt.Errorf("%w: more than %d bytes", file.ErrExtractLimitExceeded, maxSize)
+	}
+	return err
+}
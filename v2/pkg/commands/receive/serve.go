@@ -76,6 +76,11 @@ func (c *Cmd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if config.Extract != "" {
+		c.extract(ctx, w, r, rb, src, int64(fileSize))
+		return
+	}
+
 	wts, err := c.fileTransferConfig.NewWriteTransferSession(ctx, rb.name, rb.mime)
 	if err != nil {
 		log.Error().Err(err).
@@ -1,17 +1,35 @@
 package redirect
 
 import (
+	"bytes"
+	_ "embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
 	"io"
 	"net/http"
+	"strings"
+	texttemplate "text/template"
 
 	"github.com/forestnode-io/oneshot/v2/pkg/commands"
 	"github.com/forestnode-io/oneshot/v2/pkg/commands/redirect/configuration"
 	rootconfig "github.com/forestnode-io/oneshot/v2/pkg/configuration"
 	"github.com/forestnode-io/oneshot/v2/pkg/events"
+	oneshothttp "github.com/forestnode-io/oneshot/v2/pkg/net/http"
 	"github.com/forestnode-io/oneshot/v2/pkg/output"
+	"github.com/rs/zerolog"
 	"github.com/spf13/cobra"
+	"rsc.io/qr"
 )
 
+//go:embed page.template.html
+var pageTemplate string
+
+var page = template.Must(template.New("root").Parse(pageTemplate))
+
+var errStateMismatch = errors.New("oauth callback state does not match")
+
 func New(config *rootconfig.Root) *Cmd {
 	return &Cmd{
 		config: config,
@@ -21,7 +39,7 @@ func New(config *rootconfig.Root) *Cmd {
 type Cmd struct {
 	cobraCommand *cobra.Command
 	config       *rootconfig.Root
-	url          string
+	url          *texttemplate.Template
 }
 
 func (c *Cmd) Cobra() *cobra.Command {
@@ -30,13 +48,23 @@ func (c *Cmd) Cobra() *cobra.Command {
 	}
 
 	c.cobraCommand = &cobra.Command{
-		Use:   "redirect url",
+		Use:   "redirect [url]",
 		Short: "Redirect all requests to the specified url",
-		RunE:  c.setHandlerFunc,
+		Long: `Redirect all requests to the specified url.
+The url is a Go template with access to the request as .Method, .Path, .Host, .Query and .Header,
+which makes it possible to forward parts of the request along:
+
+	oneshot redirect 'http://localhost:9000/callback?code={{ .Query.Get "code" | urlquery }}'
+
+With --oauth-callback, oneshot acts as the loopback redirect URI of an OAuth login flow.
+The code and state of the first callback are printed, or included in the report, and the browser is shown a page saying the tab can be closed.
+Requests that aren't callbacks, such as for a favicon, are answered with a 404 and ignored.
+
+With --qr-handoff, the browser is shown the target url as a QR code instead of being redirected,
+so that the flow can be carried on from another device such as a phone.
+`,
+		RunE: c.setHandlerFunc,
 		Args: func(cmd *cobra.Command, args []string) error {
-			if len(args) < 1 {
-				return output.UsageErrorF("redirect url required")
-			}
 			if 1 < len(args) {
 				return output.UsageErrorF("too many arguments, only 1 url may be used")
 			}
@@ -51,11 +79,27 @@ func (c *Cmd) Cobra() *cobra.Command {
 }
 
 func (c *Cmd) setHandlerFunc(cmd *cobra.Command, args []string) error {
-	var ctx = cmd.Context()
+	var (
+		ctx    = cmd.Context()
+		config = c.config.Subcommands.Redirect
+	)
 
 	output.IncludeBody(ctx)
 
-	c.url = args[0]
+	if len(args) < 1 && !config.OAuthCallback {
+		return output.UsageErrorF("redirect url required")
+	}
+	if len(args) < 1 && config.QRHandoff {
+		return output.UsageErrorF("redirect url required for qr handoff")
+	}
+
+	if 0 < len(args) {
+		var err error
+		c.url, err = texttemplate.New("url").Option("missingkey=zero").Parse(args[0])
+		if err != nil {
+			return output.UsageErrorF("invalid url template: %w", err)
+		}
+	}
 
 	commands.SetHTTPHandlerFunc(ctx, c.ServeHTTP)
 	return nil
@@ -64,15 +108,33 @@ func (c *Cmd) setHandlerFunc(cmd *cobra.Command, args []string) error {
 func (c *Cmd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	var (
 		ctx    = c.cobraCommand.Context()
+		log    = zerolog.Ctx(ctx)
 		config = c.config.Subcommands.Redirect
 	)
 
+	var (
+		query    = r.URL.Query()
+		callback *events.OAuthCallback
+	)
+	if config.OAuthCallback {
+		if !query.Has("code") && !query.Has("error") {
+			// browsers ask for favicons and the like before the provider sends them back
+			w.(oneshothttp.ResponseWriter).IgnoreOutcome()
+			http.NotFound(w, r)
+			return
+		}
+		callback = &events.OAuthCallback{
+			Code:             query.Get("code"),
+			State:            query.Get("state"),
+			Error:            query.Get("error"),
+			ErrorDescription: query.Get("error_description"),
+		}
+	}
+
 	doneReadingBody := make(chan struct{})
 	events.Raise(ctx, output.NewHTTPRequest(r))
-
-	var header = http.Header(config.Header.Inflate())
-	for key := range header {
-		w.Header().Set(key, header.Get(key))
+	if callback != nil {
+		events.Raise(ctx, callback)
 	}
 
 	go func() {
@@ -80,9 +142,111 @@ func (c *Cmd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		defer r.Body.Close()
 		_, _ = io.Copy(io.Discard, r.Body)
 	}()
+	defer func() { <-doneReadingBody }()
 
-	http.Redirect(w, r, c.url, config.StatusCode)
+	var header = http.Header(config.Header.Inflate())
+	for key := range header {
+		w.Header().Set(key, header.Get(key))
+	}
+
+	if callback != nil {
+		var err error
+		switch {
+		case callback.Error != "":
+			err = fmt.Errorf("authorization failed: %s", callback.Error)
+			if callback.ErrorDescription != "" {
+				err = fmt.Errorf("%w: %s", err, callback.ErrorDescription)
+			}
+		case config.OAuthState != "" && callback.State != config.OAuthState:
+			err = errStateMismatch
+		}
+		if err != nil {
+			c.writePage(w, http.StatusBadRequest, pageData{
+				Title:   "Login failed",
+				Message: err.Error(),
+			})
+			events.Raise(ctx, events.ClientDisconnected{Err: err})
+			return
+		}
+	}
+
+	var target string
+	if c.url != nil {
+		var err error
+		if target, err = c.target(r); err != nil {
+			log.Error().Err(err).
+				Msg("error executing url template")
+
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			events.Raise(ctx, events.ClientDisconnected{Err: err})
+			return
+		}
+	}
+
+	switch {
+	case config.QRHandoff:
+		code, err := qr.Encode(target, qr.L)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			events.Raise(ctx, events.ClientDisconnected{Err: err})
+			return
+		}
+		c.writePage(w, http.StatusOK, pageData{
+			Title:   "Scan to continue on another device",
+			QRCode:  template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(code.PNG())),
+			URL:     target,
+			Message: callbackMessage(callback),
+		})
+	case target != "":
+		http.Redirect(w, r, target, config.StatusCode)
+	default:
+		c.writePage(w, http.StatusOK, pageData{
+			Title:   "Login complete",
+			Message: "You can close this tab and return to your terminal.",
+		})
+	}
 
 	events.Success(ctx)
-	<-doneReadingBody
+}
+
+// target executes the url template with r.
+func (c *Cmd) target(r *http.Request) (string, error) {
+	var buf bytes.Buffer
+	err := c.url.Execute(&buf, map[string]any{
+		"Method": r.Method,
+		"Path":   r.URL.Path,
+		"Host":   r.Host,
+		"Query":  r.URL.Query(),
+		"Header": r.Header,
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+type pageData struct {
+	Title   string
+	Message string
+	QRCode  template.URL
+	URL     string
+}
+
+func (c *Cmd) writePage(w http.ResponseWriter, status int, data pageData) {
+	var buf bytes.Buffer
+	if err := page.ExecuteTemplate(&buf, "page", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = w.Write(buf.Bytes())
+}
+
+func callbackMessage(callback *events.OAuthCallback) string {
+	if callback == nil {
+		return ""
+	}
+	return "Login complete, this tab can be closed once the code has been scanned."
 }
@@ -11,14 +11,20 @@ import (
 )
 
 type Configuration struct {
-	StatusCode int                 `mapstructure:"status" yaml:"status"`
-	Header     flagargs.HTTPHeader `mapstructure:"header" yaml:"header"`
+	StatusCode    int                 `mapstructure:"status" yaml:"status"`
+	Header        flagargs.HTTPHeader `mapstructure:"header" yaml:"header"`
+	OAuthCallback bool                `mapstructure:"oauthCallback" yaml:"oauthCallback"`
+	OAuthState    string              `mapstructure:"oauthState" yaml:"oauthState"`
+	QRHandoff     bool                `mapstructure:"qrHandoff" yaml:"qrHandoff"`
 }
 
 func (c *Configuration) Validate() error {
 	if t := http.StatusText(c.StatusCode); t == "" {
 		return fmt.Errorf("invalid status code")
 	}
+	if c.OAuthState != "" && !c.OAuthCallback {
+		return fmt.Errorf("oauth state requires oauth callback mode")
+	}
 	return nil
 }
 
@@ -33,6 +39,13 @@ func SetFlags(cmd *cobra.Command) {
 	flags.Int(fs, "cmd.redirect.status", "status-code", "HTTP status code to send to client.")
 	flags.StringSliceP(fs, "cmd.redirect.header", "header", "H", `Header to send to client. Can be specified multiple times.
 Format: <HEADER NAME>=<HEADER VALUE>`)
+	flags.Bool(fs, "cmd.redirect.oauthcallback", "oauth-callback", `Act as the loopback redirect URI of an OAuth login flow.
+The code and state of the first callback are printed, or included in the report, and the browser is told it can close the tab.
+If a url is given, the browser is redirected there instead.`)
+	flags.String(fs, "cmd.redirect.oauthstate", "oauth-state", `State the OAuth callback must carry.
+Callbacks with any other state are rejected and oneshot keeps waiting.`)
+	flags.Bool(fs, "cmd.redirect.qrhandoff", "qr-handoff", `Instead of redirecting, serve a page showing the target url as a QR code
+so that it can be opened on another device.`)
 
 	cobra.AddTemplateFunc("redirectFlags", func() *pflag.FlagSet {
 		return fs
@@ -0,0 +1,235 @@
+package respond
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/commands"
+	"github.com/forestnode-io/oneshot/v2/pkg/commands/respond/configuration"
+	rootconfig "github.com/forestnode-io/oneshot/v2/pkg/configuration"
+	"github.com/forestnode-io/oneshot/v2/pkg/events"
+	oneshothttp "github.com/forestnode-io/oneshot/v2/pkg/net/http"
+	"github.com/forestnode-io/oneshot/v2/pkg/output"
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+)
+
+var errNoMatch = errors.New("request matched no rule")
+
+func New(config *rootconfig.Root) *Cmd {
+	return &Cmd{
+		config: config,
+	}
+}
+
+type Cmd struct {
+	cobraCommand *cobra.Command
+	config       *rootconfig.Root
+	spec         *Spec
+	// matched counts the requests that matched a rule, requests are handled one at a time.
+	matched int
+}
+
+func (c *Cmd) Cobra() *cobra.Command {
+	if c.cobraCommand != nil {
+		return c.cobraCommand
+	}
+
+	c.cobraCommand = &cobra.Command{
+		Use:   "respond --spec responses.yaml",
+		Short: "Answer requests with scripted responses",
+		Long: `Answer requests with scripted responses.
+Requests are matched against the rules of a YAML spec by method, path, query, headers and body,
+either with regular expressions or JSON paths into a JSON body. The first matching rule answers.
+Statuses, header values and bodies are Go templates with access to the request:
+
+	rules:
+	  - name: get user
+	    match:
+	      method: GET
+	      path: ^/users/(?P<id>\d+)$
+	    response:
+	      status: 200
+	      headers:
+	        Content-Type: application/json
+	      body: '{"id": {{ .Params.id }}, "agent": {{ json (.Header.Get "User-Agent") }}}'
+	  - name: create user
+	    match:
+	      method: POST
+	      json:
+	        $.user.name: ^[a-z]+$
+	    response:
+	      status: 201
+	      body: 'created {{ jsonPath "$.user.name" .JSON }}'
+	      delay: 500ms
+	    times: 1
+	default:
+	  status: 404
+	  body: no rule for {{ .Method }} {{ .Path }}
+
+Templates are given the request as .Method, .Path, .Host, .RemoteAddr, .Query, .Header, .Body,
+the decoded JSON body as .JSON, the named groups of the path expression as .Params and the rules name as .Rule.
+Besides the builtin template functions, json, jsonPath, upper, lower and now are available.
+
+oneshot exits once --matches requests have matched a rule, every exchange is included in the report.
+`,
+		RunE: c.setHandlerFunc,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if 0 < len(args) {
+				return output.UsageErrorF("respond takes no arguments, the rules are given with --spec")
+			}
+			return nil
+		},
+	}
+
+	c.cobraCommand.SetUsageTemplate(usageTemplate)
+	configuration.SetFlags(c.cobraCommand)
+
+	return c.cobraCommand
+}
+
+func (c *Cmd) setHandlerFunc(cmd *cobra.Command, args []string) error {
+	var (
+		ctx    = cmd.Context()
+		config = c.config.Subcommands.Respond
+	)
+
+	output.IncludeBody(ctx)
+
+	if config.Spec == "" {
+		return output.UsageErrorF("spec required")
+	}
+
+	var err error
+	if c.spec, err = ReadSpec(config.Spec); err != nil {
+		return output.UsageErrorF("%w", err)
+	}
+
+	commands.SetHTTPHandlerFunc(ctx, c.ServeHTTP)
+	return nil
+}
+
+func (c *Cmd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var (
+		ctx    = c.cobraCommand.Context()
+		log    = zerolog.Ctx(ctx)
+		config = c.config.Subcommands.Respond
+	)
+
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+
+	requestEvent := events.NewHTTPRequest(r)
+	if 0 < len(body) {
+		requestEvent.Body = body
+	}
+	events.Raise(ctx, requestEvent)
+
+	if err != nil {
+		events.Raise(ctx, events.ClientDisconnected{Err: err})
+		return
+	}
+
+	req := newRequest(r, body)
+	rule, params := c.spec.Find(req)
+
+	response := c.spec.Default
+	if rule != nil {
+		response = &rule.Response
+		req.Params = params
+		req.Rule = rule.Name
+	}
+
+	var (
+		status       = config.UnmatchedStatusCode
+		header       = make(http.Header)
+		responseBody = []byte(http.StatusText(status) + "\n")
+	)
+	if response != nil {
+		if status, header, responseBody, err = response.render(req); err != nil {
+			log.Error().Err(err).
+				Str("rule", req.Rule).
+				Msg("error rendering response")
+
+			status = http.StatusInternalServerError
+			header = make(http.Header)
+			responseBody = []byte(err.Error() + "\n")
+		} else if 0 < response.Delay {
+			select {
+			case <-time.After(response.Delay):
+			case <-r.Context().Done():
+			}
+		}
+	}
+
+	for key, values := range header {
+		w.Header()[key] = values
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", http.DetectContentType(responseBody))
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(responseBody)))
+	w.WriteHeader(status)
+	if _, err := w.Write(responseBody); err != nil {
+		log.Error().Err(err).
+			Msg("error writing response")
+	}
+
+	events.Raise(ctx, &events.HTTPResponse{
+		StatusCode: status,
+		Header:     w.Header().Clone(),
+		Body:       responseBody,
+	})
+
+	// a rule only counts as matched once its response rendered
+	if err != nil {
+		events.Raise(ctx, events.ClientDisconnected{Err: err})
+		return
+	}
+	if rule == nil {
+		events.Raise(ctx, events.ClientDisconnected{Err: errNoMatch})
+		return
+	}
+
+	rule.Count()
+	c.matched++
+	if 0 < config.Matches && config.Matches <= c.matched {
+		events.Success(ctx)
+		return
+	}
+
+	// the exchange is done but more are expected
+	w.(oneshothttp.ResponseWriter).IgnoreOutcome()
+	events.Raise(ctx, events.ClientDisconnected{})
+}
+
+func newRequest(r *http.Request, body []byte) *Request {
+	req := Request{
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Host:       r.Host,
+		RemoteAddr: r.RemoteAddr,
+		Query:      r.URL.Query(),
+		Header:     r.Header,
+		Body:       string(body),
+		Params:     map[string]string{},
+	}
+
+	// bodies are decoded as JSON when they say they are or, lacking a content type, when they can be
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if 0 < len(body) && (mediaType == "" || mediaType == "application/json" || mediaType == "text/json" ||
+		strings.HasSuffix(mediaType, "+json")) {
+		var v any
+		if err := json.Unmarshal(body, &v); err == nil {
+			req.JSON = v
+		}
+	}
+
+	return &req
+}
@@ -0,0 +1,44 @@
+package configuration
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/flags"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+type Configuration struct {
+	Spec                string `mapstructure:"spec" yaml:"spec"`
+	Matches             int    `mapstructure:"matches" yaml:"matches"`
+	UnmatchedStatusCode int    `mapstructure:"unmatchedstatus" yaml:"unmatchedstatus"`
+}
+
+func (c *Configuration) Validate() error {
+	if c.Matches < 0 {
+		return fmt.Errorf("invalid number of matches: %d", c.Matches)
+	}
+	if t := http.StatusText(c.UnmatchedStatusCode); t == "" {
+		return fmt.Errorf("invalid unmatched status code")
+	}
+	return nil
+}
+
+func (c *Configuration) Hydrate() error {
+	return nil
+}
+
+func SetFlags(cmd *cobra.Command) {
+	fs := pflag.NewFlagSet("respond flags", pflag.ContinueOnError)
+	defer cmd.Flags().AddFlagSet(fs)
+
+	flags.String(fs, "cmd.respond.spec", "spec", `Path to the YAML file holding the rules requests are matched against.`)
+	flags.Int(fs, "cmd.respond.matches", "matches", `Number of requests that must match a rule before oneshot exits.
+A value of 0 will cause oneshot to keep responding until it is interrupted or times out.`)
+	flags.Int(fs, "cmd.respond.unmatchedstatus", "unmatched-status-code", `HTTP status code sent to requests that match no rule, unless the spec has a default response.`)
+
+	cobra.AddTemplateFunc("respondFlags", func() *pflag.FlagSet {
+		return fs
+	})
+}
@@ -0,0 +1,450 @@
+package respond
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec is the set of rules requests are matched against, first match wins.
+//
+//	rules:
+//	  - name: create user
+//	    match:
+//	      method: POST
+//	      path: ^/users/(?P<id>\d+)$
+//	      headers:
+//	        Content-Type: json
+//	      json:
+//	        $.user.name: ^alice$
+//	    response:
+//	      status: 201
+//	      headers:
+//	        Content-Type: application/json
+//	      body: '{"id": "{{ .Params.id }}"}'
+//	default:
+//	  status: 404
+//	  body: no rule for {{ .Method }} {{ .Path }}
+type Spec struct {
+	Rules []*Rule `yaml:"rules"`
+	// Default answers requests that match no rule.
+	Default *Response `yaml:"default"`
+}
+
+type Rule struct {
+	Name     string   `yaml:"name"`
+	Match    Match    `yaml:"match"`
+	Response Response `yaml:"response"`
+	// Times is how many requests the rule answers before it stops matching, 0 means no limit.
+	Times int `yaml:"times"`
+
+	mu      sync.Mutex
+	matched int
+}
+
+// Match holds the conditions a request must meet, every condition but Method is a regular expression.
+// Named groups in the Path expression are handed to the response templates as .Params.
+type Match struct {
+	Method  string            `yaml:"method"`
+	Path    string            `yaml:"path"`
+	Query   map[string]string `yaml:"query"`
+	Headers map[string]string `yaml:"headers"`
+	Body    string            `yaml:"body"`
+	// JSON maps JSON paths into the request body, like $.user.emails[0], to the expression their value must match.
+	JSON map[string]string `yaml:"json"`
+
+	path    *regexp.Regexp
+	query   map[string]*regexp.Regexp
+	headers map[string]*regexp.Regexp
+	body    *regexp.Regexp
+	json    map[string]*regexp.Regexp
+}
+
+// Response is sent back to matching requests.
+// Status, header values and the body are Go templates executed with the Request.
+type Response struct {
+	Status  string            `yaml:"status"`
+	Headers map[string]string `yaml:"headers"`
+	Body    string            `yaml:"body"`
+	// BodyFile is a file holding the body template, relative to the spec.
+	BodyFile string `yaml:"bodyFile"`
+	// Delay is how long to wait before responding.
+	Delay time.Duration `yaml:"delay"`
+
+	status  *template.Template
+	headers map[string]*template.Template
+	body    *template.Template
+}
+
+// Request is what response templates are executed with.
+type Request struct {
+	Method     string
+	Path       string
+	Host       string
+	RemoteAddr string
+	Query      url.Values
+	Header     http.Header
+	Body       string
+	// JSON is the decoded request body if it is JSON.
+	JSON any
+	// Params holds the named groups of the matching rules path expression.
+	Params map[string]string
+	// Rule is the name of the matching rule.
+	Rule string
+}
+
+// ReadSpec reads and compiles the spec at path.
+func ReadSpec(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read spec: %w", err)
+	}
+
+	var spec Spec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("invalid spec: %w", err)
+	}
+	if len(spec.Rules) == 0 && spec.Default == nil {
+		return nil, errors.New("spec has no rules")
+	}
+
+	dir := filepath.Dir(path)
+	for i, rule := range spec.Rules {
+		name := rule.Name
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+		if err := rule.Match.compile(); err != nil {
+			return nil, fmt.Errorf("invalid match for rule %s: %w", name, err)
+		}
+		if err := rule.Response.compile(dir); err != nil {
+			return nil, fmt.Errorf("invalid response for rule %s: %w", name, err)
+		}
+	}
+	if spec.Default != nil {
+		if err := spec.Default.compile(dir); err != nil {
+			return nil, fmt.Errorf("invalid default response: %w", err)
+		}
+	}
+
+	return &spec, nil
+}
+
+// Find returns the first rule that matches req and hasn't run out of matches,
+// along with the named groups of its path expression.
+// The match only counts towards the rules Times once it is recorded with Count.
+func (s *Spec) Find(req *Request) (*Rule, map[string]string) {
+	for _, rule := range s.Rules {
+		params, ok := rule.Match.matches(req)
+		if !ok {
+			continue
+		}
+
+		rule.mu.Lock()
+		exhausted := 0 < rule.Times && rule.Times <= rule.matched
+		rule.mu.Unlock()
+		if !exhausted {
+			return rule, params
+		}
+	}
+	return nil, nil
+}
+
+// Count records that the rule answered a request.
+func (r *Rule) Count() {
+	r.mu.Lock()
+	r.matched++
+	r.mu.Unlock()
+}
+
+func (m *Match) compile() error {
+	var err error
+	if m.Path != "" {
+		if m.path, err = regexp.Compile(m.Path); err != nil {
+			return fmt.Errorf("path: %w", err)
+		}
+	}
+	if m.Body != "" {
+		if m.body, err = regexp.Compile(m.Body); err != nil {
+			return fmt.Errorf("body: %w", err)
+		}
+	}
+	if m.query, err = compileAll(m.Query); err != nil {
+		return fmt.Errorf("query: %w", err)
+	}
+	if m.headers, err = compileAll(m.Headers); err != nil {
+		return fmt.Errorf("headers: %w", err)
+	}
+	if m.json, err = compileAll(m.JSON); err != nil {
+		return fmt.Errorf("json: %w", err)
+	}
+	for path := range m.json {
+		if _, err := parseJSONPath(path); err != nil {
+			return fmt.Errorf("json: %w", err)
+		}
+	}
+	return nil
+}
+
+func compileAll(exprs map[string]string) (map[string]*regexp.Regexp, error) {
+	compiled := make(map[string]*regexp.Regexp, len(exprs))
+	for k, expr := range exprs {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+		compiled[k] = re
+	}
+	return compiled, nil
+}
+
+func (m *Match) matches(req *Request) (map[string]string, bool) {
+	if m.Method != "" && !strings.EqualFold(m.Method, req.Method) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	if m.path != nil {
+		groups := m.path.FindStringSubmatch(req.Path)
+		if groups == nil {
+			return nil, false
+		}
+		for i, name := range m.path.SubexpNames() {
+			if name != "" {
+				params[name] = groups[i]
+			}
+		}
+	}
+
+	for key, re := range m.query {
+		if !anyMatch(re, req.Query[key]) {
+			return nil, false
+		}
+	}
+	for key, re := range m.headers {
+		if !anyMatch(re, req.Header.Values(key)) {
+			return nil, false
+		}
+	}
+	if m.body != nil && !m.body.MatchString(req.Body) {
+		return nil, false
+	}
+	for path, re := range m.json {
+		if req.JSON == nil {
+			return nil, false
+		}
+		v, ok := lookupJSONPath(req.JSON, path)
+		if !ok || !re.MatchString(jsonString(v)) {
+			return nil, false
+		}
+	}
+
+	return params, true
+}
+
+func anyMatch(re *regexp.Regexp, values []string) bool {
+	for _, v := range values {
+		if re.MatchString(v) {
+			return true
+		}
+	}
+	return false
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"jsonPath": func(path string, v any) (any, error) {
+		if _, err := parseJSONPath(path); err != nil {
+			return nil, err
+		}
+		value, _ := lookupJSONPath(v, path)
+		return value, nil
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"now":   time.Now,
+}
+
+func (r *Response) compile(dir string) error {
+	var err error
+
+	status := r.Status
+	if status == "" {
+		status = strconv.Itoa(http.StatusOK)
+	}
+	if r.status, err = template.New("status").Funcs(templateFuncs).Parse(status); err != nil {
+		return fmt.Errorf("status: %w", err)
+	}
+
+	r.headers = make(map[string]*template.Template, len(r.Headers))
+	for name, value := range r.Headers {
+		if r.headers[name], err = template.New(name).Funcs(templateFuncs).Parse(value); err != nil {
+			return fmt.Errorf("header %s: %w", name, err)
+		}
+	}
+
+	body := r.Body
+	if r.BodyFile != "" {
+		if body != "" {
+			return errors.New("only one of body and bodyFile may be given")
+		}
+		path := r.BodyFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("unable to read body file: %w", err)
+		}
+		body = string(data)
+	}
+	if r.body, err = template.New("body").Funcs(templateFuncs).Parse(body); err != nil {
+		return fmt.Errorf("body: %w", err)
+	}
+
+	return nil
+}
+
+// render executes the response templates with req.
+func (r *Response) render(req *Request) (int, http.Header, []byte, error) {
+	var buf bytes.Buffer
+	if err := r.status.Execute(&buf, req); err != nil {
+		return 0, nil, nil, fmt.Errorf("status: %w", err)
+	}
+	status, err := strconv.Atoi(strings.TrimSpace(buf.String()))
+	if err != nil || http.StatusText(status) == "" {
+		return 0, nil, nil, fmt.Errorf("invalid status %q", buf.String())
+	}
+
+	header := make(http.Header)
+	for name, tmpl := range r.headers {
+		buf.Reset()
+		if err := tmpl.Execute(&buf, req); err != nil {
+			return 0, nil, nil, fmt.Errorf("header %s: %w", name, err)
+		}
+		header.Set(name, buf.String())
+	}
+
+	buf.Reset()
+	if err := r.body.Execute(&buf, req); err != nil {
+		return 0, nil, nil, fmt.Errorf("body: %w", err)
+	}
+
+	return status, header, buf.Bytes(), nil
+}
+
+// jsonPathStep is either an object key or an array index.
+type jsonPathStep struct {
+	key   string
+	index int
+	isKey bool
+}
+
+// parseJSONPath parses the subset of JSONPath made up of $ followed by
+// .key, ['key'] and [index] steps, e.g. $.users[0]['first name'].
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("invalid json path %q, must start with $", path)
+	}
+
+	var (
+		steps []jsonPathStep
+		rest  = path[1:]
+	)
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid json path %q, empty key", path)
+			}
+			steps = append(steps, jsonPathStep{key: rest[:end], isKey: true})
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid json path %q, unclosed [", path)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			if 2 <= len(inner) && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				steps = append(steps, jsonPathStep{key: inner[1 : len(inner)-1], isKey: true})
+				continue
+			}
+			index, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, fmt.Errorf("invalid json path %q, bad index %q", path, inner)
+			}
+			steps = append(steps, jsonPathStep{index: index})
+		default:
+			return nil, fmt.Errorf("invalid json path %q", path)
+		}
+	}
+
+	return steps, nil
+}
+
+// lookupJSONPath returns the value at path in v, a value decoded by encoding/json.
+func lookupJSONPath(v any, path string) (any, bool) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, false
+	}
+
+	for _, step := range steps {
+		if step.isKey {
+			obj, ok := v.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if v, ok = obj[step.key]; !ok {
+				return nil, false
+			}
+			continue
+		}
+
+		arr, ok := v.([]any)
+		if !ok {
+			return nil, false
+		}
+		index := step.index
+		if index < 0 {
+			index += len(arr)
+		}
+		if index < 0 || len(arr) <= index {
+			return nil, false
+		}
+		v = arr[index]
+	}
+
+	return v, true
+}
+
+// jsonString returns strings as they are and every other value as JSON.
+func jsonString(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,40 @@
+package respond
+
+const usageTemplate = `Respond options:
+{{ .LocalFlags | wrappedFlagUsages | trimTrailingWhitespaces }}
+
+Discovery options:
+{{ discoveryFlags | wrappedFlagUsages | trimTrailingWhitespaces }}
+
+Output options:
+{{ outputFlags | wrappedFlagUsages | trimTrailingWhitespaces }}
+
+Server options:
+{{ serverFlags | wrappedFlagUsages | trimTrailingWhitespaces }}
+
+Basic Authentication options:
+{{ basicAuthFlags | wrappedFlagUsages | trimTrailingWhitespaces }}
+
+OIDC options:
+{{ oidcFlags | wrappedFlagUsages | trimTrailingWhitespaces }}
+
+CORS options:
+{{ corsFlags | wrappedFlagUsages | trimTrailingWhitespaces }}
+
+Hook options:
+{{ hooksFlags | wrappedFlagUsages | trimTrailingWhitespaces }}
+
+NAT Traversal options:
+{{ "P2P options:" | indent 2 }}
+{{ p2pFlags | wrappedFlagUsages | trimTrailingWhitespaces | indent 4 }}
+{{ "Port mapping options:" | indent 2 }}
+{{ upnpFlags | wrappedFlagUsages | trimTrailingWhitespaces | indent 4 }}
+{{ "Tunnel options:" | indent 2 }}
+{{ tunnelFlags | wrappedFlagUsages | trimTrailingWhitespaces | indent 4 }}
+
+Configuration options:
+{{ configFlags | wrappedFlagUsages | trimTrailingWhitespaces }}
+
+Usage:
+  {{.UseLine}}
+`
@@ -1,6 +1,7 @@
 package root
 
 import (
+	"crypto/x509"
 	"fmt"
 	"net/http"
 	"os"
@@ -10,7 +11,7 @@ import (
 	"github.com/rs/cors"
 )
 
-func (r *rootCommand) configureServer() (string, error) {
+func (r *rootCommand) configureServer() (*oneshothttp.WebRTCTokens, error) {
 	var (
 		sConf      = r.config.Server
 		timeout    = sConf.Timeout
@@ -28,12 +29,21 @@ func (r *rootCommand) configureServer() (string, error) {
 		unauthenticatedViewBytes []byte
 		unauthenticatedStatus    int
 	)
-	if uname != "" || (uname != "" && passwd != "") {
+	var htpasswd *oneshothttp.HTPasswd
+	if baConf.HTPasswd != "" {
+		htpasswd, err = oneshothttp.ReadHTPasswd(baConf.HTPasswd)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read htpasswd file: %w", err)
+		}
+		htpasswd.UploadPage = r.uploadPage
+	}
+
+	if uname != "" || (uname != "" && passwd != "") || htpasswd != nil || r.config.OIDC.Enabled() {
 		viewPath := baConf.UnauthorizedPage
 		if viewPath != "" {
 			unauthenticatedViewBytes, err = os.ReadFile(viewPath)
 			if err != nil {
-				return "", fmt.Errorf("failed to read unauthorized page: %w", err)
+				return nil, fmt.Errorf("failed to read unauthorized page: %w", err)
 			}
 		}
 
@@ -50,16 +60,50 @@ func (r *rootCommand) configureServer() (string, error) {
 	}
 
 	noLoginTrigger := baConf.NoDialog
-	baMiddleware, baToken, err := oneshothttp.BasicAuthMiddleware(
+	baMiddleware, webRTCTokens, err := oneshothttp.BasicAuthMiddleware(
 		unauthenticatedHandler(!noLoginTrigger, unauthenticatedStatus, unauthenticatedViewBytes),
-		uname, passwd)
+		forbiddenHandler,
+		uname, passwd, htpasswd)
 	if err != nil {
-		return "", fmt.Errorf("failed to create basic auth middleware: %w", err)
+		return nil, fmt.Errorf("failed to create basic auth middleware: %w", err)
+	}
+
+	var oidcMiddleware oneshothttp.Middleware
+	if oidcConf := r.config.OIDC; oidcConf.Enabled() {
+		oidcMiddleware, err = oneshothttp.OIDCMiddleware(r.Context(), oneshothttp.OIDCConfig{
+			Issuer:         oidcConf.Issuer,
+			ClientID:       oidcConf.ClientID,
+			ClientSecret:   oidcConf.ClientSecret,
+			RedirectURL:    oidcConf.RedirectURL,
+			Scopes:         oidcConf.Scopes,
+			AllowedEmails:  oidcConf.AllowedEmails,
+			AllowedDomains: oidcConf.AllowedDomains,
+			AllowedGroups:  oidcConf.AllowedGroups,
+			GroupsClaim:    oidcConf.GroupsClaim,
+			SessionTTL:     oidcConf.SessionTTL,
+		}, unauthenticatedHandler(false, unauthenticatedStatus, unauthenticatedViewBytes))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create oidc middleware: %w", err)
+		}
+	}
+
+	var (
+		clientCAs            *x509.CertPool
+		clientCertMiddleware oneshothttp.Middleware
+	)
+	if sConf.TLSClientCA != "" {
+		clientCAs, err = oneshothttp.ReadClientCAs(sConf.TLSClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CAs: %w", err)
+		}
+		clientCertMiddleware = oneshothttp.ClientCertMiddleware(forbiddenHandler,
+			sConf.TLSClientAllowedSubjects,
+			sConf.TLSClientAllowedSANs)
 	}
 
 	maxReadSize, err := configuration.ParseSizeString(sConf.MaxReadSize)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse max read size: %w", err)
+		return nil, fmt.Errorf("failed to parse max read size: %w", err)
 	}
 
 	r.server = oneshothttp.NewServer(r.Context(), r.handler, goneHandler, []oneshothttp.Middleware{
@@ -68,12 +112,15 @@ func (r *rootCommand) configureServer() (string, error) {
 			Chain(oneshothttp.LimitReaderMiddleware(maxReadSize)).
 			Chain(oneshothttp.MiddlewareShim(corsMW)).
 			Chain(oneshothttp.BotsMiddleware(allowBots)).
-			Chain(baMiddleware),
+			Chain(baMiddleware).
+			Chain(oidcMiddleware).
+			Chain(clientCertMiddleware),
 	}...)
 	r.server.TLSCert = sConf.TLSCert
 	r.server.TLSKey = sConf.TLSKey
+	r.server.ClientCAs = clientCAs
 	r.server.Timeout = timeout
 	r.server.ExitOnFail = exitOnFail
 
-	return baToken, nil
+	return webRTCTokens, nil
 }
@@ -7,6 +7,7 @@ import (
 	"os"
 
 	oneshotnet "github.com/forestnode-io/oneshot/v2/pkg/net"
+	oneshothttp "github.com/forestnode-io/oneshot/v2/pkg/net/http"
 	"github.com/forestnode-io/oneshot/v2/pkg/net/webrtc/signallingserver"
 	"github.com/forestnode-io/oneshot/v2/pkg/net/webrtc/signallingserver/messages"
 	"golang.org/x/crypto/bcrypt"
@@ -70,7 +71,21 @@ func (r *rootCommand) sendArrivalToDiscoveryServer(ctx context.Context, cmd stri
 			bam      *messages.BasicAuth
 		)
 
-		if username != "" || password != "" {
+		if baConf.HTPasswd != "" {
+			htpasswd, err := oneshothttp.ReadHTPasswd(baConf.HTPasswd)
+			if err != nil {
+				return fmt.Errorf("failed to read htpasswd file: %w", err)
+			}
+			// the htpasswd hashes are already bcrypt or argon2, so they are passed along as is
+			bam = &messages.BasicAuth{}
+			for _, user := range htpasswd.Users {
+				uHash := sha256.Sum256([]byte(user.Username))
+				bam.Users = append(bam.Users, &messages.BasicAuthUser{
+					UsernameHash: uHash[:],
+					PasswordHash: []byte(user.Hash),
+				})
+			}
+		} else if username != "" || password != "" {
 			bam = &messages.BasicAuth{}
 			if username != "" {
 				uHash := sha256.Sum256([]byte(username))
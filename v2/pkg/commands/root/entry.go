@@ -8,18 +8,24 @@ import (
 	"sync"
 
 	"github.com/forestnode-io/oneshot/v2/pkg/commands"
+	"github.com/forestnode-io/oneshot/v2/pkg/commands/capture"
 	configcmd "github.com/forestnode-io/oneshot/v2/pkg/commands/config"
 	discoveryserver "github.com/forestnode-io/oneshot/v2/pkg/commands/discovery-server"
 	"github.com/forestnode-io/oneshot/v2/pkg/commands/exec"
+	"github.com/forestnode-io/oneshot/v2/pkg/commands/get"
+	netcmd "github.com/forestnode-io/oneshot/v2/pkg/commands/net"
 	p2p "github.com/forestnode-io/oneshot/v2/pkg/commands/p2p"
+	"github.com/forestnode-io/oneshot/v2/pkg/commands/put"
 	"github.com/forestnode-io/oneshot/v2/pkg/commands/receive"
 	"github.com/forestnode-io/oneshot/v2/pkg/commands/redirect"
+	"github.com/forestnode-io/oneshot/v2/pkg/commands/respond"
 	"github.com/forestnode-io/oneshot/v2/pkg/commands/rproxy"
 	"github.com/forestnode-io/oneshot/v2/pkg/commands/send"
 	"github.com/forestnode-io/oneshot/v2/pkg/commands/version"
 	"github.com/forestnode-io/oneshot/v2/pkg/configuration"
 	"github.com/forestnode-io/oneshot/v2/pkg/events"
 	"github.com/forestnode-io/oneshot/v2/pkg/flagargs"
+	"github.com/forestnode-io/oneshot/v2/pkg/hooks"
 	oneshothttp "github.com/forestnode-io/oneshot/v2/pkg/net/http"
 	"github.com/forestnode-io/oneshot/v2/pkg/output"
 	"github.com/pion/webrtc/v3"
@@ -37,7 +43,11 @@ type rootCommand struct {
 
 	webrtcConfig *webrtc.Configuration
 
+	hooks *hooks.Hooks
+
 	handler http.HandlerFunc
+	// uploadPage is set when the handlers GET requests only serve a page to upload from
+	uploadPage bool
 
 	config *configuration.Root
 
@@ -71,9 +81,15 @@ func ExecuteContext(ctx context.Context) error {
 	root.setSubCommands()
 
 	ctx = commands.WithHTTPHandlerFuncSetter(ctx, &root.handler)
+	ctx = commands.WithUploadPageSetter(ctx, &root.uploadPage)
 	ctx = commands.WithClosers(ctx, &root.closers)
 
-	events.RegisterEventListener(ctx, output.SetEventsChan)
+	// output subscribes first so that it receives the raised events themselves
+	// and blocks raising rather than miss any of them
+	output.SetEventsChan(ctx, events.Subscribe(ctx, events.SubscribeOptions{
+		Buffer: 16,
+		Policy: events.Block,
+	}).C)
 
 	root.SetHelpTemplate(helpTemplate)
 	root.SetUsageTemplate(usageTemplate)
@@ -139,10 +155,15 @@ func subCommands(config *configuration.Root) []*cobra.Command {
 		exec.New(config).Cobra(),
 		receive.New(config).Cobra(),
 		redirect.New(config).Cobra(),
+		respond.New(config).Cobra(),
+		capture.New(config).Cobra(),
 		send.New(config).Cobra(),
 		rproxy.New(config).Cobra(),
 		p2p.New(config).Cobra(),
+		netcmd.New(config).Cobra(),
 		discoveryserver.New(config).Cobra(),
+		get.New(config).Cobra(),
+		put.New(config).Cobra(),
 		version.New().Cobra(),
 	}
 }
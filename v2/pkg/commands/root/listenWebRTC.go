@@ -9,13 +9,14 @@ import (
 	"time"
 
 	"github.com/forestnode-io/oneshot/v2/pkg/configuration"
+	oneshothttp "github.com/forestnode-io/oneshot/v2/pkg/net/http"
 	"github.com/forestnode-io/oneshot/v2/pkg/net/webrtc/sdp/signallers"
 	"github.com/forestnode-io/oneshot/v2/pkg/net/webrtc/server"
 	"github.com/forestnode-io/oneshot/v2/pkg/net/webrtc/signallingserver"
 	"github.com/rs/zerolog"
 )
 
-func (r *rootCommand) listenWebRTC(ctx context.Context, bat, portMapAddr string, iceGatherTimeout time.Duration) error {
+func (r *rootCommand) listenWebRTC(ctx context.Context, tokens *oneshothttp.WebRTCTokens, portMapAddr string, iceGatherTimeout time.Duration) error {
 	r.wg.Add(1)
 	defer r.wg.Done()
 
@@ -39,7 +40,7 @@ func (r *rootCommand) listenWebRTC(ctx context.Context, bat, portMapAddr string,
 	}
 
 	// create a webrtc server with the same handler as the http server
-	a := server.NewServer(r.webrtcConfig, bat, iceGatherTimeout, http.HandlerFunc(r.server.ServeHTTP))
+	a := server.NewServer(r.webrtcConfig, tokens, iceGatherTimeout, http.HandlerFunc(r.server.ServeHTTP))
 	defer a.Wait()
 
 	log.Info().Msg("starting p2p discovery mechanism")
@@ -4,16 +4,36 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/forestnode-io/oneshot/v2/pkg/events"
-	upnpigd "github.com/forestnode-io/oneshot/v2/pkg/net/upnp-igd"
+	"github.com/forestnode-io/oneshot/v2/pkg/net/portmap"
 	"github.com/forestnode-io/oneshot/v2/pkg/output"
+	"github.com/jackpal/gateway"
 	"github.com/rs/zerolog"
 )
 
-func (r *rootCommand) handlePortMap(ctx context.Context) (string, func(), error) {
+// portMapping is a port mapped on a gateway for as long as oneshot runs.
+type portMapping struct {
+	mapper  portmap.Mapper
+	mapping *portmap.Mapping
+}
+
+// portMappings keeps the port mappings alive and removes them once oneshot is done.
+type portMappings struct {
+	mappings []portMapping
+	lifetime time.Duration
+	timeout  time.Duration
+
+	wg     sync.WaitGroup
+	cancel func()
+}
+
+func (r *rootCommand) handlePortMap(ctx context.Context) ([]string, *portMappings, error) {
 	var (
 		log = zerolog.Ctx(ctx)
 
@@ -24,13 +44,17 @@ func (r *rootCommand) handlePortMap(ctx context.Context) (string, func(), error)
 		mapPort             = pmConf.Enabled
 		port                = r.config.Server.Port
 
-		cancel = func() {}
+		pms = &portMappings{
+			lifetime: portMappingDuration,
+			timeout:  pmConf.Timeout,
+			cancel:   func() {},
+		}
 	)
 
 	userSetUPnPConfig := 0 < externalPort || 0 < portMappingDuration
 
 	if !mapPort && !userSetUPnPConfig {
-		return "", cancel, nil
+		return nil, pms, nil
 	}
 
 	finishSpinning := output.DisplaySpinner(ctx,
@@ -39,75 +63,154 @@ func (r *rootCommand) handlePortMap(ctx context.Context) (string, func(), error)
 		"negotiating port mapping ... done",
 		[]string{".", "..", "...", ".."},
 	)
+	defer finishSpinning()
+
+	var mappers []portmap.Mapper
+	for _, protocol := range pmConf.Protocols {
+		switch protocol {
+		case portmap.ProtocolUPnP:
+			mappers = append(mappers, &portmap.UPnP{
+				DiscoveryTimeout: pmConf.Timeout,
+				Client:           http.DefaultClient,
+			})
+		case portmap.ProtocolNATPMP, portmap.ProtocolPCP:
+			gw, err := gatewayAddress(pmConf.Gateway)
+			if err != nil {
+				log.Debug().Err(err).
+					Str("protocol", protocol).
+					Msg("skipping port mapping protocol")
+				continue
+			}
+			if protocol == portmap.ProtocolNATPMP {
+				mappers = append(mappers, &portmap.NATPMP{Gateway: gw, Timeout: pmConf.Timeout})
+			} else {
+				mappers = append(mappers, &portmap.PCP{Gateway: gw, Timeout: pmConf.Timeout})
+			}
+		}
+	}
 
-	discoveryTimeout := pmConf.Timeout
-	devChan, err := upnpigd.Discover(ctx, "oneshot", discoveryTimeout, http.DefaultClient)
+	mapper, mapping, err := portmap.MapPort(ctx, mappers, port, externalPort, portMappingDuration)
 	if err != nil {
-		return "", cancel, fmt.Errorf("failed to discover UPnP IGD: %w", err)
+		return nil, pms, fmt.Errorf("failed to add port mapping: %w", err)
 	}
+	pms.mappings = append(pms.mappings, portMapping{mapper: mapper, mapping: mapping})
+	logMapping(log, mapping, "added port mapping")
+
+	if pmConf.IPv6 {
+		gw := pmConf.IPv6Gateway
+		if gw == "" {
+			gw = portmap.PCPAnycast
+		} else if _, _, err := net.SplitHostPort(gw); err != nil {
+			gw = net.JoinHostPort(gw, strconv.Itoa(portmap.GatewayPort))
+		}
 
-	devs := make([]*upnpigd.Device, 0)
-	for dev := range devChan {
-		devs = append(devs, dev)
+		pinholer := &portmap.PCP{Gateway: gw, Timeout: pmConf.Timeout}
+		pinhole, err := pinholer.Map(ctx, port, port, portMappingDuration)
+		if err != nil {
+			log.Error().Err(err).
+				Msg("failed to open IPv6 pinhole")
+		} else {
+			pms.mappings = append(pms.mappings, portMapping{mapper: pinholer, mapping: pinhole})
+			logMapping(log, pinhole, "opened IPv6 pinhole")
+		}
 	}
 
-	if len(devs) == 0 {
-		return "", cancel, errors.New("no UPnP IGD devices found")
+	scheme := "http"
+	if r.config.Server.TLSCert != "" {
+		scheme = "https"
 	}
-
-	dev := devs[0]
-	if err := dev.AddPortMapping(ctx, "TCP", externalPort, port, "oneshot", portMappingDuration); err != nil {
-		finishSpinning()
-		return "", cancel, fmt.Errorf("failed to add port mapping: %w", err)
+	externalAddrs := make([]string, 0, len(pms.mappings))
+	for _, pm := range pms.mappings {
+		externalAddrs = append(externalAddrs, fmt.Sprintf("%s://%s", scheme, pm.mapping.ExternalAddress()))
 	}
 
-	log.Info().
-		Int("internal-port", port).
-		Int("external-port", externalPort).
-		Str("duration", portMappingDuration.String()).
-		Msg("added port mapping")
+	return externalAddrs, pms, nil
+}
 
-	externalIP, err := dev.GetExternalIP(ctx)
-	if err != nil {
-		finishSpinning()
-		return "", cancel, fmt.Errorf("failed to get external address: %w", err)
+// renew keeps the port mappings alive while active reports that clients are connected.
+// oneshot exits once a mapping expires, same as if it had timed out.
+func (pms *portMappings) renew(ctx context.Context, active func() bool) {
+	if len(pms.mappings) == 0 {
+		return
 	}
-	externalAddr := fmt.Sprintf("%s:%d", externalIP, externalPort)
-
-	log.Info().
-		Str("external-address", externalAddr).
-		Msg("got external address")
 
-	finishSpinning()
+	var log = zerolog.Ctx(ctx)
+
+	ctx, cancel := context.WithCancel(ctx)
+	pms.cancel = cancel
+
+	for i := range pms.mappings {
+		pm := &pms.mappings[i]
+		pms.wg.Add(1)
+		go func() {
+			defer pms.wg.Done()
+			err := portmap.Renew(ctx, pm.mapper, pm.mapping, pms.lifetime, active, func(m *portmap.Mapping) {
+				pm.mapping = m
+				logMapping(log, m, "renewed port mapping")
+			})
+			if err == nil {
+				return
+			}
+			if errors.Is(err, portmap.ErrExpired) {
+				log.Info().
+					Str("protocol", pm.mapper.Protocol()).
+					Msg("port mapping expired")
+			} else {
+				log.Error().Err(err).
+					Str("protocol", pm.mapper.Protocol()).
+					Msg("failed to renew port mapping")
+			}
+			events.Stop(ctx)
+		}()
+	}
+}
 
-	// exit after the port mapping expires
-	// TODO(raphaelreyna): this can be handled better.
-	// Not sure about what happens when the port mapping expires while the server has
-	// active connections.
-	// Look into maybe refreshing the port mapping every so often while there are active
-	// connections.
-	t := time.AfterFunc(portMappingDuration, func() {
-		events.Stop(ctx)
-	})
+// close stops renewing the port mappings and removes them from the gateway.
+func (pms *portMappings) close(ctx context.Context) {
+	var log = zerolog.Ctx(ctx)
 
-	scheme := "http"
-	if r.config.Server.TLSCert != "" {
-		scheme = "https"
-	}
-	externalAddr = fmt.Sprintf("%s://%s", scheme, externalAddr)
+	pms.cancel()
+	pms.wg.Wait()
 
-	return externalAddr, func() {
-		t.Stop()
-		if err := dev.DeletePortMapping(ctx, "TCP", externalPort); err != nil {
+	for _, pm := range pms.mappings {
+		// the run may have been cancelled, give the gateway a moment to remove the mapping regardless
+		uctx, cancel := context.WithTimeout(context.Background(), pms.timeout)
+		err := pm.mapper.Unmap(uctx, pm.mapping)
+		cancel()
+		if err != nil {
 			log.Error().Err(err).
-				Int("internal-port", port).
-				Int("external-port", externalPort).
+				Int("internal-port", pm.mapping.InternalPort).
+				Int("external-port", pm.mapping.ExternalPort).
 				Msg("failed to delete port mapping")
+			continue
 		}
 		log.Info().
-			Int("internal-port", port).
-			Int("external-port", externalPort).
+			Int("internal-port", pm.mapping.InternalPort).
+			Int("external-port", pm.mapping.ExternalPort).
 			Msg("deleted port mapping")
+	}
+}
+
+// gatewayAddress returns the address of the NAT-PMP and PCP server, which is the default gateway if addr is empty.
+func gatewayAddress(addr string) (string, error) {
+	if addr == "" {
+		ip, err := gateway.DiscoverGateway()
+		if err != nil {
+			return "", fmt.Errorf("unable to discover default gateway: %w", err)
+		}
+		addr = ip.String()
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, strconv.Itoa(portmap.GatewayPort))
+	}
+	return addr, nil
+}
 
-	}, nil
+func logMapping(log *zerolog.Logger, m *portmap.Mapping, msg string) {
+	log.Info().
+		Str("protocol", m.Protocol).
+		Int("internal-port", m.InternalPort).
+		Str("external-address", m.ExternalAddress()).
+		Str("duration", m.Lifetime.String()).
+		Msg(msg)
 }
@@ -7,10 +7,12 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"os"
 	"strings"
 
 	"github.com/forestnode-io/oneshot/v2/pkg/configuration"
 	"github.com/forestnode-io/oneshot/v2/pkg/events"
+	"github.com/forestnode-io/oneshot/v2/pkg/hooks"
 	oneshotnet "github.com/forestnode-io/oneshot/v2/pkg/net"
 	"github.com/forestnode-io/oneshot/v2/pkg/net/webrtc/signallingserver"
 	"github.com/forestnode-io/oneshot/v2/pkg/net/webrtc/signallingserver/headers"
@@ -29,6 +31,21 @@ import (
 func (r *rootCommand) init(cmd *cobra.Command, args []string) error {
 	var ctx = cmd.Context()
 
+	profile, _ := cmd.Flags().GetString("profile")
+	trustProject, _ := cmd.Flags().GetBool("trust-project-config")
+	if err := configuration.Load(profile, trustProject); err != nil {
+		return output.UsageErrorF("invalid configuration: %w", err)
+	}
+	log := zerolog.Ctx(ctx)
+	if path := configuration.ProjectConfigPath(); path != "" {
+		log.Info().Str("path", path).
+			Msg("loaded project configuration file")
+	}
+	if path := configuration.UntrustedProjectConfigPath(); path != "" {
+		log.Warn().Str("path", path).
+			Msg("ignoring untrusted project configuration file, use --trust-project-config or add it to trustedProjectConfigs to load it")
+	}
+
 	err := viper.Unmarshal(r.config)
 	if err != nil {
 		return fmt.Errorf("failed to unmarshal configuration: %w", err)
@@ -54,7 +71,7 @@ func (r *rootCommand) init(cmd *cobra.Command, args []string) error {
 				return fmt.Errorf("invalid output format: %s", r.config.Output.Format)
 			}
 			format = parts[0]
-			if format != "json" {
+			if format != "json" && format != "ndjson" {
 				return fmt.Errorf("invalid output format: %s", r.config.Output.Format)
 			}
 
@@ -70,10 +87,32 @@ func (r *rootCommand) init(cmd *cobra.Command, args []string) error {
 
 		output.SetFormat(ctx, format)
 		output.SetFormatOpts(ctx, opts...)
+		output.SetVersion(ctx, version.Version, version.APIVersion)
 	}
+	output.SetProgressInterval(ctx, r.config.Output.ProgressInterval)
 	if r.config.Output.NoColor {
 		output.NoColor(ctx)
 	}
+	if r.config.Output.TUI && !output.EnableTUI(ctx) {
+		log.Warn().
+			Msg("stderr is not a terminal, not showing the dashboard")
+	}
+
+	if hc := r.config.Hooks; hc.Enabled() {
+		var errs io.Writer = os.Stderr
+		if r.config.Output.Quiet {
+			errs = io.Discard
+		}
+		r.hooks = hooks.New(hooks.Config{
+			OnRequest: hc.OnRequest,
+			OnSuccess: hc.OnSuccess,
+			OnFailure: hc.OnFailure,
+			Webhooks:  hc.Webhook,
+			Timeout:   hc.Timeout,
+			Errors:    errs,
+		})
+		r.hooks.Subscribe(ctx)
+	}
 
 	return nil
 }
@@ -94,11 +133,10 @@ func (r *rootCommand) errorSuppressor(next func(cmd *cobra.Command, args []strin
 // runServer starts the actual oneshot http server.
 // this should only be run after a subcommand since it relies on
 // a subcommand to have set r.handler.
-func (r *rootCommand) runServer(cmd *cobra.Command, args []string) error {
+func (r *rootCommand) runServer(cmd *cobra.Command, args []string) (err error) {
 	var (
 		ctx, cancel = context.WithCancel(cmd.Context())
 		log         = zerolog.Ctx(ctx)
-		err         error
 
 		webRTCError error
 	)
@@ -111,12 +149,19 @@ func (r *rootCommand) runServer(cmd *cobra.Command, args []string) error {
 	}
 
 	defer func() {
+		events.Raise(ctx, shutdownEvent(cmd.Context(), err))
+
 		log.Debug().Msg("stopping events")
 		events.Stop(ctx)
 
 		log.Debug().Msg("waiting for output to finish")
 		output.Wait(ctx)
 
+		if r.hooks != nil {
+			log.Debug().Msg("waiting for hooks to finish")
+			r.hooks.Wait()
+		}
+
 		log.Debug().Msg("waiting for http server to close")
 		r.wg.Wait()
 		log.Debug().Msg("all network connections closed")
@@ -127,14 +172,26 @@ func (r *rootCommand) runServer(cmd *cobra.Command, args []string) error {
 	}
 
 	// handle port mapping ( this can take a while )
-	externalAddr_UPnP, cancelPortMapping, err := r.handlePortMap(ctx)
+	externalAddrs_PortMap, portMappings, err := r.handlePortMap(ctx)
 	if err != nil {
 		log.Error().Err(err).
 			Msg("failed to negotiate port mapping")
 
 		return output.WrapPrintable(fmt.Errorf("failed to negotiate port mapping: %w", err))
 	}
-	defer cancelPortMapping()
+	defer portMappings.close(ctx)
+
+	// handle tunnel ( this can take a while too )
+	tun, err := r.openTunnel(ctx)
+	if err != nil {
+		log.Error().Err(err).
+			Msg("failed to open tunnel")
+
+		return output.WrapPrintable(fmt.Errorf("failed to open tunnel: %w", err))
+	}
+	if tun != nil {
+		defer tun.Close()
+	}
 
 	// finalize connection to discovery server
 	dsConfig := r.config.Discovery
@@ -165,18 +222,29 @@ func (r *rootCommand) runServer(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	baToken, err := r.configureServer()
+	webRTCTokens, err := r.configureServer()
 	if err != nil {
 		log.Error().Err(err).
 			Msg("failed to configure server")
 
 		return output.WrapPrintable(fmt.Errorf("failed to configure server: %w", err))
 	}
+	output.SetDashboardControls(ctx, output.DashboardControls{
+		Kick:          r.server.Kick,
+		ExtendTimeout: r.server.ExtendTimeout,
+		Deadline:      r.server.TimeoutDeadline,
+		End:           cancel,
+	})
+	portMappings.renew(ctx, r.server.HasClients)
 
 	if r.config.NATTraversal.IsUsingWebRTC() {
 		go func() {
 			iceGatherTimeout := r.config.NATTraversal.P2P.ICEGatherTimeout
-			if err := r.listenWebRTC(ctx, externalAddr_UPnP, baToken, iceGatherTimeout); err != nil {
+			var portMapAddr string
+			if 0 < len(externalAddrs_PortMap) {
+				portMapAddr = externalAddrs_PortMap[0]
+			}
+			if err := r.listenWebRTC(ctx, webRTCTokens, portMapAddr, iceGatherTimeout); err != nil {
 				if errors.Is(err, signallingserver.ErrClosedByUser) {
 					log.Debug().
 						Msg("discovery server closed connection by user request")
@@ -213,30 +281,15 @@ func (r *rootCommand) runServer(cmd *cobra.Command, args []string) error {
 		}()
 	}
 
-	port := r.config.Server.Port
-	userFacingAddr := ""
-	ds := signallingserver.GetDiscoveryServer(ctx)
-	if ds != nil {
-		userFacingAddr = ds.AssignedURL
-	}
-
-	if userFacingAddr == "" {
-		userFacingAddr = externalAddr_UPnP
+	externalAddrs := externalAddrs_PortMap
+	if ds := signallingserver.GetDiscoveryServer(ctx); ds != nil && ds.AssignedURL != "" {
+		externalAddrs = []string{ds.AssignedURL}
 	}
-	if userFacingAddr == "" {
-		// if we weren't given a listening addr,
-		// try giving the ip address that can reach the
-		// default gateway in the print out
-		sourceIP, err := oneshotnet.GetSourceIP("", 80)
-		if err == nil {
-			userFacingAddr = fmt.Sprintf("%s://%s:%d", "http", sourceIP, port)
-		} else {
-			userFacingAddr = fmt.Sprintf("%s://%s:%d", "http", "localhost", port)
-		}
+	if tun != nil {
+		externalAddrs = append([]string{tun.URL()}, externalAddrs...)
 	}
 
-	listeningAddr := oneshotfmt.Address(r.config.Server.Host, port)
-	err = r.listenAndServe(ctx, listeningAddr, userFacingAddr)
+	err = r.listenAndServe(ctx, externalAddrs, tun)
 	if err != nil {
 		log.Error().Err(err).
 			Msg("failed to listen for http connections")
@@ -246,40 +299,161 @@ func (r *rootCommand) runServer(cmd *cobra.Command, args []string) error {
 	return err
 }
 
-func (r *rootCommand) listenAndServe(ctx context.Context, listeningAddr, userFacingAddr string) error {
+// listenAndServe listens on every configured address, as well as tun if not nil, and serves until the server shuts down.
+// If there are no external addresses, the addresses shown to the user are worked out from the listeners.
+func (r *rootCommand) listenAndServe(ctx context.Context, externalAddrs []string, tun net.Listener) error {
 	var (
 		webrtcOnly = r.config.NATTraversal.P2P.Only
+		addrs      = r.config.Server.ListenAddresses()
 
-		l   net.Listener
-		err error
+		listeners []net.Listener
+		l         net.Listener
 	)
 
 	if !webrtcOnly {
-		l, err = net.Listen("tcp", listeningAddr)
-		if err != nil {
-			return output.WrapPrintable(err)
+		for _, addr := range addrs {
+			al, err := addr.Listen()
+			if err != nil {
+				for _, l := range listeners {
+					l.Close()
+				}
+				return output.WrapPrintable(err)
+			}
+			listeners = append(listeners, al)
 		}
+
+	}
+
+	all := listeners
+	if tun != nil {
+		all = append(all[:len(all):len(all)], tun)
+	}
+	switch len(all) {
+	case 0:
+	case 1:
+		l = all[0]
+	default:
+		l = oneshotnet.NewMultiListener(all...)
+	}
+	if l != nil {
 		defer l.Close()
 	}
 
+	userFacingAddrs := externalAddrs
+	if len(userFacingAddrs) == 0 {
+		userFacingAddrs = r.userFacingAddresses(addrs, listeners)
+	}
+
 	// if we are using nat traversal show the user the external address
 	if r.config.Output.QRCode {
-		output.WriteListeningOnQR(ctx, userFacingAddr)
+		output.WriteListeningOnQR(ctx, userFacingAddrs[0])
 	} else {
-		output.WriteListeningOn(ctx, userFacingAddr)
+		output.WriteListeningOn(ctx, userFacingAddrs[0])
+	}
+	for _, addr := range userFacingAddrs[1:] {
+		output.WriteListeningOn(ctx, addr)
 	}
+	listening := events.Listening{
+		Address: userFacingAddrs[0],
+	}
+	if 1 < len(userFacingAddrs) {
+		listening.Addresses = userFacingAddrs
+	}
+	events.Raise(ctx, &listening)
 
 	return r.server.Serve(ctx, l)
 }
 
+// userFacingAddresses returns the urls clients can use to reach the listeners.
+// Listeners on every interface are shown with the address that reaches the default gateway,
+// as well as the address that reaches the IPv6 internet if they accept IPv6 connections.
+func (r *rootCommand) userFacingAddresses(addrs []oneshotnet.ListenAddress, listeners []net.Listener) []string {
+	var (
+		scheme = "http"
+		urls   []string
+		seen   = make(map[string]struct{})
+	)
+	if r.config.Server.TLSCert != "" {
+		scheme = "https"
+	}
+	add := func(host string, port int) {
+		u := fmt.Sprintf("%s://%s", scheme, oneshotfmt.Address(host, port))
+		if _, ok := seen[u]; !ok {
+			seen[u] = struct{}{}
+			urls = append(urls, u)
+		}
+	}
+	sourceIP := func() string {
+		ip, err := oneshotnet.GetSourceIP("", 80)
+		if err != nil {
+			return "localhost"
+		}
+		return ip
+	}
+
+	for i, l := range listeners {
+		switch addr := l.Addr().(type) {
+		case *net.UnixAddr:
+			urls = append(urls, "unix://"+addr.Name)
+		case *net.TCPAddr:
+			if !addr.IP.IsUnspecified() {
+				add(addr.IP.String(), addr.Port)
+				continue
+			}
+
+			network := addrs[i].Network
+			if network != "tcp6" {
+				add(sourceIP(), addr.Port)
+			}
+			// tcp listeners on [::] accept both IPv4 and IPv6 connections
+			if network == "tcp6" || (network == "tcp" && addr.IP.To4() == nil) {
+				if ip, err := oneshotnet.GetSourceIP6(); err == nil {
+					add(ip, addr.Port)
+				} else if network == "tcp6" {
+					add("::1", addr.Port)
+				}
+			}
+		}
+	}
+
+	// only listening over webrtc
+	if len(urls) == 0 {
+		add(sourceIP(), r.config.Server.Port)
+	}
+
+	return urls
+}
+
 var ErrTimeout = errors.New("timeout")
 
+// shutdownEvent explains why oneshot is shutting down after runServer returned err.
+func shutdownEvent(ctx context.Context, err error) *events.Shutdown {
+	var e events.Shutdown
+	switch {
+	case events.Succeeded(ctx):
+		e.Reason = events.ShutdownSuccess
+	case events.GetExitCode(ctx) == events.ExitCodeTimeoutFailure:
+		e.Reason = events.ShutdownTimeout
+	case ctx.Err() != nil:
+		e.Reason = events.ShutdownInterrupted
+	case err != nil:
+		e.Reason = events.ShutdownError
+	default:
+		e.Reason = events.ShutdownStopped
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	return &e
+}
+
 func unauthenticatedHandler(triggerLogin bool, statCode int, content []byte) http.HandlerFunc {
 	if statCode == 0 {
 		statCode = http.StatusUnauthorized
 	}
 	if content == nil {
 		return func(w http.ResponseWriter, r *http.Request) {
+			raiseAuthFailure(r)
 			if triggerLogin {
 				w.Header().Set("WWW-Authenticate", `Basic realm="oneshot"`)
 			}
@@ -288,6 +462,7 @@ func unauthenticatedHandler(triggerLogin bool, statCode int, content []byte) htt
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
+		raiseAuthFailure(r)
 		if triggerLogin {
 			w.Header().Set("WWW-Authenticate", `Basic realm="oneshot"`)
 		}
@@ -296,6 +471,21 @@ func unauthenticatedHandler(triggerLogin bool, statCode int, content []byte) htt
 	}
 }
 
+// forbiddenHandler turns away authenticated users that lack permission for the request.
+func forbiddenHandler(w http.ResponseWriter, r *http.Request) {
+	raiseAuthFailure(r)
+	w.WriteHeader(http.StatusForbidden)
+}
+
+func raiseAuthFailure(r *http.Request) {
+	events.Raise(r.Context(), &events.AuthFailure{
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		RemoteAddr: r.RemoteAddr,
+		User:       events.UserFromContext(r.Context()),
+	})
+}
+
 func corsOptionsFromConfig(config *configuration.CORS) *cors.Options {
 	if config == nil {
 		return &cors.Options{}
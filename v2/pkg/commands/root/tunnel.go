@@ -0,0 +1,110 @@
+package root
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/net/tunnel"
+	"github.com/forestnode-io/oneshot/v2/pkg/net/webrtc/signallingserver"
+	"github.com/forestnode-io/oneshot/v2/pkg/net/webrtc/signallingserver/messages"
+	"github.com/forestnode-io/oneshot/v2/pkg/output"
+	"github.com/forestnode-io/oneshot/v2/pkg/version"
+	"github.com/rs/zerolog"
+)
+
+// openTunnel opens the configured tunnel, if any.
+// The returned tunnels url is where oneshot is reachable from anywhere.
+func (r *rootCommand) openTunnel(ctx context.Context) (tunnel.Tunnel, error) {
+	var (
+		log  = zerolog.Ctx(ctx)
+		conf = r.config.NATTraversal.Tunnel
+
+		provider tunnel.Provider
+	)
+
+	switch conf.Provider {
+	case "":
+		return nil, nil
+	case tunnel.ProviderSSH:
+		scheme := "http"
+		if r.config.Server.TLSCert != "" {
+			scheme = "https"
+		}
+		sc := conf.SSH
+		provider = &tunnel.SSH{
+			Host:                  sc.Host,
+			IdentityFile:          sc.IdentityFile,
+			KnownHostsFile:        sc.KnownHostsFile,
+			InsecureIgnoreHostKey: sc.InsecureIgnoreHostKey,
+			RemoteAddress:         sc.RemoteAddress,
+			Scheme:                scheme,
+			Timeout:               conf.Timeout,
+		}
+	case tunnel.ProviderDiscovery:
+		dc := r.config.Discovery
+		if dc.Host == "" || dc.Key == "" {
+			return nil, errors.New("the discovery tunnel provider needs a discovery server url and key")
+		}
+		relay := tunnel.Relay{
+			Config: signallingserver.DiscoveryServerConfig{
+				URL:      dc.Host,
+				Key:      dc.Key,
+				Insecure: dc.Insecure,
+				VersionInfo: messages.VersionInfo{
+					Version:    version.Version,
+					APIVersion: version.APIVersion,
+				},
+			},
+		}
+		switch {
+		case dc.RequiredURL != "":
+			relay.URL = &messages.SessionURLRequest{URL: dc.RequiredURL, Required: true}
+		case dc.PreferredURL != "":
+			relay.URL = &messages.SessionURLRequest{URL: dc.PreferredURL}
+		}
+		provider = &relay
+	default:
+		return nil, fmt.Errorf("invalid tunnel provider: %s", conf.Provider)
+	}
+
+	finishSpinning := output.DisplaySpinner(ctx,
+		333*time.Millisecond,
+		"opening tunnel",
+		"opening tunnel ... done",
+		[]string{".", "..", "...", ".."},
+	)
+	defer finishSpinning()
+
+	if 0 < conf.Timeout {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, conf.Timeout)
+		defer cancel()
+	}
+
+	t, err := provider.Open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if conf.PublicURL != "" {
+		t = &publicURLTunnel{Tunnel: t, url: conf.PublicURL}
+	}
+
+	log.Info().
+		Str("provider", provider.Name()).
+		Str("url", t.URL()).
+		Msg("opened tunnel")
+
+	return t, nil
+}
+
+// publicURLTunnel shows a url the user configured instead of the one the tunnel provider reported.
+type publicURLTunnel struct {
+	tunnel.Tunnel
+	url string
+}
+
+func (t *publicURLTunnel) URL() string {
+	return t.url
+}
@@ -1,9 +1,10 @@
 package rproxy
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httputil"
-	"net/url"
 	"strings"
 
 	"github.com/forestnode-io/oneshot/v2/pkg/commands"
@@ -11,6 +12,7 @@ import (
 	rootconfig "github.com/forestnode-io/oneshot/v2/pkg/configuration"
 	"github.com/forestnode-io/oneshot/v2/pkg/events"
 	"github.com/forestnode-io/oneshot/v2/pkg/output"
+	"github.com/rs/zerolog"
 	"github.com/spf13/cobra"
 )
 
@@ -32,19 +34,21 @@ func (c *Cmd) Cobra() *cobra.Command {
 	}
 
 	c.cobraCommand = &cobra.Command{
-		Use:     "reverse-proxy host",
+		Use:     "reverse-proxy upstream...",
 		Aliases: []string{"rproxy"},
 		Short:   "Reverse proxy all requests to the specified host",
-		RunE:    c.setHandlerFunc,
-		Args: func(cmd *cobra.Command, args []string) error {
-			if len(args) < 1 {
-				return output.UsageErrorF("proxy host required")
-			}
-			if 1 < len(args) {
-				return output.UsageErrorF("too many arguments, only 1 host may be used")
-			}
-			return nil
-		},
+		Long: `Reverse proxy all requests to the specified host.
+Requests can be split across several upstreams by path prefix, the longest matching prefix wins:
+
+	oneshot reverse-proxy /api=http://localhost:3000,/=http://localhost:5173
+
+Prefixes are not stripped from the proxied path.
+WebSocket upgrades and streamed responses, like server-sent events, are passed through as they happen.
+
+Exchanges can be recorded into an HTTP Archive (HAR) file with --har and played back later with --replay,
+in which case no upstream is needed.
+`,
+		RunE: c.setHandlerFunc,
 	}
 
 	c.cobraCommand.SetUsageTemplate(usageTemplate)
@@ -53,99 +57,189 @@ func (c *Cmd) Cobra() *cobra.Command {
 	return c.cobraCommand
 }
 
+// maxCaptureSize is the most of a response that is kept for the report.
+const maxCaptureSize = 32 << 20
+
 func (c *Cmd) setHandlerFunc(cmd *cobra.Command, args []string) error {
 	var (
 		ctx = cmd.Context()
 
-		host      = args[0]
 		config    = c.config.Subcommands.RProxy
 		spoofHost = config.SpoofHost
 	)
 
 	output.IncludeBody(ctx)
 
-	hostURL, err := url.Parse(host)
-	if err != nil {
+	var replay *harReplay
+	if config.Replay != "" {
+		var err error
+		if replay, err = newHARReplay(config.Replay); err != nil {
+			return output.UsageErrorF("%w", err)
+		}
+	} else if len(args) < 1 {
+		return output.UsageErrorF("proxy host required")
+	}
+
+	var (
+		upstreams []*upstream
+		err       error
+	)
+	if replay != nil && len(args) == 0 {
+		origin := replay.origin()
+		upstreams = []*upstream{{
+			prefix: "/",
+			url:    origin,
+			proxy:  httputil.NewSingleHostReverseProxy(origin),
+		}}
+	} else if upstreams, err = parseUpstreams(args); err != nil {
 		return output.UsageErrorF("invalid host: %w", err)
 	}
 
-	var spoofedHostURL *url.URL
-	if config.MatchHost {
-		spoofedHostURL = hostURL
+	var har *harRecorder
+	if config.HAR != "" {
+		maxBodySize, err := rootconfig.ParseSizeString(config.HARMaxBodySize)
+		if err != nil {
+			return output.UsageErrorF("invalid har-max-body-size: %w", err)
+		}
+		if har, err = newHARRecorder(config.HAR, maxBodySize); err != nil {
+			return output.UsageErrorF("%w", err)
+		}
 	}
+
 	if spoofHost != "" {
 		spoofHost = strings.TrimPrefix(spoofHost, "http://")
 		spoofHost = strings.TrimPrefix(spoofHost, "https://")
 		if idx := strings.Index(spoofHost, "/"); -1 < idx {
 			spoofHost = spoofHost[:idx]
 		}
-		spoofedHostURL = &url.URL{
-			Host: spoofHost,
-		}
 	}
 
-	c.host = host
+	c.host = strings.Join(args, ",")
 
-	if spoofedHostURL != nil {
-		config.RequestHeader.SetValue("Host", spoofedHostURL.Host)
+	var jsonOutput bool
+	if format, _ := output.GetFormatAndOpts(ctx); format == "json" || format == "ndjson" {
+		jsonOutput = true
 	}
+	capture := config.Tee || jsonOutput
 
-	rp := httputil.NewSingleHostReverseProxy(hostURL)
-	rp.ModifyResponse = func(resp *http.Response) error {
+	modifyResponse := func(resp *http.Response) error {
 		ctx := c.cobraCommand.Context()
 		originalHeader := resp.Header.Clone()
 
+		if ex := exchangeFromContext(resp.Request.Context()); ex != nil {
+			ex.setResponse(resp)
+		}
+
 		events.Raise(ctx, &events.HTTPResponse{
 			StatusCode: resp.StatusCode,
 			Header:     originalHeader,
 		})
 
+		if resp.StatusCode == http.StatusSwitchingProtocols {
+			// the body of a protocol switch is the upstream connection itself
+			if conn, ok := resp.Body.(io.ReadWriteCloser); ok && capture && isWebSocketUpgrade(resp.Header) {
+				resp.Body = newWebSocketTap(conn, func(msg *events.WebSocketMessage) {
+					events.Raise(ctx, msg)
+				})
+			}
+		}
+
 		if 0 < len(config.ResponseHeader) {
 			for k, v := range config.ResponseHeader.Inflate() {
 				resp.Header[k] = v
 			}
 		}
-		if config.StatusCode != 0 {
+		// the client asked to switch protocols, any other status would leave it hanging
+		if config.StatusCode != 0 && resp.StatusCode != http.StatusSwitchingProtocols {
 			resp.StatusCode = config.StatusCode
 		}
 		return nil
 	}
+	for _, u := range upstreams {
+		u.proxy.ModifyResponse = modifyResponse
+		if replay != nil {
+			u.proxy.Transport = replay
+			u.proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+				if errors.Is(err, errNoRecording) {
+					if ex := exchangeFromContext(r.Context()); ex != nil {
+						ex.missed = true
+					}
+					http.Error(w, err.Error(), http.StatusNotFound)
+					return
+				}
+				zerolog.Ctx(ctx).Error().Err(err).
+					Msg("error replaying response")
+				w.WriteHeader(http.StatusBadGateway)
+			}
+		}
+	}
 
 	handler := func(w http.ResponseWriter, r *http.Request) {
 		ctx := c.cobraCommand.Context()
 		events.Raise(ctx, output.NewHTTPRequest(r))
 
+		u := matchUpstream(upstreams, r.URL.Path)
+		if u == nil {
+			http.NotFound(w, r)
+			return
+		}
+
 		if 0 < len(config.RequestHeader) {
 			for k, v := range config.RequestHeader.Inflate() {
 				r.Header[k] = v
 			}
 		}
 
-		if spoofedHostURL != nil {
-			r.Host = spoofedHostURL.Host
-			r.URL = spoofedHostURL
+		switch {
+		case config.MatchHost:
+			r.Host = u.url.Host
+		case spoofHost != "":
+			r.Host = spoofHost
 		}
 
 		if config.Method != "" {
 			r.Method = strings.ToUpper(config.Method)
 		}
 
-		var jsonOutput bool
-		if format, _ := output.GetFormatAndOpts(ctx); format == "json" {
-			jsonOutput = true
+		var ex *exchange
+		if har != nil || replay != nil {
+			var maxBodySize int64
+			if har != nil {
+				maxBodySize = har.maxBodySize
+			}
+			ex, r = newExchange(r, maxBodySize)
 		}
 
-		if config.Tee || jsonOutput {
-			bw, getBufByte := output.NewBufferedWriter(ctx, w)
+		if capture {
+			// compressed messages can't be read back for the report
+			if isWebSocketUpgrade(r.Header) {
+				r.Header.Del("Sec-WebSocket-Extensions")
+			}
+
+			// streamed responses, like server-sent events, may never end
+			bw, getBufByte, truncated := output.NewCappedBufferedWriter(ctx, w, maxCaptureSize)
 
 			ww := bw.(http.ResponseWriter)
-			rp.ServeHTTP(ww, r)
+			u.proxy.ServeHTTP(ww, r)
 
 			events.Raise(ctx, &events.File{
-				Content: getBufByte,
+				Content:          getBufByte,
+				ContentTruncated: truncated(),
 			})
 		} else {
-			rp.ServeHTTP(w, r)
+			u.proxy.ServeHTTP(w, r)
+		}
+
+		if ex != nil && har != nil {
+			if err := har.add(ex.entry()); err != nil {
+				zerolog.Ctx(ctx).Error().Err(err).
+					Msg("error recording exchange")
+			}
+		}
+		// keep the stub up until a request it has a response for comes in
+		if ex != nil && ex.missed {
+			events.Raise(ctx, events.ClientDisconnected{Err: errNoRecording})
+			return
 		}
 
 		events.Success(ctx)
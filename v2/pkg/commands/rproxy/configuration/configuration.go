@@ -3,6 +3,7 @@ package configuration
 import (
 	"fmt"
 	"net/http"
+	"os"
 
 	"github.com/forestnode-io/oneshot/v2/pkg/flagargs"
 	"github.com/forestnode-io/oneshot/v2/pkg/flags"
@@ -18,6 +19,9 @@ type Configuration struct {
 	SpoofHost      string              `mapstructure:"spoofhost" yaml:"spoofhost"`
 	RequestHeader  flagargs.HTTPHeader `mapstructure:"requestheader" yaml:"requestheader"`
 	ResponseHeader flagargs.HTTPHeader `mapstructure:"responseheader" yaml:"responseheader"`
+	HAR            string              `mapstructure:"har" yaml:"har"`
+	HARMaxBodySize string              `mapstructure:"harmaxbodysize" yaml:"harmaxbodysize"`
+	Replay         string              `mapstructure:"replay" yaml:"replay"`
 }
 
 func (c *Configuration) Validate() error {
@@ -25,6 +29,16 @@ func (c *Configuration) Validate() error {
 		return fmt.Errorf("invalid status code")
 	}
 
+	if c.Replay != "" {
+		info, err := os.Stat(c.Replay)
+		if err != nil {
+			return fmt.Errorf("invalid replay file: %w", err)
+		}
+		if info.IsDir() {
+			return fmt.Errorf("invalid replay file: %s is a directory", c.Replay)
+		}
+	}
+
 	return nil
 }
 
@@ -39,13 +53,21 @@ func SetFlags(cmd *cobra.Command) {
 	flags.Int(fs, "cmd.rproxy.status", "status-code", "HTTP status code to send to client.")
 	flags.String(fs, "cmd.rproxy.method", "method", "HTTP method to send to client.")
 	flags.Bool(fs, "cmd.rproxy.matchhost", "match-host", `The 'Host' header will be set to match the host being reverse-proxied to.`)
-	flags.Bool(fs, "cmd.rproxy.tee", "tee", `Send a copy of the proxied response to the console.`)
+	flags.Bool(fs, "cmd.rproxy.tee", "tee", `Send a copy of the proxied response to the console.
+Only the first 32MB of each response is copied, the same goes for the response bodies in json reports.`)
 	flags.String(fs, "cmd.rproxy.spoofhost", "spoof-host", `Spoof the request host, the 'Host' header will be set to this value.
 This Flag is ignored if the --match-host flag is set.`)
 	flags.StringSlice(fs, "cmd.rproxy.requestheader", "request-header", `Header to send with the proxied request. Can be specified multiple times.
 Format: <HEADER NAME>=<HEADER VALUE>`)
 	flags.StringSlice(fs, "cmd.rproxy.responseheader", "response-header", `Header to send to send with the proxied response. Can be specified multiple times.
 Format: <HEADER NAME>=<HEADER VALUE>`)
+	flags.String(fs, "cmd.rproxy.har", "har", `Record every proxied exchange into this HTTP Archive (HAR) file.
+Entries already in the file are kept, new ones are added after them.`)
+	flags.String(fs, "cmd.rproxy.harmaxbodysize", "har-max-body-size", `Largest request or response body recorded into the HAR file, larger bodies are truncated.
+A value of zero records bodies in full. Uses the same format as --max-read-size.`)
+	flags.String(fs, "cmd.rproxy.replay", "replay", `Answer requests with the responses recorded in this HAR file instead of proxying them.
+Recorded responses are matched by method and path, preferring ones with the same query that have not been replayed yet.
+No upstream is needed when replaying.`)
 
 	cobra.AddTemplateFunc("sendFlags", func() *pflag.FlagSet {
 		return fs
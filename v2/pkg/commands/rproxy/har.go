@@ -0,0 +1,651 @@
+package rproxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/secrets"
+	"github.com/forestnode-io/oneshot/v2/pkg/version"
+)
+
+// The types below follow the HTTP Archive 1.2 format.
+// Fields starting with an underscore are oneshot's own, as allowed by the format.
+
+type harFile struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string      `json:"version"`
+	Creator harCreator  `json:"creator"`
+	Entries []*harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	// ClientTLS describes the connection between the client and oneshot.
+	ClientTLS *harTLS `json:"_clientTLS,omitempty"`
+	// UpstreamTLS describes the connection between oneshot and the upstream.
+	UpstreamTLS *harTLS `json:"_upstreamTLS,omitempty"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"_encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// harTimings are given in milliseconds, -1 marks a phase that did not happen.
+type harTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	SSL     float64 `json:"ssl"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+type harTLS struct {
+	Version     string `json:"version,omitempty"`
+	CipherSuite string `json:"cipherSuite,omitempty"`
+	ServerName  string `json:"serverName,omitempty"`
+	// PeerCertificate is the subject of the certificate the other end presented.
+	PeerCertificate string `json:"peerCertificate,omitempty"`
+}
+
+func newHARTLS(cs *tls.ConnectionState) *harTLS {
+	if cs == nil {
+		return nil
+	}
+	t := harTLS{
+		Version:     tls.VersionName(cs.Version),
+		CipherSuite: tls.CipherSuiteName(cs.CipherSuite),
+		ServerName:  cs.ServerName,
+	}
+	if 0 < len(cs.PeerCertificates) {
+		t.PeerCertificate = cs.PeerCertificates[0].Subject.String()
+	}
+	return &t
+}
+
+// harRecorder appends every exchange it is given to a HAR file.
+// Each entry is written over the end of the file followed by the closing brackets again,
+// so recording doesn't slow down as the file grows and the file is whole between entries.
+type harRecorder struct {
+	path        string
+	maxBodySize int64
+
+	mu sync.Mutex
+	// end is where the closing brackets of the entries start
+	end int64
+	// trailer closes the entries and the rest of the document
+	trailer []byte
+	entries int
+}
+
+// newHARRecorder records exchanges into the HAR file at path.
+// Entries already in the file are kept so that several sessions can be recorded into one file.
+// Bodies larger than maxBodySize are truncated, 0 means no limit.
+func newHARRecorder(path string, maxBodySize int64) (*harRecorder, error) {
+	r := harRecorder{
+		path:        path,
+		maxBodySize: maxBodySize,
+	}
+	har := harFile{
+		Log: harLog{
+			Version: "1.2",
+			Creator: harCreator{
+				Name:    "oneshot",
+				Version: version.Version,
+			},
+			Entries: []*harEntry{},
+		},
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("unable to read HAR file: %w", err)
+	}
+	var existing harFile
+	if len(bytes.TrimSpace(data)) != 0 {
+		if err := json.Unmarshal(data, &existing); err != nil {
+			return nil, fmt.Errorf("%s exists and is not a HAR file: %w", path, err)
+		}
+	}
+
+	// the entries are the last field of the document, so everything after them is the trailer
+	doc, err := json.MarshalIndent(&har, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	idx := bytes.LastIndex(doc, []byte("[]"))
+	r.trailer = append([]byte("\n    ]"), doc[idx+2:]...)
+
+	buf := bytes.NewBuffer(doc[:idx+1:idx+1])
+	for _, e := range existing.Log.Entries {
+		if err := r.writeEntry(buf, e); err != nil {
+			return nil, err
+		}
+		r.entries++
+	}
+	r.end = int64(buf.Len())
+	buf.Write(r.trailer)
+
+	// write the whole file out of the way first so that a crash never leaves half of it behind
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return nil, fmt.Errorf("unable to write HAR file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("unable to write HAR file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("unable to write HAR file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, fmt.Errorf("unable to write HAR file: %w", err)
+	}
+
+	return &r, nil
+}
+
+// writeEntry writes e as the next element of the entries array, indented as the rest of the file.
+func (r *harRecorder) writeEntry(w io.Writer, e *harEntry) error {
+	data, err := json.MarshalIndent(e, "      ", "  ")
+	if err != nil {
+		return err
+	}
+	sep := "\n      "
+	if 0 < r.entries {
+		sep = "," + sep
+	}
+	if _, err := io.WriteString(w, sep); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (r *harRecorder) add(entry *harEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var buf bytes.Buffer
+	if err := r.writeEntry(&buf, entry); err != nil {
+		return err
+	}
+	n := int64(buf.Len())
+	buf.Write(r.trailer)
+
+	f, err := os.OpenFile(r.path, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("unable to write HAR file: %w", err)
+	}
+	if _, err := f.WriteAt(buf.Bytes(), r.end); err != nil {
+		f.Close()
+		return fmt.Errorf("unable to write HAR file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("unable to write HAR file: %w", err)
+	}
+	r.end += n
+	r.entries++
+
+	return nil
+}
+
+type exchangeKey struct{}
+
+// exchange follows a single request through the proxy.
+type exchange struct {
+	start    time.Time
+	request  *http.Request
+	header   http.Header
+	reqBody  *cappedBuffer
+	response *http.Response
+	respBody *cappedBuffer
+	// missed is set when replaying and there was no recorded response for the request
+	missed bool
+
+	mu                              sync.Mutex
+	dnsStart, dnsDone               time.Time
+	connectStart, connectDone       time.Time
+	tlsStart, tlsDone               time.Time
+	gotConn, wroteRequest, gotFirst time.Time
+	serverIP                        string
+}
+
+// newExchange starts following r, keeping up to maxBodySize bytes of each body, 0 keeps all of them.
+// The returned request must be used in place of r.
+func newExchange(r *http.Request, maxBodySize int64) (*exchange, *http.Request) {
+	ex := exchange{
+		start:    time.Now(),
+		header:   secrets.RedactHeader(r.Header.Clone()),
+		reqBody:  &cappedBuffer{max: maxBodySize},
+		respBody: &cappedBuffer{max: maxBodySize},
+	}
+	if r.Body != nil && r.Body != http.NoBody {
+		r.Body = &recordingBody{ReadCloser: r.Body, buf: ex.reqBody}
+	}
+
+	ctx := context.WithValue(r.Context(), exchangeKey{}, &ex)
+	ctx = httptrace.WithClientTrace(ctx, ex.trace())
+	r = r.WithContext(ctx)
+	ex.request = r
+
+	return &ex, r
+}
+
+func exchangeFromContext(ctx context.Context) *exchange {
+	ex, _ := ctx.Value(exchangeKey{}).(*exchange)
+	return ex
+}
+
+func (ex *exchange) trace() *httptrace.ClientTrace {
+	now := func(t *time.Time) {
+		ex.mu.Lock()
+		*t = time.Now()
+		ex.mu.Unlock()
+	}
+	return &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { now(&ex.dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { now(&ex.dnsDone) },
+		ConnectStart:      func(string, string) { now(&ex.connectStart) },
+		ConnectDone:       func(string, string, error) { now(&ex.connectDone) },
+		TLSHandshakeStart: func() { now(&ex.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { now(&ex.tlsDone) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			now(&ex.gotConn)
+			if info.Conn == nil {
+				return
+			}
+			if host, _, err := net.SplitHostPort(info.Conn.RemoteAddr().String()); err == nil {
+				ex.mu.Lock()
+				ex.serverIP = host
+				ex.mu.Unlock()
+			}
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { now(&ex.wroteRequest) },
+		GotFirstResponseByte: func() { now(&ex.gotFirst) },
+	}
+}
+
+// setResponse records the upstream response before it is modified and starts recording its body.
+func (ex *exchange) setResponse(resp *http.Response) {
+	r := *resp
+	r.Header = resp.Header.Clone()
+	ex.response = &r
+
+	// the body of a protocol switch is the connection itself
+	if resp.StatusCode != http.StatusSwitchingProtocols && resp.Body != nil {
+		resp.Body = &recordingBody{ReadCloser: resp.Body, buf: ex.respBody}
+	}
+}
+
+// entry returns the HAR entry for the finished exchange.
+func (ex *exchange) entry() *harEntry {
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+
+	var (
+		end = time.Now()
+		r   = ex.request
+	)
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	reqURL := url.URL{
+		Scheme:   scheme,
+		Host:     r.Host,
+		Path:     r.URL.Path,
+		RawPath:  r.URL.RawPath,
+		RawQuery: r.URL.RawQuery,
+	}
+
+	e := harEntry{
+		StartedDateTime: ex.start,
+		Time:            millis(ex.start, end),
+		Request: harRequest{
+			Method:      r.Method,
+			URL:         reqURL.String(),
+			HTTPVersion: r.Proto,
+			Cookies:     harCookies(r.Cookies()),
+			Headers:     harHeaders(ex.header),
+			QueryString: []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    ex.reqBody.total,
+		},
+		Response: harResponse{
+			Cookies:     []harNameValue{},
+			Headers:     []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    ex.respBody.total,
+		},
+		ServerIPAddress: ex.serverIP,
+		ClientTLS:       newHARTLS(r.TLS),
+	}
+	for name, values := range r.URL.Query() {
+		for _, v := range values {
+			e.Request.QueryString = append(e.Request.QueryString, harNameValue{Name: name, Value: v})
+		}
+	}
+	if 0 < ex.reqBody.total {
+		text, encoding := harBody(ex.reqBody.buf.Bytes())
+		e.Request.PostData = &harPostData{
+			MimeType: r.Header.Get("Content-Type"),
+			Text:     text,
+			Encoding: encoding,
+			Comment:  ex.reqBody.comment(),
+		}
+	}
+
+	if resp := ex.response; resp != nil {
+		e.Response.Status = resp.StatusCode
+		e.Response.StatusText = http.StatusText(resp.StatusCode)
+		e.Response.HTTPVersion = resp.Proto
+		e.Response.Cookies = harCookies(resp.Cookies())
+		e.Response.Headers = harHeaders(secrets.RedactHeader(resp.Header.Clone()))
+		e.Response.RedirectURL = resp.Header.Get("Location")
+		e.Response.Content.MimeType = resp.Header.Get("Content-Type")
+		e.UpstreamTLS = newHARTLS(resp.TLS)
+	}
+	e.Response.Content.Size = ex.respBody.total
+	e.Response.Content.Text, e.Response.Content.Encoding = harBody(ex.respBody.buf.Bytes())
+	e.Response.Content.Comment = ex.respBody.comment()
+
+	e.Timings = harTimings{
+		Blocked: -1,
+		DNS:     -1,
+		Connect: -1,
+		SSL:     -1,
+	}
+	if !ex.dnsDone.IsZero() {
+		e.Timings.DNS = millis(ex.dnsStart, ex.dnsDone)
+	}
+	if !ex.connectDone.IsZero() {
+		e.Timings.Connect = millis(ex.connectStart, ex.connectDone)
+	}
+	if !ex.tlsDone.IsZero() {
+		e.Timings.SSL = millis(ex.tlsStart, ex.tlsDone)
+	}
+	if ex.gotConn.IsZero() || ex.wroteRequest.IsZero() || ex.gotFirst.IsZero() {
+		// nothing went over the network, as happens when replaying
+		e.Timings.Wait = e.Time
+		return &e
+	}
+	if ex.dnsStart.IsZero() && ex.connectStart.IsZero() {
+		e.Timings.Blocked = millis(ex.start, ex.gotConn)
+	}
+	e.Timings.Send = millis(ex.gotConn, ex.wroteRequest)
+	e.Timings.Wait = millis(ex.wroteRequest, ex.gotFirst)
+	e.Timings.Receive = millis(ex.gotFirst, end)
+
+	return &e
+}
+
+func millis(from, to time.Time) float64 {
+	if from.IsZero() || to.Before(from) {
+		return 0
+	}
+	return float64(to.Sub(from).Microseconds()) / 1000
+}
+
+func harHeaders(h http.Header) []harNameValue {
+	nvs := []harNameValue{}
+	for name, values := range h {
+		for _, v := range values {
+			nvs = append(nvs, harNameValue{Name: name, Value: v})
+		}
+	}
+	return nvs
+}
+
+// harCookies lists cookies with the values of any of oneshots secrets redacted, as with headers.
+func harCookies(cookies []*http.Cookie) []harNameValue {
+	nvs := []harNameValue{}
+	for _, c := range cookies {
+		value := c.Value
+		if secrets.IsSecret(value) {
+			value = secrets.Redacted
+		}
+		nvs = append(nvs, harNameValue{Name: c.Name, Value: value})
+	}
+	return nvs
+}
+
+// harBody returns body as text, base64 encoding it if it is not valid UTF-8.
+func harBody(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+// cappedBuffer keeps the first max bytes written to it while counting all of them.
+type cappedBuffer struct {
+	max   int64
+	buf   bytes.Buffer
+	total int64
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.total += int64(len(p))
+	if b.max <= 0 {
+		return b.buf.Write(p)
+	}
+	if room := b.max - int64(b.buf.Len()); 0 < room {
+		if int64(len(p)) < room {
+			room = int64(len(p))
+		}
+		b.buf.Write(p[:room])
+	}
+	return len(p), nil
+}
+
+func (b *cappedBuffer) comment() string {
+	if int64(b.buf.Len()) < b.total {
+		return fmt.Sprintf("truncated to %d of %d bytes", b.buf.Len(), b.total)
+	}
+	return ""
+}
+
+type recordingBody struct {
+	io.ReadCloser
+	buf *cappedBuffer
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if 0 < n {
+		b.buf.Write(p[:n])
+	}
+	return n, err
+}
+
+var errNoRecording = errors.New("no recorded response for request")
+
+// harReplay is a transport that answers requests with the responses recorded in a HAR file.
+type harReplay struct {
+	mu      sync.Mutex
+	entries []*harEntry
+	used    []bool
+}
+
+func newHARReplay(path string) (*harReplay, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read HAR file: %w", err)
+	}
+
+	var har harFile
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("invalid HAR file %s: %w", path, err)
+	}
+
+	var entries []*harEntry
+	for _, e := range har.Log.Entries {
+		// there is no connection to hand over when replaying a protocol switch
+		if e.Response.Status == http.StatusSwitchingProtocols {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no replayable entries in HAR file %s", path)
+	}
+
+	return &harReplay{
+		entries: entries,
+		used:    make([]bool, len(entries)),
+	}, nil
+}
+
+// origin returns the scheme and host of the first recorded request,
+// which stands in for the upstream when replaying.
+func (h *harReplay) origin() *url.URL {
+	u, err := url.Parse(h.entries[0].Request.URL)
+	if err != nil || u.Host == "" {
+		return &url.URL{Scheme: "http", Host: "localhost"}
+	}
+	return &url.URL{Scheme: u.Scheme, Host: u.Host}
+}
+
+// RoundTrip answers req with the recorded response for the same method and path.
+// Responses recorded for the same query are preferred, as are ones that have not been replayed yet.
+func (h *harReplay) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		_, _ = io.Copy(io.Discard, req.Body)
+		req.Body.Close()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var (
+		best      = -1
+		bestScore = -1
+		query     = req.URL.Query().Encode()
+	)
+	for i, e := range h.entries {
+		if e.Request.Method != req.Method {
+			continue
+		}
+		u, err := url.Parse(e.Request.URL)
+		if err != nil || u.Path != req.URL.Path {
+			continue
+		}
+
+		score := 0
+		if u.Query().Encode() == query {
+			score += 2
+		}
+		if !h.used[i] {
+			score++
+		}
+		if bestScore < score {
+			best, bestScore = i, score
+		}
+	}
+	if best < 0 {
+		return nil, errNoRecording
+	}
+	h.used[best] = true
+
+	e := h.entries[best].Response
+	body := []byte(e.Content.Text)
+	if e.Content.Encoding == "base64" {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(e.Content.Text); err != nil {
+			return nil, fmt.Errorf("invalid recorded response body: %w", err)
+		}
+	}
+
+	header := make(http.Header)
+	for _, h := range e.Headers {
+		header.Add(h.Name, h.Value)
+	}
+	// the recorded body may have been truncated
+	header.Del("Content-Length")
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status)),
+		StatusCode:    e.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
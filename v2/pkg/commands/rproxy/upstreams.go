@@ -0,0 +1,95 @@
+package rproxy
+
+import (
+	"fmt"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+type upstream struct {
+	// prefix is the path prefix routed to this upstream, "/" routes everything.
+	prefix string
+	url    *url.URL
+	proxy  *httputil.ReverseProxy
+}
+
+// parseUpstreams parses upstreams given either as a lone URL, which is routed everything,
+// or as path-prefix=URL pairs. Each arg may hold several upstreams separated by commas:
+//
+//	/api=http://localhost:3000,/=http://localhost:5173
+//
+// The upstreams are returned longest prefix first.
+func parseUpstreams(args []string) ([]*upstream, error) {
+	var (
+		upstreams []*upstream
+		seen      = make(map[string]struct{})
+	)
+	for _, arg := range args {
+		for _, item := range strings.Split(arg, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+
+			prefix, rawURL := "/", item
+			// urls may carry '=' in their query so only items starting with a path have a prefix
+			if strings.HasPrefix(item, "/") {
+				var ok bool
+				prefix, rawURL, ok = strings.Cut(item, "=")
+				if !ok {
+					return nil, fmt.Errorf("invalid upstream %q, expected path-prefix=url", item)
+				}
+				if prefix != "/" {
+					prefix = strings.TrimSuffix(prefix, "/")
+				}
+			}
+
+			u, err := url.Parse(rawURL)
+			if err != nil {
+				return nil, fmt.Errorf("invalid upstream %q: %w", rawURL, err)
+			}
+			// websockets are upgraded from plain http requests
+			switch u.Scheme {
+			case "ws":
+				u.Scheme = "http"
+			case "wss":
+				u.Scheme = "https"
+			}
+			if u.Scheme == "" || u.Host == "" {
+				return nil, fmt.Errorf("invalid upstream %q, expected an absolute url such as http://localhost:3000", rawURL)
+			}
+			if _, exists := seen[prefix]; exists {
+				return nil, fmt.Errorf("more than one upstream given for %s", prefix)
+			}
+			seen[prefix] = struct{}{}
+
+			upstreams = append(upstreams, &upstream{
+				prefix: prefix,
+				url:    u,
+				proxy:  httputil.NewSingleHostReverseProxy(u),
+			})
+		}
+	}
+	if len(upstreams) == 0 {
+		return nil, fmt.Errorf("no upstream given")
+	}
+
+	sort.SliceStable(upstreams, func(i, j int) bool {
+		return len(upstreams[i].prefix) > len(upstreams[j].prefix)
+	})
+
+	return upstreams, nil
+}
+
+// matchUpstream returns the upstream with the longest prefix that path falls under.
+// Prefixes only match whole path segments, /api matches /api and /api/users but not /apis.
+func matchUpstream(upstreams []*upstream, path string) *upstream {
+	for _, u := range upstreams {
+		if u.prefix == "/" || path == u.prefix || strings.HasPrefix(path, u.prefix+"/") {
+			return u
+		}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package rproxy
+
+import (
+	"encoding/binary"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/events"
+)
+
+// maxWebSocketCapture is the most data a frame reader holds on to while waiting for the rest of a message.
+// Bigger messages are still proxied, they just stop being captured.
+const maxWebSocketCapture = 16 << 20
+
+func isWebSocketUpgrade(h http.Header) bool {
+	return strings.EqualFold(h.Get("Upgrade"), "websocket")
+}
+
+// webSocketTap sits between the proxy and the upstream connection of a WebSocket,
+// reporting the messages passed in either direction.
+type webSocketTap struct {
+	io.ReadWriteCloser
+	fromUpstream *frameReader
+	fromClient   *frameReader
+}
+
+func newWebSocketTap(conn io.ReadWriteCloser, raise func(*events.WebSocketMessage)) *webSocketTap {
+	return &webSocketTap{
+		ReadWriteCloser: conn,
+		fromUpstream:    &frameReader{from: events.WebSocketFromUpstream, raise: raise},
+		fromClient:      &frameReader{from: events.WebSocketFromClient, raise: raise},
+	}
+}
+
+func (t *webSocketTap) Read(p []byte) (int, error) {
+	n, err := t.ReadWriteCloser.Read(p)
+	if 0 < n {
+		t.fromUpstream.feed(p[:n])
+	}
+	return n, err
+}
+
+func (t *webSocketTap) Write(p []byte) (int, error) {
+	n, err := t.ReadWriteCloser.Write(p)
+	if 0 < n {
+		t.fromClient.feed(p[:n])
+	}
+	return n, err
+}
+
+// frameReader reassembles the WebSocket messages sent in one direction from the raw stream.
+type frameReader struct {
+	from  string
+	raise func(*events.WebSocketMessage)
+
+	buf []byte
+	// msgType and msg hold a fragmented message until its last frame arrives
+	msgType string
+	msg     []byte
+	// broken is set once the stream stops making sense or grows too large to capture
+	broken bool
+}
+
+func (f *frameReader) feed(p []byte) {
+	if f.broken {
+		return
+	}
+
+	f.buf = append(f.buf, p...)
+	for {
+		n, ok := f.next()
+		if !ok {
+			break
+		}
+		f.buf = f.buf[n:]
+	}
+
+	if maxWebSocketCapture < len(f.buf)+len(f.msg) {
+		f.broken = true
+		f.buf = nil
+		f.msg = nil
+	}
+}
+
+// next parses the frame at the start of the buffer, if all of it has arrived,
+// and returns how many bytes it took up.
+func (f *frameReader) next() (int, bool) {
+	buf := f.buf
+	if len(buf) < 2 {
+		return 0, false
+	}
+
+	var (
+		fin    = buf[0]&0x80 != 0
+		opcode = buf[0] & 0x0f
+		masked = buf[1]&0x80 != 0
+		length = uint64(buf[1] & 0x7f)
+		offset = 2
+	)
+	switch length {
+	case 126:
+		if len(buf) < 4 {
+			return 0, false
+		}
+		length = uint64(binary.BigEndian.Uint16(buf[2:4]))
+		offset = 4
+	case 127:
+		if len(buf) < 10 {
+			return 0, false
+		}
+		length = binary.BigEndian.Uint64(buf[2:10])
+		offset = 10
+	}
+	if maxWebSocketCapture < length {
+		f.broken = true
+		return 0, false
+	}
+
+	var mask []byte
+	if masked {
+		if len(buf) < offset+4 {
+			return 0, false
+		}
+		mask = buf[offset : offset+4]
+		offset += 4
+	}
+	if uint64(len(buf)-offset) < length {
+		return 0, false
+	}
+
+	payload := make([]byte, length)
+	copy(payload, buf[offset:])
+	if mask != nil {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+
+	switch opcode {
+	case 0x0: // continuation
+		f.msg = append(f.msg, payload...)
+		if fin {
+			f.emit(f.msgType, f.msg)
+			f.msgType, f.msg = "", nil
+		}
+	case 0x1, 0x2:
+		msgType := events.WebSocketText
+		if opcode == 0x2 {
+			msgType = events.WebSocketBinary
+		}
+		if fin {
+			f.emit(msgType, payload)
+		} else {
+			f.msgType, f.msg = msgType, payload
+		}
+	case 0x8:
+		f.emit(events.WebSocketClose, payload)
+	case 0x9:
+		f.emit(events.WebSocketPing, payload)
+	case 0xA:
+		f.emit(events.WebSocketPong, payload)
+	}
+
+	return offset + int(length), true
+}
+
+func (f *frameReader) emit(msgType string, payload []byte) {
+	msg := events.WebSocketMessage{
+		From: f.from,
+		Type: msgType,
+		Time: time.Now(),
+	}
+	if msgType == events.WebSocketText {
+		msg.Data = string(payload)
+	} else if 0 < len(payload) {
+		msg.Data = payload
+	}
+	f.raise(&msg)
+}
@@ -37,6 +37,8 @@ func (c *Cmd) Cobra() *cobra.Command {
 		Short: "Send a file or directory to the client",
 		Long: `Send a file or directory to the client. If no file or directory is given, stdin will be used.
 When sending from stdin, requests are blocked until an EOF is received; content from stdin is buffered for subsequent requests.
+If the --stream flag is given, stdin is sent to the first client while it is being read instead.
+With --stream-spool, streamed stdin is also kept in memory and on disk so that subsequent requests receive it too.
 If a directory is given, it will be archived and sent to the client; oneshot does not support sending unarchived directories.
 `,
 		RunE: c.setHandlerFunc,
@@ -74,10 +76,19 @@ func (c *Cmd) setHandlerFunc(cmd *cobra.Command, args []string) error {
 		fileName = namesgenerator.GetRandomName(0)
 	}
 
-	var err error
-	c.rtc, err = file.NewReadTransferConfig(archiveMethod, args...)
-	if err != nil {
-		return fmt.Errorf("failed to create read transfer config: %w", err)
+	if config.Stream {
+		if 0 < len(paths) {
+			return output.UsageErrorF("--stream can only be used when sending stdin")
+		}
+		rtc := file.NewStdinStreamConfig(config.StreamOptions())
+		commands.MarkForClose(ctx, rtc)
+		c.rtc = rtc
+	} else {
+		var err error
+		c.rtc, err = file.NewReadTransferConfig(config.ArchiveOptions(), args...)
+		if err != nil {
+			return fmt.Errorf("failed to create read transfer config: %w", err)
+		}
 	}
 
 	if file.IsArchive(c.rtc) {
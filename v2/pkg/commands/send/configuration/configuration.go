@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/forestnode-io/oneshot/v2/pkg/file"
 	"github.com/forestnode-io/oneshot/v2/pkg/flagargs"
 	"github.com/forestnode-io/oneshot/v2/pkg/flags"
 	"github.com/spf13/cobra"
@@ -11,19 +12,58 @@ import (
 )
 
 type Configuration struct {
-	ArchiveMethod string              `mapstructure:"archivemethod" yaml:"archivemethod"`
-	NoDownload    bool                `mapstructure:"nodownload" yaml:"nodownload"`
-	MIME          string              `mapstructure:"mime" yaml:"mime"`
-	Name          string              `mapstructure:"name" yaml:"name"`
-	StatusCode    int                 `mapstructure:"statuscode" yaml:"statuscode"`
-	Header        flagargs.HTTPHeader `mapstructure:"header" yaml:"header"`
+	ArchiveMethod    string              `mapstructure:"archivemethod" yaml:"archivemethod"`
+	CompressionLevel int                 `mapstructure:"compressionlevel" yaml:"compressionlevel"`
+	Exclude          []string            `mapstructure:"exclude" yaml:"exclude"`
+	GitIgnore        bool                `mapstructure:"gitignore" yaml:"gitignore"`
+	Deterministic    bool                `mapstructure:"deterministic" yaml:"deterministic"`
+	ComputeSize      bool                `mapstructure:"computesize" yaml:"computesize"`
+	Stream           bool                `mapstructure:"stream" yaml:"stream"`
+	StreamSpool      bool                `mapstructure:"streamspool" yaml:"streamspool"`
+	StreamMemory     string              `mapstructure:"streammemory" yaml:"streammemory"`
+	NoDownload       bool                `mapstructure:"nodownload" yaml:"nodownload"`
+	MIME             string              `mapstructure:"mime" yaml:"mime"`
+	Name             string              `mapstructure:"name" yaml:"name"`
+	StatusCode       int                 `mapstructure:"statuscode" yaml:"statuscode"`
+	Header           flagargs.HTTPHeader `mapstructure:"header" yaml:"header"`
+
+	// StreamMemoryBytes is StreamMemory parsed into bytes.
+	StreamMemoryBytes int64 `mapstructure:"-" yaml:"-"`
+}
+
+// ArchiveOptions returns how directories should be archived.
+func (c *Configuration) ArchiveOptions() file.ArchiveOptions {
+	return file.ArchiveOptions{
+		Format:           c.ArchiveMethod,
+		CompressionLevel: c.CompressionLevel,
+		Exclude:          c.Exclude,
+		GitIgnore:        c.GitIgnore,
+		Deterministic:    c.Deterministic,
+		ComputeSize:      c.ComputeSize,
+	}
 }
 
 func SetFlags(cmd *cobra.Command) {
 	fs := pflag.NewFlagSet("send flags", pflag.ExitOnError)
 	defer cmd.Flags().AddFlagSet(fs)
 
-	flags.StringP(fs, "cmd.send.archivemethod", "archive-method", "a", `Which archive method to use when sending directories.`)
+	flags.StringP(fs, "cmd.send.archivemethod", "archive-method", "a", `Which archive method to use when sending directories.
+Recognized values are "tar", "tar.gz", "tar.zst", "tar.xz" and "zip".`)
+	flags.Int(fs, "cmd.send.compressionlevel", "compression-level", `Compression level to use when archiving, from 0 (none) to 9 (best).
+A zip archive with a compression level of 0 only stores its files.
+-1 uses the default level of the archive method.`)
+	flags.StringSlice(fs, "cmd.send.exclude", "exclude", `Glob pattern of files to leave out when archiving. Can be specified multiple times.
+Patterns without a slash are matched against file names, e.g. '*.o'.`)
+	flags.Bool(fs, "cmd.send.gitignore", "gitignore", "Leave out files ignored by .gitignore files when archiving.")
+	flags.Bool(fs, "cmd.send.deterministic", "deterministic", `Create deterministic archives.
+Archive entries get fixed modification times and no owner information so that sending the same files produces identical bytes.`)
+	flags.Bool(fs, "cmd.send.computesize", "compute-size", `Archive compressed formats once before they are sent to learn their size.
+The size of tar and uncompressed zip archives is always known up front.`)
+	flags.Bool(fs, "cmd.send.stream", "stream", `Send stdin to the client while it is being read instead of waiting for an EOF.
+Without --stream-spool, stdin can only be sent once.`)
+	flags.Bool(fs, "cmd.send.streamspool", "stream-spool", `Keep a copy of streamed stdin so that later requests receive it too.
+Up to --stream-memory bytes are kept in memory, the rest is spilled to a temporary file.`)
+	flags.String(fs, "cmd.send.streammemory", "stream-memory", `How much of streamed stdin to keep in memory before spilling to disk, e.g. 64MiB.`)
 	flags.BoolP(fs, "cmd.send.nodownload", "no-download", "D", "Do not allow the client to download the file.")
 	flags.StringP(fs, "cmd.send.mime", "mime", "m", `MIME type of file presented to client.`)
 	flags.StringP(fs, "cmd.send.name", "name", "n", `Name of file presented to client if downloading.`)
@@ -40,9 +80,31 @@ func (c *Configuration) Validate() error {
 	if t := http.StatusText(c.StatusCode); t == "" {
 		return fmt.Errorf("invalid status code")
 	}
+	if err := file.ValidateArchiveFormat(c.ArchiveMethod); err != nil {
+		return err
+	}
+	if err := file.ValidateCompressionLevel(c.CompressionLevel); err != nil {
+		return err
+	}
 	return nil
 }
 
 func (c *Configuration) Hydrate() error {
+	if c.StreamMemory != "" {
+		size, err := flagargs.ParseSize(c.StreamMemory)
+		if err != nil {
+			return err
+		}
+		c.StreamMemoryBytes = size
+	}
+
 	return nil
 }
+
+// StreamOptions returns how stdin should be streamed.
+func (c *Configuration) StreamOptions() file.StreamOptions {
+	return file.StreamOptions{
+		Spool:       c.StreamSpool,
+		MemoryLimit: c.StreamMemoryBytes,
+	}
+}
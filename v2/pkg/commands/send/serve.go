@@ -1,12 +1,15 @@
 package send
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"time"
 
 	"github.com/forestnode-io/oneshot/v2/pkg/events"
+	"github.com/forestnode-io/oneshot/v2/pkg/file"
+	oneshothttp "github.com/forestnode-io/oneshot/v2/pkg/net/http"
 	"github.com/forestnode-io/oneshot/v2/pkg/output"
 )
 
@@ -33,15 +36,24 @@ func (c *Cmd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	rts, err := c.rtc.NewReaderTransferSession(ctx)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		status := http.StatusInternalServerError
+		if errors.Is(err, file.ErrStdinConsumed) {
+			status = http.StatusGone
+		}
+		http.Error(w, err.Error(), status)
 		events.Raise(ctx, events.ClientDisconnected{Err: err})
 		return
 	}
 	defer rts.Close()
+	var out io.Writer = w
 	size, err := rts.Size()
 	if err == nil {
 		w.Header().Set("Content-Length", fmt.Sprintf("%d", size))
 	}
+	if file.IsStream(c.rtc) {
+		// pass streamed content on to the client as soon as it arrives
+		out = oneshothttp.NewFlushWriter(w)
+	}
 
 	for key := range header {
 		w.Header().Set(key, header.Get(key))
@@ -53,12 +65,12 @@ func (c *Cmd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		&rts.Progress,
 		125*time.Millisecond,
 		r.RemoteAddr,
-		0,
+		size,
 	)
 	defer cancelProgDisp()
 
 	// Start writing the file data to the client while timing how long it takes
-	bw, getBufBytes := output.NewBufferedWriter(ctx, w)
+	bw, getBufBytes := output.NewBufferedWriter(ctx, out)
 	fileReport := events.File{
 		Size:              int64(size),
 		TransferStartTime: time.Now(),
@@ -6,15 +6,17 @@ import (
 	"os"
 
 	"github.com/forestnode-io/oneshot/v2/pkg/flags"
+	"github.com/forestnode-io/oneshot/v2/pkg/secrets"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 )
 
 type BasicAuth struct {
 	Username           string `mapstructure:"username" yaml:"username"`
-	Password           string `mapstructure:"password" yaml:"password"`
-	PasswordFile       string `mapstructure:"passwordFile" yaml:"passwordFile"`
+	Password           string `mapstructure:"password" yaml:"password" json:"-" secret:"true"`
+	PasswordFile       string `mapstructure:"passwordFile" yaml:"passwordFile" secret:"true"`
 	PasswordPrompt     bool   `mapstructure:"passwordPrompt" yaml:"passwordPrompt"`
+	HTPasswd           string `mapstructure:"htpasswd" yaml:"htpasswd"`
 	UnauthorizedPage   string `mapstructure:"unauthorizedPage" yaml:"unauthorizedPage"`
 	UnauthorizedStatus int    `mapstructure:"unauthorizedStatus" yaml:"unauthorizedStatus"`
 	NoDialog           bool   `mapstructure:"noDialog" yaml:"noDialog"`
@@ -28,13 +30,21 @@ func setBasicAuthFlags(cmd *cobra.Command) {
 If a password is not also provided then the client may enter any password.`)
 	flags.StringP(fs, "basicauth.password", "password", "P", `Password for basic authentication.
 If a username is not also provided using the --username flag then the client may enter any username.
-If either the --password-prompt or --password-file flags are set, this flag will be ignored.`)
+If either the --password-prompt or --password-file flags are set, this flag will be ignored.
+`+secrets.ReferenceHelp("password"))
 	flags.String(fs, "basicauth.passwordfile", "password-file", `Path to file containing password for basic authentication.
 If a username is not also provided then the client may enter any username.
-If the --password-prompt flag is set, this flags will be ignored.`)
+If the --password-prompt flag is set, this flags will be ignored.
+`+secrets.PathReferenceHelp)
 	flags.BoolP(fs, "basicauth.passwordprompt", "password-prompt", "W", `Prompt for password for basic authentication.
 If a username is not also provided then the client may enter any username.
 If the --password-file flag is set, this flag will be ignored.`)
+	flags.String(fs, "basicauth.htpasswd", "htpasswd", `Path to an htpasswd file of users allowed to authenticate.
+Entries are user:hash where hash is a bcrypt or argon2 hash of the users password.
+An optional third field limits what the user may do, a comma separated list of download and upload,
+e.g. alice:$2y$10$...:download lets alice make GET requests but not upload.
+The page receive serves to upload from only needs the upload permission.
+Cannot be used with --username, --password or --password-file.`)
 	flags.String(fs, "basicauth.unauthorizedpage", "unauthorized-page", `Path to file containing HTML to display when a user is unauthorized.
 If this flag is not set then a default page will be displayed.`)
 	flags.Int(fs, "basicauth.unauthorizedstatus", "unauthorized-status", `HTTP status code to return when a user is unauthorized.`)
@@ -49,10 +59,12 @@ If this flag is not set then a default page will be displayed.`)
 If a password is not also provided then the client may enter any password.`)
 		fs.StringP("password", "P", "", `Password for basic authentication.
 If a username is not also provided using the --username flag then the client may enter any username.
-If either the --password-prompt or --password-file flags are set, this flag will be ignored.`)
+If either the --password-prompt or --password-file flags are set, this flag will be ignored.
+`+secrets.ReferenceHelp("password"))
 		fs.String("password-file", "", `Path to file containing password for basic authentication.
 If a username is not also provided then the client may enter any username.
-If the --password-prompt flag is set, this flags will be ignored.`)
+If the --password-prompt flag is set, this flags will be ignored.
+`+secrets.PathReferenceHelp)
 		fs.BoolP("password-prompt", "W", false, `Prompt for password for basic authentication.
 If a username is not also provided then the client may enter any username.
 If the --password-file flag is set, this flag will be ignored.`)
@@ -65,6 +77,19 @@ func (c *BasicAuth) validate() error {
 		return fmt.Errorf("invalid unauthorized status code")
 	}
 
+	if c.HTPasswd != "" {
+		if c.Username != "" || c.Password != "" || c.PasswordFile != "" {
+			return fmt.Errorf("htpasswd cannot be used with a username or password")
+		}
+		stat, err := os.Stat(c.HTPasswd)
+		if err != nil {
+			return fmt.Errorf("unable to stat htpasswd file: %w", err)
+		}
+		if stat.IsDir() {
+			return fmt.Errorf("htpasswd file is a directory")
+		}
+	}
+
 	if c.UnauthorizedPage != "" {
 		stat, err := os.Stat(c.UnauthorizedPage)
 		if err != nil {
@@ -80,12 +105,19 @@ func (c *BasicAuth) validate() error {
 
 func (c *BasicAuth) hydrate() error {
 	if c.PasswordFile != "" {
-		data, err := os.ReadFile(c.PasswordFile)
+		password, err := secrets.ResolvePath(c.PasswordFile)
 		if err != nil {
-			return err
+			return fmt.Errorf("failed to read password: %w", err)
 		}
-		c.Password = string(data)
+		c.Password = password
+		return nil
+	}
+
+	password, err := secrets.Resolve(c.Password)
+	if err != nil {
+		return fmt.Errorf("failed to read password: %w", err)
 	}
+	c.Password = password
 
 	return nil
 }
@@ -14,6 +14,8 @@ import (
 
 var (
 	configPath string
+	// defaults holds the default value of each configuration key
+	defaults = map[string]any{}
 )
 
 func init() {
@@ -74,131 +76,229 @@ func setDefault() {
 	viper.SetTypeByDefaultValue(true)
 
 	// output
-	viper.SetDefault("output.quiet", false)
-	viper.SetDefault("output.format", "")
-	viper.SetDefault("output.qrCode", false)
-	viper.SetDefault("output.noColor", false)
+	setDefaultValue("output.quiet", false)
+	setDefaultValue("output.format", "")
+	setDefaultValue("output.qrCode", false)
+	setDefaultValue("output.noColor", false)
+	setDefaultValue("output.tui", false)
+	setDefaultValue("output.progressInterval", time.Second)
 
 	// server
-	viper.SetDefault("server.host", "")
-	viper.SetDefault("server.port", 8080)
-	viper.SetDefault("server.timeout", 0*time.Second)
-	viper.SetDefault("server.allowbots", false)
-	viper.SetDefault("server.maxreadsize", "0")
-	viper.SetDefault("server.exitonfail", "0")
-	viper.SetDefault("server.tlscert", "")
-	viper.SetDefault("server.tlskey", "")
+	setDefaultValue("server.host", "")
+	setDefaultValue("server.port", 8080)
+	setDefaultValue("server.listen", []string{})
+	setDefaultValue("server.timeout", 0*time.Second)
+	setDefaultValue("server.allowbots", false)
+	setDefaultValue("server.maxreadsize", "0")
+	setDefaultValue("server.exitonfail", "0")
+	setDefaultValue("server.tlscert", "")
+	setDefaultValue("server.tlskey", "")
+	setDefaultValue("server.tlsclientca", "")
+	setDefaultValue("server.tlsclientallowedsubjects", []string{})
+	setDefaultValue("server.tlsclientallowedsans", []string{})
 
 	// basic auth
-	viper.SetDefault("basicauth.username", "")
-	viper.SetDefault("basicauth.password", "")
-	viper.SetDefault("basicauth.passwordfile", "")
-	viper.SetDefault("basicauth.passwordprompt", false)
-	viper.SetDefault("basicauth.unauthorizedpage", "")
-	viper.SetDefault("basicauth.unauthorizedstatus", http.StatusUnauthorized)
-	viper.SetDefault("basicauth.noDialog", false)
+	setDefaultValue("basicauth.username", "")
+	setDefaultValue("basicauth.password", "")
+	setDefaultValue("basicauth.passwordfile", "")
+	setDefaultValue("basicauth.passwordprompt", false)
+	setDefaultValue("basicauth.htpasswd", "")
+	setDefaultValue("basicauth.unauthorizedpage", "")
+	setDefaultValue("basicauth.unauthorizedstatus", http.StatusUnauthorized)
+	setDefaultValue("basicauth.noDialog", false)
+
+	// oidc
+	setDefaultValue("oidc.issuer", "")
+	setDefaultValue("oidc.clientid", "")
+	setDefaultValue("oidc.clientsecret", "")
+	setDefaultValue("oidc.redirecturl", "")
+	setDefaultValue("oidc.scopes", []string{"email", "profile"})
+	setDefaultValue("oidc.allowedemails", []string{})
+	setDefaultValue("oidc.alloweddomains", []string{})
+	setDefaultValue("oidc.allowedgroups", []string{})
+	setDefaultValue("oidc.groupsclaim", "groups")
+	setDefaultValue("oidc.sessionttl", 12*time.Hour)
 
 	// cors
-	viper.SetDefault("cors.allowedorigins", []string{})
-	viper.SetDefault("cors.allowedheaders", []string{})
-	viper.SetDefault("cors.maxage", 0)
-	viper.SetDefault("cors.allowcredentials", false)
-	viper.SetDefault("cors.allowprivatenetwork", false)
-	viper.SetDefault("cors.successstatus", http.StatusNoContent)
+	setDefaultValue("cors.allowedorigins", []string{})
+	setDefaultValue("cors.allowedheaders", []string{})
+	setDefaultValue("cors.maxage", 0)
+	setDefaultValue("cors.allowcredentials", false)
+	setDefaultValue("cors.allowprivatenetwork", false)
+	setDefaultValue("cors.successstatus", http.StatusNoContent)
+
+	// hooks
+	setDefaultValue("hooks.onRequest", []string{})
+	setDefaultValue("hooks.onSuccess", []string{})
+	setDefaultValue("hooks.onFailure", []string{})
+	setDefaultValue("hooks.webhook", []string{})
+	setDefaultValue("hooks.timeout", 30*time.Second)
 
 	// nat traversal - p2p
-	viper.SetDefault("nattraversal.p2p.enabled", false)
-	viper.SetDefault("nattraversal.p2p.only", false)
-	viper.SetDefault("nattraversal.p2p.discoverydir", "")
-	viper.SetDefault("nattraversal.p2p.webrtcconfiguration", []byte{})
-	viper.SetDefault("nattraversal.p2p.webrtcconfigurationfile", "")
-	viper.SetDefault("nattraversal.p2p.icegathertimeout", 30*time.Second)
+	setDefaultValue("nattraversal.p2p.enabled", false)
+	setDefaultValue("nattraversal.p2p.only", false)
+	setDefaultValue("nattraversal.p2p.discoverydir", "")
+	setDefaultValue("nattraversal.p2p.webrtcconfiguration", []byte{})
+	setDefaultValue("nattraversal.p2p.webrtcconfigurationfile", "")
+	setDefaultValue("nattraversal.p2p.icegathertimeout", 30*time.Second)
 
 	// nat traversal - upnp
-	viper.SetDefault("nattraversal.upnp.enabled", false)
-	viper.SetDefault("nattraversal.upnp.externalport", 0)
-	viper.SetDefault("nattraversal.upnp.duration", 0*time.Second)
-	viper.SetDefault("nattraversal.upnp.timeout", 60*time.Second)
+	setDefaultValue("nattraversal.upnp.enabled", false)
+	setDefaultValue("nattraversal.upnp.externalport", 0)
+	setDefaultValue("nattraversal.upnp.duration", 0*time.Second)
+	setDefaultValue("nattraversal.upnp.timeout", 60*time.Second)
+	setDefaultValue("nattraversal.upnp.protocols", []string{"upnp", "natpmp", "pcp"})
+	setDefaultValue("nattraversal.upnp.gateway", "")
+	setDefaultValue("nattraversal.upnp.ipv6", false)
+	setDefaultValue("nattraversal.upnp.ipv6gateway", "")
+
+	// nat traversal - tunnel
+	setDefaultValue("nattraversal.tunnel.provider", "")
+	setDefaultValue("nattraversal.tunnel.publicurl", "")
+	setDefaultValue("nattraversal.tunnel.timeout", 30*time.Second)
+	setDefaultValue("nattraversal.tunnel.ssh.host", "")
+	setDefaultValue("nattraversal.tunnel.ssh.identityfile", "")
+	setDefaultValue("nattraversal.tunnel.ssh.knownhostsfile", "")
+	setDefaultValue("nattraversal.tunnel.ssh.insecureignorehostkey", false)
+	setDefaultValue("nattraversal.tunnel.ssh.remoteaddress", "0.0.0.0:0")
 
 	// subcommands - receive
-	viper.SetDefault("cmd.receive.csrftoken", "")
-	viper.SetDefault("cmd.receive.eol", "")
-	viper.SetDefault("cmd.receive.uifile", "")
-	viper.SetDefault("cmd.receive.decodeb64", false)
-	viper.SetDefault("cmd.receive.status", http.StatusOK)
-	viper.SetDefault("cmd.receive.header", map[string][]string{})
-	viper.SetDefault("cmd.receive.includebody", false)
+	setDefaultValue("cmd.receive.csrftoken", "")
+	setDefaultValue("cmd.receive.eol", "")
+	setDefaultValue("cmd.receive.uifile", "")
+	setDefaultValue("cmd.receive.decodeb64", false)
+	setDefaultValue("cmd.receive.status", http.StatusOK)
+	setDefaultValue("cmd.receive.header", map[string][]string{})
+	setDefaultValue("cmd.receive.includebody", false)
+	setDefaultValue("cmd.receive.extract", "")
+	setDefaultValue("cmd.receive.extractmaxsize", "10GiB")
+	setDefaultValue("cmd.receive.extractmaxentries", 100000)
 
 	// cmd - send
 	archiveMethod := "tar.gz"
 	if runtime.GOOS == "windows" {
 		archiveMethod = "zip"
 	}
-	viper.SetDefault("cmd.send.archivemethod", archiveMethod)
-	viper.SetDefault("cmd.send.nodownload", false)
-	viper.SetDefault("cmd.send.mime", "")
-	viper.SetDefault("cmd.send.name", "")
-	viper.SetDefault("cmd.send.statuscode", http.StatusOK)
-	viper.SetDefault("cmd.send.header", map[string][]string{})
+	setDefaultValue("cmd.send.archivemethod", archiveMethod)
+	setDefaultValue("cmd.send.compressionlevel", -1)
+	setDefaultValue("cmd.send.exclude", []string{})
+	setDefaultValue("cmd.send.gitignore", false)
+	setDefaultValue("cmd.send.deterministic", false)
+	setDefaultValue("cmd.send.computesize", false)
+	setDefaultValue("cmd.send.stream", false)
+	setDefaultValue("cmd.send.streamspool", false)
+	setDefaultValue("cmd.send.streammemory", "32MiB")
+	setDefaultValue("cmd.send.nodownload", false)
+	setDefaultValue("cmd.send.mime", "")
+	setDefaultValue("cmd.send.name", "")
+	setDefaultValue("cmd.send.statuscode", http.StatusOK)
+	setDefaultValue("cmd.send.header", map[string][]string{})
 
 	// cmd - exec
-	viper.SetDefault("cmd.exec.enforcecgi", false)
-	viper.SetDefault("cmd.exec.env", []string{})
-	viper.SetDefault("cmd.exec.dir", "")
-	viper.SetDefault("cmd.exec.stderr", "")
-	viper.SetDefault("cmd.exec.replaceheaders", false)
-	viper.SetDefault("cmd.exec.headers", map[string][]string{})
+	setDefaultValue("cmd.exec.enforcecgi", false)
+	setDefaultValue("cmd.exec.env", []string{})
+	setDefaultValue("cmd.exec.dir", "")
+	setDefaultValue("cmd.exec.stderr", "")
+	setDefaultValue("cmd.exec.replaceheaders", false)
+	setDefaultValue("cmd.exec.headers", map[string][]string{})
 
 	// cmd - redirect
-	viper.SetDefault("cmd.redirect.status", http.StatusTemporaryRedirect)
-	viper.SetDefault("cmd.redirect.header", map[string][]string{})
+	setDefaultValue("cmd.redirect.status", http.StatusTemporaryRedirect)
+	setDefaultValue("cmd.redirect.header", map[string][]string{})
+	setDefaultValue("cmd.redirect.oauthcallback", false)
+	setDefaultValue("cmd.redirect.oauthstate", "")
+	setDefaultValue("cmd.redirect.qrhandoff", false)
 
 	// cmd - rproxy
-	viper.SetDefault("cmd.rproxy.status", 0)
-	viper.SetDefault("cmd.rproxy.method", "")
-	viper.SetDefault("cmd.rproxy.matchhost", false)
-	viper.SetDefault("cmd.rproxy.tee", false)
-	viper.SetDefault("cmd.rproxy.spoofhost", "")
-	viper.SetDefault("cmd.rproxy.requestheader", map[string][]string{})
-	viper.SetDefault("cmd.rproxy.responseheader", map[string][]string{})
+	setDefaultValue("cmd.rproxy.status", 0)
+	setDefaultValue("cmd.rproxy.method", "")
+	setDefaultValue("cmd.rproxy.matchhost", false)
+	setDefaultValue("cmd.rproxy.tee", false)
+	setDefaultValue("cmd.rproxy.spoofhost", "")
+	setDefaultValue("cmd.rproxy.requestheader", map[string][]string{})
+	setDefaultValue("cmd.rproxy.responseheader", map[string][]string{})
+	setDefaultValue("cmd.rproxy.har", "")
+	setDefaultValue("cmd.rproxy.harmaxbodysize", "1MB")
+	setDefaultValue("cmd.rproxy.replay", "")
+
+	// cmd - respond
+	setDefaultValue("cmd.respond.spec", "")
+	setDefaultValue("cmd.respond.matches", 1)
+	setDefaultValue("cmd.respond.unmatchedstatus", http.StatusNotFound)
+
+	// cmd - capture
+	setDefaultValue("cmd.capture.status", http.StatusOK)
+	setDefaultValue("cmd.capture.header", map[string][]string{})
+	setDefaultValue("cmd.capture.body", "")
+	setDefaultValue("cmd.capture.count", 1)
+	setDefaultValue("cmd.capture.hmacsecret", "")
+	setDefaultValue("cmd.capture.hmacheader", "X-Hub-Signature-256")
+	setDefaultValue("cmd.capture.hmacalgorithm", "sha256")
+	setDefaultValue("cmd.capture.hmactolerance", 5*time.Minute)
 
 	// cmd - p2p - browserclient
-	viper.SetDefault("cmd.p2p.browserclient.open", false)
+	setDefaultValue("cmd.p2p.browserclient.open", false)
 
 	// cmd - p2p - client - receive
 
 	// cmd - p2p - client - send
-	viper.SetDefault("cmd.p2p.client.send.name", "")
-	viper.SetDefault("cmd.p2p.client.send.archivemethod", "")
+	setDefaultValue("cmd.p2p.client.send.name", "")
+	setDefaultValue("cmd.p2p.client.send.archivemethod", "")
+
+	// cmd - get
+	setDefaultValue("cmd.get.extract", "")
+	setDefaultValue("cmd.get.header", map[string][]string{})
+
+	// cmd - put
+	setDefaultValue("cmd.put.archivemethod", archiveMethod)
+	setDefaultValue("cmd.put.compressionlevel", -1)
+	setDefaultValue("cmd.put.exclude", []string{})
+	setDefaultValue("cmd.put.gitignore", false)
+	setDefaultValue("cmd.put.deterministic", false)
+	setDefaultValue("cmd.put.computesize", false)
+	setDefaultValue("cmd.put.name", "")
+	setDefaultValue("cmd.put.mime", "")
+	setDefaultValue("cmd.put.method", http.MethodPost)
+	setDefaultValue("cmd.put.csrftoken", "")
+	setDefaultValue("cmd.put.multipart", false)
+	setDefaultValue("cmd.put.header", map[string][]string{})
 
 	// cmd - discovery server
-	viper.SetDefault("cmd.discoveryserver.requiredkey.path", "")
-	viper.SetDefault("cmd.discoveryserver.requiredkey.value", "")
-	viper.SetDefault("cmd.discoveryserver.jwt.key", "")
-	viper.SetDefault("cmd.discoveryserver.jwt.value", "")
-	viper.SetDefault("cmd.discoveryserver.maxqueuesize", 0)
-	viper.SetDefault("cmd.discoveryserver.urlassignment.scheme", "")
-	viper.SetDefault("cmd.discoveryserver.urlassignment.domain", "")
-	viper.SetDefault("cmd.discoveryserver.urlassignment.port", "")
-	viper.SetDefault("cmd.discoveryserver.urlassignment.path", "")
-	viper.SetDefault("cmd.discoveryserver.urlassignment.pathprefix", "")
-	viper.SetDefault("cmd.discoveryserver.server.addr", "")
-	viper.SetDefault("cmd.discoveryserver.server.tlscert", "")
-	viper.SetDefault("cmd.discoveryserver.server.tlskey", "")
+	setDefaultValue("cmd.discoveryserver.requiredkey.path", "")
+	setDefaultValue("cmd.discoveryserver.requiredkey.value", "")
+	setDefaultValue("cmd.discoveryserver.jwt.key", "")
+	setDefaultValue("cmd.discoveryserver.jwt.value", "")
+	setDefaultValue("cmd.discoveryserver.maxqueuesize", 0)
+	setDefaultValue("cmd.discoveryserver.urlassignment.scheme", "")
+	setDefaultValue("cmd.discoveryserver.urlassignment.domain", "")
+	setDefaultValue("cmd.discoveryserver.urlassignment.port", "")
+	setDefaultValue("cmd.discoveryserver.urlassignment.path", "")
+	setDefaultValue("cmd.discoveryserver.urlassignment.pathprefix", "")
+	setDefaultValue("cmd.discoveryserver.server.addr", "")
+	setDefaultValue("cmd.discoveryserver.server.tlscert", "")
+	setDefaultValue("cmd.discoveryserver.server.tlskey", "")
 
 	// discovery
-	viper.SetDefault("discovery.enabled", true)
-	viper.SetDefault("discovery.host", "")
-	viper.SetDefault("discovery.key", "")
-	viper.SetDefault("discovery.keypath", "")
-	viper.SetDefault("discovery.insecure", false)
-	viper.SetDefault("discovery.preferredurl", "")
-	viper.SetDefault("discovery.requiredurl", "")
-	viper.SetDefault("discovery.onlyredirect", false)
-	viper.SetDefault("discovery.reports.enabled", true)
-	viper.SetDefault("discovery.reports.headerfilter.usedefaults", true)
-	viper.SetDefault("discovery.reports.headerfilter.allow", []string{})
-	viper.SetDefault("discovery.reports.headerfilter.block", []string{})
+	setDefaultValue("discovery.enabled", true)
+	setDefaultValue("discovery.host", "")
+	setDefaultValue("discovery.key", "")
+	setDefaultValue("discovery.keypath", "")
+	setDefaultValue("discovery.insecure", false)
+	setDefaultValue("discovery.preferredurl", "")
+	setDefaultValue("discovery.requiredurl", "")
+	setDefaultValue("discovery.onlyredirect", false)
+	setDefaultValue("discovery.reports.enabled", true)
+	setDefaultValue("discovery.reports.headerfilter.usedefaults", true)
+	setDefaultValue("discovery.reports.headerfilter.allow", []string{})
+	setDefaultValue("discovery.reports.headerfilter.block", []string{})
+
+	// project configuration files
+	setDefaultValue("trustedProjectConfigs", []string{})
+}
+
+func setDefaultValue(key string, value any) {
+	defaults[strings.ToLower(key)] = value
+	viper.SetDefault(key, value)
 }
 
 func readInConfig() {
@@ -2,9 +2,9 @@ package configuration
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/forestnode-io/oneshot/v2/pkg/flags"
+	"github.com/forestnode-io/oneshot/v2/pkg/secrets"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 )
@@ -23,8 +23,8 @@ type Reports struct {
 type Discovery struct {
 	Enabled      bool    `mapstructure:"enabled" yaml:"enabled"`
 	Host         string  `mapstructure:"host" yaml:"host"`
-	Key          string  `mapstructure:"key" yaml:"key" json:"-"`
-	KeyPath      string  `mapstructure:"keyPath" yaml:"keyPath"`
+	Key          string  `mapstructure:"key" yaml:"key" json:"-" secret:"true"`
+	KeyPath      string  `mapstructure:"keyPath" yaml:"keyPath" secret:"true"`
 	Insecure     bool    `mapstructure:"insecure" yaml:"insecure"`
 	PreferredURL string  `mapstructure:"preferredURL" yaml:"preferredURL"`
 	RequiredURL  string  `mapstructure:"requiredURL" yaml:"requiredURL"`
@@ -37,9 +37,11 @@ func setDiscoveryFlags(cmd *cobra.Command) {
 	defer cmd.PersistentFlags().AddFlagSet(fs)
 
 	flags.Bool(fs, "discovery.enabled", "discovery-enabled", "Enable discovery server.")
-	flags.String(fs, "discovery.url", "discovery-url", "URL of the discovery server to connect to.")
-	flags.String(fs, "discovery.keypath", "discovery-key-path", "Path to the key to present to the discovery server.")
-	flags.String(fs, "discovery.key", "discovery-key", "Key to present to the discovery server.")
+	// the key must match the mapstructure tag of Discovery.Host,
+	// binding the flag to discovery.url left Host empty no matter what --discovery-url was set to.
+	flags.String(fs, "discovery.host", "discovery-url", "URL of the discovery server to connect to.")
+	flags.String(fs, "discovery.keypath", "discovery-key-path", "Path to the key to present to the discovery server.\n"+secrets.PathReferenceHelp)
+	flags.String(fs, "discovery.key", "discovery-key", "Key to present to the discovery server.\n"+secrets.ReferenceHelp("key"))
 	fs.Lookup("discovery-key").DefValue = ""
 	flags.Bool(fs, "discovery.insecure", "discovery-insecure", "Allow insecure connections to the discovery server.")
 	flags.String(fs, "discovery.preferredurl", "discovery-preferred-url", "URL that the discovery server should try to reserve for connecting client.")
@@ -57,11 +59,17 @@ func setDiscoveryFlags(cmd *cobra.Command) {
 
 func (c *Discovery) hydrate() error {
 	if c.KeyPath != "" {
-		key, err := os.ReadFile(c.KeyPath)
+		key, err := secrets.ResolvePath(c.KeyPath)
 		if err != nil {
 			return fmt.Errorf("failed to read discovery server key file: %w", err)
 		}
-		c.Key = string(key)
+		c.Key = key
+	} else {
+		key, err := secrets.Resolve(c.Key)
+		if err != nil {
+			return fmt.Errorf("failed to read discovery server key: %w", err)
+		}
+		c.Key = key
 	}
 
 	if c.Key == "" {
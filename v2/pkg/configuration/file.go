@@ -0,0 +1,120 @@
+package configuration
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// File is a configuration file on its own, without defaults, other files, profiles,
+// environment variables or flags layered on top of it.
+type File struct {
+	path     string
+	settings map[string]any
+}
+
+// OpenFile reads the configuration file at path.
+// A file that does not exist yet is treated as empty.
+func OpenFile(path string) (*File, error) {
+	if path == "" {
+		return nil, errors.New("no configuration file found")
+	}
+	settings, err := readConfigFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("unable to read configuration file %s: %w", path, err)
+		}
+		settings = map[string]any{}
+	}
+	return &File{
+		path:     path,
+		settings: settings,
+	}, nil
+}
+
+func (f *File) Path() string {
+	return f.path
+}
+
+// Get returns the value the file sets key to.
+func (f *File) Get(key string) (any, bool) {
+	path := strings.Split(strings.ToLower(key), ".")
+	m := f.settings
+	for _, k := range path[:len(path)-1] {
+		next, ok := m[k].(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		m = next
+	}
+	v, ok := m[path[len(path)-1]]
+	return v, ok
+}
+
+// Set sets key to value.
+func (f *File) Set(key string, value any) {
+	path := strings.Split(strings.ToLower(key), ".")
+	m := f.settings
+	for _, k := range path[:len(path)-1] {
+		next, ok := m[k].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			m[k] = next
+		}
+		m = next
+	}
+	m[path[len(path)-1]] = value
+}
+
+// Unset removes key from the file, and any sections left empty by doing so.
+// It reports whether the file set key.
+func (f *File) Unset(key string) bool {
+	return unset(f.settings, strings.Split(strings.ToLower(key), "."))
+}
+
+func unset(m map[string]any, path []string) bool {
+	if len(path) == 1 {
+		_, ok := m[path[0]]
+		delete(m, path[0])
+		return ok
+	}
+	next, ok := m[path[0]].(map[string]any)
+	if !ok {
+		return false
+	}
+	ok = unset(next, path[1:])
+	if len(next) == 0 {
+		delete(m, path[0])
+	}
+	return ok
+}
+
+// Validate checks that the file is a valid configuration once layered on top of the defaults.
+func (f *File) Validate() error {
+	v := viper.New()
+	for key, value := range defaults {
+		v.SetDefault(key, value)
+	}
+	if err := v.MergeConfigMap(f.settings); err != nil {
+		return err
+	}
+
+	root := EmptyRoot()
+	if err := v.Unmarshal(root); err != nil {
+		return err
+	}
+	return root.Validate()
+}
+
+// Write writes the file back to disk.
+func (f *File) Write() error {
+	v := viper.New()
+	v.SetConfigFile(f.path)
+	if err := v.MergeConfigMap(f.settings); err != nil {
+		return err
+	}
+	return v.WriteConfig()
+}
@@ -0,0 +1,58 @@
+package configuration
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/flags"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+type Hooks struct {
+	OnRequest []string      `mapstructure:"onRequest" yaml:"onRequest"`
+	OnSuccess []string      `mapstructure:"onSuccess" yaml:"onSuccess"`
+	OnFailure []string      `mapstructure:"onFailure" yaml:"onFailure"`
+	Webhook   []string      `mapstructure:"webhook" yaml:"webhook"`
+	Timeout   time.Duration `mapstructure:"timeout" yaml:"timeout"`
+}
+
+func setHooksFlags(cmd *cobra.Command) {
+	fs := pflag.NewFlagSet("Hooks Flags", pflag.ExitOnError)
+	defer cmd.PersistentFlags().AddFlagSet(fs)
+
+	flags.StringArray(fs, "hooks.onRequest", "on-request", `Shell command to run whenever a client makes a request. Can be specified multiple times.
+The event is given to the command as json on stdin and as ONESHOT_* environment variables.`)
+	flags.StringArray(fs, "hooks.onSuccess", "on-success", `Shell command to run once oneshot is done after a successful transfer. Can be specified multiple times.
+ONESHOT_FILE_PATH is set to the path the file was saved to, if any.`)
+	flags.StringArray(fs, "hooks.onFailure", "on-failure", `Shell command to run whenever a client fails to complete a transfer. Can be specified multiple times.
+ONESHOT_ERROR is set to the reason the transfer failed.`)
+	flags.StringArray(fs, "hooks.webhook", "webhook", `URL to POST the request, success and failure events to as json. Can be specified multiple times.`)
+	flags.Duration(fs, "hooks.timeout", "hook-timeout", `How long a hook command or webhook may run before it is cancelled.`)
+
+	cobra.AddTemplateFunc("hooksFlags", func() *pflag.FlagSet {
+		return fs
+	})
+}
+
+func (c *Hooks) validate() error {
+	for _, wh := range c.Webhook {
+		u, err := url.Parse(wh)
+		if err != nil {
+			return fmt.Errorf("invalid webhook url %q: %w", wh, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("invalid webhook url %q: scheme must be http or https", wh)
+		}
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("invalid hook timeout: %s", c.Timeout)
+	}
+	return nil
+}
+
+// Enabled returns whether any hooks have been configured.
+func (c *Hooks) Enabled() bool {
+	return 0 < len(c.OnRequest)+len(c.OnSuccess)+len(c.OnFailure)+len(c.Webhook)
+}
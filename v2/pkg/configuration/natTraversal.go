@@ -4,9 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"time"
 
 	"github.com/forestnode-io/oneshot/v2/pkg/flags"
+	"github.com/forestnode-io/oneshot/v2/pkg/net/portmap"
+	"github.com/forestnode-io/oneshot/v2/pkg/net/tunnel"
 	"github.com/forestnode-io/oneshot/v2/pkg/net/webrtc"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -14,8 +17,9 @@ import (
 )
 
 type NATTraversal struct {
-	P2P  P2P  `mapstructure:"p2p" yaml:"p2p"`
-	UPnP UPnP `mapstructure:"upnp" yaml:"upnp"`
+	P2P    P2P    `mapstructure:"p2p" yaml:"p2p"`
+	UPnP   UPnP   `mapstructure:"upnp" yaml:"upnp"`
+	Tunnel Tunnel `mapstructure:"tunnel" yaml:"tunnel"`
 }
 
 func (c *NATTraversal) IsUsingWebRTC() bool {
@@ -29,6 +33,7 @@ func (c *NATTraversal) IsUsingUPnP() bool {
 func setNATTraversalFlags(cmd *cobra.Command) {
 	setP2PFlags(cmd)
 	setUPnPFlags(cmd)
+	setTunnelFlags(cmd)
 }
 
 func (c *NATTraversal) validate() error {
@@ -38,6 +43,9 @@ func (c *NATTraversal) validate() error {
 	if err := c.UPnP.validate(); err != nil {
 		return fmt.Errorf("invalid UPnP configuration: %w", err)
 	}
+	if err := c.Tunnel.validate(); err != nil {
+		return fmt.Errorf("invalid tunnel configuration: %w", err)
+	}
 
 	return nil
 }
@@ -93,7 +101,9 @@ func (c *P2P) hydrate() error {
 	if c.WebRTCConfigurationFile == "" {
 		return nil
 	}
-	if c.WebRTCConfiguration != nil {
+	// the configuration defaults to an empty rather than a nil slice,
+	// so only skip reading the file if there is something in it.
+	if len(c.WebRTCConfiguration) != 0 {
 		return nil
 	}
 
@@ -122,19 +132,32 @@ func (c *P2P) ParseConfig() (*webrtc.Configuration, error) {
 
 type UPnP struct {
 	ExternalPort int           `mapstructure:"externalPort" yaml:"externalPort"`
-	Enabled      bool          `mapstructure:"mapPort" yaml:"mapPort"`
+	Enabled      bool          `mapstructure:"enabled" yaml:"enabled"`
 	Duration     time.Duration `mapstructure:"duration" yaml:"duration"`
 	Timeout      time.Duration `mapstructure:"timeout" yaml:"timeout" flag:"upnp-discovery-timeout"`
+	// Protocols are the port mapping protocols to try, in order.
+	Protocols []string `mapstructure:"protocols" yaml:"protocols"`
+	// Gateway is the NAT-PMP and PCP server, defaults to the default gateway.
+	Gateway string `mapstructure:"gateway" yaml:"gateway"`
+	// IPv6 opens an IPv6 pinhole using PCP as well as mapping the IPv4 port.
+	IPv6 bool `mapstructure:"ipv6" yaml:"ipv6"`
+	// IPv6Gateway is the PCP server used for IPv6 pinholes, defaults to the PCP anycast address.
+	IPv6Gateway string `mapstructure:"ipv6Gateway" yaml:"ipv6Gateway"`
 }
 
 func setUPnPFlags(cmd *cobra.Command) {
-	fs := pflag.NewFlagSet("UPnP-IGD Flags", pflag.ExitOnError)
-	defer cmd.Flags().AddFlagSet(fs)
+	fs := pflag.NewFlagSet("Port Mapping Flags", pflag.ExitOnError)
+	defer cmd.PersistentFlags().AddFlagSet(fs)
 
-	flags.Int(fs, "nattraversal.upnp.externalport", "external-port", "External port to use for UPnP IGD port mapping.")
-	flags.Duration(fs, "nattraversal.upnp.duration", "port-mapping-duration", "Duration to use for UPnP IGD port mapping.")
-	flags.Duration(fs, "nattraversal.upnp.timeout", "upnp-discovery-timeout", "Timeout for UPnP IGD discovery.")
-	flags.Bool(fs, "nattraversal.upnp.enabled", "map-port", "Map port using UPnP IGD.")
+	flags.Int(fs, "nattraversal.upnp.externalport", "external-port", "External port to use for port mapping. Defaults to the port being listened on.")
+	flags.Duration(fs, "nattraversal.upnp.duration", "port-mapping-duration", `Duration to use for port mapping.
+The mapping is renewed before it expires while clients are connected, otherwise oneshot exits once it expires.`)
+	flags.Duration(fs, "nattraversal.upnp.timeout", "upnp-discovery-timeout", "Timeout for UPnP IGD discovery and for NAT-PMP and PCP gateways to respond.")
+	flags.Bool(fs, "nattraversal.upnp.enabled", "map-port", "Map port using UPnP IGD, NAT-PMP or PCP.")
+	flags.StringSlice(fs, "nattraversal.upnp.protocols", "port-mapping-protocols", "Comma separated list of port mapping protocols to try, in order. Valid protocols are: upnp, natpmp, pcp.")
+	flags.String(fs, "nattraversal.upnp.gateway", "port-mapping-gateway", "Address of the NAT-PMP and PCP server. Defaults to the default gateway.")
+	flags.Bool(fs, "nattraversal.upnp.ipv6", "port-mapping-ipv6", "Also open an IPv6 pinhole in the gateways firewall using PCP.")
+	flags.String(fs, "nattraversal.upnp.ipv6gateway", "port-mapping-ipv6-gateway", "Address of the PCP server to open IPv6 pinholes with. Defaults to the PCP anycast address 2001:1::1.")
 
 	cobra.AddTemplateFunc("upnpFlags", func() *pflag.FlagSet {
 		return fs
@@ -154,10 +177,6 @@ func (c *UPnP) validate() error {
 		return errors.New("port mapping duration must be specified when external port is specified")
 	}
 
-	if 0 < c.Duration && c.ExternalPort == 0 {
-		return errors.New("external port must be specified when port mapping duration is specified")
-	}
-
 	if c.Enabled && c.Duration == 0 {
 		return errors.New("port mapping duration must be specified when UPnP is enabled")
 	}
@@ -166,5 +185,71 @@ func (c *UPnP) validate() error {
 		return errors.New("UPnP discovery timeout must be specified when UPnP is enabled or external port is specified")
 	}
 
+	if len(c.Protocols) == 0 && (c.Enabled || c.ExternalPort != 0) {
+		return errors.New("at least one port mapping protocol must be specified")
+	}
+	for _, p := range c.Protocols {
+		if !slices.Contains(portmap.Protocols, p) {
+			return fmt.Errorf("invalid port mapping protocol: %s", p)
+		}
+	}
+
+	if c.IPv6 && !slices.Contains(c.Protocols, portmap.ProtocolPCP) {
+		return errors.New("pcp must be one of the port mapping protocols to open IPv6 pinholes")
+	}
+
+	return nil
+}
+
+type Tunnel struct {
+	// Provider is the tunnel provider to expose oneshot through, either ssh or discovery.
+	Provider string `mapstructure:"provider" yaml:"provider"`
+	// PublicURL replaces the url the provider reports, e.g. when the tunnel is behind a reverse proxy.
+	PublicURL string        `mapstructure:"publicURL" yaml:"publicURL"`
+	Timeout   time.Duration `mapstructure:"timeout" yaml:"timeout"`
+	SSH       SSHTunnel     `mapstructure:"ssh" yaml:"ssh"`
+}
+
+type SSHTunnel struct {
+	Host                  string `mapstructure:"host" yaml:"host"`
+	IdentityFile          string `mapstructure:"identityFile" yaml:"identityFile"`
+	KnownHostsFile        string `mapstructure:"knownHostsFile" yaml:"knownHostsFile"`
+	InsecureIgnoreHostKey bool   `mapstructure:"insecureIgnoreHostKey" yaml:"insecureIgnoreHostKey"`
+	RemoteAddress         string `mapstructure:"remoteAddress" yaml:"remoteAddress"`
+}
+
+func setTunnelFlags(cmd *cobra.Command) {
+	fs := pflag.NewFlagSet("Tunnel Flags", pflag.ExitOnError)
+	defer cmd.PersistentFlags().AddFlagSet(fs)
+
+	flags.String(fs, "nattraversal.tunnel.provider", "tunnel", `Expose oneshot at a public url through a tunnel. Valid providers are:
+ssh: a reverse tunnel on an ssh server, like ssh -R. The ssh server must allow remote port forwarding.
+discovery: the discovery server relays connections made to a url it assigns.`)
+	flags.String(fs, "nattraversal.tunnel.publicurl", "tunnel-public-url", "URL to show instead of the one the tunnel provider reports, e.g. when a reverse proxy sits in front of the tunnel.")
+	flags.Duration(fs, "nattraversal.tunnel.timeout", "tunnel-timeout", "Timeout for opening the tunnel.")
+	flags.String(fs, "nattraversal.tunnel.ssh.host", "tunnel-ssh-host", "SSH server to open the tunnel on as [user@]host[:port].")
+	flags.String(fs, "nattraversal.tunnel.ssh.identityfile", "tunnel-ssh-identity-file", "Private key to authenticate to the ssh server with. Defaults to the keys of a running ssh-agent and the default keys in ~/.ssh.")
+	flags.String(fs, "nattraversal.tunnel.ssh.knownhostsfile", "tunnel-ssh-known-hosts-file", "Known hosts file to check the ssh servers host key against. Defaults to ~/.ssh/known_hosts.")
+	flags.Bool(fs, "nattraversal.tunnel.ssh.insecureignorehostkey", "tunnel-ssh-insecure", "Do not check the ssh servers host key.")
+	flags.String(fs, "nattraversal.tunnel.ssh.remoteaddress", "tunnel-ssh-remote-address", "Address the ssh server listens on for the tunnel. Port 0 lets the ssh server pick the port.")
+
+	cobra.AddTemplateFunc("tunnelFlags", func() *pflag.FlagSet {
+		return fs
+	})
+}
+
+func (c *Tunnel) validate() error {
+	if c.Provider == "" {
+		return nil
+	}
+	if !slices.Contains(tunnel.Providers, c.Provider) {
+		return fmt.Errorf("invalid tunnel provider: %s", c.Provider)
+	}
+	if c.Provider == tunnel.ProviderSSH && c.SSH.Host == "" {
+		return errors.New("tunnel-ssh-host must be set to use the ssh tunnel provider")
+	}
+	if c.Timeout < 0 {
+		return errors.New("invalid tunnel timeout")
+	}
 	return nil
 }
@@ -0,0 +1,94 @@
+package configuration
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/flags"
+	"github.com/forestnode-io/oneshot/v2/pkg/secrets"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+type OIDC struct {
+	Issuer         string        `mapstructure:"issuer" yaml:"issuer"`
+	ClientID       string        `mapstructure:"clientID" yaml:"clientID"`
+	ClientSecret   string        `mapstructure:"clientSecret" yaml:"clientSecret" json:"-" secret:"true"`
+	RedirectURL    string        `mapstructure:"redirectURL" yaml:"redirectURL"`
+	Scopes         []string      `mapstructure:"scopes" yaml:"scopes"`
+	AllowedEmails  []string      `mapstructure:"allowedEmails" yaml:"allowedEmails"`
+	AllowedDomains []string      `mapstructure:"allowedDomains" yaml:"allowedDomains"`
+	AllowedGroups  []string      `mapstructure:"allowedGroups" yaml:"allowedGroups"`
+	GroupsClaim    string        `mapstructure:"groupsClaim" yaml:"groupsClaim"`
+	SessionTTL     time.Duration `mapstructure:"sessionTTL" yaml:"sessionTTL"`
+}
+
+func setOIDCFlags(cmd *cobra.Command) {
+	fs := pflag.NewFlagSet("OIDC Flags", pflag.ExitOnError)
+	defer cmd.PersistentFlags().AddFlagSet(fs)
+
+	flags.String(fs, "oidc.issuer", "oidc-issuer", `URL of the OpenID Connect provider to log users in with.
+Browsers are sent through the providers authorization code flow before reaching oneshot.`)
+	flags.String(fs, "oidc.clientid", "oidc-client-id", `Client ID oneshot is registered with at the OpenID Connect provider.`)
+	flags.String(fs, "oidc.clientsecret", "oidc-client-secret", `Client secret oneshot is registered with at the OpenID Connect provider.
+Public clients may leave this unset and rely on PKCE alone.
+`+secrets.ReferenceHelp("secret"))
+	flags.String(fs, "oidc.redirecturl", "oidc-redirect-url", `URL the provider should send users back to.
+Defaults to /.oneshot/oidc/callback on the host the user connected to.`)
+	flags.StringSlice(fs, "oidc.scopes", "oidc-scopes", `Comma separated list of scopes to request, openid is always requested.`)
+	flags.StringSlice(fs, "oidc.allowedemails", "oidc-allowed-emails", `Comma separated list of email addresses allowed in.`)
+	flags.StringSlice(fs, "oidc.alloweddomains", "oidc-allowed-domains", `Comma separated list of email domains allowed in.
+Either allowlist only lets in users whose email the provider has verified.`)
+	flags.StringSlice(fs, "oidc.allowedgroups", "oidc-allowed-groups", `Comma separated list of groups allowed in.
+A user must be in at least one of them.`)
+	flags.String(fs, "oidc.groupsclaim", "oidc-groups-claim", `ID token claim holding the users groups.`)
+	flags.Duration(fs, "oidc.sessionttl", "oidc-session-ttl", `How long a login lasts before the user has to log in again.`)
+
+	cobra.AddTemplateFunc("oidcFlags", func() *pflag.FlagSet {
+		return fs
+	})
+}
+
+// Enabled returns whether users have to log in through an OpenID Connect provider.
+func (c *OIDC) Enabled() bool {
+	return c.Issuer != ""
+}
+
+func (c *OIDC) validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	if c.ClientID == "" {
+		return errors.New("oidc-client-id must be set when oidc-issuer is set")
+	}
+	if u, err := url.Parse(c.Issuer); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("invalid issuer url %q", c.Issuer)
+	}
+	if c.RedirectURL != "" {
+		if u, err := url.Parse(c.RedirectURL); err != nil || !u.IsAbs() {
+			return fmt.Errorf("invalid redirect url %q", c.RedirectURL)
+		}
+	}
+	if c.SessionTTL <= 0 {
+		return fmt.Errorf("invalid session ttl: %s", c.SessionTTL)
+	}
+
+	return nil
+}
+
+func (c *OIDC) hydrate() error {
+	if c.ClientSecret == "" {
+		return nil
+	}
+
+	secret, err := secrets.Resolve(c.ClientSecret)
+	if err != nil {
+		return fmt.Errorf("failed to read client secret: %w", err)
+	}
+	c.ClientSecret = secret
+
+	return nil
+}
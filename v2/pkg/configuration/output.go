@@ -3,6 +3,7 @@ package configuration
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/forestnode-io/oneshot/v2/pkg/flags"
 	"github.com/spf13/cobra"
@@ -14,6 +15,9 @@ type Output struct {
 	Format  string `mapstructure:"format" yaml:"format"`
 	QRCode  bool   `mapstructure:"qrCode" yaml:"qrCode"`
 	NoColor bool   `mapstructure:"noColor" yaml:"noColor"`
+	TUI     bool   `mapstructure:"tui" yaml:"tui"`
+
+	ProgressInterval time.Duration `mapstructure:"progressInterval" yaml:"progressInterval"`
 }
 
 func setOutputFlags(cmd *cobra.Command) {
@@ -21,8 +25,10 @@ func setOutputFlags(cmd *cobra.Command) {
 	defer cmd.PersistentFlags().AddFlagSet(fs)
 
 	flags.BoolP(fs, "output.quiet", "quiet", "q", "Disable all output except for received data")
-	flags.StringP(fs, "output.format", "output", "o", `Set output format. Valid formats are: json[=opts].
-Valid json opts are:
+	flags.StringP(fs, "output.format", "output", "o", `Set output format. Valid formats are: json[=opts] and ndjson[=opts].
+json prints a single report when oneshot exits.
+ndjson prints a versioned json object on its own line for each event as it happens.
+Valid opts are:
 	- compact
 		Disables tabbed, pretty printed json.
 	- include-file-contents
@@ -33,6 +39,11 @@ Valid json opts are:
 		This is on by default when sending or receiving to or from disk.`)
 	flags.Bool(fs, "output.qrCode", "qr-code", "Print a QR code of a URL that the server can be reached at")
 	flags.Bool(fs, "output.noColor", "no-color", "Disable color output")
+	flags.Bool(fs, "output.tui", "tui", `Show a full screen dashboard of connected clients instead of the regular output.
+Clients can be kicked, the timeout extended, and the session ended from the keyboard.
+Requires stderr to be a terminal, otherwise the regular output is used.`)
+	flags.Duration(fs, "output.progressInterval", "progress-interval", `How often to report transfer progress with ndjson output and in the dashboard (--tui).
+0 only reports progress once a transfer is done, the dashboard still refreshes at least every 250ms.`)
 
 	cobra.AddTemplateFunc("outputFlags", func() *pflag.FlagSet {
 		return fs
@@ -40,8 +51,10 @@ Valid json opts are:
 	cobra.AddTemplateFunc("outputClientFlags", func() *pflag.FlagSet {
 		fs := pflag.NewFlagSet("Output Flags", pflag.ExitOnError)
 		fs.BoolP("quiet", "q", false, "Disable all output except for received data")
-		fs.StringP("output", "o", "", `Set output format. Valid formats are: json[=opts].
-Valid json opts are:
+		fs.StringP("output", "o", "", `Set output format. Valid formats are: json[=opts] and ndjson[=opts].
+json prints a single report when oneshot exits.
+ndjson prints a versioned json object on its own line for each event as it happens.
+Valid opts are:
 	- compact
 		Disables tabbed, pretty printed json.
 	- include-file-contents
@@ -51,11 +64,17 @@ Valid json opts are:
 		Excludes the contents of files in the json output.
 		This is on by default when sending or receiving to or from disk.`)
 		fs.Bool("no-color", false, "Disable color output")
+		fs.Duration("progress-interval", time.Second, `How often to report transfer progress with ndjson output and in the dashboard (--tui).
+0 only reports progress once a transfer is done, the dashboard still refreshes at least every 250ms.`)
 		return fs
 	})
 }
 
 func (c *Output) validate() error {
+	if c.TUI && (c.Quiet || c.Format != "") {
+		return fmt.Errorf("--tui can not be used with --quiet or --output")
+	}
+
 	if c.Format == "" {
 		return nil
 	}
@@ -64,7 +83,7 @@ func (c *Output) validate() error {
 		return fmt.Errorf("invalid output format: %s", c.Format)
 	}
 	format := parts[0]
-	if format != "json" {
+	if format != "json" && format != "ndjson" {
 		return fmt.Errorf("invalid output format: %s", c.Format)
 	}
 
@@ -79,5 +98,9 @@ func (c *Output) validate() error {
 		}
 	}
 
+	if c.ProgressInterval < 0 {
+		return fmt.Errorf("invalid progress interval: %s", c.ProgressInterval)
+	}
+
 	return nil
 }
@@ -0,0 +1,322 @@
+package configuration
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/flags"
+	"github.com/forestnode-io/oneshot/v2/pkg/secrets"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// ProjectConfigName is the name of the per-directory configuration file.
+// The closest one found in the working directory or any of its parents
+// is layered on top of the user configuration file if it is trusted.
+const ProjectConfigName = ".oneshot.yaml"
+
+// trustedProjectConfigsKey lists the per-directory configuration files that are trusted.
+const trustedProjectConfigsKey = "trustedProjectConfigs"
+
+// profilesKey holds the named profiles of a configuration file.
+const profilesKey = "profiles"
+
+// Layer is a set of configuration values and where they were read from.
+type Layer struct {
+	// Source is the path of the file the values were read from,
+	// followed by the profile name if they came from a profile.
+	Source   string
+	settings map[string]any
+}
+
+var (
+	projectConfigPath string
+	// untrustedProjectConfigPath is the per-directory configuration file that was found but not loaded
+	untrustedProjectConfigPath string
+	profile                    string
+	// layers are ordered from lowest to highest precedence
+	layers []Layer
+)
+
+// ProjectConfigPath returns the path of the per-directory configuration file being used, if any.
+func ProjectConfigPath() string {
+	return projectConfigPath
+}
+
+// UntrustedProjectConfigPath returns the path of the per-directory configuration file
+// that was found but not loaded because it isn't trusted, if any.
+func UntrustedProjectConfigPath() string {
+	return untrustedProjectConfigPath
+}
+
+// Profile returns the name of the profile being used, if any.
+func Profile() string {
+	return profile
+}
+
+// Layers returns the configuration files and profiles that were loaded, from lowest to highest precedence.
+func Layers() []Layer {
+	return layers
+}
+
+func setProfileFlags(cmd *cobra.Command) {
+	fs := pflag.NewFlagSet("Configuration Flags", pflag.ExitOnError)
+	defer cmd.PersistentFlags().AddFlagSet(fs)
+
+	fs.String("profile", "", `Name of the configuration profile to use.
+Profiles are read from the profiles section of the configuration file and of the closest `+ProjectConfigName+` file.
+Defaults to the value of the ONESHOT_PROFILE environment variable.`)
+	fs.Bool("trust-project-config", false, `Load the closest `+ProjectConfigName+` file even if it isn't listed in trustedProjectConfigs.
+Per-directory configuration files can set hooks and listen addresses, so they are only loaded once trusted.
+Trust one for good with: oneshot config set trustedProjectConfigs <path>`)
+
+	cobra.AddTemplateFunc("configFlags", func() *pflag.FlagSet {
+		return fs
+	})
+}
+
+// Load layers the per-directory configuration file and the named profile
+// on top of the user configuration file, in this order:
+//   - the user configuration file
+//   - the profile from the user configuration file
+//   - the per-directory configuration file
+//   - the profile from the per-directory configuration file
+//
+// Environment variables and flags still take precedence over all of them.
+// An empty profile name falls back to $ONESHOT_PROFILE.
+// The per-directory configuration file is only loaded if trustProject is set
+// or it is listed in trustedProjectConfigs.
+func Load(profileName string, trustProject bool) error {
+	if profileName == "" {
+		profileName = os.Getenv("ONESHOT_PROFILE")
+	}
+
+	projectConfigPath, untrustedProjectConfigPath = findProjectConfig(), ""
+	if projectConfigPath != "" && !trustProject && !isTrusted(projectConfigPath) {
+		projectConfigPath, untrustedProjectConfigPath = "", projectConfigPath
+	}
+
+	var (
+		loaded       []Layer
+		foundProfile = profileName == ""
+	)
+	for _, path := range []string{configPath, projectConfigPath} {
+		if path == "" {
+			continue
+		}
+		settings, err := readConfigFile(path)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return fmt.Errorf("unable to read configuration file %s: %w", path, err)
+		}
+		// commands are only run from configuration the user wrote themselves
+		if path == projectConfigPath {
+			if key := commandReference(settings, ""); key != "" {
+				return fmt.Errorf("%s: %s: %s secret references are only allowed in the user configuration file, environment variables and flags",
+					path, key, secrets.ProviderCmd)
+			}
+		}
+		loaded = append(loaded, Layer{
+			Source:   path,
+			settings: settings,
+		})
+
+		if profileName == "" {
+			continue
+		}
+		ps, ok := profileSettings(settings, profileName)
+		if !ok {
+			continue
+		}
+		foundProfile = true
+		loaded = append(loaded, Layer{
+			Source:   fmt.Sprintf("%s (profile %s)", path, profileName),
+			settings: ps,
+		})
+	}
+	if !foundProfile {
+		return fmt.Errorf("no such profile: %s", profileName)
+	}
+
+	for _, l := range loaded {
+		// the user configuration file has already been read in
+		if l.Source == configPath {
+			continue
+		}
+		if err := viper.MergeConfigMap(l.settings); err != nil {
+			return fmt.Errorf("unable to merge %s: %w", l.Source, err)
+		}
+	}
+
+	profile = profileName
+	layers = loaded
+
+	return nil
+}
+
+// Source describes where the value of key comes from:
+// a flag, an environment variable, a configuration file or profile, or the default.
+func Source(key string) string {
+	key = strings.ToLower(key)
+	if flag, ok := flags.Changed(key); ok {
+		return "flag --" + flag.Name
+	}
+	env := "ONESHOT_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+	if _, ok := os.LookupEnv(env); ok {
+		return "env " + env
+	}
+	path := strings.Split(key, ".")
+	for i := len(layers) - 1; 0 <= i; i-- {
+		if lookup(layers[i].settings, path) {
+			return layers[i].Source
+		}
+	}
+	return "default"
+}
+
+// Profiles returns the names of the profiles defined in the loaded configuration files.
+func Profiles() []string {
+	var (
+		seen  = map[string]struct{}{}
+		names []string
+	)
+	for _, l := range layers {
+		ps, ok := l.settings[profilesKey].(map[string]any)
+		if !ok {
+			continue
+		}
+		for name := range ps {
+			if _, ok := seen[name]; !ok {
+				seen[name] = struct{}{}
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// findProjectConfig looks for the per-directory configuration file
+// in the working directory and each of its parents.
+func findProjectConfig() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, ProjectConfigName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// isTrusted reports whether the per-directory configuration file at path, or its directory,
+// is listed in trustedProjectConfigs.
+// This must be called before any per-directory configuration file is merged in so that one can't trust itself.
+func isTrusted(path string) bool {
+	path = canonicalPath(path)
+	for _, trusted := range viper.GetStringSlice(trustedProjectConfigsKey) {
+		// relative paths are relative to the user configuration file rather than wherever oneshot is run
+		if !filepath.IsAbs(trusted) && configPath != "" {
+			trusted = filepath.Join(filepath.Dir(configPath), trusted)
+		}
+		trusted = canonicalPath(trusted)
+		if trusted == path || trusted == filepath.Dir(path) {
+			return true
+		}
+	}
+	return false
+}
+
+func canonicalPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	return path
+}
+
+// readConfigFile reads the settings in the configuration file at path, with keys lower cased.
+func readConfigFile(path string) (map[string]any, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	return v.AllSettings(), nil
+}
+
+func profileSettings(settings map[string]any, name string) (map[string]any, bool) {
+	ps, ok := settings[profilesKey].(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	p, ok := ps[strings.ToLower(name)].(map[string]any)
+	return p, ok
+}
+
+// commandReference returns the key of the first value in settings that is a cmd secret reference, if any.
+func commandReference(settings map[string]any, prefix string) string {
+	isCmd := func(v any) bool {
+		s, ok := v.(string)
+		return ok && secrets.IsReference(s) && strings.HasPrefix(s, secrets.ProviderCmd+":")
+	}
+	for k, v := range settings {
+		if prefix != "" {
+			k = prefix + "." + k
+		}
+		switch v := v.(type) {
+		case map[string]any:
+			if key := commandReference(v, k); key != "" {
+				return key
+			}
+		case []any:
+			for _, e := range v {
+				if isCmd(e) {
+					return k
+				}
+			}
+		default:
+			if isCmd(v) {
+				return k
+			}
+		}
+	}
+	return ""
+}
+
+// lookup reports whether the nested settings hold a value at path.
+func lookup(settings map[string]any, path []string) bool {
+	for i, k := range path {
+		v, ok := settings[k]
+		if !ok {
+			return false
+		}
+		if i == len(path)-1 {
+			return true
+		}
+		if settings, ok = v.(map[string]any); !ok {
+			return false
+		}
+	}
+	return false
+}
@@ -3,15 +3,19 @@ package configuration
 import (
 	"fmt"
 
+	capture "github.com/forestnode-io/oneshot/v2/pkg/commands/capture/configuration"
 	discoveryserver "github.com/forestnode-io/oneshot/v2/pkg/commands/discovery-server/configuration"
 	exec "github.com/forestnode-io/oneshot/v2/pkg/commands/exec/configuration"
+	get "github.com/forestnode-io/oneshot/v2/pkg/commands/get/configuration"
 	browserclient "github.com/forestnode-io/oneshot/v2/pkg/commands/p2p/browser-client/configuration"
 	client "github.com/forestnode-io/oneshot/v2/pkg/commands/p2p/client/configuration"
 	clientreceive "github.com/forestnode-io/oneshot/v2/pkg/commands/p2p/client/receive/configuration"
 	clientsend "github.com/forestnode-io/oneshot/v2/pkg/commands/p2p/client/send/configuration"
 	p2p "github.com/forestnode-io/oneshot/v2/pkg/commands/p2p/configuration"
+	put "github.com/forestnode-io/oneshot/v2/pkg/commands/put/configuration"
 	receive "github.com/forestnode-io/oneshot/v2/pkg/commands/receive/configuration"
 	redirect "github.com/forestnode-io/oneshot/v2/pkg/commands/redirect/configuration"
+	respond "github.com/forestnode-io/oneshot/v2/pkg/commands/respond/configuration"
 	rproxy "github.com/forestnode-io/oneshot/v2/pkg/commands/rproxy/configuration"
 	send "github.com/forestnode-io/oneshot/v2/pkg/commands/send/configuration"
 	"github.com/spf13/cobra"
@@ -23,8 +27,12 @@ type Subcommands struct {
 	Exec            *exec.Configuration            `mapstructure:"exec" yaml:"exec"`
 	Redirect        *redirect.Configuration        `mapstructure:"redirect" yaml:"redirect"`
 	RProxy          *rproxy.Configuration          `mapstructure:"rproxy" yaml:"rproxy"`
+	Respond         *respond.Configuration         `mapstructure:"respond" yaml:"respond"`
+	Capture         *capture.Configuration         `mapstructure:"capture" yaml:"capture"`
 	P2P             *p2p.Configuration             `mapstructure:"p2p" yaml:"p2p"`
 	DiscoveryServer *discoveryserver.Configuration `mapstructure:"discoveryServer" yaml:"discoveryServer"`
+	Get             *get.Configuration             `mapstructure:"get" yaml:"get"`
+	Put             *put.Configuration             `mapstructure:"put" yaml:"put"`
 }
 
 func (c *Subcommands) init(cmd *cobra.Command) {
@@ -43,6 +51,12 @@ func (c *Subcommands) init(cmd *cobra.Command) {
 	if c.RProxy == nil {
 		c.RProxy = &rproxy.Configuration{}
 	}
+	if c.Respond == nil {
+		c.Respond = &respond.Configuration{}
+	}
+	if c.Capture == nil {
+		c.Capture = &capture.Configuration{}
+	}
 	if c.P2P == nil {
 		c.P2P = &p2p.Configuration{
 			BrowserClient: &browserclient.Configuration{},
@@ -55,6 +69,12 @@ func (c *Subcommands) init(cmd *cobra.Command) {
 	if c.DiscoveryServer == nil {
 		c.DiscoveryServer = &discoveryserver.Configuration{}
 	}
+	if c.Get == nil {
+		c.Get = &get.Configuration{}
+	}
+	if c.Put == nil {
+		c.Put = &put.Configuration{}
+	}
 }
 
 func (s *Subcommands) validate() error {
@@ -73,9 +93,21 @@ func (s *Subcommands) validate() error {
 	if err := s.RProxy.Validate(); err != nil {
 		return fmt.Errorf("error validating rproxy configuration: %w", err)
 	}
+	if err := s.Respond.Validate(); err != nil {
+		return fmt.Errorf("error validating respond configuration: %w", err)
+	}
+	if err := s.Capture.Validate(); err != nil {
+		return fmt.Errorf("error validating capture configuration: %w", err)
+	}
 	if err := s.DiscoveryServer.Validate(); err != nil {
 		return fmt.Errorf("error validating discovery server configuration: %w", err)
 	}
+	if err := s.Get.Validate(); err != nil {
+		return fmt.Errorf("error validating get configuration: %w", err)
+	}
+	if err := s.Put.Validate(); err != nil {
+		return fmt.Errorf("error validating put configuration: %w", err)
+	}
 
 	return nil
 }
@@ -96,9 +128,21 @@ func (s *Subcommands) hydrate() error {
 	if err := s.RProxy.Hydrate(); err != nil {
 		return fmt.Errorf("error hydrating rproxy configuration: %w", err)
 	}
+	if err := s.Respond.Hydrate(); err != nil {
+		return fmt.Errorf("error hydrating respond configuration: %w", err)
+	}
+	if err := s.Capture.Hydrate(); err != nil {
+		return fmt.Errorf("error hydrating capture configuration: %w", err)
+	}
 	if err := s.DiscoveryServer.Hydrate(); err != nil {
 		return fmt.Errorf("error hydrating discovery server configuration: %w", err)
 	}
+	if err := s.Get.Hydrate(); err != nil {
+		return fmt.Errorf("error hydrating get configuration: %w", err)
+	}
+	if err := s.Put.Hydrate(); err != nil {
+		return fmt.Errorf("error hydrating put configuration: %w", err)
+	}
 
 	return nil
 }
@@ -107,10 +151,15 @@ type Root struct {
 	Output       Output       `mapstructure:"output" yaml:"output"`
 	Server       Server       `mapstructure:"server" yaml:"server"`
 	BasicAuth    BasicAuth    `mapstructure:"basicAuth" yaml:"basicAuth"`
+	OIDC         OIDC         `mapstructure:"oidc" yaml:"oidc"`
 	CORS         CORS         `mapstructure:"cors" yaml:"cors"`
+	Hooks        Hooks        `mapstructure:"hooks" yaml:"hooks"`
 	NATTraversal NATTraversal `mapstructure:"natTraversal" yaml:"natTraversal"`
 	Subcommands  *Subcommands `mapstructure:"cmd" yaml:"cmd"`
 	Discovery    Discovery    `mapstructure:"discovery" yaml:"discovery"`
+	// TrustedProjectConfigs lists the per-directory configuration files, or the directories holding them, that may be loaded.
+	// Only the user configuration file and environment variables are consulted for it.
+	TrustedProjectConfigs []string `mapstructure:"trustedProjectConfigs" yaml:"trustedProjectConfigs"`
 }
 
 func EmptyRoot() *Root {
@@ -121,6 +170,8 @@ func EmptyRoot() *Root {
 			Exec:     &exec.Configuration{},
 			Redirect: &redirect.Configuration{},
 			RProxy:   &rproxy.Configuration{},
+			Respond:  &respond.Configuration{},
+			Capture:  &capture.Configuration{},
 			P2P: &p2p.Configuration{
 				BrowserClient: &browserclient.Configuration{},
 				Client: &client.Configuration{
@@ -129,6 +180,8 @@ func EmptyRoot() *Root {
 				},
 			},
 			DiscoveryServer: &discoveryserver.Configuration{},
+			Get:             &get.Configuration{},
+			Put:             &put.Configuration{},
 		},
 	}
 }
@@ -137,9 +190,12 @@ func (c *Root) Init(cmd *cobra.Command) {
 	setOutputFlags(cmd)
 	setServerFlags(cmd)
 	setBasicAuthFlags(cmd)
+	setOIDCFlags(cmd)
 	setCORSFlags(cmd)
+	setHooksFlags(cmd)
 	setNATTraversalFlags(cmd)
 	setDiscoveryFlags(cmd)
+	setProfileFlags(cmd)
 	c.Subcommands = &Subcommands{}
 	c.Subcommands.init(cmd)
 }
@@ -161,6 +217,10 @@ func (c *Root) Validate() error {
 		return fmt.Errorf("error validating basic auth configuration: %w", err)
 	}
 
+	if err := c.OIDC.validate(); err != nil {
+		return fmt.Errorf("error validating OIDC configuration: %w", err)
+	}
+
 	if err := c.CORS.validate(); err != nil {
 		return fmt.Errorf("error validating CORS configuration: %w", err)
 	}
@@ -169,6 +229,10 @@ func (c *Root) Validate() error {
 		return fmt.Errorf("error validating NAT traversal configuration: %w", err)
 	}
 
+	if err := c.Hooks.validate(); err != nil {
+		return fmt.Errorf("error validating hooks configuration: %w", err)
+	}
+
 	return nil
 }
 
@@ -177,6 +241,10 @@ func (c *Root) Hydrate() error {
 		return fmt.Errorf("error hydrating subcommands: %w", err)
 	}
 
+	if err := c.Server.hydrate(); err != nil {
+		return fmt.Errorf("error hydrating server configuration: %w", err)
+	}
+
 	if err := c.NATTraversal.hydrate(); err != nil {
 		return fmt.Errorf("error hydrating NAT traversal configuration: %w", err)
 	}
@@ -185,6 +253,10 @@ func (c *Root) Hydrate() error {
 		return fmt.Errorf("error hydrating basic auth configuration: %w", err)
 	}
 
+	if err := c.OIDC.hydrate(); err != nil {
+		return fmt.Errorf("error hydrating OIDC configuration: %w", err)
+	}
+
 	if err := c.Discovery.hydrate(); err != nil {
 		return fmt.Errorf("error hydrating discovery configuration: %w", err)
 	}
@@ -0,0 +1,174 @@
+package configuration
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/flagargs"
+	"github.com/forestnode-io/oneshot/v2/pkg/flags"
+)
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	headerType   = reflect.TypeOf(flagargs.HTTPHeader{})
+)
+
+// Key describes a single configuration key.
+type Key struct {
+	// Name is the dotted path of the key, lower cased the same as viper keys.
+	Name string
+	Type reflect.Type
+	// Default is the value used when the key is not set anywhere, nil if there is none.
+	Default any
+	// Env is the environment variable that sets the key.
+	Env string
+	// Flag is the name of the flag that sets the key, if there is one.
+	Flag string
+	// Secret is true for keys that hold a secret or a reference to one.
+	Secret bool
+}
+
+// Keys returns every configuration key, sorted by name.
+// Keys are read off of the mapstructure tags of Root.
+func Keys() []Key {
+	var keys []Key
+	collectKeys(reflect.TypeOf(Root{}), "", &keys)
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Name < keys[j].Name
+	})
+	return keys
+}
+
+// LookupKey returns the configuration key called name.
+func LookupKey(name string) (Key, bool) {
+	name = strings.ToLower(name)
+	for _, k := range Keys() {
+		if k.Name == name {
+			return k, true
+		}
+	}
+	return Key{}, false
+}
+
+func collectKeys(t reflect.Type, prefix string, keys *[]Key) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		name := strings.ToLower(tag)
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+
+		ft := field.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct {
+			collectKeys(ft, name, keys)
+			continue
+		}
+
+		*keys = append(*keys, Key{
+			Name:    name,
+			Type:    field.Type,
+			Default: defaults[name],
+			Env:     "ONESHOT_" + strings.ToUpper(strings.ReplaceAll(name, ".", "_")),
+			Flag:    flags.Name(name),
+			Secret:  field.Tag.Get("secret") == "true",
+		})
+	}
+}
+
+// TypeName is the type of the key as shown to users.
+func (k Key) TypeName() string {
+	switch k.Type {
+	case durationType:
+		return "duration"
+	case headerType:
+		return "header"
+	}
+	return k.Type.String()
+}
+
+// Parse converts args into a value for the key.
+// Slices take either one argument per element or a single comma separated argument,
+// headers take one name=value argument per value.
+func (k Key) Parse(args []string) (any, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("missing value for %s", k.Name)
+	}
+	if k.Type.Kind() != reflect.Slice && 1 < len(args) {
+		return nil, fmt.Errorf("%s takes a single %s value", k.Name, k.TypeName())
+	}
+
+	if k.Type == headerType {
+		for _, arg := range args {
+			if !strings.Contains(arg, "=") {
+				return nil, fmt.Errorf("invalid header for %s: %s, expected name=value", k.Name, arg)
+			}
+		}
+		return args, nil
+	}
+
+	if k.Type == durationType {
+		d, err := time.ParseDuration(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration for %s: %w", k.Name, err)
+		}
+		// durations are written out the same way they are given on the command line
+		return d.String(), nil
+	}
+
+	switch k.Type.Kind() {
+	case reflect.String:
+		return args[0], nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid bool for %s: %w", k.Name, err)
+		}
+		return b, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(args[0], 10, k.Type.Bits())
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer for %s: %w", k.Name, err)
+		}
+		return n, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(args[0], 10, k.Type.Bits())
+		if err != nil {
+			return nil, fmt.Errorf("invalid unsigned integer for %s: %w", k.Name, err)
+		}
+		return n, nil
+	case reflect.Slice:
+		if len(args) == 1 {
+			args = strings.Split(args[0], ",")
+		}
+		switch k.Type.Elem().Kind() {
+		case reflect.String:
+			return args, nil
+		case reflect.Int:
+			ints := make([]int, len(args))
+			for i, arg := range args {
+				n, err := strconv.Atoi(arg)
+				if err != nil {
+					return nil, fmt.Errorf("invalid integer for %s: %w", k.Name, err)
+				}
+				ints[i] = n
+			}
+			return ints, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported type for %s: %s", k.Name, k.Type)
+}
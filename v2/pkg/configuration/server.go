@@ -2,9 +2,12 @@ package configuration
 
 import (
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/forestnode-io/oneshot/v2/pkg/flags"
+	oneshotnet "github.com/forestnode-io/oneshot/v2/pkg/net"
+	oneshotfmt "github.com/forestnode-io/oneshot/v2/pkg/output/fmt"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 )
@@ -12,12 +15,17 @@ import (
 type Server struct {
 	Host        string        `mapstructure:"host" yaml:"host"`
 	Port        int           `mapstructure:"port" yaml:"port"`
+	Listen      []string      `mapstructure:"listen" yaml:"listen"`
 	Timeout     time.Duration `mapstructure:"timeout" yaml:"timeout"`
 	AllowBots   bool          `mapstructure:"allowBots" yaml:"allowBots"`
 	MaxReadSize string        `mapstructure:"maxReadSize" yaml:"maxReadSize"`
 	ExitOnFail  bool          `mapstructure:"exitOnFail" yaml:"exitOnFail"`
 	TLSCert     string        `mapstructure:"tlsCert" yaml:"tlsCert"`
 	TLSKey      string        `mapstructure:"tlsKey" yaml:"tlsKey"`
+
+	TLSClientCA              string   `mapstructure:"tlsClientCA" yaml:"tlsClientCA"`
+	TLSClientAllowedSubjects []string `mapstructure:"tlsClientAllowedSubjects" yaml:"tlsClientAllowedSubjects"`
+	TLSClientAllowedSANs     []string `mapstructure:"tlsClientAllowedSANs" yaml:"tlsClientAllowedSANs"`
 }
 
 func setServerFlags(cmd *cobra.Command) {
@@ -26,6 +34,9 @@ func setServerFlags(cmd *cobra.Command) {
 
 	flags.String(fs, "server.host", "host", "Host to listen on")
 	flags.IntP(fs, "server.port", "port", "p", "Port to listen on")
+	flags.StringArray(fs, "server.listen", "listen", `Address to listen on, replacing host and port. Can be specified multiple times.
+Format: tcp://<HOST>:<PORT>, tcp4://<HOST>:<PORT>, tcp6://[<HOST>]:<PORT> or unix://<SOCKET PATH>
+Examples: tcp://[::]:8080 (dual-stack), tcp://0.0.0.0:8080, unix:///run/oneshot.sock`)
 	flags.Duration(fs, "server.timeout", "timeout", `How long to wait for a connection to be established before timing out.
 A value of 0 will cause oneshot to wait indefinitely.`)
 	flags.Bool(fs, "server.allowbots", "allow-bots", "Allow bots access")
@@ -40,6 +51,13 @@ A value of 0 will cause oneshot to wait indefinitely.`)
 	flags.Bool(fs, "server.exitonfail", "exit-on-fail", "Exit after a failed transfer, without waiting for a new connection")
 	flags.String(fs, "server.tlscert", "tls-cert", "Path to TLS certificate")
 	flags.String(fs, "server.tlskey", "tls-key", "Path to TLS key")
+	flags.String(fs, "server.tlsclientca", "tls-client-ca", `Path to a PEM bundle of CA certificates used to verify client certificates.
+When set, clients must present a certificate signed by one of these CAs and clients connecting over WebRTC are turned away.
+Requires tls-cert and tls-key.`)
+	flags.StringSlice(fs, "server.tlsclientallowedsubjects", "tls-client-allowed-subjects", `Comma separated list of client certificate subjects allowed in.
+Each entry is matched against the subjects common name and its full distinguished name, e.g. CN=backup,O=Example.`)
+	flags.StringSlice(fs, "server.tlsclientallowedsans", "tls-client-allowed-sans", `Comma separated list of client certificate subject alternative names allowed in.
+DNS names, email addresses, IP addresses and URIs are all matched.`)
 
 	cobra.AddTemplateFunc("serverFlags", func() *pflag.FlagSet {
 		return fs
@@ -51,6 +69,12 @@ func (c *Server) validate() error {
 		return fmt.Errorf("invalid port: %d", c.Port)
 	}
 
+	for _, addr := range c.Listen {
+		if _, err := oneshotnet.ParseListenAddress(addr); err != nil {
+			return err
+		}
+	}
+
 	if c.TLSCert != "" && c.TLSKey == "" {
 		return fmt.Errorf("tls-key is required when tls-cert is set")
 	}
@@ -58,5 +82,53 @@ func (c *Server) validate() error {
 		return fmt.Errorf("tls-cert is required when tls-key is set")
 	}
 
+	if c.TLSClientCA != "" {
+		if c.TLSCert == "" {
+			return fmt.Errorf("tls-cert and tls-key are required when tls-client-ca is set")
+		}
+		info, err := os.Stat(c.TLSClientCA)
+		if err != nil {
+			return fmt.Errorf("invalid tls-client-ca: %w", err)
+		}
+		if info.IsDir() {
+			return fmt.Errorf("invalid tls-client-ca: %s is a directory", c.TLSClientCA)
+		}
+	} else if 0 < len(c.TLSClientAllowedSubjects) || 0 < len(c.TLSClientAllowedSANs) {
+		return fmt.Errorf("tls-client-ca is required when allowing client certificate subjects or SANs")
+	}
+
+	return nil
+}
+
+// ListenAddresses returns the addresses to listen on, which are either those given with --listen
+// or the host and port, which listens on every interface and both IPv4 and IPv6 if no host is given.
+func (c *Server) ListenAddresses() []oneshotnet.ListenAddress {
+	if len(c.Listen) == 0 {
+		return []oneshotnet.ListenAddress{{
+			Network: "tcp",
+			Address: oneshotfmt.Address(c.Host, c.Port),
+		}}
+	}
+
+	addrs := make([]oneshotnet.ListenAddress, 0, len(c.Listen))
+	for _, s := range c.Listen {
+		addr, err := oneshotnet.ParseListenAddress(s)
+		if err != nil {
+			// caught during validation
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+func (c *Server) hydrate() error {
+	// port mapping and discovery use the port of the first tcp listener
+	for _, addr := range c.ListenAddresses() {
+		if port := addr.Port(); 0 < port {
+			c.Port = port
+			break
+		}
+	}
 	return nil
 }
@@ -0,0 +1,230 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// Policy decides what happens to an event raised while a subscriber's buffer is full.
+type Policy int
+
+const (
+	// Block makes Raise wait until the subscriber has room for the event.
+	// Subscribers that need to see every event, like the json report, should block.
+	Block Policy = iota
+	// DropNewest drops the event being raised.
+	DropNewest
+	// DropOldest drops the oldest event in the subscriber's buffer to make room.
+	DropOldest
+)
+
+type SubscribeOptions struct {
+	// Buffer is how many events may be waiting for the subscriber.
+	// Subscribers with a drop policy always get a buffer of at least 1.
+	Buffer int
+	Policy Policy
+	// Filter decides which events are delivered to the subscriber, nil delivers all of them.
+	// Filters are called while the event is being raised and must not block.
+	Filter func(Event) bool
+}
+
+// OfType is a filter that only lets through events of type T.
+func OfType[T Event](e Event) bool {
+	_, ok := e.(T)
+	return ok
+}
+
+// Subscription receives raised events on C until events stop
+// or it is unsubscribed, at which point C is closed.
+type Subscription struct {
+	C <-chan Event
+
+	c       chan Event
+	opts    SubscribeOptions
+	bus     *bus
+	dropped atomic.Int64
+	// gone is closed when unsubscribing to release raisers blocked on the subscription
+	gone     chan struct{}
+	goneOnce sync.Once
+}
+
+// Subscribe returns a subscription to the events raised from now on.
+// The first subscriber an event is delivered to receives the event itself, the rest receive copies
+// so that they can read them while the first subscriber modifies its own.
+func Subscribe(ctx context.Context, opts SubscribeOptions) *Subscription {
+	return bndl(ctx).bus.subscribe(opts)
+}
+
+// Dropped returns how many events the subscription has missed because of its policy.
+func (s *Subscription) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Unsubscribe stops events from being delivered to s and closes s.C.
+func (s *Subscription) Unsubscribe() {
+	s.goneOnce.Do(func() { close(s.gone) })
+	s.bus.unsubscribe(s)
+}
+
+func (s *Subscription) deliver(e Event, closing <-chan struct{}) {
+	switch s.opts.Policy {
+	case DropNewest:
+		select {
+		case s.c <- e:
+		default:
+			s.dropped.Add(1)
+		}
+	case DropOldest:
+		for {
+			select {
+			case s.c <- e:
+				return
+			default:
+			}
+			select {
+			case <-s.c:
+				s.dropped.Add(1)
+			default:
+			}
+		}
+	default:
+		select {
+		case s.c <- e:
+		case <-closing:
+		case <-s.gone:
+		}
+	}
+}
+
+type bus struct {
+	mu     sync.RWMutex
+	subs   []*Subscription
+	closed bool
+	// closing is closed when the bus starts closing to release raisers blocked on a subscriber
+	closing chan struct{}
+}
+
+func newBus() *bus {
+	return &bus{
+		closing: make(chan struct{}),
+	}
+}
+
+func (b *bus) subscribe(opts SubscribeOptions) *Subscription {
+	if opts.Policy != Block && opts.Buffer < 1 {
+		opts.Buffer = 1
+	}
+	c := make(chan Event, opts.Buffer)
+	s := Subscription{
+		C:    c,
+		c:    c,
+		opts: opts,
+		bus:  b,
+		gone: make(chan struct{}),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(c)
+	} else {
+		b.subs = append(b.subs, &s)
+	}
+
+	return &s
+}
+
+func (b *bus) unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, sub := range b.subs {
+		if sub == s {
+			b.subs = append(b.subs[:i:i], b.subs[i+1:]...)
+			close(s.c)
+			return
+		}
+	}
+}
+
+func (b *bus) raise(e Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closed {
+		return
+	}
+
+	// the body can only be read once, share what was read with every subscriber
+	if f, ok := e.(HTTPRequestBody); ok {
+		e = f.once()
+	}
+
+	var (
+		subs   = make([]*Subscription, 0, len(b.subs))
+		copies = make([]Event, 0, len(b.subs))
+	)
+	for _, s := range b.subs {
+		if f := s.opts.Filter; f != nil && !f(e) {
+			continue
+		}
+		// copy the event before the first subscriber gets a chance to modify it
+		c := e
+		if 0 < len(subs) {
+			c = copyEvent(e)
+		}
+		subs = append(subs, s)
+		copies = append(copies, c)
+	}
+	for i, s := range subs {
+		s.deliver(copies[i], b.closing)
+	}
+}
+
+func (b *bus) close() {
+	close(b.closing)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for _, s := range b.subs {
+		close(s.c)
+	}
+	b.subs = nil
+}
+
+// copyEvent copies the fields of e that subscribers modify.
+// Maps and bodies are shared with the original, request bodies are only read once, see raise.
+func copyEvent(e Event) Event {
+	switch e := e.(type) {
+	case *File:
+		return e.copy()
+	case *HTTPRequest:
+		c := *e
+		return &c
+	case *HTTPResponse:
+		c := *e
+		return &c
+	case *AuthFailure:
+		c := *e
+		return &c
+	case *Progress:
+		c := *e
+		return &c
+	case *Listening:
+		c := *e
+		return &c
+	case *Shutdown:
+		c := *e
+		return &c
+	case *WebSocketMessage:
+		c := *e
+		return &c
+	case *OAuthCallback:
+		c := *e
+		return &c
+	}
+	return e
+}
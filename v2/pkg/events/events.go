@@ -2,6 +2,7 @@ package events
 
 import (
 	"context"
+	"sync"
 )
 
 // Event represents events in oneshot that should be communicated to the user.
@@ -13,13 +14,8 @@ type _event interface {
 	isEvent()
 }
 
-type SetEventChanFunc func(context.Context, chan Event)
-
-func RegisterEventListener(ctx context.Context, f SetEventChanFunc) {
-	b := bndl(ctx)
-	f(ctx, b.eventsChan)
-}
-
+// ClientDisconnected ends the current client session without ending the run.
+// Err is why the client failed, sessions that were answered but didn't finish the transfer leave it nil.
 type ClientDisconnected struct {
 	Err error
 }
@@ -27,34 +23,61 @@ type ClientDisconnected struct {
 func (ClientDisconnected) isEvent() {}
 
 func (c ClientDisconnected) Error() string {
+	if c.Err == nil {
+		return "client disconnected"
+	}
 	return c.Err.Error()
 }
 
+// AuthFailure is raised when a client fails to authenticate.
+type AuthFailure struct {
+	Method     string `json:",omitempty"`
+	Path       string `json:",omitempty"`
+	RemoteAddr string `json:",omitempty"`
+	// User is set when an authenticated user lacked permission for the request.
+	User string `json:",omitempty"`
+}
+
+func (*AuthFailure) isEvent() {}
+
 type HTTPRequestBody func() ([]byte, error)
 
 func (HTTPRequestBody) isEvent() {}
 
+// once returns a HTTPRequestBody that reads the body the first time it is called
+// and hands every later call the same bytes, so that each subscriber sees the whole body.
+func (f HTTPRequestBody) once() HTTPRequestBody {
+	var (
+		o    sync.Once
+		body []byte
+		err  error
+	)
+	return func() ([]byte, error) {
+		o.Do(func() {
+			body, err = f()
+		})
+		return body, err
+	}
+}
+
 func WithEvents(ctx context.Context) context.Context {
 	ctx, cancel := context.WithCancel(ctx)
 	b := bundle{
-		eventsChan: make(chan Event, 1),
-		cancel:     cancel,
-		exitCode:   -1,
+		bus:      newBus(),
+		cancel:   cancel,
+		exitCode: -1,
 	}
 
+	ctx = context.WithValue(ctx, bundleKey{}, &b)
+
 	go func() {
 		<-ctx.Done()
-		close(b.eventsChan)
+		b.bus.close()
 	}()
-	ctx = context.WithValue(ctx, bundleKey{}, &b)
 
 	return ctx
 }
 
-func eventChan(ctx context.Context) chan Event {
-	return bndl(ctx).eventsChan
-}
-
 func Success(ctx context.Context) {
 	b := bndl(ctx)
 	b.success = true
@@ -64,8 +87,10 @@ func Succeeded(ctx context.Context) bool {
 	return bndl(ctx).success
 }
 
+// Raise delivers e to every subscriber that wants it.
+// Events raised after events have stopped are dropped.
 func Raise(ctx context.Context, e Event) {
-	eventChan(ctx) <- e
+	bndl(ctx).bus.raise(e)
 }
 
 func Stop(ctx context.Context) {
@@ -75,11 +100,11 @@ func Stop(ctx context.Context) {
 
 type bundleKey struct{}
 type bundle struct {
-	eventsChan chan Event
-	err        error
-	success    bool
-	cancel     func()
-	exitCode   int
+	bus      *bus
+	err      error
+	success  bool
+	cancel   func()
+	exitCode int
 }
 
 func bndl(ctx context.Context) *bundle {
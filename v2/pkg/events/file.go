@@ -28,6 +28,12 @@ type File struct {
 	TransferRate int64 `json:",omitempty"`
 
 	Content any `json:",omitempty"`
+	// ContentTruncated is set when Content only holds the start of what was transferred.
+	ContentTruncated bool `json:",omitempty"`
+
+	// Extracted holds the files written to disk when the file
+	// was an archive that oneshot extracted.
+	Extracted []*File `json:",omitempty"`
 }
 
 // ComputeTransferFields handles calculating field values that could not be
@@ -38,7 +44,24 @@ func (f *File) ComputeTransferFields() {
 	}
 
 	f.TransferDuration = f.TransferEndTime.Sub(f.TransferStartTime)
-	f.TransferRate = 1000 * 1000 * 1000 * f.TransferSize / int64(f.TransferDuration)
+	if 0 < f.TransferDuration {
+		f.TransferRate = 1000 * 1000 * 1000 * f.TransferSize / int64(f.TransferDuration)
+	}
+
+	for _, ef := range f.Extracted {
+		ef.ComputeTransferFields()
+	}
+}
+
+func (f *File) copy() *File {
+	c := *f
+	if f.Extracted != nil {
+		c.Extracted = make([]*File, len(f.Extracted))
+		for i, ef := range f.Extracted {
+			c.Extracted[i] = ef.copy()
+		}
+	}
+	return &c
 }
 
 func (*File) isEvent() {}
@@ -2,8 +2,14 @@ package events
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"io"
 	"net/http"
+	"time"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/secrets"
 )
 
 type HTTPRequest struct {
@@ -16,12 +22,29 @@ type HTTPRequest struct {
 	Host       string              `json:",omitempty"`
 	Trailer    map[string][]string `json:",omitempty"`
 	RemoteAddr string              `json:",omitempty"`
+	// User is the username the request was authenticated as.
+	User string `json:",omitempty"`
+	// ClientCertificate is the verified certificate the client presented, if any.
+	ClientCertificate *ClientCertificate `json:",omitempty"`
 
 	Body any `json:",omitempty"`
+	// Form holds the fields of a url encoded or multipart form body.
+	Form map[string][]string `json:",omitempty"`
+	// Parts holds the parts of a multipart body.
+	Parts []*HTTPRequestPart `json:",omitempty"`
 
 	body func() ([]byte, error) `json:"-"`
 }
 
+// HTTPRequestPart is a part of a multipart request body.
+type HTTPRequestPart struct {
+	Name     string              `json:",omitempty"`
+	FileName string              `json:",omitempty"`
+	Header   map[string][]string `json:",omitempty"`
+	Size     int64
+	Content  any `json:",omitempty"`
+}
+
 // ReadBody reads in the http requests body by calling body() if its not nil.
 // the body func just reads in a buffered copy of the body; it will have already
 // been read from the client point of view.
@@ -45,6 +68,8 @@ func (hr *HTTPRequest) ReadBody() error {
 	return nil
 }
 
+// NewHTTPRequest copies r into an event.
+// Header values holding any of oneshots secrets are redacted so that they never make it into reports.
 func NewHTTPRequest(r *http.Request) *HTTPRequest {
 	return &HTTPRequest{
 		Method:     r.Method,
@@ -52,13 +77,71 @@ func NewHTTPRequest(r *http.Request) *HTTPRequest {
 		Path:       r.URL.Path,
 		Query:      r.URL.Query(),
 		Protocol:   r.Proto,
-		Header:     r.Header.Clone(),
+		Header:     secrets.RedactHeader(r.Header.Clone()),
 		Host:       r.Host,
 		Trailer:    r.Trailer.Clone(),
 		RemoteAddr: r.RemoteAddr,
+		User:       UserFromContext(r.Context()),
+
+		ClientCertificate: NewClientCertificate(r.TLS),
 	}
 }
 
+// ClientCertificate identifies a client by the TLS certificate it presented.
+type ClientCertificate struct {
+	Subject      string    `json:",omitempty"`
+	Issuer       string    `json:",omitempty"`
+	SerialNumber string    `json:",omitempty"`
+	SANs         []string  `json:",omitempty"`
+	NotBefore    time.Time `json:",omitempty"`
+	NotAfter     time.Time `json:",omitempty"`
+}
+
+// NewClientCertificate returns the identity of the verified client certificate of a connection.
+// It returns nil if the client did not present a certificate or it was not verified.
+func NewClientCertificate(cs *tls.ConnectionState) *ClientCertificate {
+	if cs == nil || len(cs.VerifiedChains) == 0 || len(cs.VerifiedChains[0]) == 0 {
+		return nil
+	}
+
+	cert := cs.VerifiedChains[0][0]
+	return &ClientCertificate{
+		Subject:      cert.Subject.String(),
+		Issuer:       cert.Issuer.String(),
+		SerialNumber: cert.SerialNumber.String(),
+		SANs:         CertificateSANs(cert),
+		NotBefore:    cert.NotBefore,
+		NotAfter:     cert.NotAfter,
+	}
+}
+
+// CertificateSANs returns the DNS names, email addresses, IP addresses and URIs of cert.
+func CertificateSANs(cert *x509.Certificate) []string {
+	var sans []string
+	sans = append(sans, cert.DNSNames...)
+	sans = append(sans, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+	return sans
+}
+
+type userKey struct{}
+
+// WithUser records in ctx the username a request was authenticated as.
+func WithUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFromContext returns the username recorded by WithUser.
+func UserFromContext(ctx context.Context) string {
+	user, _ := ctx.Value(userKey{}).(string)
+	return user
+}
+
 // newHTTPRequest_WithBody replaces the requests body with a tee reader that copies the data into a byte buffer.
 // This allows for the body to be written out later in a report should we need to.
 func NewHTTPRequest_WithBody(r *http.Request) *HTTPRequest {
@@ -81,6 +164,8 @@ type HTTPResponse struct {
 	StatusCode int         `json:",omitempty"`
 	Header     http.Header `json:",omitempty"`
 	Body       any         `json:",omitempty"`
+	// BodyTruncated is set when Body only holds the start of the response body.
+	BodyTruncated bool `json:",omitempty"`
 }
 
 func (hr *HTTPResponse) ReadBody() error {
@@ -0,0 +1,44 @@
+package events
+
+// Listening is raised once oneshot is ready for clients.
+type Listening struct {
+	// Address is where clients can reach oneshot.
+	Address string `json:",omitempty"`
+	// Addresses holds every address clients can reach oneshot at when it listens on more than one, Address first.
+	Addresses []string `json:",omitempty"`
+}
+
+func (*Listening) isEvent() {}
+
+// Progress is raised periodically while a file is being transferred, and once more when it is done.
+type Progress struct {
+	// Host is the other end of the transfer.
+	Host string `json:",omitempty"`
+	// Bytes is the number of bytes transferred so far.
+	Bytes int64
+	// Total is the number of bytes that will be transferred, if known.
+	Total int64 `json:",omitempty"`
+	// Rate is given in bytes / second.
+	Rate int64
+	Done bool `json:",omitempty"`
+}
+
+func (*Progress) isEvent() {}
+
+// reasons oneshot shuts down
+const (
+	ShutdownSuccess     = "success"
+	ShutdownTimeout     = "timeout"
+	ShutdownInterrupted = "interrupted"
+	ShutdownError       = "error"
+	ShutdownStopped     = "stopped"
+)
+
+// Shutdown is the last event raised before events stop.
+type Shutdown struct {
+	// Reason is why oneshot is shutting down.
+	Reason string
+	Error  string `json:",omitempty"`
+}
+
+func (*Shutdown) isEvent() {}
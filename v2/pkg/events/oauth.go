@@ -0,0 +1,12 @@
+package events
+
+// OAuthCallback is raised when an OAuth authorization server redirects the browser back with the outcome of a login.
+type OAuthCallback struct {
+	Code  string `json:",omitempty"`
+	State string `json:",omitempty"`
+	// Error and ErrorDescription are set if the authorization was refused.
+	Error            string `json:",omitempty"`
+	ErrorDescription string `json:",omitempty"`
+}
+
+func (*OAuthCallback) isEvent() {}
@@ -0,0 +1,31 @@
+package events
+
+import "time"
+
+// which end of a WebSocket connection sent a message
+const (
+	WebSocketFromClient   = "client"
+	WebSocketFromUpstream = "upstream"
+)
+
+// WebSocket message types
+const (
+	WebSocketText   = "text"
+	WebSocketBinary = "binary"
+	WebSocketClose  = "close"
+	WebSocketPing   = "ping"
+	WebSocketPong   = "pong"
+)
+
+// WebSocketMessage is raised for each message passed through a proxied WebSocket connection.
+type WebSocketMessage struct {
+	// From is either WebSocketFromClient or WebSocketFromUpstream.
+	From string
+	// Type is one of the WebSocket message types.
+	Type string
+	// Data is a string for text messages and a byte slice otherwise.
+	Data any       `json:",omitempty"`
+	Time time.Time `json:",omitempty"`
+}
+
+func (*WebSocketMessage) isEvent() {}
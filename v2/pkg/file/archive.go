@@ -0,0 +1,307 @@
+package file
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// ArchiveFormats are the formats directories can be archived with.
+var ArchiveFormats = []string{"tar", "tar.gz", "tar.zst", "tar.xz", "zip"}
+
+// DefaultCompressionLevel lets each archive format use its own default compression level.
+const DefaultCompressionLevel = -1
+
+// deterministicModTime is used for every entry of a deterministic archive.
+// zip can not represent times before 1980.
+var deterministicModTime = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+var (
+	ErrArchiveSizeUnknown = errors.New("archive size is not known in advance")
+	ErrArchiveSizeChanged = errors.New("archive size changed while it was being sent")
+)
+
+// ArchiveOptions controls how files and directories are archived before being sent.
+type ArchiveOptions struct {
+	// Format is one of ArchiveFormats.
+	Format string
+	// CompressionLevel goes from 0 (no compression) to 9 (best compression).
+	// A zip archive with a compression level of 0 only stores its files.
+	CompressionLevel int
+	// Exclude holds glob patterns of files to leave out of the archive.
+	// Patterns without a slash are matched against file names,
+	// otherwise they are matched against the path inside of the archive.
+	Exclude []string
+	// GitIgnore leaves out files ignored by .gitignore files found in archived directories.
+	GitIgnore bool
+	// Deterministic archives have fixed modification times and no owner information
+	// so that archiving the same files always produces the same bytes.
+	Deterministic bool
+	// ComputeSize archives compressed formats once before they are sent
+	// so that their size is known up front.
+	ComputeSize bool
+
+	// sizing replaces the contents of files with zeros of the same length,
+	// which is enough to learn the size of an uncompressed archive.
+	sizing bool
+}
+
+// ValidateArchiveFormat returns an error if format is not one of ArchiveFormats.
+func ValidateArchiveFormat(format string) error {
+	for _, f := range ArchiveFormats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid archive format %q, must be one of %s", format, strings.Join(ArchiveFormats, ", "))
+}
+
+// ValidateCompressionLevel returns an error if level is neither DefaultCompressionLevel nor between 0 and 9.
+func ValidateCompressionLevel(level int) error {
+	if level < DefaultCompressionLevel || 9 < level {
+		return fmt.Errorf("invalid compression level %d, must be between 0 and 9", level)
+	}
+	return nil
+}
+
+func (o *ArchiveOptions) archive(paths []string, w io.Writer) error {
+	switch o.Format {
+	case "zip":
+		return zip(o, paths, w)
+	case "tar", "tar.gz", "tar.zst", "tar.xz":
+		return tarball(o, paths, w)
+	default:
+		// keep the historical default for unset formats
+		opts := *o
+		opts.Format = "tar.gz"
+		return tarball(&opts, paths, w)
+	}
+}
+
+// size returns the exact size in bytes of the archive of paths.
+// Uncompressed tarballs and stored zip archives are sized from file stats alone,
+// compressed formats are only sized if ComputeSize is set.
+func (o *ArchiveOptions) size(paths []string) (int64, error) {
+	opts := *o
+	switch {
+	case o.Format == "tar", o.Format == "zip" && o.CompressionLevel == 0:
+		opts.sizing = true
+	case !o.ComputeSize:
+		return 0, ErrArchiveSizeUnknown
+	}
+
+	var cw countingWriter
+	if err := opts.archive(paths, &cw); err != nil {
+		return 0, err
+	}
+	return cw.n, nil
+}
+
+// open returns the content of a regular file that is being archived.
+func (o *ArchiveOptions) open(entry *archiveEntry) (io.ReadCloser, error) {
+	if o.sizing {
+		return io.NopCloser(io.LimitReader(zeroReader{}, entry.info.Size())), nil
+	}
+	return os.Open(entry.path)
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// sizeCheckReader fails when the archive it reads from does not have the size
+// that was announced to the client.
+type sizeCheckReader struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (r *sizeCheckReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.remaining -= int64(n)
+	switch {
+	case r.remaining < 0:
+		// never hand out more than was announced
+		return n + int(r.remaining), fmt.Errorf("%w: archive is larger than expected", ErrArchiveSizeChanged)
+	case errors.Is(err, io.EOF) && 0 < r.remaining:
+		return n, fmt.Errorf("%w: archive is %d bytes smaller than expected", ErrArchiveSizeChanged, r.remaining)
+	}
+	return n, err
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// compressor wraps w with the compression used by the tarball format.
+func (o *ArchiveOptions) compressor(w io.Writer) (io.WriteCloser, error) {
+	level := o.CompressionLevel
+	switch o.Format {
+	case "tar.gz":
+		if level == DefaultCompressionLevel {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+	case "tar.zst":
+		var el zstd.EncoderLevel
+		switch {
+		case level == DefaultCompressionLevel:
+			el = zstd.SpeedDefault
+		case level <= 3:
+			el = zstd.SpeedFastest
+		case level <= 6:
+			el = zstd.SpeedDefault
+		case level <= 8:
+			el = zstd.SpeedBetterCompression
+		default:
+			el = zstd.SpeedBestCompression
+		}
+		// a single encoder goroutine keeps the output reproducible
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(el), zstd.WithEncoderConcurrency(1))
+	case "tar.xz":
+		// dictionary sizes of the xz utility presets
+		dictCaps := []int{256 << 10, 1 << 20, 2 << 20, 4 << 20, 4 << 20, 8 << 20, 8 << 20, 16 << 20, 32 << 20, 64 << 20}
+		config := xz.WriterConfig{}
+		if level != DefaultCompressionLevel {
+			config.DictCap = dictCaps[level]
+		}
+		return config.NewWriter(w)
+	default:
+		return nopWriteCloser{w}, nil
+	}
+}
+
+type archiveEntry struct {
+	// path is where the entry is on disk
+	path string
+	// name is the slash separated name of the entry inside of the archive
+	name string
+	info os.FileInfo
+	// link is the target of a symbolic link
+	link string
+}
+
+// walk calls f for each file and symbolic link that should be archived,
+// in a stable order. Symbolic links are not followed.
+func (o *ArchiveOptions) walk(paths []string, f func(*archiveEntry) error) error {
+	exclude := ignoreRules{}
+	for _, pattern := range o.Exclude {
+		exclude.add("", pattern)
+	}
+
+	visit := func(fp, name string, info os.FileInfo) error {
+		if info.Mode()&os.ModeSocket != 0 {
+			// sockets can not be archived
+			return nil
+		}
+
+		entry := archiveEntry{
+			path: fp,
+			name: name,
+			info: info,
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			var err error
+			if entry.link, err = os.Readlink(fp); err != nil {
+				return err
+			}
+		}
+		return f(&entry)
+	}
+
+	for _, p := range paths {
+		// paths given by the user are followed if they are symbolic links
+		info, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return err
+		}
+		base := filepath.Base(abs)
+
+		if !info.IsDir() {
+			if exclude.match(base, false) {
+				continue
+			}
+			if err := visit(p, base, info); err != nil {
+				return err
+			}
+			continue
+		}
+
+		root, err := filepath.EvalSymlinks(p)
+		if err != nil {
+			return err
+		}
+
+		ignore := ignoreRules{}
+		err = filepath.Walk(root, func(fp string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			rel, err := filepath.Rel(root, fp)
+			if err != nil {
+				return err
+			}
+			name := path.Join(base, filepath.ToSlash(rel))
+			isDir := info.IsDir()
+
+			skip := exclude.match(name, isDir)
+			if o.GitIgnore {
+				// git never archives its own directory
+				skip = skip || ignore.match(name, isDir) || (isDir && info.Name() == ".git")
+			}
+			if skip {
+				if isDir {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if isDir {
+				if o.GitIgnore {
+					if err := ignore.load(filepath.Join(fp, ".gitignore"), name); err != nil {
+						return err
+					}
+				}
+				// archives only hold files, directories are implied by their paths
+				return nil
+			}
+
+			return visit(fp, name, info)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,339 @@
+package file
+
+import (
+	"archive/tar"
+	z "archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/events"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+var (
+	ErrIllegalArchivePath   = errors.New("illegal path in archive")
+	ErrExtractLimitExceeded = errors.New("archive extraction limit exceeded")
+	ErrUnknownArchiveFormat = errors.New("unable to determine archive format")
+)
+
+// ArchiveFormat returns the archive format implied by the name of a file,
+// or an empty string if the name does not look like an archive oneshot can extract.
+func ArchiveFormat(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return "tar.gz"
+	case strings.HasSuffix(name, ".tar.zst"), strings.HasSuffix(name, ".tzst"):
+		return "tar.zst"
+	case strings.HasSuffix(name, ".tar.xz"), strings.HasSuffix(name, ".txz"):
+		return "tar.xz"
+	case strings.HasSuffix(name, ".tar"):
+		return "tar"
+	case strings.HasSuffix(name, ".zip"):
+		return "zip"
+	}
+	return ""
+}
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	xzMagic   = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	zipMagic  = []byte("PK\x03\x04")
+	tarMagic  = []byte("ustar")
+)
+
+// sniffArchiveFormat peeks at the start of the stream to find its archive format.
+// Compressed streams are assumed to contain a tarball.
+func sniffArchiveFormat(br *bufio.Reader) string {
+	head, _ := br.Peek(512)
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		return "tar.gz"
+	case bytes.HasPrefix(head, zstdMagic):
+		return "tar.zst"
+	case bytes.HasPrefix(head, xzMagic):
+		return "tar.xz"
+	case bytes.HasPrefix(head, zipMagic):
+		return "zip"
+	case 262 <= len(head) && bytes.Equal(head[257:262], tarMagic):
+		return "tar"
+	}
+	return ""
+}
+
+// ExtractConfig configures where and how much of an archive is extracted.
+type ExtractConfig struct {
+	// Dir is the directory the archive is extracted into.
+	Dir string
+	// MaxSize is the maximum number of bytes that will be written to disk.
+	// Zero means no limit.
+	MaxSize int64
+	// MaxEntries is the maximum number of files and directories that will be extracted.
+	// Zero means no limit.
+	MaxEntries int
+}
+
+type extraction struct {
+	ExtractConfig
+	ctx    context.Context
+	report func(*events.File)
+
+	size    int64
+	entries int
+}
+
+// Extract extracts the archive read from r into the directory given by config.
+// If format is empty, it is determined from the first bytes of r.
+// report is called with a completed events.File for each file written to disk.
+// Symbolic links, hard links and special files are never extracted.
+func Extract(ctx context.Context, config ExtractConfig, format string, r io.Reader, report func(*events.File)) error {
+	if err := os.MkdirAll(config.Dir, 0755); err != nil {
+		return err
+	}
+	if err := isDirWritable(config.Dir); err != nil {
+		return err
+	}
+
+	if format == "" {
+		br := bufio.NewReader(r)
+		if format = sniffArchiveFormat(br); format == "" {
+			return ErrUnknownArchiveFormat
+		}
+		r = br
+	}
+
+	e := extraction{
+		ExtractConfig: config,
+		ctx:           ctx,
+		report:        report,
+	}
+	e.Dir = filepath.Clean(e.Dir)
+
+	switch format {
+	case "tar":
+		return e.untar(r)
+	case "tar.gz":
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		return e.untar(gr)
+	case "tar.zst":
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		return e.untar(zr)
+	case "tar.xz":
+		xr, err := xz.NewReader(r)
+		if err != nil {
+			return err
+		}
+		return e.untar(xr)
+	case "zip":
+		return e.unzip(r)
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownArchiveFormat, format)
+	}
+}
+
+func (e *extraction) untar(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		if err := e.ctx.Err(); err != nil {
+			return err
+		}
+
+		header, err := tr.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := e.mkdir(header.Name); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := e.extractFile(header.Name, header.FileInfo().Mode(), header.Size, tr); err != nil {
+				return err
+			}
+		default:
+			// links and special files are not extracted
+		}
+	}
+}
+
+func (e *extraction) unzip(r io.Reader) error {
+	// zip archives keep their index at the end of the file so the archive
+	// has to be spooled to disk before it can be read.
+	spool, err := os.CreateTemp("", "oneshot-*.zip")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}()
+
+	if 0 < e.MaxSize {
+		// the archive itself counts against the limit since it is written to disk too
+		r = io.LimitReader(r, e.MaxSize+1)
+	}
+	size, err := io.Copy(spool, r)
+	if err != nil {
+		return err
+	}
+	if 0 < e.MaxSize && e.MaxSize < size {
+		return fmt.Errorf("%w: archive is larger than %d bytes", ErrExtractLimitExceeded, e.MaxSize)
+	}
+
+	zr, err := z.NewReader(spool, size)
+	if err != nil {
+		return err
+	}
+
+	for _, zf := range zr.File {
+		if err := e.ctx.Err(); err != nil {
+			return err
+		}
+
+		mode := zf.Mode()
+		switch {
+		case mode.IsDir():
+			if err := e.mkdir(zf.Name); err != nil {
+				return err
+			}
+		case mode.IsRegular():
+			rc, err := zf.Open()
+			if err != nil {
+				return err
+			}
+			// the uncompressed size in the header is only used for reporting,
+			// limits are enforced on what is actually written.
+			err = e.extractFile(zf.Name, mode, int64(zf.UncompressedSize64), rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		default:
+			// links and special files are not extracted
+		}
+	}
+
+	return nil
+}
+
+func (e *extraction) countEntry() error {
+	e.entries++
+	if 0 < e.MaxEntries && e.MaxEntries < e.entries {
+		return fmt.Errorf("%w: more than %d entries", ErrExtractLimitExceeded, e.MaxEntries)
+	}
+	return nil
+}
+
+func (e *extraction) mkdir(name string) error {
+	if err := e.countEntry(); err != nil {
+		return err
+	}
+	path, err := e.path(name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(path, 0755)
+}
+
+func (e *extraction) extractFile(name string, mode os.FileMode, size int64, r io.Reader) error {
+	if err := e.countEntry(); err != nil {
+		return err
+	}
+	path, err := e.path(name)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode.Perm()|0600)
+	if err != nil {
+		return err
+	}
+
+	if 0 < e.MaxSize {
+		r = io.LimitReader(r, e.MaxSize-e.size+1)
+	}
+
+	fileReport := events.File{
+		Name:              name,
+		Path:              path,
+		Size:              size,
+		TransferStartTime: time.Now(),
+	}
+	fileReport.TransferSize, err = io.Copy(f, r)
+	fileReport.TransferEndTime = time.Now()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	e.size += fileReport.TransferSize
+	if err == nil && 0 < e.MaxSize && e.MaxSize < e.size {
+		err = fmt.Errorf("%w: more than %d bytes", ErrExtractLimitExceeded, e.MaxSize)
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	if e.report != nil {
+		e.report(&fileReport)
+	}
+
+	return nil
+}
+
+// path returns where an archive entry should be written to,
+// refusing entries that would land outside of the extraction directory
+// either directly or by way of a symbolic link already on disk.
+func (e *extraction) path(name string) (string, error) {
+	path := filepath.Join(e.Dir, filepath.FromSlash(name))
+	if path == e.Dir {
+		return path, nil
+	}
+	if !strings.HasPrefix(path, e.Dir+string(os.PathSeparator)) {
+		return "", fmt.Errorf("%w: %s", ErrIllegalArchivePath, name)
+	}
+
+	current := e.Dir
+	for _, part := range strings.Split(path[len(e.Dir)+1:], string(os.PathSeparator)) {
+		current = filepath.Join(current, part)
+		stat, err := os.Lstat(current)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				break
+			}
+			return "", err
+		}
+		if stat.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("%w: %s passes through a symbolic link", ErrIllegalArchivePath, name)
+		}
+	}
+
+	return path, nil
+}
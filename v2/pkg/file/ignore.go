@@ -0,0 +1,126 @@
+package file
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path"
+	"strings"
+)
+
+// ignoreRule is a single .gitignore style pattern.
+type ignoreRule struct {
+	// base is the slash separated directory the rule was found in
+	base     string
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// ignoreRules matches slash separated paths against .gitignore style patterns.
+// Later rules take precedence over earlier ones.
+type ignoreRules []ignoreRule
+
+func (r *ignoreRules) add(base, pattern string) {
+	pattern = strings.TrimRight(pattern, " ")
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return
+	}
+
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(pattern, "!") {
+		rule.negate = true
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		rule.dirOnly = true
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	// a slash anywhere but the end anchors the pattern to the base directory
+	if strings.Contains(pattern, "/") {
+		rule.anchored = true
+		pattern = strings.TrimPrefix(pattern, "/")
+	}
+	if pattern == "" {
+		return
+	}
+	rule.pattern = pattern
+
+	*r = append(*r, rule)
+}
+
+// load adds the rules of the .gitignore file at path, found in the directory base.
+func (r *ignoreRules) load(file, base string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		r.add(base, scanner.Text())
+	}
+	return scanner.Err()
+}
+
+func (r ignoreRules) match(name string, isDir bool) bool {
+	ignored := false
+	for _, rule := range r {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		rel := name
+		if rule.base != "" {
+			if !strings.HasPrefix(name, rule.base+"/") {
+				continue
+			}
+			rel = strings.TrimPrefix(name, rule.base+"/")
+		}
+
+		var matched bool
+		if rule.anchored {
+			matched = globMatch(rule.pattern, rel)
+		} else {
+			matched = globMatch(rule.pattern, path.Base(rel))
+		}
+		if matched {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// globMatch reports whether name matches the slash separated pattern.
+// In addition to the syntax of path.Match, a ** path segment matches any number of directories.
+func globMatch(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) != 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern = pattern[1:]
+		name = name[1:]
+	}
+	return len(name) == 0
+}
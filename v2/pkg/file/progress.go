@@ -0,0 +1,18 @@
+package file
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// ProgressReader adds the number of bytes read from R to Progress.
+type ProgressReader struct {
+	R        io.Reader
+	Progress *atomic.Int64
+}
+
+func (pr *ProgressReader) Read(p []byte) (int, error) {
+	n, err := pr.R.Read(p)
+	pr.Progress.Add(int64(n))
+	return n, err
+}
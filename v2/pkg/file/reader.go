@@ -76,12 +76,17 @@ func IsTTY(rtc ReadTransferConfig) bool {
 	return ok
 }
 
+func IsStream(rtc ReadTransferConfig) bool {
+	_, ok := rtc.(*StdinStreamConfig)
+	return ok
+}
+
 func IsArchive(rtc ReadTransferConfig) bool {
 	_, ok := rtc.(*archiveReaderConfig)
 	return ok
 }
 
-func NewReadTransferConfig(archiveFormat string, locations ...string) (ReadTransferConfig, error) {
+func NewReadTransferConfig(archive ArchiveOptions, locations ...string) (ReadTransferConfig, error) {
 	var rc ReadTransferConfig
 	// determine what we're sending and what needs to be archived
 	switch len(locations) {
@@ -120,8 +125,8 @@ func NewReadTransferConfig(archiveFormat string, locations ...string) (ReadTrans
 
 		if stat.IsDir() {
 			rc = &archiveReaderConfig{
-				format: archiveFormat,
-				paths:  locations,
+				opts:  archive,
+				paths: locations,
 			}
 		} else {
 			rc = &fileReaderConfig{
@@ -136,8 +141,8 @@ func NewReadTransferConfig(archiveFormat string, locations ...string) (ReadTrans
 			}
 		}
 		rc = &archiveReaderConfig{
-			format: archiveFormat,
-			paths:  locations,
+			opts:  archive,
+			paths: locations,
 		}
 	}
 
@@ -170,47 +175,37 @@ func (c *fileReaderConfig) NewReaderTransferSession(ctx context.Context) (*ReadT
 
 // fileReaderConfig defaults to lazy-buffering its input under a certain size
 type archiveReaderConfig struct {
-	format string
-	paths  []string
-	buf    []byte
+	opts  ArchiveOptions
+	paths []string
+	buf   []byte
 }
 
 func (c *archiveReaderConfig) NewReaderTransferSession(ctx context.Context) (*ReadTransferSession, error) {
 	if c.buf == nil {
+		size, sizeErr := c.opts.size(c.paths)
+
 		r, w := io.Pipe()
 		go func() {
-			switch c.format {
-			case "zip":
-				_ = zip(c.paths, w)
-			case "tar":
-				_ = tarball(false, c.paths, w)
-			default:
-				_ = tarball(true, c.paths, w)
-			}
-			w.Close()
+			w.CloseWithError(c.opts.archive(c.paths, w))
 		}()
-		return &ReadTransferSession{
+		rts := ReadTransferSession{
 			r: r,
 			Size: func() (int64, error) {
-				return 0, fmt.Errorf("NA")
+				return size, sizeErr
 			},
-		}, nil
+		}
+		if sizeErr == nil {
+			rts.r = &sizeCheckReader{
+				ReadCloser: r,
+				remaining:  size,
+			}
+		}
+		return &rts, nil
 	}
 
 	buf := bytes.NewBuffer(c.buf)
-	switch c.format {
-	case "zip":
-		if err := zip(c.paths, buf); err != nil {
-			return nil, err
-		}
-	case "tar":
-		if err := tarball(false, c.paths, buf); err != nil {
-			return nil, err
-		}
-	default:
-		if err := tarball(true, c.paths, buf); err != nil {
-			return nil, err
-		}
+	if err := c.opts.archive(c.paths, buf); err != nil {
+		return nil, err
 	}
 
 	return &ReadTransferSession{
@@ -0,0 +1,244 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+var ErrStdinConsumed = errors.New("stdin has already been sent")
+
+// StreamOptions configures how stdin is streamed to clients.
+type StreamOptions struct {
+	// Spool keeps a copy of stdin as it is read so that later requests receive it too.
+	// Without a spool, stdin can only be sent once.
+	Spool bool
+	// MemoryLimit is how many bytes of stdin are spooled in memory,
+	// the rest is spilled to a temporary file.
+	MemoryLimit int64
+}
+
+// NewStdinStreamConfig returns a ReadTransferConfig that sends stdin while it is being read
+// instead of waiting for an EOF first.
+// The returned config should be closed to remove its spool from disk.
+func NewStdinStreamConfig(opts StreamOptions) *StdinStreamConfig {
+	return &StdinStreamConfig{
+		opts: opts,
+	}
+}
+
+type StdinStreamConfig struct {
+	opts StreamOptions
+
+	mu       sync.Mutex
+	consumed bool
+	spool    *spool
+}
+
+func (c *StdinStreamConfig) NewReaderTransferSession(ctx context.Context) (*ReadTransferSession, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.opts.Spool {
+		if c.consumed {
+			return nil, ErrStdinConsumed
+		}
+		c.consumed = true
+		return &ReadTransferSession{
+			r: io.NopCloser(os.Stdin),
+			Size: func() (int64, error) {
+				return 0, fmt.Errorf("NA")
+			},
+		}, nil
+	}
+
+	if c.spool == nil {
+		c.spool = newSpool(c.opts.MemoryLimit)
+		go c.spool.fill(os.Stdin)
+	}
+
+	return &ReadTransferSession{
+		r:    c.spool.newReader(ctx),
+		Size: c.spool.size,
+	}, nil
+}
+
+// Close removes the spool from disk.
+func (c *StdinStreamConfig) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.spool == nil {
+		return nil
+	}
+	return c.spool.close()
+}
+
+// spool holds everything read from a stream so far and lets any number of readers
+// follow along while it is still being read.
+// The first memoryLimit bytes are kept in memory, the rest in a temporary file.
+type spool struct {
+	memoryLimit int64
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	mem  []byte
+	// file holds the bytes that come after mem
+	file *os.File
+	// n is the number of bytes spooled so far
+	n    int64
+	done bool
+	err  error
+}
+
+func newSpool(memoryLimit int64) *spool {
+	s := spool{
+		memoryLimit: memoryLimit,
+	}
+	s.cond = sync.NewCond(&s.mu)
+	return &s
+}
+
+func (s *spool) fill(r io.Reader) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if 0 < n {
+			if werr := s.write(buf[:n]); werr != nil {
+				err = werr
+			}
+		}
+		if err != nil {
+			s.mu.Lock()
+			if !errors.Is(err, io.EOF) {
+				s.err = err
+			}
+			s.done = true
+			s.cond.Broadcast()
+			s.mu.Unlock()
+			return
+		}
+	}
+}
+
+func (s *spool) write(p []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.cond.Broadcast()
+
+	if room := s.memoryLimit - int64(len(s.mem)); 0 < room && s.file == nil {
+		m := min(room, int64(len(p)))
+		s.mem = append(s.mem, p[:m]...)
+		s.n += m
+		p = p[m:]
+	}
+	if len(p) == 0 {
+		return nil
+	}
+
+	if s.file == nil {
+		f, err := os.CreateTemp("", "oneshot-stdin-*")
+		if err != nil {
+			return fmt.Errorf("failed to spill stdin to disk: %w", err)
+		}
+		s.file = f
+	}
+	n, err := s.file.Write(p)
+	s.n += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to spill stdin to disk: %w", err)
+	}
+
+	return nil
+}
+
+// size is only known once the whole stream has been spooled.
+func (s *spool) size() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.done || s.err != nil {
+		return 0, fmt.Errorf("NA")
+	}
+	return s.n, nil
+}
+
+func (s *spool) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	s.file.Close()
+	return os.Remove(s.file.Name())
+}
+
+func (s *spool) newReader(ctx context.Context) *spoolReader {
+	// wake up readers waiting on the spool when their context is done
+	stop := context.AfterFunc(ctx, func() {
+		s.mu.Lock()
+		s.cond.Broadcast()
+		s.mu.Unlock()
+	})
+	return &spoolReader{
+		s:    s,
+		ctx:  ctx,
+		stop: stop,
+	}
+}
+
+type spoolReader struct {
+	s    *spool
+	ctx  context.Context
+	stop func() bool
+	off  int64
+}
+
+func (r *spoolReader) Read(p []byte) (int, error) {
+	s := r.s
+	s.mu.Lock()
+	for s.n <= r.off && !s.done && r.ctx.Err() == nil {
+		s.cond.Wait()
+	}
+	if err := r.ctx.Err(); err != nil {
+		s.mu.Unlock()
+		return 0, err
+	}
+	if s.n <= r.off {
+		err := s.err
+		s.mu.Unlock()
+		if err == nil {
+			err = io.EOF
+		}
+		return 0, err
+	}
+
+	if avail := s.n - r.off; avail < int64(len(p)) {
+		p = p[:avail]
+	}
+	memLen := int64(len(s.mem))
+	if r.off < memLen {
+		n := copy(p, s.mem[r.off:])
+		r.off += int64(n)
+		s.mu.Unlock()
+		return n, nil
+	}
+	f := s.file
+	s.mu.Unlock()
+
+	n, err := f.ReadAt(p, r.off-memLen)
+	r.off += int64(n)
+	if errors.Is(err, io.EOF) && n == len(p) {
+		err = nil
+	}
+	return n, err
+}
+
+func (r *spoolReader) Close() error {
+	r.stop()
+	return nil
+}
@@ -2,99 +2,65 @@ package file
 
 import (
 	"archive/tar"
-	"compress/gzip"
 	"io"
-	"os"
-	"path/filepath"
-	"strings"
 )
 
-func tarball(compress bool, paths []string, w io.Writer) error {
-	var tw *tar.Writer
-	if compress {
-		gw := gzip.NewWriter(w)
-		defer gw.Close()
-		tw = tar.NewWriter(gw)
-	} else {
-		tw = tar.NewWriter(w)
+func tarball(opts *ArchiveOptions, paths []string, w io.Writer) error {
+	cw, err := opts.compressor(w)
+	if err != nil {
+		return err
 	}
-	defer tw.Close()
 
-	formatName := func(name string) string {
-		// needed for windows
-		name = strings.ReplaceAll(name, `\`, `/`)
-		if string(name[0]) == `/` {
-			name = name[1:]
+	tw := tar.NewWriter(cw)
+	err = opts.walk(paths, func(entry *archiveEntry) error {
+		header, err := tar.FileInfoHeader(entry.info, entry.link)
+		if err != nil {
+			return err
 		}
-		return name
-	}
+		header.Name = entry.name
 
-	writeFile := func(path, name string, info os.FileInfo) error {
-		header := tar.Header{
-			Name:    name,
-			Size:    info.Size(),
-			Mode:    int64(info.Mode()),
-			ModTime: info.ModTime(),
-		}
-		if err := tw.WriteHeader(&header); err != nil {
-			return err
+		if xattrs, err := readXattrs(entry.path); err == nil && 0 < len(xattrs) {
+			header.PAXRecords = make(map[string]string, len(xattrs))
+			for k, v := range xattrs {
+				header.PAXRecords["SCHILY.xattr."+k] = v
+			}
 		}
 
-		currFile, err := os.Open(path)
-		if err != nil {
-			return err
+		if opts.Deterministic {
+			header.ModTime = deterministicModTime
+			header.AccessTime = deterministicModTime
+			header.ChangeTime = deterministicModTime
+			header.Uid = 0
+			header.Gid = 0
+			header.Uname = ""
+			header.Gname = ""
 		}
 
-		_, err = io.Copy(tw, currFile)
-		currFile.Close()
-		if err != nil {
+		if err := tw.WriteHeader(header); err != nil {
 			return err
 		}
 
-		return nil
-	}
-
-	walkFunc := func(path string) func(string, os.FileInfo, error) error {
-		dir := filepath.Dir(path)
-		return func(fp string, info os.FileInfo, err error) error {
-			if info.IsDir() {
-				return nil
-			} else if err != nil {
-				return err
-			}
-
-			name := strings.TrimPrefix(fp, dir)
-			name = formatName(name)
-
-			if err = writeFile(fp, name, info); err != nil {
-				return err
-			}
-
+		if !entry.info.Mode().IsRegular() {
 			return nil
 		}
-	}
 
-	// Loop over files to be archived
-	for _, path := range paths {
-		info, err := os.Stat(path)
+		currFile, err := opts.open(entry)
 		if err != nil {
 			return err
 		}
-		if !info.IsDir() { // Archiving a file
-			name := filepath.Base(path)
-			name = formatName(name)
+		_, err = io.Copy(tw, currFile)
+		currFile.Close()
 
-			err = writeFile(path, name, info)
-			if err != nil {
-				return err
-			}
-		} else { // Archiving a directory; needs to be walked
-			err := filepath.Walk(path, walkFunc(path))
-			if err != nil {
-				return err
-			}
-		}
-	}
+		return err
+	})
 
-	return nil
+	// the footer and whatever the compressor still holds are only written on close,
+	// without them the archive is truncated
+	if cerr := tw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := cw.Close(); err == nil {
+		err = cerr
+	}
+	return err
 }
@@ -27,7 +27,7 @@ func NewWriteTransferConfig(ctx context.Context, location string) (*WriteTransfe
 	// if we are receiving to stdout
 	if location == "" {
 		// and are outputting json
-		if format, _ := output.GetFormatAndOpts(ctx); format == "json" {
+		if format, _ := output.GetFormatAndOpts(ctx); format == "json" || format == "ndjson" {
 			// send the contents into the ether.
 			// there's a buffer elsewhere that will provide the contents in the json object.
 			wtc.w = null{}
@@ -133,6 +133,18 @@ func (ts *WriteTransferSession) Close() error {
 	return err
 }
 
+// Commit closes the session and keeps the file whether or not the run has succeeded yet,
+// unlike Close which removes it. The file is still removed if closing it fails.
+func (ts *WriteTransferSession) Commit() error {
+	err := ts.w.Close()
+	if err != nil {
+		if file, ok := ts.w.(*os.File); ok && file != nil && file != os.Stdout {
+			_ = os.Remove(file.Name())
+		}
+	}
+	return err
+}
+
 func (ts *WriteTransferSession) Path() string {
 	if file, ok := ts.w.(*os.File); ok && file != nil {
 		if file != os.Stdout {
@@ -0,0 +1,38 @@
+package file
+
+import (
+	"bytes"
+
+	"golang.org/x/sys/unix"
+)
+
+// readXattrs returns the extended attributes of the file at path without following symbolic links.
+func readXattrs(path string) (map[string]string, error) {
+	size, err := unix.Llistxattr(path, nil)
+	if err != nil || size == 0 {
+		return nil, err
+	}
+	buf := make([]byte, size)
+	if size, err = unix.Llistxattr(path, buf); err != nil {
+		return nil, err
+	}
+
+	xattrs := make(map[string]string)
+	for _, name := range bytes.Split(buf[:size], []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+
+		vsize, err := unix.Lgetxattr(path, string(name), nil)
+		if err != nil {
+			return nil, err
+		}
+		value := make([]byte, vsize)
+		if vsize, err = unix.Lgetxattr(path, string(name), value); err != nil {
+			return nil, err
+		}
+		xattrs[string(name)] = string(value[:vsize])
+	}
+
+	return xattrs, nil
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package file
+
+// readXattrs is only implemented on linux.
+func readXattrs(path string) (map[string]string, error) {
+	return nil, nil
+}
@@ -2,86 +2,67 @@ package file
 
 import (
 	z "archive/zip"
+	"compress/flate"
 	"io"
-	"os"
-	"path/filepath"
 	"strings"
 )
 
-func zip(paths []string, w io.Writer) error {
+func zip(opts *ArchiveOptions, paths []string, w io.Writer) error {
 	zw := z.NewWriter(w)
-	defer zw.Close()
 
-	formatName := func(name string) string {
-		// needed for windows
-		name = strings.ReplaceAll(name, `\`, `/`)
-		if string(name[0]) == `/` {
-			name = name[1:]
-		}
-		return name
+	if level := opts.CompressionLevel; 0 < level {
+		zw.RegisterCompressor(z.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(w, level)
+		})
 	}
 
-	writeFile := func(path, name string, info os.FileInfo) error {
-		zFile, err := zw.Create(name)
+	err := opts.walk(paths, func(entry *archiveEntry) error {
+		header, err := z.FileInfoHeader(entry.info)
 		if err != nil {
 			return err
 		}
+		header.Name = entry.name
 
-		currFile, err := os.Open(path)
-		if err != nil {
-			return err
+		switch {
+		case entry.link != "":
+			header.Method = z.Store
+		case opts.CompressionLevel == 0:
+			header.Method = z.Store
+		default:
+			header.Method = z.Deflate
 		}
 
-		_, err = io.Copy(zFile, currFile)
-		currFile.Close()
+		if opts.Deterministic {
+			header.Modified = deterministicModTime
+		}
+
+		zFile, err := zw.CreateHeader(header)
 		if err != nil {
 			return err
 		}
 
-		return nil
-	}
-
-	walkFunc := func(path string) func(string, os.FileInfo, error) error {
-		dir := filepath.Dir(path)
-		return func(fp string, info os.FileInfo, err error) error {
-			if info.IsDir() {
-				return nil
-			} else if err != nil {
-				return err
-			}
-
-			name := strings.TrimPrefix(fp, dir)
-			name = formatName(name)
-
-			if err = writeFile(fp, name, info); err != nil {
-				return err
-			}
-
+		switch {
+		case entry.link != "":
+			// zip stores the target of a symbolic link as its content
+			_, err = io.Copy(zFile, strings.NewReader(entry.link))
+			return err
+		case !entry.info.Mode().IsRegular():
 			return nil
 		}
-	}
 
-	// Loop over files to be archived
-	for _, path := range paths {
-		info, err := os.Stat(path)
+		currFile, err := opts.open(entry)
 		if err != nil {
 			return err
 		}
-		if !info.IsDir() { // Archiving a file
-			name := filepath.Base(path)
-			name = formatName(name)
+		_, err = io.Copy(zFile, currFile)
+		currFile.Close()
 
-			err = writeFile(path, name, info)
-			if err != nil {
-				return err
-			}
-		} else { // Archiving a directory; needs to be walked
-			err := filepath.Walk(path, walkFunc(path))
-			if err != nil {
-				return err
-			}
-		}
-	}
+		return err
+	})
 
-	return nil
+	// the central directory is only written on close, without it the archive can't be read
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	return err
 }
@@ -16,11 +16,11 @@ func (a *ArchiveMethod) String() string {
 
 func (a *ArchiveMethod) Set(value string) error {
 	switch value {
-	case "zip", "tar", "tar.gz":
+	case "zip", "tar", "tar.gz", "tar.zst", "tar.xz":
 		*a = ArchiveMethod(value)
 		return nil
 	default:
-		return fmt.Errorf(`invalid archive method %q, must be "zip", "tar" or "tar.gz`, value)
+		return fmt.Errorf(`invalid archive method %q, must be "zip", "tar", "tar.gz", "tar.zst" or "tar.xz"`, value)
 	}
 }
 
@@ -43,8 +43,11 @@ func (o *OutputFormat) String() string {
 
 func (o *OutputFormat) Set(v string) error {
 	switch {
-	case strings.HasPrefix(v, "json"):
+	case strings.HasPrefix(v, "json"), strings.HasPrefix(v, "ndjson"):
 		o.Format = "json"
+		if strings.HasPrefix(v, "ndjson") {
+			o.Format = "ndjson"
+		}
 		parts := strings.Split(v, "=")
 		if len(parts) < 2 {
 			return nil
@@ -52,7 +55,7 @@ func (o *OutputFormat) Set(v string) error {
 		o.Opts = strings.Split(parts[1], ",")
 		return nil
 	}
-	return errors.New(`must be "json[=opts...]"`)
+	return errors.New(`must be "json[=opts...]" or "ndjson[=opts...]"`)
 }
 
 func (o *OutputFormat) Type() string {
@@ -66,7 +69,7 @@ func (s *Size) String() string {
 }
 
 func (s *Size) Set(v string) error {
-	size, err := parseSize(v)
+	size, err := ParseSize(v)
 	if err != nil {
 		return err
 	}
@@ -80,7 +83,9 @@ func (s *Size) Type() string {
 
 var sizeRe = regexp.MustCompile(`([1-9]\d*)([kmgtKMGT]?[i]?[bB])`)
 
-func parseSize(s string) (int64, error) {
+// ParseSize parses a human readable size such as 10MB or 1GiB into a number of bytes.
+// Lower case b units are read as bits.
+func ParseSize(s string) (int64, error) {
 	const (
 		k  = 1000
 		ki = 1024
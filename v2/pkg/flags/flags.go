@@ -1,60 +1,94 @@
 package flags
 
 import (
+	"strings"
+
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
+// bound holds the flag each configuration key is bound to.
+var bound = map[string]*pflag.Flag{}
+
+func bind(fs *pflag.FlagSet, key, name string) {
+	flag := fs.Lookup(name)
+	bound[strings.ToLower(key)] = flag
+	viper.BindPFlag(key, flag)
+}
+
+// Changed returns the flag bound to the configuration key if it was set on the command line.
+func Changed(key string) (*pflag.Flag, bool) {
+	flag, ok := bound[strings.ToLower(key)]
+	if !ok || flag == nil || !flag.Changed {
+		return nil, false
+	}
+	return flag, true
+}
+
 func Bool(fs *pflag.FlagSet, key, name, usage string) {
 	defValue := viper.GetBool(key)
 	fs.Bool(name, defValue, usage)
-	viper.BindPFlag(key, fs.Lookup(name))
+	bind(fs, key, name)
 }
 
 func BoolP(fs *pflag.FlagSet, key, name, shorthand, usage string) {
 	defValue := viper.GetBool(key)
 	fs.BoolP(name, shorthand, defValue, usage)
-	viper.BindPFlag(key, fs.Lookup(name))
+	bind(fs, key, name)
 }
 
 func String(fs *pflag.FlagSet, key, name, usage string) {
 	defValue := viper.GetString(key)
 	fs.String(name, defValue, usage)
-	viper.BindPFlag(key, fs.Lookup(name))
+	bind(fs, key, name)
 }
 
 func StringP(fs *pflag.FlagSet, key, name, shorthand, usage string) {
 	defValue := viper.GetString(key)
 	fs.StringP(name, shorthand, defValue, usage)
-	viper.BindPFlag(key, fs.Lookup(name))
+	bind(fs, key, name)
 }
 
 func StringSlice(fs *pflag.FlagSet, key, name, usage string) {
 	defValue := viper.GetStringSlice(key)
 	fs.StringSlice(name, defValue, usage)
-	viper.BindPFlag(key, fs.Lookup(name))
+	bind(fs, key, name)
 }
 
 func StringSliceP(fs *pflag.FlagSet, key, name, shorthand, usage string) {
 	defValue := viper.GetStringSlice(key)
 	fs.StringSliceP(name, shorthand, defValue, usage)
-	viper.BindPFlag(key, fs.Lookup(name))
+	bind(fs, key, name)
+}
+
+func StringArray(fs *pflag.FlagSet, key, name, usage string) {
+	defValue := viper.GetStringSlice(key)
+	fs.StringArray(name, defValue, usage)
+	bind(fs, key, name)
 }
 
 func Int(fs *pflag.FlagSet, key, name, usage string) {
 	defValue := viper.GetInt(key)
 	fs.Int(name, defValue, usage)
-	viper.BindPFlag(key, fs.Lookup(name))
+	bind(fs, key, name)
 }
 
 func IntP(fs *pflag.FlagSet, key, name, shorthand, usage string) {
 	defValue := viper.GetInt(key)
 	fs.IntP(name, shorthand, defValue, usage)
-	viper.BindPFlag(key, fs.Lookup(name))
+	bind(fs, key, name)
 }
 
 func Duration(fs *pflag.FlagSet, key, name, usage string) {
 	defValue := viper.GetDuration(key)
 	fs.Duration(name, defValue, usage)
-	viper.BindPFlag(key, fs.Lookup(name))
+	bind(fs, key, name)
+}
+
+// Name returns the name of the flag bound to the configuration key, if any.
+func Name(key string) string {
+	if flag, ok := bound[strings.ToLower(key)]; ok && flag != nil {
+		return flag.Name
+	}
+	return ""
 }
@@ -0,0 +1,270 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/events"
+	"github.com/rs/zerolog"
+)
+
+// hook event types
+const (
+	EventRequest = "request"
+	EventSuccess = "success"
+	EventFailure = "failure"
+)
+
+// Event is given to hooks as json.
+// It holds the same event structs the json report is made of.
+type Event struct {
+	// Type is one of "request", "success" or "failure".
+	Type     string
+	Time     time.Time
+	Request  *events.HTTPRequest  `json:",omitempty"`
+	File     *events.File         `json:",omitempty"`
+	Response *events.HTTPResponse `json:",omitempty"`
+	// Error is why the transfer failed.
+	Error string `json:",omitempty"`
+}
+
+type Config struct {
+	// OnRequest commands run whenever a client makes a request.
+	OnRequest []string
+	// OnSuccess commands run once oneshot is done after a successful transfer.
+	OnSuccess []string
+	// OnFailure commands run whenever a client fails to complete a transfer.
+	OnFailure []string
+	// Webhooks are URLs that every event is POSTed to.
+	Webhooks []string
+	// Timeout is how long a command or webhook may run before it is cancelled.
+	Timeout time.Duration
+	// Errors is where hook failures are reported to.
+	Errors io.Writer
+}
+
+// Hooks runs commands and webhooks for oneshot events.
+// Hooks that fail are reported but never stop oneshot.
+type Hooks struct {
+	config Config
+	client *http.Client
+
+	wg   sync.WaitGroup
+	done chan struct{}
+}
+
+func New(config Config) *Hooks {
+	if config.Errors == nil {
+		config.Errors = io.Discard
+	}
+	return &Hooks{
+		config: config,
+		client: &http.Client{},
+		done:   make(chan struct{}),
+	}
+}
+
+// Subscribe runs hooks for the events raised from now on.
+func (h *Hooks) Subscribe(ctx context.Context) {
+	sub := events.Subscribe(ctx, events.SubscribeOptions{
+		Buffer: 16,
+		Policy: events.Block,
+		Filter: func(e events.Event) bool {
+			switch e.(type) {
+			case *events.HTTPRequest, *events.File, *events.HTTPResponse, events.ClientDisconnected:
+				return true
+			}
+			return false
+		},
+	})
+	go h.run(ctx, sub.C)
+}
+
+// Wait waits for events to stop and for all hooks to finish.
+func (h *Hooks) Wait() {
+	<-h.done
+	h.wg.Wait()
+}
+
+func (h *Hooks) run(ctx context.Context, ec <-chan events.Event) {
+	defer close(h.done)
+
+	// session collects the events of the current client
+	var session *Event
+	for e := range ec {
+		switch e := e.(type) {
+		case *events.HTTPRequest:
+			session = &Event{Request: e}
+			h.fire(ctx, EventRequest, session, h.config.OnRequest)
+		case *events.File:
+			if session == nil {
+				session = &Event{}
+			}
+			// contents are only available to the json report
+			e.Content = nil
+			session.File = e
+		case *events.HTTPResponse:
+			if session == nil {
+				session = &Event{}
+			}
+			e.Body = nil
+			session.Response = e
+		case events.ClientDisconnected:
+			if session == nil {
+				session = &Event{}
+			}
+			// sessions closed without an error were answered but didn't end the run
+			if e.Err != nil {
+				session.Error = e.Err.Error()
+				h.fire(ctx, EventFailure, session, h.config.OnFailure)
+			}
+			session = nil
+		}
+	}
+
+	if events.Succeeded(ctx) {
+		if session == nil {
+			session = &Event{}
+		}
+		h.fire(ctx, EventSuccess, session, h.config.OnSuccess)
+	} else if session != nil {
+		session.Error = "oneshot exited before the transfer completed"
+		h.fire(ctx, EventFailure, session, h.config.OnFailure)
+	}
+}
+
+// fire runs the commands and webhooks for the event in the background.
+func (h *Hooks) fire(ctx context.Context, eventType string, session *Event, cmds []string) {
+	if len(cmds) == 0 && len(h.config.Webhooks) == 0 {
+		return
+	}
+
+	event := *session
+	event.Type = eventType
+	event.Time = time.Now()
+
+	// the event is encoded right away since the session keeps changing
+	payload, err := json.Marshal(&event)
+	if err != nil {
+		h.report(ctx, eventType, "", fmt.Errorf("unable to encode event: %w", err))
+		return
+	}
+	env := environment(&event)
+
+	// hooks outlive the events context so that they can run while oneshot shuts down
+	ctx = context.WithoutCancel(ctx)
+	for _, cmd := range cmds {
+		cmd := cmd
+		h.wg.Add(1)
+		go func() {
+			defer h.wg.Done()
+			if err := h.runCommand(ctx, cmd, payload, env); err != nil {
+				h.report(ctx, eventType, cmd, err)
+			}
+		}()
+	}
+	for _, url := range h.config.Webhooks {
+		url := url
+		h.wg.Add(1)
+		go func() {
+			defer h.wg.Done()
+			if err := h.postWebhook(ctx, url, payload); err != nil {
+				h.report(ctx, eventType, url, err)
+			}
+		}()
+	}
+}
+
+func (h *Hooks) runCommand(ctx context.Context, command string, payload []byte, env []string) error {
+	ctx, cancel := context.WithTimeout(ctx, h.config.Timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = bytes.NewReader(payload)
+	// stdout is left alone since it may hold oneshot's own output
+	cmd.Stdout = h.config.Errors
+	cmd.Stderr = h.config.Errors
+
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s", h.config.Timeout)
+	}
+	return err
+}
+
+func (h *Hooks) postWebhook(ctx context.Context, url string, payload []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, h.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || 299 < resp.StatusCode {
+		return fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+	return nil
+}
+
+func (h *Hooks) report(ctx context.Context, eventType, hook string, err error) {
+	zerolog.Ctx(ctx).Error().Err(err).
+		Str("event", eventType).
+		Str("hook", hook).
+		Msg("hook failed")
+
+	fmt.Fprintf(h.config.Errors, "%s hook %q failed: %s\n", eventType, hook, err)
+}
+
+// environment returns the parts of the event that are handed to commands as environment variables.
+func environment(e *Event) []string {
+	env := []string{
+		"ONESHOT_EVENT=" + e.Type,
+	}
+	if r := e.Request; r != nil {
+		env = append(env,
+			"ONESHOT_REQUEST_METHOD="+r.Method,
+			"ONESHOT_REQUEST_PATH="+r.Path,
+			"ONESHOT_REMOTE_ADDR="+r.RemoteAddr,
+		)
+	}
+	if f := e.File; f != nil {
+		env = append(env,
+			"ONESHOT_FILE_NAME="+f.Name,
+			"ONESHOT_FILE_PATH="+f.Path,
+			"ONESHOT_FILE_SIZE="+strconv.FormatInt(f.TransferSize, 10),
+		)
+	}
+	if r := e.Response; r != nil {
+		env = append(env, "ONESHOT_RESPONSE_STATUS="+strconv.Itoa(r.StatusCode))
+	}
+	if e.Error != "" {
+		env = append(env, "ONESHOT_ERROR="+e.Error)
+	}
+	return env
+}
@@ -0,0 +1,157 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/version"
+)
+
+// DiscoveryRedirectQueryKey is the query parameter that asks a discovery server
+// to redirect the client straight to the oneshot it is fronting.
+const DiscoveryRedirectQueryKey = "x-oneshot-discovery-redirect"
+
+const defaultMaxRedirects = 10
+
+// ClientRequestFunc creates a new request to the given url.
+// It is called once per redirect so that request bodies may be recreated.
+type ClientRequestFunc func(url string) (*http.Request, error)
+
+// Client is an HTTP client for talking to oneshot servers.
+// Redirects are followed by the client itself rather than the underlying http.Client
+// so that basic auth credentials survive redirects within the server the request was first sent to
+// and so that the request method is preserved where the redirect allows it.
+// Credentials, whether from Username and Password or Authorization and Cookie headers set by the ClientRequestFunc,
+// are never sent to other hosts and redirects from https to http are refused.
+type Client struct {
+	Username string
+	Password string
+
+	MaxRedirects int
+
+	client *http.Client
+}
+
+func NewClient() *Client {
+	return &Client{
+		MaxRedirects: defaultMaxRedirects,
+		client: &http.Client{
+			Transport: &http.Transport{
+				Proxy: http.ProxyFromEnvironment,
+				// give servers that respond before reading the body (such as a discovery server redirecting us)
+				// the chance to do so before we start sending the body.
+				ExpectContinueTimeout: time.Second,
+			},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Do sends the request created by newRequest to rawURL, following any redirects.
+func (c *Client) Do(ctx context.Context, rawURL string, newRequest ClientRequestFunc) (*http.Response, error) {
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+
+	q := u.Query()
+	q.Set(DiscoveryRedirectQueryKey, "true")
+	u.RawQuery = q.Encode()
+
+	var (
+		origin = u
+		// asGET is set once a redirect has turned the request into a GET without a body
+		asGET bool
+	)
+	for redirects := 0; ; redirects++ {
+		req, err := newRequest(u.String())
+		if err != nil {
+			return nil, err
+		}
+		req = req.WithContext(ctx)
+		if asGET {
+			dropBody(req)
+		}
+		if !sameOrigin(origin, u) {
+			dropCredentials(req.Header)
+		} else if c.Username != "" || c.Password != "" {
+			req.SetBasicAuth(c.Username, c.Password)
+		}
+		if req.Header.Get("User-Agent") == "" {
+			req.Header.Set("User-Agent", "oneshot/"+version.Version)
+		}
+
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		switch resp.StatusCode {
+		case http.StatusSeeOther:
+			asGET = asGET || req.Method != http.MethodHead
+		case http.StatusMovedPermanently, http.StatusFound:
+			asGET = asGET || req.Method == http.MethodPost
+		case http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		default:
+			return resp, nil
+		}
+
+		location, err := resp.Location()
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid redirect: %w", err)
+		}
+		if c.MaxRedirects <= redirects {
+			return nil, fmt.Errorf("stopped after %d redirects", redirects)
+		}
+		if u.Scheme == "https" && location.Scheme != "https" {
+			return nil, fmt.Errorf("refusing to follow redirect from %s to %s", u.Redacted(), location.Redacted())
+		}
+		u = location
+	}
+}
+
+// sameOrigin reports whether b has the same scheme and host as a.
+func sameOrigin(a, b *url.URL) bool {
+	return a.Scheme == b.Scheme && strings.EqualFold(a.Host, b.Host)
+}
+
+// credentialHeaders are the headers only sent to the origin a request was first made to, as net/http does.
+var credentialHeaders = []string{"Authorization", "Www-Authenticate", "Cookie", "Cookie2"}
+
+// dropCredentials removes the credential headers from h, however their names are cased.
+func dropCredentials(h http.Header) {
+	for k := range h {
+		for _, ch := range credentialHeaders {
+			if strings.EqualFold(k, ch) {
+				delete(h, k)
+			}
+		}
+	}
+}
+
+// dropBody turns req into a GET without a body, as clients do after a 303 or after a 301 or 302 from a POST.
+// The body is closed so that whatever is producing it, like a pipe being written to, is let go.
+func dropBody(req *http.Request) {
+	if req.Method != http.MethodHead {
+		req.Method = http.MethodGet
+	}
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	req.Body = nil
+	req.GetBody = nil
+	req.ContentLength = 0
+	req.Header.Del("Content-Type")
+	req.Header.Del("Content-Length")
+	req.Header.Del("Content-Encoding")
+}
@@ -0,0 +1,75 @@
+package http
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/events"
+)
+
+// ReadClientCAs reads the PEM encoded CA certificates at path that client certificates are verified against.
+func ReadClientCAs(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read client CA file: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM encoded certificates found in %s", path)
+	}
+
+	return pool, nil
+}
+
+// ClientCertMiddleware only lets through requests made over a TLS connection
+// whose client presented a verified certificate.
+// If either allowlist is not empty then the certificate must also match one of its entries.
+// Subjects are matched against the certificates common name and full distinguished name,
+// SANs against its DNS names, email addresses, IP addresses and URIs.
+// Requests that don't make it through are handed to forbidden.
+// The certificates common name is recorded as the requests user, see events.WithUser.
+func ClientCertMiddleware(forbidden http.HandlerFunc, allowedSubjects, allowedSANs []string) Middleware {
+	allowed := func(cert *x509.Certificate) bool {
+		if len(allowedSubjects) == 0 && len(allowedSANs) == 0 {
+			return true
+		}
+
+		subject := cert.Subject.String()
+		for _, s := range allowedSubjects {
+			if s == subject || s == cert.Subject.CommonName {
+				return true
+			}
+		}
+		for _, san := range events.CertificateSANs(cert) {
+			for _, s := range allowedSANs {
+				if s == san {
+					return true
+				}
+			}
+		}
+
+		return false
+	}
+
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			// requests coming in over webRTC never carry a client certificate
+			if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+				forbidden(w, r)
+				return
+			}
+
+			cert := r.TLS.VerifiedChains[0][0]
+			r = r.WithContext(events.WithUser(r.Context(), cert.Subject.CommonName))
+			if !allowed(cert) {
+				forbidden(w, r)
+				return
+			}
+
+			next(w, r)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"net/http"
+)
+
+// FlushWriter flushes every write through to the client as soon as it is made.
+type FlushWriter struct {
+	w  io.Writer
+	rc *http.ResponseController
+}
+
+func NewFlushWriter(w http.ResponseWriter) *FlushWriter {
+	return &FlushWriter{
+		w:  w,
+		rc: http.NewResponseController(w),
+	}
+}
+
+func (f *FlushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	if err := f.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return n, err
+	}
+	return n, nil
+}
@@ -0,0 +1,225 @@
+package http
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// htpasswd permissions
+const (
+	// PermissionDownload lets a user make GET, HEAD and OPTIONS requests.
+	PermissionDownload = "download"
+	// PermissionUpload lets a user make every other kind of request.
+	PermissionUpload = "upload"
+)
+
+// HTPasswdUser is a single line of an htpasswd file.
+type HTPasswdUser struct {
+	Username string
+	// Hash is the bcrypt or argon2 hash of the users password.
+	Hash string
+	// Permissions the user has, a user without any has all of them.
+	Permissions []string
+}
+
+// Allows reports whether the user may make a request with the given method.
+func (u *HTPasswdUser) Allows(method string) bool {
+	if len(u.Permissions) == 0 {
+		return true
+	}
+
+	need := PermissionUpload
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		need = PermissionDownload
+	}
+	for _, p := range u.Permissions {
+		if p == need {
+			return true
+		}
+	}
+	return false
+}
+
+// allows reports whether user may make a request with the given method.
+func (h *HTPasswd) allows(user *HTPasswdUser, method string) bool {
+	if user.Allows(method) {
+		return true
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return h.UploadPage && user.Allows(http.MethodPost)
+	}
+	return false
+}
+
+// HTPasswd holds the users of an htpasswd file.
+// Each line holds a username and a bcrypt or argon2 hash of their password, separated by a colon:
+//
+//	alice:$2y$10$...
+//
+// An optional third field gives the users permissions as a comma separated list of download and upload:
+//
+//	bob:$argon2id$v=19$m=65536,t=3,p=4$...:upload
+type HTPasswd struct {
+	Users map[string]*HTPasswdUser
+	// UploadPage is set when GET, HEAD and OPTIONS requests only serve a page to upload from,
+	// upload permission is then enough to make them.
+	UploadPage bool
+	// dummy is checked against when a username is unknown,
+	// that way unknown and known users take just as long to turn away.
+	dummy string
+}
+
+// ReadHTPasswd reads the htpasswd file at path.
+func ReadHTPasswd(path string) (*HTPasswd, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open htpasswd file: %w", err)
+	}
+	defer f.Close()
+
+	return ParseHTPasswd(f)
+}
+
+// ParseHTPasswd parses the htpasswd entries in r.
+// Blank lines and lines starting with # are skipped.
+func ParseHTPasswd(r io.Reader) (*HTPasswd, error) {
+	h := HTPasswd{
+		Users: make(map[string]*HTPasswdUser),
+	}
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.Split(line, ":")
+		if len(parts) < 2 || 3 < len(parts) || parts[0] == "" {
+			return nil, fmt.Errorf("invalid htpasswd entry on line %d, expected user:hash[:permissions]", n)
+		}
+
+		user := HTPasswdUser{
+			Username: parts[0],
+			Hash:     parts[1],
+		}
+		if !isBcrypt(user.Hash) && !isArgon2(user.Hash) {
+			return nil, fmt.Errorf("unsupported hash for user %s on line %d, only bcrypt and argon2 hashes are supported", user.Username, n)
+		}
+		if len(parts) == 3 && parts[2] != "" {
+			for _, p := range strings.Split(parts[2], ",") {
+				p = strings.TrimSpace(p)
+				if p != PermissionDownload && p != PermissionUpload {
+					return nil, fmt.Errorf("invalid permission for user %s on line %d: %s", user.Username, n, p)
+				}
+				user.Permissions = append(user.Permissions, p)
+			}
+		}
+		if _, exists := h.Users[user.Username]; exists {
+			return nil, fmt.Errorf("duplicate htpasswd entry for user %s on line %d", user.Username, n)
+		}
+
+		h.Users[user.Username] = &user
+		if h.dummy == "" {
+			h.dummy = user.Hash
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read htpasswd entries: %w", err)
+	}
+	if len(h.Users) == 0 {
+		return nil, fmt.Errorf("no htpasswd entries found")
+	}
+
+	return &h, nil
+}
+
+// Authenticate returns the user with the given username if password matches their hash.
+func (h *HTPasswd) Authenticate(username, password string) (*HTPasswdUser, bool) {
+	user, ok := h.Users[username]
+	if !ok {
+		_ = VerifyPassword(h.dummy, password)
+		return nil, false
+	}
+	if !VerifyPassword(user.Hash, password) {
+		return nil, false
+	}
+	return user, true
+}
+
+// VerifyPassword reports whether password matches the bcrypt or argon2 hash.
+func VerifyPassword(hash, password string) bool {
+	switch {
+	case isBcrypt(hash):
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	case isArgon2(hash):
+		return verifyArgon2(hash, password)
+	}
+	return false
+}
+
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") ||
+		strings.HasPrefix(hash, "$2b$") ||
+		strings.HasPrefix(hash, "$2y$")
+}
+
+func isArgon2(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$") ||
+		strings.HasPrefix(hash, "$argon2i$")
+}
+
+// verifyArgon2 checks password against a hash in the PHC string format:
+// $argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>
+func verifyArgon2(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false
+	}
+
+	var (
+		memory     uint32
+		iterations uint32
+		threads    uint8
+	)
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &threads); err != nil {
+		return false
+	}
+	if iterations == 0 || threads == 0 {
+		return false
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false
+	}
+
+	var got []byte
+	if parts[1] == "argon2id" {
+		got = argon2.IDKey([]byte(password), salt, iterations, memory, threads, uint32(len(want)))
+	} else {
+		got = argon2.Key([]byte(password), salt, iterations, memory, threads, uint32(len(want)))
+	}
+
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
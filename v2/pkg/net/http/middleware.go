@@ -1,12 +1,16 @@
 package http
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/forestnode-io/oneshot/v2/pkg/events"
+	"github.com/forestnode-io/oneshot/v2/pkg/secrets"
 	"github.com/google/uuid"
 )
 
@@ -16,6 +20,9 @@ func (mw Middleware) Chain(m Middleware) Middleware {
 	if mw == nil {
 		return m
 	}
+	if m == nil {
+		return mw
+	}
 	return func(hf http.HandlerFunc) http.HandlerFunc {
 		hf = mw(hf)
 		return m(hf)
@@ -42,21 +49,42 @@ func BotsMiddleware(block bool) Middleware {
 	}
 }
 
-func BasicAuthMiddleware(unauthenticated http.HandlerFunc, username, password string) (Middleware, string, error) {
-	if username == "" && password == "" {
+// BasicAuthMiddleware only lets through requests carrying valid basic auth credentials.
+// If htpasswd is not nil then its users are checked and username and password are not used,
+// otherwise whichever of username and password is missing is not checked.
+// Users without permission for the request are handed to forbidden.
+// The username a request was authenticated as is recorded in its context, see events.WithUser.
+// Requests made over WebRTC authenticate with a token from the returned WebRTCTokens instead,
+// which is nil if no credentials are needed.
+func BasicAuthMiddleware(unauthenticated, forbidden http.HandlerFunc, username, password string, htpasswd *HTPasswd) (Middleware, *WebRTCTokens, error) {
+	if username == "" && password == "" && htpasswd == nil {
 		return func(hf http.HandlerFunc) http.HandlerFunc {
 			return hf
-		}, "", nil
+		}, nil, nil
 	}
 
-	baToken := uuid.NewString()
+	tokens := &WebRTCTokens{
+		users: make(map[string]string),
+	}
 
 	return func(authenticated http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
 			if token := r.Header.Get("X-HTTPOverWebRTC-Authorization"); token != "" {
-				if token == baToken {
-					authenticated(w, r)
-					return
+				if u, ok := tokens.user(token); ok {
+					r = r.WithContext(events.WithUser(r.Context(), u))
+					if htpasswd == nil {
+						authenticated(w, r)
+						return
+					}
+					// tokens only stand in for the credentials of the user they were issued to
+					if user, ok := htpasswd.Users[u]; ok {
+						if !htpasswd.allows(user, r.Method) {
+							forbidden(w, r)
+							return
+						}
+						authenticated(w, r)
+						return
+					}
 				}
 			}
 
@@ -65,18 +93,90 @@ func BasicAuthMiddleware(unauthenticated http.HandlerFunc, username, password st
 				unauthenticated(w, r)
 				return
 			}
-			// Whichever field is missing is not checked
-			if username != "" && username != u {
-				unauthenticated(w, r)
+
+			if htpasswd != nil {
+				user, ok := htpasswd.Authenticate(u, p)
+				if !ok {
+					unauthenticated(w, r)
+					return
+				}
+				// only the hash is known ahead of time, so the password is
+				// registered now to keep it out of reports
+				secrets.Register(p)
+				r = r.WithContext(events.WithUser(r.Context(), u))
+				if !htpasswd.allows(user, r.Method) {
+					forbidden(w, r)
+					return
+				}
+				authenticated(w, r)
 				return
 			}
-			if password != "" && password != p {
+
+			// Whichever field is missing is not checked.
+			// Both are always compared so that a bad username takes as long as a bad password.
+			uOK := username == "" || constantTimeEqual(username, u)
+			pOK := password == "" || constantTimeEqual(password, p)
+			if !uOK || !pOK {
 				unauthenticated(w, r)
 				return
 			}
-			authenticated(w, r)
+			authenticated(w, r.WithContext(events.WithUser(r.Context(), u)))
 		}
-	}, baToken, nil
+	}, tokens, nil
+}
+
+// WebRTCTokens hands out the tokens that requests made over WebRTC authenticate with.
+// Each token stands in for the user it was issued to, who was authenticated by
+// the discovery server, and gets that users permissions.
+// The methods of a nil *WebRTCTokens do nothing.
+type WebRTCTokens struct {
+	mu sync.Mutex
+	// users maps tokens to the user they were issued to
+	users map[string]string
+}
+
+// Issue returns a new token for user, which may be empty if the user is not known.
+func (t *WebRTCTokens) Issue(user string) string {
+	if t == nil {
+		return ""
+	}
+	token := uuid.NewString()
+	t.mu.Lock()
+	t.users[token] = user
+	t.mu.Unlock()
+	return token
+}
+
+// Revoke stops token from authenticating any more requests.
+func (t *WebRTCTokens) Revoke(token string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	delete(t.users, token)
+	t.mu.Unlock()
+}
+
+// user returns the user token was issued to.
+// Every token is compared so that how long this takes doesn't give away a valid token.
+func (t *WebRTCTokens) user(token string) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var (
+		user  string
+		found bool
+	)
+	for tk, u := range t.users {
+		if constantTimeEqual(tk, token) {
+			user, found = u, true
+		}
+	}
+	return user, found
+}
+
+// constantTimeEqual compares a and b without leaking where they differ.
+func constantTimeEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
 }
 
 // botHeaders are the known User-Agent header values in use by bots / machines
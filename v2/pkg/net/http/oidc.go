@@ -0,0 +1,573 @@
+package http
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/events"
+	"github.com/forestnode-io/oneshot/v2/pkg/secrets"
+	"github.com/golang-jwt/jwt"
+	"github.com/rs/zerolog"
+)
+
+// OIDCCallbackPath is where the OpenID Connect provider sends users back to after logging in.
+const OIDCCallbackPath = "/.oneshot/oidc/callback"
+
+const (
+	oidcSessionCookie = "oneshot-session"
+	// oidcLoginTTL is how long a user has to finish logging in with the provider.
+	oidcLoginTTL = 10 * time.Minute
+)
+
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is where the provider sends users back to.
+	// If empty, OIDCCallbackPath on the host the user connected to is used.
+	RedirectURL string
+	// Scopes are requested along with openid.
+	Scopes []string
+
+	// AllowedEmails and AllowedDomains let in users whose email matches either of them.
+	// AllowedGroups further requires users to be in one of the groups listed in the GroupsClaim.
+	// Everyone the provider logs in is let in if none of them are set.
+	AllowedEmails  []string
+	AllowedDomains []string
+	AllowedGroups  []string
+	GroupsClaim    string
+
+	// SessionTTL is how long a login lasts.
+	SessionTTL time.Duration
+
+	// Client talks to the provider, http.DefaultClient is used if nil.
+	Client *http.Client
+}
+
+type oidcProviderMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcLogin is a login that has been started but not finished.
+type oidcLogin struct {
+	verifier    string
+	nonce       string
+	redirectURI string
+	returnTo    string
+	expires     time.Time
+}
+
+type oidcSession struct {
+	user    string
+	expires time.Time
+}
+
+type oidc struct {
+	config   OIDCConfig
+	provider oidcProviderMetadata
+
+	mu       sync.Mutex
+	logins   map[string]*oidcLogin
+	sessions map[string]*oidcSession
+	keys     map[string]any
+}
+
+// OIDCMiddleware sends browsers through an OpenID Connect authorization code flow with PKCE
+// before letting them through.
+// Once the ID token is validated and the user is found in the allowlists,
+// a session cookie is set and the user is recorded in the request context, see events.WithUser.
+// Requests that can not be logged in are handed to unauthenticated.
+func OIDCMiddleware(ctx context.Context, config OIDCConfig, unauthenticated http.HandlerFunc) (Middleware, error) {
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = "groups"
+	}
+
+	o := oidc{
+		config:   config,
+		logins:   make(map[string]*oidcLogin),
+		sessions: make(map[string]*oidcSession),
+	}
+	if err := o.discover(ctx); err != nil {
+		return nil, fmt.Errorf("unable to discover OIDC provider: %w", err)
+	}
+
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == OIDCCallbackPath {
+				o.callback(w, r, unauthenticated)
+				return
+			}
+
+			if user, ok := o.session(r); ok {
+				next(w, r.WithContext(events.WithUser(r.Context(), user)))
+				return
+			}
+
+			// only requests a browser can be redirected and later replay are sent to log in
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				unauthenticated(w, r)
+				return
+			}
+			o.login(w, r)
+		}
+	}, nil
+}
+
+func (o *oidc) discover(ctx context.Context) error {
+	u := strings.TrimSuffix(o.config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := o.getJSON(ctx, u, &o.provider); err != nil {
+		return err
+	}
+
+	if o.provider.Issuer != o.config.Issuer {
+		return fmt.Errorf("issuer mismatch, expected %s but provider is %s", o.config.Issuer, o.provider.Issuer)
+	}
+	if o.provider.AuthorizationEndpoint == "" || o.provider.TokenEndpoint == "" || o.provider.JWKSURI == "" {
+		return errors.New("provider metadata is missing an endpoint")
+	}
+
+	return nil
+}
+
+func (o *oidc) getJSON(ctx context.Context, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := o.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", u, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// session returns the user the request has a session for.
+func (o *oidc) session(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie(oidcSessionCookie)
+	if err != nil {
+		return "", false
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	s, ok := o.sessions[cookie.Value]
+	if !ok {
+		return "", false
+	}
+	if time.Now().After(s.expires) {
+		delete(o.sessions, cookie.Value)
+		secrets.Unregister(cookie.Value)
+		return "", false
+	}
+	return s.user, true
+}
+
+// login redirects the browser to the provider to log in.
+func (o *oidc) login(w http.ResponseWriter, r *http.Request) {
+	login := oidcLogin{
+		verifier:    randomString(),
+		nonce:       randomString(),
+		redirectURI: o.config.RedirectURL,
+		returnTo:    localRedirect(r.URL.RequestURI()),
+		expires:     time.Now().Add(oidcLoginTTL),
+	}
+	if login.redirectURI == "" {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		login.redirectURI = scheme + "://" + r.Host + OIDCCallbackPath
+	}
+	state := randomString()
+
+	o.mu.Lock()
+	now := time.Now()
+	for s, l := range o.logins {
+		if now.After(l.expires) {
+			delete(o.logins, s)
+		}
+	}
+	o.logins[state] = &login
+	o.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(login.verifier))
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", o.config.ClientID)
+	q.Set("redirect_uri", login.redirectURI)
+	q.Set("scope", strings.Join(append([]string{"openid"}, o.config.Scopes...), " "))
+	q.Set("state", state)
+	q.Set("nonce", login.nonce)
+	q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	q.Set("code_challenge_method", "S256")
+
+	authURL := o.provider.AuthorizationEndpoint
+	if strings.Contains(authURL, "?") {
+		authURL += "&" + q.Encode()
+	} else {
+		authURL += "?" + q.Encode()
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// callback finishes logging in once the provider sends the browser back.
+func (o *oidc) callback(w http.ResponseWriter, r *http.Request, unauthenticated http.HandlerFunc) {
+	var (
+		log   = zerolog.Ctx(r.Context())
+		q     = r.URL.Query()
+		state = q.Get("state")
+	)
+
+	o.mu.Lock()
+	login, ok := o.logins[state]
+	delete(o.logins, state)
+	o.mu.Unlock()
+	if !ok || time.Now().After(login.expires) {
+		log.Debug().Msg("oidc callback with unknown or expired state")
+		unauthenticated(w, r)
+		return
+	}
+	if e := q.Get("error"); e != "" {
+		log.Debug().Str("error", e).Str("description", q.Get("error_description")).
+			Msg("oidc provider returned an error")
+		unauthenticated(w, r)
+		return
+	}
+
+	idToken, err := o.exchange(r.Context(), q.Get("code"), login)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to exchange oidc authorization code")
+		unauthenticated(w, r)
+		return
+	}
+
+	claims, err := o.verify(r.Context(), idToken, login.nonce)
+	if err != nil {
+		log.Error().Err(err).Msg("invalid oidc id token")
+		unauthenticated(w, r)
+		return
+	}
+
+	user, err := o.allow(claims)
+	if err != nil {
+		log.Debug().Err(err).Msg("oidc user not allowed")
+		unauthenticated(w, r.WithContext(events.WithUser(r.Context(), user)))
+		return
+	}
+
+	id := randomString()
+	// the session id is as good as the users credentials, keep it out of reports
+	secrets.Register(id)
+	now := time.Now()
+	expires := now.Add(o.config.SessionTTL)
+	o.mu.Lock()
+	// abandoned sessions are never looked up again, so they are cleared out here
+	for sid, s := range o.sessions {
+		if now.After(s.expires) {
+			delete(o.sessions, sid)
+			secrets.Unregister(sid)
+		}
+	}
+	o.sessions[id] = &oidcSession{
+		user:    user,
+		expires: expires,
+	}
+	o.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcSessionCookie,
+		Value:    id,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, login.returnTo, http.StatusFound)
+}
+
+// localRedirect returns uri if it is a path on this server, otherwise /.
+// Paths like //host or /\host are taken by browsers to be another host.
+func localRedirect(uri string) string {
+	if !strings.HasPrefix(uri, "/") || strings.HasPrefix(uri, "//") || strings.HasPrefix(uri, "/\\") {
+		return "/"
+	}
+	return uri
+}
+
+// exchange trades the authorization code for an ID token.
+func (o *oidc) exchange(ctx context.Context, code string, login *oidcLogin) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", login.redirectURI)
+	form.Set("client_id", o.config.ClientID)
+	form.Set("code_verifier", login.verifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.provider.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if o.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(o.config.ClientID), url.QueryEscape(o.config.ClientSecret))
+	}
+
+	resp, err := o.config.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("unable to decode token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		return "", fmt.Errorf("token endpoint returned %s: %s %s", resp.Status, body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", errors.New("token response is missing the id_token")
+	}
+
+	return body.IDToken, nil
+}
+
+// verify checks the ID tokens signature and claims.
+func (o *oidc) verify(ctx context.Context, idToken, nonce string) (jwt.MapClaims, error) {
+	parser := jwt.Parser{
+		ValidMethods: []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"},
+	}
+	claims := jwt.MapClaims{}
+	_, err := parser.ParseWithClaims(idToken, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return o.key(ctx, kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	if !claims.VerifyExpiresAt(now, true) {
+		return nil, errors.New("token is expired")
+	}
+	if !claims.VerifyIssuer(o.provider.Issuer, true) {
+		return nil, errors.New("token was issued by someone else")
+	}
+	if !claims.VerifyAudience(o.config.ClientID, true) {
+		return nil, errors.New("token is for someone else")
+	}
+	if n, _ := claims["nonce"].(string); n != nonce {
+		return nil, errors.New("token nonce does not match")
+	}
+
+	return claims, nil
+}
+
+// key returns the providers signing key with the given id.
+// The providers keys are fetched again if the key is unknown since the provider may have rotated them.
+func (o *oidc) key(ctx context.Context, kid string) (any, error) {
+	o.mu.Lock()
+	key, ok := o.keys[kid]
+	o.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	var jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := o.getJSON(ctx, o.provider.JWKSURI, &jwks); err != nil {
+		return nil, fmt.Errorf("unable to fetch provider keys: %w", err)
+	}
+
+	keys := make(map[string]any)
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pk, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = pk
+	}
+
+	o.mu.Lock()
+	o.keys = keys
+	o.mu.Unlock()
+
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	// a provider with a single key may leave out the key id
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// allow returns the user if the claims pass the allowlists.
+// The user is their email if they have one, otherwise their subject.
+func (o *oidc) allow(claims jwt.MapClaims) (string, error) {
+	email, _ := claims["email"].(string)
+	user := email
+	if user == "" {
+		user, _ = claims["sub"].(string)
+	}
+
+	c := o.config
+	if 0 < len(c.AllowedEmails)+len(c.AllowedDomains) {
+		if email == "" {
+			return user, errors.New("token has no email")
+		}
+		// an email the provider hasn't verified could belong to anyone
+		if verified, _ := claims["email_verified"].(bool); !verified {
+			return user, errors.New("email is not verified")
+		}
+
+		allowed := false
+		for _, e := range c.AllowedEmails {
+			if strings.EqualFold(e, email) {
+				allowed = true
+			}
+		}
+		if _, domain, ok := strings.Cut(email, "@"); ok {
+			for _, d := range c.AllowedDomains {
+				if strings.EqualFold(d, domain) {
+					allowed = true
+				}
+			}
+		}
+		if !allowed {
+			return user, fmt.Errorf("%s is not an allowed email", email)
+		}
+	}
+
+	if 0 < len(c.AllowedGroups) {
+		var groups []string
+		switch g := claims[c.GroupsClaim].(type) {
+		case string:
+			groups = []string{g}
+		case []any:
+			for _, v := range g {
+				if s, ok := v.(string); ok {
+					groups = append(groups, s)
+				}
+			}
+		}
+
+		allowed := false
+		for _, want := range c.AllowedGroups {
+			for _, g := range groups {
+				if g == want {
+					allowed = true
+				}
+			}
+		}
+		if !allowed {
+			return user, fmt.Errorf("%s is not in an allowed group", user)
+		}
+	}
+
+	return user, nil
+}
+
+// jwk is a JSON Web Key as served from the providers jwks_uri.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k *jwk) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
+
+func randomString() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -2,7 +2,10 @@ package http
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"fmt"
 	"net"
 	"net/http"
 	"runtime"
@@ -31,22 +34,40 @@ type Server struct {
 	PostSuccessHandler http.HandlerFunc
 
 	TLSCert, TLSKey string
-	Timeout         time.Duration
+	// ClientCAs, if not nil, makes clients present a certificate signed by one of its CAs.
+	ClientCAs *x509.CertPool
+	Timeout   time.Duration
 
 	ExitOnFail bool
 
 	queue chan _wr
+
+	mu sync.Mutex
+	// conns maps open connections to the address their client is known by, see clientAddr
+	conns         map[net.Conn]string
+	nextConnID    uint64
+	listenerTimer *oneshotnet.ListenerTimer
 }
 
+var (
+	ErrUnknownClient = errors.New("unknown client")
+	ErrNoTimeout     = errors.New("no timeout to extend")
+)
+
 func NewServer(ctx context.Context, preSucc, postSucc http.HandlerFunc, mw ...Middleware) *Server {
 	s := Server{
 		PreSuccessHandler:  preSucc,
 		PostSuccessHandler: postSucc,
 
 		queue: make(chan _wr, runtime.NumCPU()),
+		conns: make(map[net.Conn]string),
 
 		server: http.Server{},
 	}
+	s.server.ConnState = s.trackConn
+	s.server.ConnContext = func(ctx context.Context, c net.Conn) context.Context {
+		return context.WithValue(ctx, clientAddrKey{}, s.addConn(c))
+	}
 	s.server.BaseContext = func(l net.Listener) context.Context {
 		return ctx
 	}
@@ -74,7 +95,13 @@ func NewServer(ctx context.Context, preSucc, postSucc http.HandlerFunc, mw ...Mi
 	}
 
 	r := mux.NewRouter()
-	r.PathPrefix("/").HandlerFunc(handler)
+	r.PathPrefix("/").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// requests are reported with the same address Kick knows their client by
+		if addr, ok := req.Context().Value(clientAddrKey{}).(string); ok {
+			req.RemoteAddr = addr
+		}
+		handler(w, req)
+	})
 	s.server.Handler = r
 
 	return &s
@@ -130,7 +157,12 @@ func (s *Server) Serve(ctx context.Context, l net.Listener) error {
 
 	preSuccWorker := func() {
 		for wr := range s.queue {
-			s.PreSuccessHandler(wr.w, wr.r.WithContext(ctx))
+			rctx := ctx
+			// keep who the request was authenticated as
+			if user := events.UserFromContext(wr.r.Context()); user != "" {
+				rctx = events.WithUser(rctx, user)
+			}
+			s.PreSuccessHandler(wr.w, wr.r.WithContext(rctx))
 
 			if !wr.w.ignoreOutcome && (events.Succeeded(ctx) || s.ExitOnFail) {
 				tsw, ok := wr.w.ResponseWriter.(ts)
@@ -160,6 +192,9 @@ func (s *Server) Serve(ctx context.Context, l net.Listener) error {
 	if 0 < s.Timeout {
 		lt := oneshotnet.NewListenerTimer(l, s.Timeout)
 		l = lt
+		s.mu.Lock()
+		s.listenerTimer = lt
+		s.mu.Unlock()
 		go func() {
 			select {
 			case <-lt.C:
@@ -186,6 +221,13 @@ func (s *Server) Serve(ctx context.Context, l net.Listener) error {
 				Str("cert", s.TLSCert).
 				Str("key", s.TLSKey).
 				Msg("serving HTTPS")
+			if s.ClientCAs != nil {
+				log.Info().Msg("requiring client certificates")
+				s.server.TLSConfig = &tls.Config{
+					ClientCAs:  s.ClientCAs,
+					ClientAuth: tls.RequireAndVerifyClientCert,
+				}
+			}
 			err = s.server.ServeTLS(l, s.TLSCert, s.TLSKey)
 			err = output.WrapPrintable(err)
 		} else {
@@ -221,6 +263,93 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	s.server.Handler.ServeHTTP(w, r)
 }
 
+// Kick closes the connection to the client at addr.
+func (s *Server) Kick(addr string) error {
+	s.mu.Lock()
+	var conn net.Conn
+	for c, a := range s.conns {
+		if a == addr {
+			conn = c
+			break
+		}
+	}
+	s.mu.Unlock()
+	if conn == nil {
+		return ErrUnknownClient
+	}
+	return conn.Close()
+}
+
+// ExtendTimeout gives clients d more time to connect and returns the new deadline.
+func (s *Server) ExtendTimeout(d time.Duration) (time.Time, error) {
+	s.mu.Lock()
+	lt := s.listenerTimer
+	s.mu.Unlock()
+	if lt == nil {
+		return time.Time{}, ErrNoTimeout
+	}
+	deadline, ok := lt.Extend(d)
+	if !ok {
+		return time.Time{}, ErrNoTimeout
+	}
+	return deadline, nil
+}
+
+// TimeoutDeadline returns when the server times out if no client connects before then.
+func (s *Server) TimeoutDeadline() (time.Time, bool) {
+	s.mu.Lock()
+	lt := s.listenerTimer
+	s.mu.Unlock()
+	if lt == nil {
+		return time.Time{}, false
+	}
+	return lt.Deadline()
+}
+
+// HasClients reports whether any clients are connected.
+func (s *Server) HasClients() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return 0 < len(s.conns)
+}
+
+type clientAddrKey struct{}
+
+// addConn starts tracking conn and returns the address its client is known by.
+func (s *Server) addConn(conn net.Conn) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextConnID++
+	addr := clientAddr(conn, s.nextConnID)
+	s.conns[conn] = addr
+	return addr
+}
+
+func (s *Server) trackConn(conn net.Conn, state http.ConnState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch state {
+	case http.StateClosed, http.StateHijacked:
+		delete(s.conns, conn)
+	}
+}
+
+// clientAddr returns the remote address of conn, or for unix socket clients
+// which all share the same address, the network followed by the connections id.
+func clientAddr(conn net.Conn, id uint64) string {
+	addr := conn.RemoteAddr()
+	if addr == nil || addr.String() == "" || addr.String() == "@" {
+		network := "unix"
+		if addr != nil {
+			network = addr.Network()
+		}
+		return fmt.Sprintf("%s#%d", network, id)
+	}
+	return addr.String()
+}
+
 func cleanServerShutdownErr(err error) error {
 	if errors.Is(err, http.ErrServerClosed) ||
 		errors.Is(err, context.Canceled) ||
@@ -252,6 +381,11 @@ func (w *responseWriter) WriteHeader(code int) {
 	w.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 func (w *responseWriter) IgnoreOutcome() {
 	w.ignoreOutcome = true
 }
@@ -0,0 +1,229 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ListenAddress is an address oneshot can listen on.
+type ListenAddress struct {
+	// Network is one of tcp, tcp4, tcp6 or unix.
+	Network string
+	// Address is a host:port pair for tcp networks and a path for unix sockets.
+	Address string
+}
+
+// ParseListenAddress parses addresses of the form tcp://host:port, tcp4://host:port,
+// tcp6://[host]:port and unix:///path/to/socket.
+// Addresses without a scheme are taken to be tcp host:port pairs.
+func ParseListenAddress(s string) (ListenAddress, error) {
+	if !strings.Contains(s, "://") {
+		if _, _, err := net.SplitHostPort(s); err != nil {
+			return ListenAddress{}, fmt.Errorf("invalid listen address %q: %w", s, err)
+		}
+		return ListenAddress{Network: "tcp", Address: s}, nil
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return ListenAddress{}, fmt.Errorf("invalid listen address %q: %w", s, err)
+	}
+
+	switch u.Scheme {
+	case "tcp", "tcp4", "tcp6":
+		host, port, err := net.SplitHostPort(u.Host)
+		if err != nil {
+			return ListenAddress{}, fmt.Errorf("invalid listen address %q: %w", s, err)
+		}
+		if p, err := strconv.Atoi(port); err != nil || p < 0 || 65535 < p {
+			return ListenAddress{}, fmt.Errorf("invalid listen address %q: invalid port %q", s, port)
+		}
+		if host != "" && u.Scheme != "tcp" {
+			ip := net.ParseIP(host)
+			if ip != nil && (ip.To4() != nil) != (u.Scheme == "tcp4") {
+				return ListenAddress{}, fmt.Errorf("invalid listen address %q: %s is not a %s address", s, host, u.Scheme)
+			}
+		}
+		return ListenAddress{Network: u.Scheme, Address: u.Host}, nil
+	case "unix":
+		path := u.Path
+		if u.Host != "" {
+			// unix://relative/path.sock
+			path = u.Host + u.Path
+		}
+		if path == "" {
+			return ListenAddress{}, fmt.Errorf("invalid listen address %q: missing socket path", s)
+		}
+		return ListenAddress{Network: "unix", Address: path}, nil
+	}
+
+	return ListenAddress{}, fmt.Errorf("invalid listen address %q: unsupported scheme %q, expected tcp, tcp4, tcp6 or unix", s, u.Scheme)
+}
+
+func (a ListenAddress) String() string {
+	if a.Network == "unix" {
+		return "unix://" + a.Address
+	}
+	return a.Network + "://" + a.Address
+}
+
+// Port returns the port of a tcp address, or 0 for unix sockets and addresses without one.
+func (a ListenAddress) Port() int {
+	if a.Network == "unix" {
+		return 0
+	}
+	_, port, err := net.SplitHostPort(a.Address)
+	if err != nil {
+		return 0
+	}
+	p, _ := strconv.Atoi(port)
+	return p
+}
+
+// Listen listens on a.
+// Stale unix sockets left behind by a process that didn't get to clean up are removed first.
+func (a ListenAddress) Listen() (net.Listener, error) {
+	if a.Network == "unix" {
+		if err := removeStaleSocket(a.Address); err != nil {
+			return nil, err
+		}
+	}
+
+	l, err := net.Listen(a.Network, a.Address)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func removeStaleSocket(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("unable to listen on %s: file exists and is not a socket", path)
+	}
+
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("unable to listen on %s: socket is in use", path)
+	}
+
+	return os.Remove(path)
+}
+
+// MultiListener accepts connections from several listeners at once.
+type MultiListener struct {
+	listeners []net.Listener
+	conns     chan acceptResult
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type acceptResult struct {
+	conn net.Conn
+	err  error
+}
+
+// NewMultiListener returns a listener accepting connections from all of ls.
+// Its address is that of the first listener.
+func NewMultiListener(ls ...net.Listener) *MultiListener {
+	ml := MultiListener{
+		listeners: ls,
+		conns:     make(chan acceptResult),
+		done:      make(chan struct{}),
+	}
+	for _, l := range ls {
+		go ml.accept(l)
+	}
+	return &ml
+}
+
+// accept hands the connections accepted by l to Accept.
+// Temporary errors, such as running out of file descriptors, are retried with a backoff
+// the same way net/http.Server.Serve does, any other error is handed to Accept and ends the loop.
+func (ml *MultiListener) accept(l net.Listener) {
+	var backoff time.Duration
+	for {
+		conn, err := l.Accept()
+		if err != nil && isTemporary(err) {
+			if backoff == 0 {
+				backoff = 5 * time.Millisecond
+			} else if backoff *= 2; time.Second < backoff {
+				backoff = time.Second
+			}
+			select {
+			case <-time.After(backoff):
+				continue
+			case <-ml.done:
+				return
+			}
+		}
+		backoff = 0
+
+		select {
+		case ml.conns <- acceptResult{conn: conn, err: err}:
+		case <-ml.done:
+			if conn != nil {
+				conn.Close()
+			}
+			return
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// isTemporary reports whether accepting may succeed if tried again later.
+func isTemporary(err error) bool {
+	if errors.Is(err, syscall.EMFILE) || errors.Is(err, syscall.ENFILE) ||
+		errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var ne interface{ Temporary() bool }
+	return errors.As(err, &ne) && ne.Temporary()
+}
+
+func (ml *MultiListener) Accept() (net.Conn, error) {
+	select {
+	case r := <-ml.conns:
+		return r.conn, r.err
+	case <-ml.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close closes all of the listeners.
+func (ml *MultiListener) Close() error {
+	var errs []error
+	ml.closeOnce.Do(func() {
+		close(ml.done)
+		for _, l := range ml.listeners {
+			if err := l.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+				errs = append(errs, err)
+			}
+		}
+	})
+	return errors.Join(errs...)
+}
+
+func (ml *MultiListener) Addr() net.Addr {
+	return ml.listeners[0].Addr()
+}
+
+// Listeners returns the listeners being accepted from.
+func (ml *MultiListener) Listeners() []net.Listener {
+	return ml.listeners
+}
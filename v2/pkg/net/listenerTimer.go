@@ -2,19 +2,23 @@ package network
 
 import (
 	"net"
+	"sync"
 	"time"
 )
 
 type ListenerTimer struct {
 	net.Listener
-	timer *time.Timer
-	C     <-chan time.Time
+	mu       sync.Mutex
+	timer    *time.Timer
+	deadline time.Time
+	C        <-chan time.Time
 }
 
 func NewListenerTimer(l net.Listener, d time.Duration) *ListenerTimer {
 	var ll ListenerTimer
 	ll.Listener = l
 	ll.timer = time.NewTimer(d)
+	ll.deadline = time.Now().Add(d)
 	ll.C = ll.timer.C
 	return &ll
 }
@@ -22,6 +26,8 @@ func NewListenerTimer(l net.Listener, d time.Duration) *ListenerTimer {
 func (l *ListenerTimer) Accept() (net.Conn, error) {
 	conn, err := l.Listener.Accept()
 
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	if l.timer != nil && err == nil {
 		if !l.timer.Stop() {
 			<-l.timer.C
@@ -32,6 +38,29 @@ func (l *ListenerTimer) Accept() (net.Conn, error) {
 	return conn, err
 }
 
+// Extend pushes the deadline back by d.
+// The deadline can no longer be extended once a connection has been accepted or the timer has fired.
+func (l *ListenerTimer) Extend(d time.Duration) (time.Time, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.timer == nil || !l.timer.Stop() {
+		return time.Time{}, false
+	}
+	l.deadline = l.deadline.Add(d)
+	l.timer.Reset(time.Until(l.deadline))
+	return l.deadline, true
+}
+
+// Deadline returns when the timer fires, if it is still running.
+func (l *ListenerTimer) Deadline() (time.Time, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.timer == nil {
+		return time.Time{}, false
+	}
+	return l.deadline, true
+}
+
 func (l *ListenerTimer) Close() error {
 	return l.Listener.Close()
 }
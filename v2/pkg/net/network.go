@@ -1,8 +1,8 @@
 package network
 
 import (
-	"fmt"
 	"net"
+	"strconv"
 	"strings"
 	"time"
 
@@ -10,7 +10,8 @@ import (
 	"github.com/forestnode-io/oneshot/v2/pkg/log"
 )
 
-// HostAddresses returns all available ip addresses from all interfaces
+// HostAddresses returns all available ip addresses from all interfaces.
+// Link-local IPv6 addresses are left out since they can't be used without naming the interface.
 func HostAddresses() ([]string, error) {
 	ifaceAddrs, err := net.InterfaceAddrs()
 	if err != nil {
@@ -21,9 +22,6 @@ func HostAddresses() ([]string, error) {
 	// run the loop backwards so 127.0.0.1 ends up at the bottom of the list
 	for idx := len(ifaceAddrs) - 1; 0 <= idx; idx-- {
 		addr := ifaceAddrs[idx].String()
-		if strings.Contains(addr, "::") {
-			continue
-		}
 
 		parts := strings.Split(addr, "/")
 		ip := net.ParseIP(parts[0])
@@ -31,8 +29,7 @@ func HostAddresses() ([]string, error) {
 			continue
 		}
 
-		// Filter out IPv6 address
-		if ip.To4() == nil {
+		if ip.To4() == nil && ip.IsLinkLocalUnicast() {
 			continue
 		}
 
@@ -59,7 +56,7 @@ func GetSourceIP(target string, port int) (string, error) {
 		err  error
 	)
 	if 0 < port {
-		conn, err = net.Dial("udp", fmt.Sprintf("%s:%d", target, port))
+		conn, err = net.Dial("udp", net.JoinHostPort(target, strconv.Itoa(port)))
 	} else {
 		conn, err = net.Dial("udp", target)
 	}
@@ -120,18 +117,33 @@ func PreferNonPrivateIP(ips []string) (string, string) {
 	return preferredAddress.String(), port
 }
 
+// ipv6Probe is a public IPv6 address used to find out which local address routes to the IPv6 internet.
+// Nothing is sent to it.
+const ipv6Probe = "2001:4860:4860::8888"
+
+// GetSourceIP6 returns the IPv6 address used to reach the IPv6 internet.
+func GetSourceIP6() (string, error) {
+	conn, err := net.Dial("udp6", net.JoinHostPort(ipv6Probe, "80"))
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	return conn.LocalAddr().(*net.UDPAddr).IP.String(), nil
+}
+
 func AddressParts(add string) (string, string) {
-	parts := strings.Split(add, ":")
-	if len(parts) == 2 {
-		return parts[0], parts[1]
+	host, port, err := net.SplitHostPort(add)
+	if err != nil {
+		return add, ""
 	}
 
-	return parts[0], ""
+	return host, port
 }
 
 func IsAddressReachable(addr string) bool {
-	parts := strings.Split(addr, ":")
-	ip := net.ParseIP(parts[0])
+	host, _ := AddressParts(addr)
+	ip := net.ParseIP(host)
 	if ip != nil {
 		if ip.IsPrivate() {
 			return false
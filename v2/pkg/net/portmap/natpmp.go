@@ -0,0 +1,180 @@
+package portmap
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// GatewayPort is the port NAT-PMP and PCP servers listen on.
+const GatewayPort = 5351
+
+const (
+	natpmpVersion = 0
+
+	natpmpOpExternalAddress = 0
+	natpmpOpMapTCP          = 2
+	natpmpOpResponse        = 128
+)
+
+var natpmpResults = map[uint16]string{
+	1: "unsupported version",
+	2: "not authorized or refused",
+	3: "network failure",
+	4: "out of resources",
+	5: "unsupported opcode",
+}
+
+// NATPMP maps ports using NAT-PMP (RFC 6886).
+type NATPMP struct {
+	// Gateway is the address of the NAT-PMP server, usually the default gateway on port 5351.
+	Gateway string
+	// Timeout is how long to wait for the server to respond, 0 waits until ctx is done.
+	Timeout time.Duration
+}
+
+func (n *NATPMP) Protocol() string {
+	return ProtocolNATPMP
+}
+
+func (n *NATPMP) Map(ctx context.Context, internalPort, externalPort int, lifetime time.Duration) (*Mapping, error) {
+	ctx, cancel := withTimeout(ctx, n.Timeout)
+	defer cancel()
+
+	conn, err := dialGateway(ctx, n.Gateway)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	resp, err := exchange(ctx, conn, []byte{natpmpVersion, natpmpOpExternalAddress}, natpmpAccept(natpmpOpExternalAddress, 12))
+	if err != nil {
+		return nil, err
+	}
+	externalIP := net.IP(append([]byte(nil), resp[8:12]...))
+
+	resp, err = exchange(ctx, conn, natpmpMapRequest(internalPort, externalPort, lifetime), natpmpAccept(natpmpOpMapTCP, 16))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Mapping{
+		Protocol:     ProtocolNATPMP,
+		ExternalIP:   externalIP,
+		ExternalPort: int(binary.BigEndian.Uint16(resp[10:12])),
+		InternalPort: int(binary.BigEndian.Uint16(resp[8:10])),
+		Lifetime:     time.Duration(binary.BigEndian.Uint32(resp[12:16])) * time.Second,
+	}, nil
+}
+
+func (n *NATPMP) Unmap(ctx context.Context, m *Mapping) error {
+	ctx, cancel := withTimeout(ctx, n.Timeout)
+	defer cancel()
+
+	conn, err := dialGateway(ctx, n.Gateway)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// a lifetime and external port of 0 deletes the mapping
+	_, err = exchange(ctx, conn, natpmpMapRequest(m.InternalPort, 0, 0), natpmpAccept(natpmpOpMapTCP, 16))
+	return err
+}
+
+func natpmpMapRequest(internalPort, externalPort int, lifetime time.Duration) []byte {
+	req := make([]byte, 12)
+	req[0] = natpmpVersion
+	req[1] = natpmpOpMapTCP
+	binary.BigEndian.PutUint16(req[4:6], uint16(internalPort))
+	binary.BigEndian.PutUint16(req[6:8], uint16(externalPort))
+	binary.BigEndian.PutUint32(req[8:12], lifetimeSeconds(lifetime))
+	return req
+}
+
+// natpmpAccept returns a function accepting responses to op, which are turned into errors if they carry one.
+func natpmpAccept(op byte, size int) func([]byte) (bool, error) {
+	return func(resp []byte) (bool, error) {
+		if len(resp) < 4 || resp[1] != natpmpOpResponse+op {
+			return false, nil
+		}
+		if code := binary.BigEndian.Uint16(resp[2:4]); code != 0 {
+			return true, resultError("nat-pmp", natpmpResults, code)
+		}
+		if resp[0] != natpmpVersion || len(resp) < size {
+			return true, errors.New("malformed nat-pmp response")
+		}
+		return true, nil
+	}
+}
+
+func resultError(protocol string, results map[uint16]string, code uint16) error {
+	if msg, ok := results[code]; ok {
+		return fmt.Errorf("%s server responded with error: %s", protocol, msg)
+	}
+	return fmt.Errorf("%s server responded with error code %d", protocol, code)
+}
+
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func dialGateway(ctx context.Context, gateway string) (*net.UDPConn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", gateway)
+	if err != nil {
+		return nil, fmt.Errorf("unable to reach gateway %s: %w", gateway, err)
+	}
+	return conn.(*net.UDPConn), nil
+}
+
+// exchange sends req to the gateway until a response is accepted or ctx is done.
+// Requests are resent with the backoff described by RFC 6886, starting at 250ms and doubling each time.
+func exchange(ctx context.Context, conn *net.UDPConn, req []byte, accept func([]byte) (bool, error)) ([]byte, error) {
+	var (
+		buf     = make([]byte, 1100)
+		backoff = 250 * time.Millisecond
+	)
+
+	for {
+		if _, err := conn.Write(req); err != nil {
+			return nil, fmt.Errorf("error sending request to gateway: %w", err)
+		}
+
+		deadline := time.Now().Add(backoff)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		_ = conn.SetReadDeadline(deadline)
+
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				var ne net.Error
+				if errors.As(err, &ne) && ne.Timeout() {
+					break
+				}
+				// ICMP port unreachable surfaces here as a refused connection,
+				// meaning nothing on the gateway speaks the protocol
+				return nil, fmt.Errorf("error reading response from gateway: %w", err)
+			}
+			if ok, err := accept(buf[:n]); ok {
+				if err != nil {
+					return nil, err
+				}
+				return append([]byte(nil), buf[:n]...), nil
+			}
+		}
+
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("no response from gateway: %w", err)
+		}
+		backoff *= 2
+	}
+}
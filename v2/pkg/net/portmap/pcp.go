@@ -0,0 +1,157 @@
+package portmap
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	pcpVersion = 2
+
+	pcpOpMap       = 1
+	pcpOpResponse  = 0x80
+	pcpProtocolTCP = 6
+
+	pcpHeaderSize = 24
+	pcpMapSize    = 36
+)
+
+var pcpResults = map[uint16]string{
+	1:  "unsupported version",
+	2:  "not authorized",
+	3:  "malformed request",
+	4:  "unsupported opcode",
+	5:  "unsupported option",
+	6:  "malformed option",
+	7:  "network failure",
+	8:  "no resources",
+	9:  "unsupported protocol",
+	10: "user exceeded quota",
+	11: "cannot provide external address",
+	12: "address mismatch",
+	13: "excessive remote peers",
+}
+
+// PCPAnycast is the well known address of PCP servers reachable over IPv6 (RFC 7723).
+var PCPAnycast = net.JoinHostPort("2001:1::1", "5351")
+
+// PCP maps ports using the MAP opcode of PCP (RFC 6887).
+// When the server is reached over IPv6 the mapping is a pinhole through the gateways firewall
+// and the external address is the address of this host.
+type PCP struct {
+	// Gateway is the address of the PCP server, usually the default gateway on port 5351.
+	Gateway string
+	// Timeout is how long to wait for the server to respond, 0 waits until ctx is done.
+	Timeout time.Duration
+
+	nonce []byte
+}
+
+func (p *PCP) Protocol() string {
+	return ProtocolPCP
+}
+
+func (p *PCP) Map(ctx context.Context, internalPort, externalPort int, lifetime time.Duration) (*Mapping, error) {
+	ctx, cancel := withTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	conn, err := dialGateway(ctx, p.Gateway)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// mappings are identified by their nonce, reusing it renews the mapping
+	if p.nonce == nil {
+		p.nonce = make([]byte, 12)
+		if _, err := rand.Read(p.nonce); err != nil {
+			return nil, fmt.Errorf("unable to generate nonce: %w", err)
+		}
+	}
+
+	resp, err := exchange(ctx, conn, p.mapRequest(conn, internalPort, externalPort, lifetime), p.accept)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := resp[pcpHeaderSize:]
+	externalIP := net.IP(append([]byte(nil), payload[20:36]...))
+	if ip4 := externalIP.To4(); ip4 != nil {
+		externalIP = ip4
+	}
+
+	return &Mapping{
+		Protocol:     ProtocolPCP,
+		ExternalIP:   externalIP,
+		ExternalPort: int(binary.BigEndian.Uint16(payload[18:20])),
+		InternalPort: int(binary.BigEndian.Uint16(payload[16:18])),
+		Lifetime:     time.Duration(binary.BigEndian.Uint32(resp[4:8])) * time.Second,
+	}, nil
+}
+
+func (p *PCP) Unmap(ctx context.Context, m *Mapping) error {
+	if p.nonce == nil {
+		return nil
+	}
+
+	ctx, cancel := withTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	conn, err := dialGateway(ctx, p.Gateway)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// a lifetime of 0 deletes the mapping
+	_, err = exchange(ctx, conn, p.mapRequest(conn, m.InternalPort, 0, 0), p.accept)
+	return err
+}
+
+func (p *PCP) mapRequest(conn *net.UDPConn, internalPort, externalPort int, lifetime time.Duration) []byte {
+	req := make([]byte, pcpHeaderSize+pcpMapSize)
+	req[0] = pcpVersion
+	req[1] = pcpOpMap
+	binary.BigEndian.PutUint32(req[4:8], lifetimeSeconds(lifetime))
+	// the server checks the client address against the source address of the request
+	clientIP := conn.LocalAddr().(*net.UDPAddr).IP
+	copy(req[8:24], clientIP.To16())
+
+	payload := req[pcpHeaderSize:]
+	copy(payload[0:12], p.nonce)
+	payload[12] = pcpProtocolTCP
+	binary.BigEndian.PutUint16(payload[16:18], uint16(internalPort))
+	binary.BigEndian.PutUint16(payload[18:20], uint16(externalPort))
+	// let the server pick the external address, :: for IPv6 and ::ffff:0.0.0.0 for IPv4
+	if clientIP.To4() != nil {
+		copy(payload[20:36], net.IPv4zero.To16())
+	}
+
+	return req
+}
+
+func (p *PCP) accept(resp []byte) (bool, error) {
+	if len(resp) < 4 || resp[1] != pcpOpResponse|pcpOpMap {
+		return false, nil
+	}
+	// NAT-PMP only servers answer with their own version
+	if resp[0] != pcpVersion {
+		return true, errors.New("pcp server responded with error: unsupported version")
+	}
+	if code := uint16(resp[3]); code != 0 {
+		return true, resultError("pcp", pcpResults, code)
+	}
+	if len(resp) < pcpHeaderSize+pcpMapSize {
+		return true, errors.New("malformed pcp response")
+	}
+	// ignore responses to other mappings
+	if string(resp[pcpHeaderSize:pcpHeaderSize+12]) != string(p.nonce) {
+		return false, nil
+	}
+	return true, nil
+}
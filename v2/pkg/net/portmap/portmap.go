@@ -0,0 +1,144 @@
+// Package portmap forwards ports from a gateway to this host using UPnP-IGD, NAT-PMP or PCP.
+package portmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	ProtocolUPnP   = "upnp"
+	ProtocolNATPMP = "natpmp"
+	ProtocolPCP    = "pcp"
+)
+
+// Protocols lists the supported port mapping protocols in the order they are tried by default.
+var Protocols = []string{ProtocolUPnP, ProtocolNATPMP, ProtocolPCP}
+
+var (
+	ErrNoMapper = errors.New("no port mapping protocols to try")
+	// ErrExpired is returned by Renew when a mapping was allowed to lapse.
+	ErrExpired = errors.New("port mapping expired")
+)
+
+// Mapping is a tcp port on a gateway forwarded to a port on this host.
+// For IPv6 pinholes the external address is the address of this host.
+type Mapping struct {
+	Protocol     string
+	ExternalIP   net.IP
+	ExternalPort int
+	InternalPort int
+	// Lifetime is how long the gateway keeps the mapping for, which may differ from what was asked for.
+	Lifetime time.Duration
+}
+
+// ExternalAddress returns the host:port pair clients outside of the gateway connect to.
+func (m *Mapping) ExternalAddress() string {
+	return net.JoinHostPort(m.ExternalIP.String(), strconv.Itoa(m.ExternalPort))
+}
+
+// Mapper maps ports on a gateway using a single protocol.
+type Mapper interface {
+	// Protocol returns the name of the protocol used.
+	Protocol() string
+	// Map asks the gateway to forward externalPort to internalPort for lifetime.
+	// Mapping a port that is already mapped renews it.
+	Map(ctx context.Context, internalPort, externalPort int, lifetime time.Duration) (*Mapping, error)
+	// Unmap removes m from the gateway.
+	Unmap(ctx context.Context, m *Mapping) error
+}
+
+// MapPort tries each mapper in order until one of them maps the port.
+// The external port defaults to the internal port.
+func MapPort(ctx context.Context, mappers []Mapper, internalPort, externalPort int, lifetime time.Duration) (Mapper, *Mapping, error) {
+	if len(mappers) == 0 {
+		return nil, nil, ErrNoMapper
+	}
+	if externalPort == 0 {
+		externalPort = internalPort
+	}
+
+	var errs []error
+	for _, mapper := range mappers {
+		m, err := mapper.Map(ctx, internalPort, externalPort, lifetime)
+		if err == nil {
+			return mapper, m, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", mapper.Protocol(), err))
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return nil, nil, errors.Join(errs...)
+}
+
+// Renew keeps m mapped while active reports true, renewing it halfway through its lifetime.
+// If active reports false when the mapping expires, the mapping is left to lapse and Renew returns ErrExpired.
+// Renew returns nil once ctx is done.
+// renewed, if not nil, is called with each renewed mapping.
+func Renew(ctx context.Context, mapper Mapper, m *Mapping, lifetime time.Duration, active func() bool, renewed func(*Mapping)) error {
+	if m.Lifetime <= 0 {
+		// the gateway only hands out permanent mappings
+		<-ctx.Done()
+		return nil
+	}
+
+	var (
+		expires = time.Now().Add(m.Lifetime)
+		poll    = m.Lifetime / 4
+	)
+	if time.Second < poll {
+		poll = time.Second
+	}
+
+	timer := time.NewTimer(m.Lifetime / 2)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-timer.C:
+		}
+
+		now := time.Now()
+		if active() {
+			nm, err := mapper.Map(ctx, m.InternalPort, m.ExternalPort, lifetime)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return fmt.Errorf("failed to renew port mapping: %w", err)
+			}
+
+			m = nm
+			expires = now.Add(m.Lifetime)
+			if renewed != nil {
+				renewed(m)
+			}
+			timer.Reset(m.Lifetime / 2)
+			continue
+		}
+
+		if !now.Before(expires) {
+			return ErrExpired
+		}
+		// nothing is using the mapping, check back in case a client shows up before it expires
+		wait := poll
+		if left := expires.Sub(now); left < wait {
+			wait = left
+		}
+		timer.Reset(wait)
+	}
+}
+
+// lifetimeSeconds rounds d up to whole seconds as used on the wire by NAT-PMP and PCP.
+func lifetimeSeconds(d time.Duration) uint32 {
+	return uint32((d + time.Second - 1) / time.Second)
+}
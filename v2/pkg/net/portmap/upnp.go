@@ -0,0 +1,80 @@
+package portmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	upnpigd "github.com/forestnode-io/oneshot/v2/pkg/net/upnp-igd"
+)
+
+// UPnP maps ports using the first UPnP Internet Gateway Device found on the network.
+type UPnP struct {
+	// DiscoveryTimeout is how long to look for devices for.
+	DiscoveryTimeout time.Duration
+	Client           *http.Client
+
+	dev *upnpigd.Device
+}
+
+func (u *UPnP) Protocol() string {
+	return ProtocolUPnP
+}
+
+func (u *UPnP) Map(ctx context.Context, internalPort, externalPort int, lifetime time.Duration) (*Mapping, error) {
+	if u.dev == nil {
+		if err := u.discover(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := u.dev.AddPortMapping(ctx, "TCP", externalPort, internalPort, "oneshot", lifetime); err != nil {
+		return nil, fmt.Errorf("failed to add port mapping: %w", err)
+	}
+
+	externalIP, err := u.dev.GetExternalIP(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get external address: %w", err)
+	}
+
+	return &Mapping{
+		Protocol:     ProtocolUPnP,
+		ExternalIP:   externalIP,
+		ExternalPort: externalPort,
+		InternalPort: internalPort,
+		Lifetime:     lifetime,
+	}, nil
+}
+
+func (u *UPnP) Unmap(ctx context.Context, m *Mapping) error {
+	if u.dev == nil {
+		return nil
+	}
+	return u.dev.DeletePortMapping(ctx, "TCP", m.ExternalPort)
+}
+
+func (u *UPnP) discover(ctx context.Context) error {
+	client := u.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	devChan, err := upnpigd.Discover(ctx, "oneshot", u.DiscoveryTimeout, client)
+	if err != nil {
+		return fmt.Errorf("failed to discover UPnP IGD: %w", err)
+	}
+
+	// drain the channel so discovery can finish
+	for dev := range devChan {
+		if u.dev == nil {
+			u.dev = dev
+		}
+	}
+
+	if u.dev == nil {
+		return errors.New("no UPnP IGD devices found")
+	}
+	return nil
+}
@@ -0,0 +1,324 @@
+package tunnel
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/net/webrtc/signallingserver"
+	"github.com/forestnode-io/oneshot/v2/pkg/net/webrtc/signallingserver/messages"
+)
+
+// relayBufferSize is the most a relayed connection sends to the discovery server in a single message.
+const relayBufferSize = 32 * 1024
+
+// Relay has the discovery server relay the connections made to a url it assigns.
+// Each connection is carried over the stream to the discovery server, no port needs to be reachable from outside.
+type Relay struct {
+	Config signallingserver.DiscoveryServerConfig
+	// URL is the url to ask the discovery server for, if any.
+	URL *messages.SessionURLRequest
+}
+
+func (r *Relay) Name() string {
+	return ProviderDiscovery
+}
+
+func (r *Relay) Open(ctx context.Context) (Tunnel, error) {
+	// the stream outlives ctx, only opening it is bound by ctx
+	sctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, cancel)
+	ds, url, err := signallingserver.OpenRelay(sctx, r.Config, r.URL)
+	if !stop() {
+		if err == nil {
+			ds.Close()
+		}
+		cancel()
+		return nil, fmt.Errorf("failed to open relay: %w", ctx.Err())
+	}
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to open relay: %w", err)
+	}
+
+	t := relayTunnel{
+		ds:      ds,
+		url:     url,
+		cancel:  cancel,
+		conns:   make(map[string]*relayConn),
+		accept:  make(chan net.Conn),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go t.receive()
+
+	return &t, nil
+}
+
+type relayTunnel struct {
+	ds     *signallingserver.DiscoveryServer
+	url    string
+	cancel func()
+
+	sendMu sync.Mutex
+
+	mu    sync.Mutex
+	conns map[string]*relayConn
+
+	accept chan net.Conn
+	// closing is closed once the relay stops accepting connections
+	closing     chan struct{}
+	closingOnce sync.Once
+	// done is closed once the relay is closed
+	done      chan struct{}
+	err       error
+	closeOnce sync.Once
+}
+
+func (t *relayTunnel) URL() string {
+	return t.url
+}
+
+func (t *relayTunnel) Addr() net.Addr {
+	return relayAddr(t.url)
+}
+
+func (t *relayTunnel) Accept() (net.Conn, error) {
+	select {
+	case c := <-t.accept:
+		return c, nil
+	case <-t.closing:
+		return nil, net.ErrClosed
+	case <-t.done:
+		return nil, t.err
+	}
+}
+
+// Close stops accepting connections, the relay stays open until the connections already accepted are closed.
+func (t *relayTunnel) Close() error {
+	t.closingOnce.Do(func() {
+		close(t.closing)
+	})
+
+	t.mu.Lock()
+	idle := len(t.conns) == 0
+	t.mu.Unlock()
+	if idle {
+		t.stop(net.ErrClosed)
+	}
+	return nil
+}
+
+// remove forgets the connection with id, closing the relay if it was the last connection of a closing relay.
+// It reports whether the connection was still open.
+func (t *relayTunnel) remove(id string) bool {
+	t.mu.Lock()
+	_, open := t.conns[id]
+	delete(t.conns, id)
+	idle := len(t.conns) == 0
+	t.mu.Unlock()
+
+	if idle {
+		select {
+		case <-t.closing:
+			t.stop(net.ErrClosed)
+		default:
+		}
+	}
+	return open
+}
+
+// stop closes the relay and every relayed connection, Accept returns err from then on.
+func (t *relayTunnel) stop(err error) {
+	t.closeOnce.Do(func() {
+		t.err = err
+		close(t.done)
+		t.ds.Close()
+		t.cancel()
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		for id, c := range t.conns {
+			c.close()
+			delete(t.conns, id)
+		}
+	})
+}
+
+func (t *relayTunnel) send(m messages.Message) error {
+	t.sendMu.Lock()
+	defer t.sendMu.Unlock()
+	return signallingserver.Send(t.ds, m)
+}
+
+func (t *relayTunnel) receive() {
+	for {
+		m, err := signallingserver.Receive[messages.Message](t.ds)
+		if err != nil {
+			select {
+			case <-t.done:
+			default:
+				t.stop(fmt.Errorf("relay closed: %w", err))
+			}
+			return
+		}
+
+		switch m := m.(type) {
+		case *messages.RelayOpen:
+			t.open(m)
+		case *messages.RelayData:
+			t.mu.Lock()
+			c := t.conns[m.ConnectionID]
+			t.mu.Unlock()
+			if c != nil {
+				c.write(m.Data)
+			}
+		case *messages.RelayClose:
+			t.mu.Lock()
+			c := t.conns[m.ConnectionID]
+			t.mu.Unlock()
+			if c != nil {
+				c.remoteClosed.Store(true)
+				c.closeWrite()
+			}
+		}
+	}
+}
+
+func (t *relayTunnel) open(m *messages.RelayOpen) {
+	select {
+	case <-t.closing:
+		_ = t.send(&messages.RelayClose{ConnectionID: m.ConnectionID})
+		return
+	default:
+	}
+
+	server, relay := net.Pipe()
+	c := relayConn{
+		id:    m.ConnectionID,
+		relay: relay,
+		in:    make(chan []byte, 16),
+		done:  make(chan struct{}),
+	}
+
+	t.mu.Lock()
+	t.conns[c.id] = &c
+	t.mu.Unlock()
+
+	go c.pumpIn()
+	go t.pumpOut(&c)
+
+	conn := relayedConn{
+		Conn:       server,
+		localAddr:  relayAddr(t.url),
+		remoteAddr: relayAddr(m.RemoteAddr),
+	}
+	select {
+	case t.accept <- &conn:
+	case <-t.closing:
+		server.Close()
+	case <-t.done:
+		server.Close()
+	}
+}
+
+// pumpOut sends what the server writes to the discovery server until either end closes the connection.
+func (t *relayTunnel) pumpOut(c *relayConn) {
+	buf := make([]byte, relayBufferSize)
+	for {
+		n, err := c.relay.Read(buf)
+		if 0 < n {
+			data := append([]byte(nil), buf[:n]...)
+			if serr := t.send(&messages.RelayData{ConnectionID: c.id, Data: data}); serr != nil {
+				err = serr
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	c.close()
+	if t.remove(c.id) && !c.remoteClosed.Load() {
+		_ = t.send(&messages.RelayClose{ConnectionID: c.id})
+	}
+}
+
+// relayConn is the relays end of a relayed connection.
+type relayConn struct {
+	id    string
+	relay net.Conn
+	in    chan []byte
+	// remoteClosed is set once the discovery server closed the connection
+	remoteClosed atomic.Bool
+
+	inOnce   sync.Once
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+// pumpIn hands what the client sent to the server.
+func (c *relayConn) pumpIn() {
+	defer c.close()
+	for {
+		select {
+		case data, ok := <-c.in:
+			if !ok {
+				return
+			}
+			if _, err := c.relay.Write(data); err != nil {
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *relayConn) write(data []byte) {
+	select {
+	case c.in <- data:
+	case <-c.done:
+	}
+}
+
+// closeWrite closes the connection once the server has read what the client sent.
+func (c *relayConn) closeWrite() {
+	c.inOnce.Do(func() {
+		close(c.in)
+	})
+}
+
+func (c *relayConn) close() {
+	c.doneOnce.Do(func() {
+		close(c.done)
+		c.relay.Close()
+	})
+}
+
+// relayedConn is the servers end of a relayed connection.
+type relayedConn struct {
+	net.Conn
+	localAddr  net.Addr
+	remoteAddr net.Addr
+}
+
+func (c *relayedConn) LocalAddr() net.Addr {
+	return c.localAddr
+}
+
+func (c *relayedConn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+type relayAddr string
+
+func (a relayAddr) Network() string {
+	return "relay"
+}
+
+func (a relayAddr) String() string {
+	return string(a)
+}
@@ -0,0 +1,272 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SSH opens a reverse tunnel on an ssh server, the same as ssh -R does.
+// The ssh server has to allow remote port forwarding, and GatewayPorts for connections from other hosts.
+type SSH struct {
+	// Host is the ssh server as [user@]host[:port].
+	Host string
+	// IdentityFile is the private key to authenticate with.
+	// Defaults to the keys of a running ssh-agent and the default keys in ~/.ssh.
+	IdentityFile string
+	// KnownHostsFile holds the host keys the ssh server is checked against, defaults to ~/.ssh/known_hosts.
+	KnownHostsFile string
+	// InsecureIgnoreHostKey skips checking the ssh servers host key.
+	InsecureIgnoreHostKey bool
+	// RemoteAddress is the address the ssh server listens on.
+	// Port 0 lets the ssh server pick the port.
+	RemoteAddress string
+	// Scheme is the scheme of the tunnels url, http or https.
+	Scheme  string
+	Timeout time.Duration
+}
+
+func (s *SSH) Name() string {
+	return ProviderSSH
+}
+
+func (s *SSH) Open(ctx context.Context) (Tunnel, error) {
+	username, addr, err := splitSSHHost(s.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	auth, err := s.authMethods()
+	if err != nil {
+		return nil, err
+	}
+	hostKeyCallback, err := s.hostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+
+	d := net.Dialer{Timeout: s.Timeout}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to ssh server: %w", err)
+	}
+
+	// the ssh handshake does not take a context, bound it with a deadline instead
+	deadline, ok := ctx.Deadline()
+	if !ok && 0 < s.Timeout {
+		deadline = time.Now().Add(s.Timeout)
+	}
+	_ = conn.SetDeadline(deadline)
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, &ssh.ClientConfig{
+		User:            username,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to establish ssh connection: %w", err)
+	}
+	client := ssh.NewClient(c, chans, reqs)
+
+	remote := s.RemoteAddress
+	if remote == "" {
+		remote = "0.0.0.0:0"
+	}
+	l, err := client.Listen("tcp", remote)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("ssh server refused to forward %s: %w", remote, err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	// connections made to a wildcard address are reached through the ssh servers host name
+	host, _, _ := net.SplitHostPort(addr)
+	if tcpAddr, ok := l.Addr().(*net.TCPAddr); ok {
+		if ip := tcpAddr.IP; ip != nil && !ip.IsUnspecified() && !ip.IsLoopback() {
+			host = ip.String()
+		}
+		host = net.JoinHostPort(host, strconv.Itoa(tcpAddr.Port))
+	}
+	scheme := s.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	return &sshTunnel{
+		Listener: l,
+		client:   client,
+		url:      fmt.Sprintf("%s://%s", scheme, host),
+	}, nil
+}
+
+func (s *SSH) authMethods() ([]ssh.AuthMethod, error) {
+	if s.IdentityFile != "" {
+		signer, err := readPrivateKey(s.IdentityFile)
+		if err != nil {
+			return nil, err
+		}
+		return []ssh.AuthMethod{ssh.PublicKeys(signer)}, nil
+	}
+
+	var methods []ssh.AuthMethod
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		var signers []ssh.Signer
+		for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+			// keys that need a passphrase are only usable through the agent
+			if signer, err := readPrivateKey(filepath.Join(home, ".ssh", name)); err == nil {
+				signers = append(signers, signer)
+			}
+		}
+		if 0 < len(signers) {
+			methods = append(methods, ssh.PublicKeys(signers...))
+		}
+	}
+
+	if len(methods) == 0 {
+		return nil, errors.New("no ssh keys found, set an identity file or run an ssh-agent")
+	}
+	return methods, nil
+}
+
+func (s *SSH) hostKeyCallback() (ssh.HostKeyCallback, error) {
+	if s.InsecureIgnoreHostKey {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	path := s.KnownHostsFile
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("unable to find known hosts file: %w", err)
+		}
+		path = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	callback, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read known hosts file: %w", err)
+	}
+	return callback, nil
+}
+
+func readPrivateKey(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ssh identity file: %w", err)
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	if err != nil {
+		var pme *ssh.PassphraseMissingError
+		if errors.As(err, &pme) {
+			return nil, fmt.Errorf("ssh identity file %s is passphrase protected, add it to an ssh-agent instead", path)
+		}
+		return nil, fmt.Errorf("failed to parse ssh identity file: %w", err)
+	}
+	return signer, nil
+}
+
+// splitSSHHost splits [user@]host[:port] into the user name and the address to dial.
+// The user defaults to the current user and the port to 22.
+func splitSSHHost(s string) (string, string, error) {
+	if s == "" {
+		return "", "", errors.New("no ssh server given")
+	}
+
+	username, host, ok := strings.Cut(s, "@")
+	if !ok {
+		host = username
+		u, err := user.Current()
+		if err != nil {
+			return "", "", fmt.Errorf("unable to get current user: %w", err)
+		}
+		username = u.Username
+	}
+
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(strings.Trim(host, "[]"), "22")
+	}
+	return username, host, nil
+}
+
+// sshTunnel closes the ssh connection once the listener and every connection accepted from it are closed.
+type sshTunnel struct {
+	net.Listener
+	client *ssh.Client
+	url    string
+
+	mu     sync.Mutex
+	conns  int
+	closed bool
+}
+
+func (t *sshTunnel) URL() string {
+	return t.url
+}
+
+func (t *sshTunnel) Accept() (net.Conn, error) {
+	conn, err := t.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	t.conns++
+	t.mu.Unlock()
+
+	return &sshConn{Conn: conn, t: t}, nil
+}
+
+// Close stops accepting connections, the connections already accepted are left open.
+func (t *sshTunnel) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+
+	err := t.Listener.Close()
+	if t.conns == 0 {
+		t.client.Close()
+	}
+	return err
+}
+
+func (t *sshTunnel) connClosed() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.conns--
+	if t.closed && t.conns == 0 {
+		t.client.Close()
+	}
+}
+
+type sshConn struct {
+	net.Conn
+	t         *sshTunnel
+	closeOnce sync.Once
+}
+
+func (c *sshConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(c.t.connClosed)
+	return err
+}
@@ -0,0 +1,30 @@
+// Package tunnel exposes oneshot at a public url by having a public host forward the connections made to it back to this host.
+package tunnel
+
+import (
+	"context"
+	"net"
+)
+
+const (
+	ProviderSSH       = "ssh"
+	ProviderDiscovery = "discovery"
+)
+
+// Providers lists the supported tunnel providers.
+var Providers = []string{ProviderSSH, ProviderDiscovery}
+
+// Tunnel accepts the connections made to its public url.
+type Tunnel interface {
+	net.Listener
+	// URL returns the url clients use to reach this host through the tunnel.
+	URL() string
+}
+
+// Provider opens tunnels through a single kind of public host.
+type Provider interface {
+	// Name returns the name of the provider.
+	Name() string
+	// Open opens a tunnel, ctx only bounds how long opening it takes.
+	Open(ctx context.Context) (Tunnel, error)
+}
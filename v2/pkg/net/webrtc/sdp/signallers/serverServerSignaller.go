@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/forestnode-io/oneshot/v2/pkg/events"
 	"github.com/forestnode-io/oneshot/v2/pkg/net/webrtc/sdp"
 	"github.com/forestnode-io/oneshot/v2/pkg/net/webrtc/signallingserver"
 	"github.com/forestnode-io/oneshot/v2/pkg/net/webrtc/signallingserver/messages"
@@ -70,8 +71,8 @@ func (s *serverServerSignaller) Start(ctx context.Context, handler RequestHandle
 		log.Debug().
 			Msg("got offer request from discovery server")
 
-		// get the offer
-		if err := handler.HandleRequest(ctx, gor.SessionID, gor.Configuration, s.answerOffer); err != nil {
+		// get the offer, the client gets the permissions of whoever the discovery server authenticated them as
+		if err := handler.HandleRequest(events.WithUser(ctx, gor.User), gor.SessionID, gor.Configuration, s.answerOffer); err != nil {
 			log.Error().Err(err).
 				Msg("error handling offer request")
 
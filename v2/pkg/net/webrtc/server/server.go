@@ -7,26 +7,37 @@ import (
 	"sync"
 	"time"
 
+	"github.com/forestnode-io/oneshot/v2/pkg/events"
 	"github.com/forestnode-io/oneshot/v2/pkg/net/webrtc/sdp/signallers"
 	"github.com/pion/webrtc/v3"
 )
 
+// TokenIssuer hands out the tokens clients authenticate their requests with.
+type TokenIssuer interface {
+	// Issue returns a new token for user, or an empty string if no token is needed.
+	Issue(user string) string
+	// Revoke stops token from authenticating any more requests.
+	Revoke(token string)
+}
+
 // Server satisfies the sdp.RequestHandler interface.
 // Server acts as a factory for new peer connections when a client request comes in.
 type Server struct {
 	handler          http.HandlerFunc
 	config           *webrtc.Configuration
 	wg               sync.WaitGroup
-	basicAuthToken   string
+	tokens           TokenIssuer
 	iceGatherTimeout time.Duration
 }
 
-func NewServer(config *webrtc.Configuration, bat string, iceGatherTimeout time.Duration, handler http.HandlerFunc) *Server {
+// NewServer returns a Server that serves handler to each client that connects.
+// Clients are given a token from tokens for the user the signaller authenticated them as, see events.UserFromContext.
+func NewServer(config *webrtc.Configuration, tokens TokenIssuer, iceGatherTimeout time.Duration, handler http.HandlerFunc) *Server {
 	return &Server{
 		handler:          handler,
 		config:           config,
 		wg:               sync.WaitGroup{},
-		basicAuthToken:   bat,
+		tokens:           tokens,
 		iceGatherTimeout: iceGatherTimeout,
 	}
 }
@@ -42,9 +53,15 @@ func (s *Server) HandleRequest(ctx context.Context, id string, conf *webrtc.Conf
 	if conf == nil {
 		conf = s.config
 	}
+	var bat string
+	if s.tokens != nil {
+		bat = s.tokens.Issue(events.UserFromContext(ctx))
+		defer s.tokens.Revoke(bat)
+	}
+
 	// create a new peer connection.
 	// newPeerConnection does not wait for the peer connection to be established.
-	pc, pcErrs := newPeerConnection(ctx, id, s.basicAuthToken, s.iceGatherTimeout, answerOfferFunc, conf)
+	pc, pcErrs := newPeerConnection(ctx, id, bat, s.iceGatherTimeout, answerOfferFunc, conf)
 	if pc == nil {
 		err := <-pcErrs
 		err = fmt.Errorf("unable to create new webRTC peer connection: %w", err)
@@ -101,6 +101,94 @@ func ConnectToDiscoveryServer(ctx context.Context, c DiscoveryServerConfig) erro
 	return nil
 }
 
+// Handshake connects to the discovery server, exchanges version information and disconnects.
+// It checks that the discovery server is reachable and accepts the key without arriving.
+func Handshake(ctx context.Context, c DiscoveryServerConfig) (*messages.VersionInfo, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ds, vi, err := handshake(ctx, &c)
+	if err != nil {
+		return nil, err
+	}
+	defer ds.Close()
+
+	return vi, nil
+}
+
+// OpenRelay connects to the discovery server on a stream of its own and asks it to relay
+// the connections made to a public url over that stream.
+// The stream stays open until ctx is done or the returned DiscoveryServer is closed.
+func OpenRelay(ctx context.Context, c DiscoveryServerConfig, url *messages.SessionURLRequest) (*DiscoveryServer, string, error) {
+	ds, vi, err := handshake(ctx, &c)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if semver.Compare(vi.APIVersion, c.VersionInfo.APIVersion) < 0 {
+		ds.Close()
+		return nil, "", fmt.Errorf("discovery server is running an older version of the API (%s) than this client (%s)", vi.APIVersion, c.VersionInfo.APIVersion)
+	}
+
+	if err := Send(ds, &messages.RelayRequest{URL: url}); err != nil {
+		ds.Close()
+		return nil, "", fmt.Errorf("failed to send relay request to discovery server: %w", err)
+	}
+	rr, err := Receive[*messages.RelayResponse](ds)
+	if err != nil {
+		ds.Close()
+		return nil, "", fmt.Errorf("failed to receive relay response from discovery server: %w", err)
+	}
+	if rr.Error != "" {
+		ds.Close()
+		return nil, "", fmt.Errorf("discovery server returned error: %s", rr.Error)
+	}
+	if rr.URL == "" {
+		ds.Close()
+		return nil, "", errors.New("discovery server did not assign a relay URL")
+	}
+
+	return ds, rr.URL, nil
+}
+
+// handshake dials the discovery server and exchanges version information on a new stream.
+// The stream is independent of oneshot arriving at the discovery server, c is always treated as enabled.
+func handshake(ctx context.Context, c *DiscoveryServerConfig) (*DiscoveryServer, *messages.VersionInfo, error) {
+	c.Enabled = true
+
+	conn, err := getConnectionToDiscoveryServer(ctx, c)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to discovery server: %w", err)
+	}
+
+	ds, err := newDiscoveryServer(ctx, c, conn)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	err = Send(ds, &messages.Handshake{
+		ID:          c.Key,
+		VersionInfo: c.VersionInfo,
+	})
+	if err != nil {
+		ds.Close()
+		return nil, nil, fmt.Errorf("failed to send handshake to discovery server: %w", err)
+	}
+
+	hs, err := Receive[*messages.Handshake](ds)
+	if err != nil {
+		ds.Close()
+		return nil, nil, fmt.Errorf("failed to receive handshake from discovery server: %w", err)
+	}
+	if hs.Error != "" {
+		ds.Close()
+		return nil, nil, fmt.Errorf("discovery server returned error: %s", hs.Error)
+	}
+
+	return ds, &hs.VersionInfo, nil
+}
+
 func SendArrivalToDiscoveryServer(ctx context.Context, arrival *messages.ServerArrivalRequest) error {
 	ds := GetDiscoveryServer(ctx)
 	if ds == nil {
@@ -130,7 +218,8 @@ func GetDiscoveryServer(ctx context.Context) *DiscoveryServer {
 
 func SendReportToDiscoveryServer(ctx context.Context, report *messages.Report) {
 	ds := GetDiscoveryServer(ctx)
-	if ds == nil {
+	// client commands never connect to the discovery server
+	if ds == nil || ds.config == nil {
 		return
 	}
 
@@ -29,6 +29,15 @@ func (h *Handshake) Type() string {
 type BasicAuth struct {
 	UsernameHash []byte
 	PasswordHash []byte
+	// Users are the users of an htpasswd file, when one is in use.
+	Users []*BasicAuthUser `json:",omitempty"`
+}
+
+// BasicAuthUser holds the sha256 hash of a username
+// and the bcrypt or argon2 hash of their password.
+type BasicAuthUser struct {
+	UsernameHash []byte
+	PasswordHash []byte
 }
 
 type SessionURLRequest struct {
@@ -86,6 +95,8 @@ func (a *AnswerOfferResponse) Type() string {
 type GetOfferRequest struct {
 	SessionID     string
 	Configuration *webrtc.Configuration `json:",omitempty"`
+	// User is the username the client authenticated with, if any.
+	User string `json:",omitempty"`
 }
 
 func (g *GetOfferRequest) Type() string {
@@ -156,6 +167,55 @@ func (u *UpdatePingRateRequest) Type() string {
 	return "UpdatePingRateRequest"
 }
 
+// sent from the oneshot server to the signalling server after VersionInfo has been exchanged,
+// asks the signalling server to relay the connections made to a public url over this stream
+type RelayRequest struct {
+	URL *SessionURLRequest
+}
+
+func (r *RelayRequest) Type() string {
+	return "RelayRequest"
+}
+
+// sent from the signalling server to the oneshot server in response to a RelayRequest
+type RelayResponse struct {
+	URL   string
+	Error string
+}
+
+func (r *RelayResponse) Type() string {
+	return "RelayResponse"
+}
+
+// sent from the signalling server to the oneshot server when a client connects to the relay url
+type RelayOpen struct {
+	ConnectionID string
+	RemoteAddr   string
+}
+
+func (r *RelayOpen) Type() string {
+	return "RelayOpen"
+}
+
+// sent both ways, carries the bytes of a relayed connection
+type RelayData struct {
+	ConnectionID string
+	Data         []byte
+}
+
+func (r *RelayData) Type() string {
+	return "RelayData"
+}
+
+// sent both ways when either end of a relayed connection closes it
+type RelayClose struct {
+	ConnectionID string
+}
+
+func (r *RelayClose) Type() string {
+	return "RelayClose"
+}
+
 type HTTPRequest struct {
 	Method     string              `json:",omitempty"`
 	RequestURI string              `json:",omitempty"`
@@ -166,6 +226,7 @@ type HTTPRequest struct {
 	Host       string              `json:",omitempty"`
 	Trailer    map[string][]string `json:",omitempty"`
 	RemoteAddr string              `json:",omitempty"`
+	User       string              `json:",omitempty"`
 }
 
 func HTTPRequestFromEvent(r *events.HTTPRequest) *HTTPRequest {
@@ -183,6 +244,7 @@ func HTTPRequestFromEvent(r *events.HTTPRequest) *HTTPRequest {
 		Host:       r.Host,
 		Trailer:    r.Trailer,
 		RemoteAddr: r.RemoteAddr,
+		User:       r.User,
 	}
 
 	return &httpr
@@ -58,6 +58,26 @@ func Unmarshal(typeName string, data []byte) (Message, error) {
 		var s FinishedSessionRequest
 		err := json.Unmarshal(data, &s)
 		return &s, err
+	case "RelayRequest":
+		var r RelayRequest
+		err := json.Unmarshal(data, &r)
+		return &r, err
+	case "RelayResponse":
+		var r RelayResponse
+		err := json.Unmarshal(data, &r)
+		return &r, err
+	case "RelayOpen":
+		var r RelayOpen
+		err := json.Unmarshal(data, &r)
+		return &r, err
+	case "RelayData":
+		var r RelayData
+		err := json.Unmarshal(data, &r)
+		return &r, err
+	case "RelayClose":
+		var r RelayClose
+		err := json.Unmarshal(data, &r)
+		return &r, err
 	}
 
 	return nil, fmt.Errorf("unknown message type: %s", typeName)
@@ -8,6 +8,7 @@ import (
 	"os"
 
 	"github.com/forestnode-io/oneshot/v2/pkg/output"
+	"github.com/forestnode-io/oneshot/v2/pkg/secrets"
 	"github.com/pion/datachannel"
 	"github.com/pion/webrtc/v3"
 )
@@ -35,7 +36,7 @@ func (r *DataChannelByteReader) Read(p []byte) (int, error) {
 type ICEServer struct {
 	URLs                  []string `yaml:"urls" mapstructure:"urls"`
 	Username              string   `yaml:"username" mapstructure:"username"`
-	Credential            []byte   `yaml:"credential" mapstructure:"credential"`
+	Credential            []byte   `yaml:"credential" mapstructure:"credential" json:"-"`
 	CredentialPath        string   `yaml:"credentialPath" mapstructure:"credentialPath"`
 	CredentialTypeIsOAuth bool     `yaml:"credentialTypeIsOAuth" mapstructure:"credentialTypeIsOAuth"`
 }
@@ -69,11 +70,17 @@ func (c *Configuration) WebRTCConfiguration() (*webrtc.Configuration, error) {
 			return nil, output.UsageErrorF("no URLs configured for ICE server")
 		}
 		if s.CredentialPath != "" {
-			data, err := os.ReadFile(s.CredentialPath)
+			credential, err := secrets.ResolvePath(s.CredentialPath)
 			if err != nil {
 				return nil, fmt.Errorf("failed to read credential path: %w", err)
 			}
-			s.Credential = data
+			s.Credential = []byte(credential)
+		} else if 0 < len(s.Credential) {
+			credential, err := secrets.Resolve(string(s.Credential))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read credential: %w", err)
+			}
+			s.Credential = []byte(credential)
 		}
 
 		config.ICEServers = append(config.ICEServers, webrtc.ICEServer{
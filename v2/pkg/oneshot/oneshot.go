@@ -0,0 +1,240 @@
+// Package oneshot runs oneshot servers from within other Go programs.
+//
+// Each call runs its own server with its own events. Nothing is read from the
+// oneshot configuration file, environment variables or flags; the options given
+// are all that is used.
+package oneshot
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/events"
+	oneshotnet "github.com/forestnode-io/oneshot/v2/pkg/net"
+	oneshothttp "github.com/forestnode-io/oneshot/v2/pkg/net/http"
+	oneshotfmt "github.com/forestnode-io/oneshot/v2/pkg/output/fmt"
+)
+
+// DefaultAddr is where servers listen when no address is given, the same as the oneshot command.
+const DefaultAddr = ":8080"
+
+// Event is anything raised while a server runs, see the events package for the types of events.
+type Event = events.Event
+
+// Options configures the server common to every oneshot function.
+type Options struct {
+	// Addr is the address to listen on, in any of the forms accepted by the --listen flag.
+	// Defaults to DefaultAddr.
+	Addr string
+	// Listener, if not nil, is served instead of listening on Addr.
+	// It is closed once the server shuts down.
+	Listener net.Listener
+
+	// Timeout is how long to wait for a client to connect before giving up, 0 waits forever.
+	Timeout time.Duration
+	// ExitOnFail shuts the server down after the first client fails instead of waiting for another.
+	ExitOnFail bool
+	// AllowBots lets bots and link previewers through instead of turning them away.
+	AllowBots bool
+	// MaxReadSize limits how many bytes are read from a request body, 0 is unlimited.
+	MaxReadSize int64
+
+	TLSCert, TLSKey string
+
+	// Username and Password, if either is set, require clients to use basic authentication.
+	Username, Password string
+
+	// Events, if not nil, is sent every event raised while the server runs and is closed once it is done.
+	// Events must be read from until it is closed, the server waits for each event to be received.
+	Events chan<- Event
+}
+
+// Result describes how a server run went.
+type Result struct {
+	// Reason is why the server shut down, one of the events.Shutdown reasons.
+	Reason string
+	// Request is the last request a client made.
+	Request *events.HTTPRequest
+	// File describes the last file transferred, if any.
+	File *events.File
+}
+
+// Succeeded reports whether a client was served successfully.
+func (r *Result) Succeeded() bool {
+	return r.Reason == events.ShutdownSuccess
+}
+
+// IgnoreRequest stops the request w answers from counting towards the outcome of the run,
+// whether it succeeds or fails. It is a noop for writers not given by a oneshot server.
+func IgnoreRequest(w http.ResponseWriter) {
+	if rw, ok := w.(oneshothttp.ResponseWriter); ok {
+		rw.IgnoreOutcome()
+	}
+}
+
+func run(ctx context.Context, opts *Options, handler http.HandlerFunc) (*Result, error) {
+	l, err := opts.listen()
+	if err != nil {
+		return nil, err
+	}
+	return serve(ctx, opts, l, handler)
+}
+
+func (o *Options) listen() (net.Listener, error) {
+	if o.Listener != nil {
+		return o.Listener, nil
+	}
+
+	addr := o.Addr
+	if addr == "" {
+		addr = DefaultAddr
+	}
+	la, err := oneshotnet.ParseListenAddress(addr)
+	if err != nil {
+		return nil, err
+	}
+	return la.Listen()
+}
+
+// serve serves handler on l until a client is served successfully, the timeout passes or ctx is done.
+// Handlers are given requests whose context carries the events of the run.
+func serve(ctx context.Context, opts *Options, l net.Listener, handler http.HandlerFunc) (*Result, error) {
+	defer l.Close()
+
+	// events outlive the parent context so that the shutdown event makes it out when it is done
+	parent := ctx
+	ctx, cancel := context.WithCancel(events.WithEvents(context.WithoutCancel(parent)))
+	defer cancel()
+	defer context.AfterFunc(parent, cancel)()
+
+	var (
+		result    Result
+		collected = make(chan struct{})
+		sub       = events.Subscribe(ctx, events.SubscribeOptions{
+			Buffer: 16,
+			Policy: events.Block,
+		})
+	)
+	go func() {
+		defer close(collected)
+		if opts.Events != nil {
+			defer close(opts.Events)
+		}
+		for e := range sub.C {
+			switch e := e.(type) {
+			case *events.HTTPRequest:
+				result.Request = e
+			case *events.File:
+				result.File = e
+			case *events.Shutdown:
+				result.Reason = e.Reason
+			}
+			if opts.Events != nil {
+				opts.Events <- e
+			}
+		}
+	}()
+
+	server, err := opts.server(ctx, handler)
+	if err == nil {
+		events.Raise(ctx, &events.Listening{
+			Address: listeningAddress(l, opts.TLSCert != ""),
+		})
+		err = server.Serve(ctx, l)
+	}
+
+	events.Raise(ctx, shutdownEvent(ctx, err))
+	events.Stop(ctx)
+	<-collected
+
+	return &result, err
+}
+
+func (o *Options) server(ctx context.Context, handler http.HandlerFunc) (*oneshothttp.Server, error) {
+	baMiddleware, _, err := oneshothttp.BasicAuthMiddleware(
+		unauthenticatedHandler, forbiddenHandler,
+		o.Username, o.Password, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create basic auth middleware: %w", err)
+	}
+
+	goneHandler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}
+	var mw oneshothttp.Middleware
+	server := oneshothttp.NewServer(ctx, handler, goneHandler, mw.
+		Chain(oneshothttp.BlockPrefetch("Safari")).
+		Chain(oneshothttp.LimitReaderMiddleware(o.MaxReadSize)).
+		Chain(oneshothttp.BotsMiddleware(!o.AllowBots)).
+		Chain(baMiddleware),
+	)
+	server.TLSCert = o.TLSCert
+	server.TLSKey = o.TLSKey
+	server.Timeout = o.Timeout
+	server.ExitOnFail = o.ExitOnFail
+
+	return server, nil
+}
+
+// listeningAddress returns the url clients can reach l at.
+func listeningAddress(l net.Listener, tls bool) string {
+	scheme := "http"
+	if tls {
+		scheme = "https"
+	}
+	switch addr := l.Addr().(type) {
+	case *net.UnixAddr:
+		return "unix://" + addr.Name
+	case *net.TCPAddr:
+		host := addr.IP.String()
+		if addr.IP.IsUnspecified() {
+			host = "localhost"
+		}
+		return fmt.Sprintf("%s://%s", scheme, oneshotfmt.Address(host, addr.Port))
+	}
+	return fmt.Sprintf("%s://%s", scheme, l.Addr().String())
+}
+
+// shutdownEvent explains why the server shut down after serving returned err.
+func shutdownEvent(ctx context.Context, err error) *events.Shutdown {
+	var e events.Shutdown
+	switch {
+	case events.Succeeded(ctx):
+		e.Reason = events.ShutdownSuccess
+	case events.GetExitCode(ctx) == events.ExitCodeTimeoutFailure:
+		e.Reason = events.ShutdownTimeout
+	case ctx.Err() != nil:
+		e.Reason = events.ShutdownInterrupted
+	case err != nil:
+		e.Reason = events.ShutdownError
+	default:
+		e.Reason = events.ShutdownStopped
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	return &e
+}
+
+func unauthenticatedHandler(w http.ResponseWriter, r *http.Request) {
+	raiseAuthFailure(r)
+	w.Header().Set("WWW-Authenticate", `Basic realm="oneshot"`)
+	w.WriteHeader(http.StatusUnauthorized)
+}
+
+func forbiddenHandler(w http.ResponseWriter, r *http.Request) {
+	raiseAuthFailure(r)
+	w.WriteHeader(http.StatusForbidden)
+}
+
+func raiseAuthFailure(r *http.Request) {
+	events.Raise(r.Context(), &events.AuthFailure{
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		RemoteAddr: r.RemoteAddr,
+		User:       events.UserFromContext(r.Context()),
+	})
+}
@@ -0,0 +1,250 @@
+package oneshot
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/events"
+	"github.com/forestnode-io/oneshot/v2/pkg/file"
+)
+
+// ReceiveOptions configures Receive.
+type ReceiveOptions struct {
+	Options
+
+	// Location is where received files are written, as with the receive command's location argument.
+	// If empty, files are not written anywhere and their contents are read from File.Body instead.
+	Location string
+	// StatusCode is the status code sent to the client once the file is received, defaults to 200.
+	StatusCode int
+}
+
+// File is a file a client sent.
+type File struct {
+	// Name is the name presented by the client, if any.
+	Name string
+	MIME string
+	// Size is the size of the file in bytes, 0 if the client didn't say.
+	Size int64
+
+	// Path is where the file was written when receiving to a location.
+	Path string
+	// Body streams the file from the client when not receiving to a location.
+	// The client is answered once Body has been read to the end, closing it early fails the transfer.
+	Body io.ReadCloser
+}
+
+// Receiver is a server started by Receive.
+type Receiver struct {
+	// Files delivers the files clients send.
+	// When receiving to a location, only the file that was received successfully is delivered,
+	// otherwise every file a client starts sending is delivered as it arrives and must be read for the transfer to go on.
+	// Files is closed once the server has shut down.
+	Files <-chan *File
+
+	done   chan struct{}
+	result *Result
+	err    error
+}
+
+// Wait waits for the server to shut down and returns how the run went.
+func (r *Receiver) Wait() (*Result, error) {
+	<-r.done
+	return r.result, r.err
+}
+
+// Receive waits for a client to send a file and returns once the server is listening.
+func Receive(ctx context.Context, opts ReceiveOptions) (*Receiver, error) {
+	var (
+		files = make(chan *File, 1)
+		rc    = receiver{
+			opts:  &opts,
+			files: files,
+		}
+		err error
+	)
+	if opts.Location != "" {
+		rc.wtc, err = file.NewWriteTransferConfig(ctx, opts.Location)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	l, err := opts.listen()
+	if err != nil {
+		return nil, err
+	}
+
+	r := Receiver{
+		Files: files,
+		done:  make(chan struct{}),
+	}
+	go func() {
+		defer close(r.done)
+		defer close(files)
+		r.result, r.err = serve(ctx, &opts.Options, l, rc.ServeHTTP)
+	}()
+
+	return &r, nil
+}
+
+type receiver struct {
+	opts  *ReceiveOptions
+	wtc   *file.WriteTransferConfig
+	files chan<- *File
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	events.Raise(ctx, events.NewHTTPRequest(r))
+
+	f, src, err := requestFile(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		events.Raise(ctx, events.ClientDisconnected{Err: err})
+		return
+	}
+	defer r.Body.Close()
+
+	fileReport := events.File{
+		Name:              f.Name,
+		MIME:              f.MIME,
+		Size:              f.Size,
+		TransferStartTime: time.Now(),
+	}
+	if rc.wtc != nil {
+		fileReport.TransferSize, err = rc.writeToLocation(ctx, f, src)
+	} else {
+		fileReport.TransferSize, err = rc.stream(ctx, f, src)
+	}
+	fileReport.TransferEndTime = time.Now()
+	fileReport.Path = f.Path
+	fileReport.ComputeTransferFields()
+	events.Raise(ctx, &fileReport)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		events.Raise(ctx, events.ClientDisconnected{Err: err})
+		return
+	}
+
+	status := rc.opts.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+
+	events.Success(ctx)
+	if rc.wtc != nil {
+		rc.files <- f
+	}
+}
+
+// writeToLocation writes src to the receive location and sets f.Path.
+// The file is removed if the transfer fails.
+func (rc *receiver) writeToLocation(ctx context.Context, f *File, src io.Reader) (int64, error) {
+	wts, err := rc.wtc.NewWriteTransferSession(ctx, f.Name, f.MIME)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(wts, src)
+	if err != nil {
+		// the session removes the file when closed before succeeding
+		wts.Close()
+		return n, err
+	}
+	// the file is complete, keep it even though the run only succeeds once the client is answered
+	if err := wts.Commit(); err != nil {
+		return n, err
+	}
+	f.Path = wts.Path()
+
+	return n, nil
+}
+
+// stream hands f to the receiver with a body that src is copied into as it is read.
+func (rc *receiver) stream(ctx context.Context, f *File, src io.Reader) (int64, error) {
+	pr, pw := io.Pipe()
+	f.Body = pr
+
+	select {
+	case rc.files <- f:
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+
+	n, err := io.Copy(pw, src)
+	pw.CloseWithError(err)
+	return n, err
+}
+
+// requestFile works out what file the client is sending the same way the receive command does,
+// from a multipart form, a url encoded form's text field or the raw request body.
+func requestFile(r *http.Request) (*File, io.Reader, error) {
+	var (
+		ct  = r.Header.Get("Content-Type")
+		f   File
+		src io.Reader
+	)
+
+	switch {
+	case strings.Contains(ct, "multipart/form-data"):
+		mr, err := r.MultipartReader()
+		if err != nil {
+			return nil, nil, err
+		}
+		part, err := mr.NextPart()
+		if err != nil {
+			return nil, nil, err
+		}
+		f.Name = safeFileName(part.FileName())
+		f.MIME = part.Header.Get("Content-Type")
+		src = part
+	case r.ContentLength != 0:
+		f.Name = dispositionFileName(r.Header.Get("Content-Disposition"))
+		f.MIME = ct
+		if 0 < r.ContentLength {
+			f.Size = r.ContentLength
+		}
+		src = r.Body
+	case strings.Contains(ct, "application/x-www-form-urlencoded"):
+		if err := r.ParseForm(); err != nil {
+			return nil, nil, err
+		}
+		text := r.PostForm.Get("text")
+		f.Size = int64(len(text))
+		src = strings.NewReader(text)
+	default:
+		src = r.Body
+	}
+	return &f, src, nil
+}
+
+func dispositionFileName(cd string) string {
+	if cd == "" {
+		return ""
+	}
+	_, params, err := mime.ParseMediaType(cd)
+	if err != nil {
+		return ""
+	}
+	return safeFileName(params["filename"])
+}
+
+// safeFileName strips name down to its last element so that a client can't write outside of the location.
+// Names that are left without a file, like ".." or "/", are dropped and a random name is used instead.
+func safeFileName(name string) string {
+	if name == "" {
+		return ""
+	}
+	switch name = filepath.Base(name); name {
+	case ".", "..", string(filepath.Separator):
+		return ""
+	}
+	return name
+}
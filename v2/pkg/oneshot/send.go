@@ -0,0 +1,109 @@
+package oneshot
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/events"
+	oneshothttp "github.com/forestnode-io/oneshot/v2/pkg/net/http"
+)
+
+// ErrReaderConsumed is given to clients that arrive after another client started reading what Send is sending.
+var ErrReaderConsumed = errors.New("content has already been sent")
+
+// SendOptions configures Send.
+type SendOptions struct {
+	Options
+
+	// Name is the file name suggested to the client.
+	Name string
+	// MIME is the content type of what is being sent.
+	MIME string
+	// Size is the number of bytes being sent, 0 if unknown.
+	Size int64
+	// StatusCode is the status code sent to the client, defaults to 200.
+	StatusCode int
+	// Header holds extra headers sent to the client.
+	Header http.Header
+}
+
+// Send serves the contents of r to the first client and returns once it has all of it,
+// the timeout passes or ctx is done.
+// r can only be read once, so clients arriving after a failed transfer are turned away with ErrReaderConsumed.
+func Send(ctx context.Context, opts SendOptions, r io.Reader) (*Result, error) {
+	s := sender{
+		opts: &opts,
+		r:    r,
+	}
+	return run(ctx, &opts.Options, s.ServeHTTP)
+}
+
+type sender struct {
+	opts *SendOptions
+	r    io.Reader
+	// consumed is only used by the server's worker so it needs no lock
+	consumed bool
+}
+
+func (s *sender) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var (
+		ctx  = r.Context()
+		opts = s.opts
+	)
+
+	events.Raise(ctx, events.NewHTTPRequest(r))
+
+	if s.consumed {
+		http.Error(w, ErrReaderConsumed.Error(), http.StatusGone)
+		events.Raise(ctx, events.ClientDisconnected{Err: ErrReaderConsumed})
+		return
+	}
+	s.consumed = true
+
+	for key, values := range opts.Header {
+		w.Header()[key] = values
+	}
+	if opts.MIME != "" {
+		w.Header().Set("Content-Type", opts.MIME)
+	}
+	if opts.Name != "" {
+		w.Header().Set("Content-Disposition",
+			mime.FormatMediaType("attachment", map[string]string{"filename": opts.Name}))
+	}
+	if 0 < opts.Size {
+		w.Header().Set("Content-Length", strconv.FormatInt(opts.Size, 10))
+	}
+	status := opts.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+
+	fileReport := events.File{
+		Name:              opts.Name,
+		MIME:              opts.MIME,
+		Size:              opts.Size,
+		TransferStartTime: time.Now(),
+	}
+	var out io.Writer = w
+	if opts.Size <= 0 {
+		// the size is unknown so r may be a stream, pass it on to the client as soon as it arrives
+		out = oneshothttp.NewFlushWriter(w)
+	}
+	n, err := io.Copy(out, s.r)
+	fileReport.TransferSize = n
+	fileReport.TransferEndTime = time.Now()
+	fileReport.ComputeTransferFields()
+	events.Raise(ctx, &fileReport)
+	if err != nil {
+		events.Raise(ctx, events.ClientDisconnected{Err: err})
+		return
+	}
+
+	events.Success(ctx)
+}
@@ -0,0 +1,55 @@
+package oneshot
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/events"
+	oneshothttp "github.com/forestnode-io/oneshot/v2/pkg/net/http"
+)
+
+// Serve serves handler until it answers a request successfully, the timeout passes or ctx is done.
+// Requests answered with a status code below 400 succeed, the rest fail.
+// Handlers that answer requests that shouldn't end the run, like those for assets, should call IgnoreRequest.
+// The request's context carries the events of the run so handlers may raise their own.
+func Serve(ctx context.Context, opts Options, handler http.Handler) (*Result, error) {
+	return run(ctx, &opts, func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		events.Raise(ctx, events.NewHTTPRequest(r))
+
+		sw := statusWriter{ResponseWriter: w.(oneshothttp.ResponseWriter)}
+		handler.ServeHTTP(&sw, r)
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+
+		if sw.status < http.StatusBadRequest {
+			events.Success(ctx)
+		}
+	})
+}
+
+// statusWriter remembers the status code a handler answered with.
+type statusWriter struct {
+	oneshothttp.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/muesli/termenv"
 	"github.com/rs/zerolog"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 type UsageError struct {
@@ -48,6 +49,9 @@ func InvocationInfo(ctx context.Context, cmd *cobra.Command, args []string) {
 	log := zerolog.Ctx(ctx)
 	o := getOutput(ctx)
 	o.setCommandInvocation(cmd, args)
+	if o.Format == "ndjson" {
+		o.writeNDJSONStart()
+	}
 
 	go func() {
 		if err := getOutput(ctx).run(ctx); err != nil {
@@ -57,7 +61,7 @@ func InvocationInfo(ctx context.Context, cmd *cobra.Command, args []string) {
 	}()
 }
 
-func SetEventsChan(ctx context.Context, ec chan events.Event) {
+func SetEventsChan(ctx context.Context, ec <-chan events.Event) {
 	getOutput(ctx).events = ec
 }
 
@@ -69,6 +73,24 @@ func WriteListeningOn(ctx context.Context, addr string) {
 	getOutput(ctx).writeListeningOn(addr)
 }
 
+// EnableTUI replaces the human readable output with a full screen dashboard on stderr.
+// It reports false if stderr is not a terminal, in which case the output is left as is.
+func EnableTUI(ctx context.Context) bool {
+	o := getOutput(ctx)
+	if o.stderrTTY == nil || !term.IsTerminal(int(os.Stderr.Fd())) {
+		return false
+	}
+	o.tui = newDashboard(o.stderrTTY)
+	return true
+}
+
+// SetDashboardControls gives the dashboard a way to act on the running server.
+func SetDashboardControls(ctx context.Context, c DashboardControls) {
+	if o := getOutput(ctx); o.tui != nil {
+		o.tui.setControls(c)
+	}
+}
+
 func Quiet(ctx context.Context) {
 	getOutput(ctx).quiet = true
 }
@@ -84,6 +106,29 @@ func SetFormatOpts(ctx context.Context, opts ...string) {
 	}
 }
 
+// SetVersion sets the version of oneshot reported in ndjson output.
+func SetVersion(ctx context.Context, version, apiVersion string) {
+	o := getOutput(ctx)
+	o.version = version
+	o.apiVersion = apiVersion
+}
+
+// SetProgressInterval sets how often progress events are raised.
+// An interval of 0 only reports progress once a transfer is done.
+func SetProgressInterval(ctx context.Context, d time.Duration) {
+	getOutput(ctx).progressInterval = d
+}
+
+// Exit reports the exit code oneshot is about to exit with.
+func Exit(ctx context.Context, code int) {
+	o := getOutput(ctx)
+	if o.Format == "ndjson" {
+		o.writeNDJSON(NDJSONExit, NDJSONExitData{
+			Code: code,
+		})
+	}
+}
+
 func IncludeBody(ctx context.Context) {
 	getOutput(ctx).includeBody = true
 }
@@ -139,8 +184,14 @@ func GetBufferedWriteCloser(ctx context.Context) io.WriteCloser {
 
 func DisplayProgress(ctx context.Context, prog *atomic.Int64, period time.Duration, host string, total int64) func() {
 	o := getOutput(ctx)
-	if o.quiet || o.Format == "json" {
-		return func() {}
+	interval := o.progressInterval
+	if o.tui != nil && (interval <= 0 || dashboardRefresh < interval) {
+		// the dashboard shows progress from progress events
+		interval = dashboardRefresh
+	}
+	stopRaising := raiseProgress(ctx, interval, prog, host, total)
+	if o.quiet || o.machineReadable() || o.tui != nil {
+		return stopRaising
 	}
 
 	var (
@@ -171,6 +222,7 @@ func DisplayProgress(ctx context.Context, prog *atomic.Int64, period time.Durati
 	}
 
 	return func() {
+		stopRaising()
 		if done != nil {
 			done <- struct{}{}
 			close(done)
@@ -186,6 +238,24 @@ func DisplayProgress(ctx context.Context, prog *atomic.Int64, period time.Durati
 }
 
 func NewBufferedWriter(ctx context.Context, w io.Writer) (io.Writer, func() []byte) {
+	bw, buf := newBufferedWriter(ctx, w, 0)
+	if buf == nil {
+		return bw, nil
+	}
+	return bw, buf.Bytes
+}
+
+// NewCappedBufferedWriter is like NewBufferedWriter but only the first maxSize bytes written are buffered.
+// The returned truncated func reports whether more than that was written.
+func NewCappedBufferedWriter(ctx context.Context, w io.Writer, maxSize int64) (bw io.Writer, getBuf func() []byte, truncated func() bool) {
+	bw, buf := newBufferedWriter(ctx, w, maxSize)
+	if buf == nil {
+		return bw, nil, func() bool { return false }
+	}
+	return bw, buf.Bytes, buf.truncated
+}
+
+func newBufferedWriter(ctx context.Context, w io.Writer, maxSize int64) (io.Writer, *cappedBuffer) {
 	o := getOutput(ctx)
 
 	if _, ok := o.FormatOpts["exclude-file-contents"]; ok {
@@ -197,13 +267,13 @@ func NewBufferedWriter(ctx context.Context, w io.Writer) (io.Writer, func() []by
 	// if the command name is 'reverse-proxy' or the format
 	// is json for any other command, buffer the output
 	if o.Format == "json" || o.cmdName == "reverse-proxy" || includeFileContents {
-		buf := bytes.NewBuffer(nil)
+		buf := &cappedBuffer{max: maxSize}
 		tw := teeWriter{
 			w:    w,
 			copy: buf,
 		}
 
-		return tw, buf.Bytes
+		return tw, buf
 	}
 
 	return w, nil
@@ -230,6 +300,29 @@ func NewBufferedReader(ctx context.Context, r io.Reader) (io.Reader, *bytes.Buff
 	return r, nil
 }
 
+// cappedBuffer keeps the first max bytes written to it, or all of them if max is 0.
+type cappedBuffer struct {
+	bytes.Buffer
+	max     int64
+	dropped bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if 0 < b.max {
+		if room := b.max - int64(b.Len()); room < int64(len(p)) {
+			p = p[:max(room, 0)]
+			b.dropped = true
+		}
+	}
+	_, err := b.Buffer.Write(p)
+	return n, err
+}
+
+func (b *cappedBuffer) truncated() bool {
+	return b.dropped
+}
+
 type teeWriter struct {
 	w, copy io.Writer
 }
@@ -258,6 +351,20 @@ func (t teeWriter) WriteHeader(code int) {
 	}
 }
 
+// Flush lets streamed responses, like server-sent events, through as they are written.
+func (t teeWriter) Flush() {
+	if h, ok := t.w.(http.ResponseWriter); ok {
+		_ = http.NewResponseController(h).Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer,
+// which is how connections are hijacked when switching protocols.
+func (t teeWriter) Unwrap() http.ResponseWriter {
+	h, _ := t.w.(http.ResponseWriter)
+	return h
+}
+
 type writer struct {
 	w   io.Writer
 	buf *bytes.Buffer
@@ -2,6 +2,8 @@ package fmt
 
 import (
 	"fmt"
+	"net"
+	"strconv"
 	"time"
 )
 
@@ -89,9 +91,10 @@ func PrettyPercent[T Number](x, total T) string {
 	return fmt.Sprintf("%.2f%%", float64(100*x/total))
 }
 
+// Address joins host and port, bracketing IPv6 hosts.
 func Address(host string, port int) string {
 	if port != 0 {
-		return fmt.Sprintf("%s:%d", host, port)
+		return net.JoinHostPort(host, strconv.Itoa(port))
 	}
 
 	return host
@@ -44,6 +44,10 @@ func _json_handleEvent(o *output, e events.Event) {
 		o.currentClientSession = &ClientSession{
 			Request: event,
 		}
+		// capture shows every request as it comes in
+		if humanOutput && !o.quiet && o.cmdName == "capture" {
+			writeRequest(os.Stdout, event)
+		}
 	case *events.File:
 		// if the command is reverse-proxy, then the file is the response
 		// and so we store it in the current client session
@@ -56,6 +60,7 @@ func _json_handleEvent(o *output, e events.Event) {
 				if bf, ok := event.Content.(func() []byte); ok {
 					bodyBytes := bf()
 					o.currentClientSession.Response.Body = bodyBytes
+					o.currentClientSession.Response.BodyTruncated = event.ContentTruncated
 					if humanOutput && !o.quiet {
 						os.Stdout.Write(bodyBytes)
 					}
@@ -96,12 +101,28 @@ func _json_handleEvent(o *output, e events.Event) {
 					// then copy the body over to the event body if we have the body as bytes
 					if body, ok := o.currentClientSession.Response.Body.([]byte); ok {
 						event.Body = body
+						event.BodyTruncated = o.currentClientSession.Response.BodyTruncated
 					}
 				}
 			}
 			// then store the response in the current client session
 			o.currentClientSession.Response = event
 		}
+	case *events.WebSocketMessage:
+		if o.currentClientSession != nil {
+			o.currentClientSession.WebSocketMessages = append(o.currentClientSession.WebSocketMessages, event)
+		}
+		// reverse-proxy tees text messages as they come in
+		if humanOutput && !o.quiet && event.Type == events.WebSocketText {
+			fmt.Fprintln(os.Stdout, event.Data)
+		}
+	case *events.OAuthCallback:
+		if o.currentClientSession != nil {
+			o.currentClientSession.OAuthCallback = event
+		}
+		if humanOutput && !o.quiet && event.Code != "" {
+			fmt.Fprintf(os.Stdout, "code=%s\nstate=%s\n", event.Code, event.State)
+		}
 	case events.HTTPRequestBody:
 		if humanOutput {
 			body, err := event()
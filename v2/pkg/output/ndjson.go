@@ -0,0 +1,148 @@
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/events"
+	"github.com/rs/zerolog"
+)
+
+// NDJSONSchemaVersion is the version of the ndjson output schema.
+// It changes whenever an existing line type or field changes meaning or is removed;
+// new line types and new fields may be added without changing it.
+const NDJSONSchemaVersion = 1
+
+// ndjson line types
+const (
+	NDJSONStart              = "start"
+	NDJSONListening          = "listening"
+	NDJSONRequest            = "request"
+	NDJSONAuthFailure        = "auth-failure"
+	NDJSONProgress           = "progress"
+	NDJSONFile               = "file"
+	NDJSONResponse           = "response"
+	NDJSONClientDisconnected = "client-disconnected"
+	NDJSONWebSocketMessage   = "websocket-message"
+	NDJSONOAuthCallback      = "oauth-callback"
+	NDJSONShutdown           = "shutdown"
+	NDJSONExit               = "exit"
+)
+
+// NDJSONLine is a single line of ndjson output.
+type NDJSONLine struct {
+	Version int       `json:"v"`
+	Type    string    `json:"type"`
+	Time    time.Time `json:"time"`
+	Data    any       `json:"data,omitempty"`
+}
+
+type NDJSONStartData struct {
+	Command    string
+	Version    string
+	APIVersion string
+}
+
+type NDJSONClientDisconnectedData struct {
+	Error string `json:",omitempty"`
+}
+
+type NDJSONExitData struct {
+	Code int
+}
+
+func (o *output) writeNDJSON(t string, data any) {
+	o.ndjsonMu.Lock()
+	defer o.ndjsonMu.Unlock()
+
+	if o.ndjsonEncoder == nil {
+		o.ndjsonEncoder = json.NewEncoder(os.Stdout)
+	}
+	_ = o.ndjsonEncoder.Encode(NDJSONLine{
+		Version: NDJSONSchemaVersion,
+		Type:    t,
+		Time:    time.Now(),
+		Data:    data,
+	})
+}
+
+func (o *output) writeNDJSONStart() {
+	o.writeNDJSON(NDJSONStart, NDJSONStartData{
+		Command:    o.cmdName,
+		Version:    o.version,
+		APIVersion: o.apiVersion,
+	})
+}
+
+func runNDJSON(ctx context.Context, o *output) {
+	log := zerolog.Ctx(ctx)
+
+	for event := range o.events {
+		_ndjson_handleEvent(o, event)
+	}
+
+	if err := events.GetCancellationError(ctx); err != nil {
+		log.Error().
+			Msg("connection cancelled event")
+	}
+}
+
+func _ndjson_handleEvent(o *output, e events.Event) {
+	_, includeFileContent := o.FormatOpts["include-file-contents"]
+	if _, exclude := o.FormatOpts["exclude-file-contents"]; exclude {
+		includeFileContent = false
+	}
+
+	switch event := e.(type) {
+	case *events.HTTPRequest:
+		o.writeNDJSON(NDJSONRequest, event)
+	case *events.AuthFailure:
+		o.writeNDJSON(NDJSONAuthFailure, event)
+	case *events.File:
+		if bf, ok := event.Content.(func() []byte); ok {
+			event.Content = nil
+			if bf != nil {
+				content := bf()
+				if event.TransferSize == 0 {
+					event.TransferSize = int64(len(content))
+				}
+				if includeFileContent {
+					event.Content = content
+				}
+			}
+		} else if !includeFileContent {
+			event.Content = nil
+		}
+		event.ComputeTransferFields()
+		o.writeNDJSON(NDJSONFile, event)
+	case *events.HTTPResponse:
+		if bf, ok := event.Body.(func() []byte); ok {
+			event.Body = nil
+			if bf != nil && includeFileContent {
+				event.Body = bf()
+			}
+		}
+		o.writeNDJSON(NDJSONResponse, event)
+	case events.ClientDisconnected:
+		data := NDJSONClientDisconnectedData{}
+		if event.Err != nil {
+			data.Error = event.Err.Error()
+		}
+		o.writeNDJSON(NDJSONClientDisconnected, data)
+	case *events.WebSocketMessage:
+		o.writeNDJSON(NDJSONWebSocketMessage, event)
+	case *events.OAuthCallback:
+		o.writeNDJSON(NDJSONOAuthCallback, event)
+	case *events.Listening:
+		o.writeNDJSON(NDJSONListening, event)
+	case *events.Progress:
+		o.writeNDJSON(NDJSONProgress, event)
+	case *events.Shutdown:
+		o.writeNDJSON(NDJSONShutdown, event)
+	case events.HTTPRequestBody:
+		// request bodies are not part of the ndjson output
+		_, _ = event()
+	}
+}
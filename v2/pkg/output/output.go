@@ -3,8 +3,10 @@ package output
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/forestnode-io/oneshot/v2/pkg/events"
@@ -27,11 +29,13 @@ func getOutput(ctx context.Context) *output {
 }
 
 type output struct {
-	events chan events.Event
+	events <-chan events.Event
 
 	stderrTTY *termenv.Output
 
 	dynamicOutput *tabbedDynamicOutput
+	// tui is the full screen dashboard, nil unless enabled
+	tui *dashboard
 
 	Format     string
 	FormatOpts map[string]struct{}
@@ -50,6 +54,11 @@ type output struct {
 	displayProgresssPeriod    time.Duration
 	lastProgressDisplayAmount int64
 
+	// progressInterval is how often progress events are raised
+	progressInterval time.Duration
+	ndjsonMu         sync.Mutex
+	ndjsonEncoder    *json.Encoder
+
 	restoreConsole  []func() error
 	stdoutFailColor termenv.Color
 	stderrFailColor termenv.Color
@@ -57,15 +66,28 @@ type output struct {
 	gotInvocationInfo bool
 
 	cmdName string
+
+	version    string
+	apiVersion string
 }
 
 func (o *output) run(ctx context.Context) error {
 	log := zerolog.Ctx(ctx)
 
-	log.Debug().
-		Msg("output running in json mode")
-	NewHTTPRequest = events.NewHTTPRequest_WithBody
-	runJSON(ctx, o)
+	if o.Format == "ndjson" {
+		log.Debug().
+			Msg("output running in ndjson mode")
+		runNDJSON(ctx, o)
+	} else if o.tui != nil {
+		log.Debug().
+			Msg("output running in tui mode")
+		runTUI(ctx, o)
+	} else {
+		log.Debug().
+			Msg("output running in json mode")
+		NewHTTPRequest = events.NewHTTPRequest_WithBody
+		runJSON(ctx, o)
+	}
 
 	log.Debug().
 		Msg("output system shutting down")
@@ -85,7 +107,22 @@ func (o *output) run(ctx context.Context) error {
 	return nil
 }
 
+// machineReadable reports whether the output is meant to be read by other programs
+// rather than a person.
+func (o *output) machineReadable() bool {
+	return o.Format == "json" || o.Format == "ndjson"
+}
+
 func (o *output) writeListeningOnQRCode(addr string) {
+	if o.Format == "ndjson" {
+		// written once the listening event comes in
+		return
+	}
+	if o.tui != nil {
+		// drawn by the dashboard once the listening event comes in
+		o.tui.showQR = true
+		return
+	}
 	if o.skipSummary || o.quiet || addr == "" {
 		return
 	}
@@ -104,6 +141,10 @@ func (o *output) writeListeningOnQRCode(addr string) {
 }
 
 func (o *output) writeListeningOn(addr string) {
+	if o.Format == "ndjson" || o.tui != nil {
+		// written once the listening event comes in
+		return
+	}
 	if o.skipSummary || o.quiet || addr == "" {
 		return
 	}
@@ -155,7 +196,8 @@ func (o *output) ttyCheck() error {
 }
 
 func (o *output) enableDynamicOutput() {
-	if o.stderrTTY == nil {
+	// the dashboard owns the terminal
+	if o.stderrTTY == nil || o.tui != nil {
 		return
 	}
 
@@ -174,10 +216,17 @@ type ClientSession struct {
 	Request  *events.HTTPRequest  `json:",omitempty"`
 	File     *events.File         `json:",omitempty"`
 	Response *events.HTTPResponse `json:",omitempty"`
-	Error    string               `json:",omitempty"`
+	// WebSocketMessages holds the messages passed through the connection if it switched to WebSockets.
+	WebSocketMessages []*events.WebSocketMessage `json:",omitempty"`
+	// OAuthCallback holds the code and state the client was redirected back with.
+	OAuthCallback *events.OAuthCallback `json:",omitempty"`
+	Error         string                `json:",omitempty"`
 }
 
 func newClientSessionMessage(s *ClientSession) *messages.ClientSession {
+	if s == nil {
+		return nil
+	}
 	return &messages.ClientSession{
 		Request:  messages.HTTPRequestFromEvent(s.Request),
 		File:     messages.FileFromEvent(s.File),
@@ -1,11 +1,13 @@
 package output
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"sync/atomic"
 	"time"
 
+	"github.com/forestnode-io/oneshot/v2/pkg/events"
 	oneshotfmt "github.com/forestnode-io/oneshot/v2/pkg/output/fmt"
 )
 
@@ -95,3 +97,55 @@ func _displayFlush(o *output, s string, success bool) {
 		}
 	}
 }
+
+// raiseProgress raises a progress event every interval until the returned func is called,
+// which raises a final progress event.
+func raiseProgress(ctx context.Context, interval time.Duration, prog *atomic.Int64, host string, total int64) func() {
+	var (
+		start   = time.Now()
+		done    = make(chan struct{})
+		stopped = make(chan struct{})
+		event   = func(final bool) *events.Progress {
+			n := prog.Load()
+			e := events.Progress{
+				Host:  host,
+				Bytes: n,
+				Total: total,
+				Done:  final,
+			}
+			if dt := time.Since(start); 0 < dt {
+				e.Rate = int64(bytesPerSecond(n, dt))
+			}
+			return &e
+		}
+	)
+
+	if 0 < interval {
+		ticker := time.NewTicker(interval)
+		go func(done <-chan struct{}) {
+			defer close(stopped)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					events.Raise(ctx, event(false))
+				}
+			}
+		}(done)
+	} else {
+		close(stopped)
+	}
+
+	return func() {
+		if done == nil {
+			return
+		}
+		close(done)
+		done = nil
+		// make sure the final event is the last one
+		<-stopped
+		events.Raise(ctx, event(true))
+	}
+}
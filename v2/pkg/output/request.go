@@ -0,0 +1,111 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/events"
+	oneshotfmt "github.com/forestnode-io/oneshot/v2/pkg/output/fmt"
+)
+
+// writeRequest writes a readable view of a captured request to w.
+// JSON bodies are indented, form bodies are listed field by field and
+// binary content is summarized rather than written out.
+func writeRequest(w io.Writer, r *events.HTTPRequest) {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "%s %s %s\n", r.Method, r.RequestURI, r.Protocol)
+	fmt.Fprintf(&buf, "Host: %s\n", r.Host)
+	writeHeader(&buf, "", r.Header)
+	if r.RemoteAddr != "" {
+		fmt.Fprintf(&buf, "\n# from %s", r.RemoteAddr)
+		if r.User != "" {
+			fmt.Fprintf(&buf, " as %s", r.User)
+		}
+		buf.WriteString("\n")
+	}
+
+	body, _ := r.Body.([]byte)
+	switch {
+	case 0 < len(r.Parts):
+		buf.WriteString("\n")
+		for i, part := range r.Parts {
+			fmt.Fprintf(&buf, "--- part %d: %s", i+1, part.Name)
+			if part.FileName != "" {
+				fmt.Fprintf(&buf, " (file %s)", part.FileName)
+			}
+			fmt.Fprintf(&buf, ", %s\n", oneshotfmt.PrettySize(part.Size))
+			writeHeader(&buf, "  ", part.Header)
+			content, _ := part.Content.([]byte)
+			if s, ok := part.Content.(string); ok {
+				content = []byte(s)
+			}
+			writeBody(&buf, headerValue(part.Header, "Content-Type"), content)
+		}
+	case 0 < len(r.Form):
+		buf.WriteString("\n")
+		writeHeader(&buf, "", r.Form)
+	case 0 < len(body):
+		buf.WriteString("\n")
+		writeBody(&buf, headerValue(r.Header, "Content-Type"), body)
+	}
+
+	buf.WriteString("\n")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// writeHeader writes the values of h as sorted "name: value" lines.
+func writeHeader(buf *bytes.Buffer, indent string, h map[string][]string) {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, v := range h[name] {
+			fmt.Fprintf(buf, "%s%s: %s\n", indent, name, v)
+		}
+	}
+}
+
+func writeBody(buf *bytes.Buffer, contentType string, body []byte) {
+	if len(body) == 0 {
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "" || strings.Contains(mediaType, "json") {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, body, "", "  "); err == nil {
+			buf.Write(indented.Bytes())
+			buf.WriteString("\n")
+			return
+		}
+	}
+
+	if !utf8.Valid(body) {
+		fmt.Fprintf(buf, "<%s of binary data>\n", oneshotfmt.PrettySize(int64(len(body))))
+		return
+	}
+
+	buf.Write(body)
+	if body[len(body)-1] != '\n' {
+		buf.WriteString("\n")
+	}
+}
+
+func headerValue(h map[string][]string, name string) string {
+	for k, v := range h {
+		if strings.EqualFold(k, name) && 0 < len(v) {
+			return v[0]
+		}
+	}
+	return ""
+}
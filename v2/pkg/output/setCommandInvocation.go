@@ -29,22 +29,25 @@ func (o *output) setCommandInvocation(cmd *cobra.Command, args []string) {
 
 	switch o.cmdName {
 	case "exec":
-		if o.Format == "json" {
+		if o.machineReadable() {
 			includeContent()
 		}
 	case "redirect":
+	case "respond":
+	case "capture":
 	case "webrtc client send":
 		fallthrough
 	case "send":
 		switch argc {
 		case 0: // sending from stdin
-			if o.Format != "json" {
+			o.stdinInUse()
+			if !o.machineReadable() {
 				o.enableDynamicOutput()
 			} else {
 				includeContent()
 			}
 		default: // sending file(s)
-			if o.Format != "json" {
+			if !o.machineReadable() {
 				o.enableDynamicOutput()
 			}
 		}
@@ -53,7 +56,32 @@ func (o *output) setCommandInvocation(cmd *cobra.Command, args []string) {
 	case "receive":
 		switch argc {
 		case 0: // receiving to stdout
-			if o.Format == "json" {
+			if o.machineReadable() {
+				includeContent()
+			}
+		default: // receiving to a file
+			if !o.quiet {
+				o.enableDynamicOutput()
+			}
+		}
+	case "put":
+		switch argc {
+		case 1: // sending from stdin
+			o.stdinInUse()
+			if !o.machineReadable() {
+				o.enableDynamicOutput()
+			} else {
+				includeContent()
+			}
+		default: // sending file(s)
+			if !o.machineReadable() {
+				o.enableDynamicOutput()
+			}
+		}
+	case "get":
+		switch argc {
+		case 1: // receiving to stdout
+			if o.machineReadable() {
 				includeContent()
 			}
 		default: // receiving to a file
@@ -62,7 +90,7 @@ func (o *output) setCommandInvocation(cmd *cobra.Command, args []string) {
 			}
 		}
 	case "reverse-proxy":
-		if o.Format == "json" {
+		if o.machineReadable() {
 			includeContent()
 		}
 	case "webrtc signalling-server":
@@ -71,3 +99,10 @@ func (o *output) setCommandInvocation(cmd *cobra.Command, args []string) {
 	default:
 	}
 }
+
+// stdinInUse keeps the dashboard from reading key presses from stdin.
+func (o *output) stdinInUse() {
+	if o.tui != nil {
+		o.tui.keys = false
+	}
+}
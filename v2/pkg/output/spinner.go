@@ -8,7 +8,7 @@ import (
 
 func DisplaySpinner(ctx context.Context, period time.Duration, prefix, succ string, charSet []string) func() {
 	o := getOutput(ctx)
-	if o.quiet || o.Format == "json" {
+	if o.quiet || o.machineReadable() || o.tui != nil {
 		return func() {}
 	}
 
@@ -0,0 +1,527 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/forestnode-io/oneshot/v2/pkg/events"
+	oneshotfmt "github.com/forestnode-io/oneshot/v2/pkg/output/fmt"
+	"github.com/mdp/qrterminal/v3"
+	"github.com/muesli/termenv"
+	"github.com/rs/zerolog"
+	"golang.org/x/term"
+)
+
+const (
+	// dashboardRefresh is how often the dashboard is redrawn and how often it wants progress events
+	dashboardRefresh = 250 * time.Millisecond
+	// dashboardExtension is how much time the extend key adds to the timeout
+	dashboardExtension = time.Minute
+	// dashboardMaxRejected is how many rejected requests the dashboard remembers
+	dashboardMaxRejected = 8
+)
+
+// client statuses shown on the dashboard
+const (
+	clientConnected    = "connected"
+	clientTransferring = "transferring"
+	clientDone         = "done"
+	clientKicked       = "kicked"
+	clientFailed       = "failed"
+)
+
+// DashboardControls are the actions the dashboard can take on the running server.
+// Any of them may be nil if the command does not support the action.
+type DashboardControls struct {
+	// Kick disconnects the client at addr.
+	Kick func(addr string) error
+	// ExtendTimeout gives clients more time to connect and returns the new deadline.
+	ExtendTimeout func(time.Duration) (time.Time, error)
+	// Deadline returns when oneshot times out if no client connects.
+	Deadline func() (time.Time, bool)
+	// End ends the session.
+	End func()
+}
+
+type dashboardClient struct {
+	addr      string
+	userAgent string
+	user      string
+	request   string
+	bytes     int64
+	total     int64
+	rate      int64
+	status    string
+}
+
+type rejectedRequest struct {
+	time   time.Time
+	addr   string
+	method string
+	path   string
+}
+
+// dashboard is the model behind the full screen terminal ui.
+// Events and key presses update it and view renders it.
+type dashboard struct {
+	mu sync.Mutex
+
+	te       *termenv.Output
+	controls DashboardControls
+	// keys is false when stdin is needed for something else
+	keys bool
+
+	start   time.Time
+	address string
+	// others holds the addresses besides address that oneshot listens on
+	others   []string
+	showQR   bool
+	qr       []string
+	clients  []*dashboardClient
+	current  *dashboardClient
+	rejected []rejectedRequest
+	selected int
+	message  string
+	reason   string
+}
+
+func newDashboard(te *termenv.Output) *dashboard {
+	return &dashboard{
+		te:    te,
+		keys:  true,
+		start: time.Now(),
+	}
+}
+
+func (d *dashboard) setControls(c DashboardControls) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.controls = c
+}
+
+func (d *dashboard) update(e events.Event) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch e := e.(type) {
+	case *events.Listening:
+		d.address = e.Address
+		if 1 < len(e.Addresses) {
+			d.others = e.Addresses[1:]
+		}
+		if d.showQR && e.Address != "" {
+			buf := bytes.NewBuffer(nil)
+			qrterminal.GenerateHalfBlock(e.Address, qrterminal.L, buf)
+			d.qr = strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+		}
+	case *events.HTTPRequest:
+		c := dashboardClient{
+			addr:    e.RemoteAddr,
+			request: e.Method + " " + e.Path,
+			status:  clientConnected,
+			// whoever the request was authenticated as, by basic auth, htpasswd, OIDC or client certificate
+			user: e.User,
+		}
+		if e.Header != nil {
+			c.userAgent = http.Header(e.Header).Get("User-Agent")
+		}
+		d.clients = append(d.clients, &c)
+		d.current = &c
+	case *events.Progress:
+		c := d.client(e.Host)
+		if c == nil {
+			c = &dashboardClient{
+				addr: e.Host,
+			}
+			d.clients = append(d.clients, c)
+			d.current = c
+		}
+		c.bytes = e.Bytes
+		c.total = e.Total
+		c.rate = e.Rate
+		if c.status == clientConnected || c.status == "" {
+			c.status = clientTransferring
+		}
+	case *events.File:
+		if c := d.current; c != nil {
+			if c.bytes < e.TransferSize {
+				c.bytes = e.TransferSize
+			}
+			if c.status != clientKicked {
+				c.status = clientDone
+			}
+		}
+	case events.ClientDisconnected:
+		if c := d.current; c != nil && c.status != clientKicked {
+			if e.Err != nil {
+				c.status = clientFailed
+			} else {
+				c.status = clientDone
+			}
+		}
+		d.current = nil
+	case *events.AuthFailure:
+		d.rejected = append(d.rejected, rejectedRequest{
+			time:   time.Now(),
+			addr:   e.RemoteAddr,
+			method: e.Method,
+			path:   e.Path,
+		})
+		if dashboardMaxRejected < len(d.rejected) {
+			d.rejected = d.rejected[len(d.rejected)-dashboardMaxRejected:]
+		}
+	case *events.Shutdown:
+		d.reason = e.Reason
+	}
+}
+
+// client returns the most recent client connecting from addr.
+func (d *dashboard) client(addr string) *dashboardClient {
+	for i := len(d.clients) - 1; 0 <= i; i-- {
+		if d.clients[i].addr == addr {
+			return d.clients[i]
+		}
+	}
+	return nil
+}
+
+// handleKeys updates the dashboard for the keys in p and reports whether the session should end.
+func (d *dashboard) handleKeys(p []byte) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for 0 < len(p) {
+		switch {
+		case bytes.HasPrefix(p, []byte("\x1b[A")):
+			p = p[3:]
+			d.moveSelection(-1)
+			continue
+		case bytes.HasPrefix(p, []byte("\x1b[B")):
+			p = p[3:]
+			d.moveSelection(1)
+			continue
+		}
+
+		key := p[0]
+		p = p[1:]
+		switch key {
+		case 'k':
+			d.moveSelection(-1)
+		case 'j':
+			d.moveSelection(1)
+		case 'x':
+			d.kickSelected()
+		case '+':
+			d.extendTimeout()
+		case 'q', 3, 4: // ctrl-c and ctrl-d are not turned into signals in raw mode
+			d.message = "ending session"
+			return true
+		}
+	}
+
+	return false
+}
+
+func (d *dashboard) moveSelection(delta int) {
+	d.selected += delta
+	if len(d.clients) <= d.selected {
+		d.selected = len(d.clients) - 1
+	}
+	if d.selected < 0 {
+		d.selected = 0
+	}
+}
+
+func (d *dashboard) kickSelected() {
+	if d.selected < 0 || len(d.clients) <= d.selected {
+		d.message = "no client selected"
+		return
+	}
+	c := d.clients[d.selected]
+	if d.controls.Kick == nil {
+		d.message = "clients can not be kicked"
+		return
+	}
+	if err := d.controls.Kick(c.addr); err != nil {
+		d.message = fmt.Sprintf("unable to kick %s: %s", c.addr, err)
+		return
+	}
+	c.status = clientKicked
+	d.message = "kicked " + c.addr
+}
+
+func (d *dashboard) extendTimeout() {
+	if d.controls.ExtendTimeout == nil {
+		d.message = "the timeout can not be extended"
+		return
+	}
+	deadline, err := d.controls.ExtendTimeout(dashboardExtension)
+	if err != nil {
+		d.message = fmt.Sprintf("unable to extend timeout: %s", err)
+		return
+	}
+	d.message = "timeout extended until " + deadline.Format(time.TimeOnly)
+}
+
+func (d *dashboard) end() {
+	d.mu.Lock()
+	end := d.controls.End
+	d.mu.Unlock()
+
+	if end != nil {
+		end()
+		return
+	}
+	// without a way to end the session, do what ctrl-c would have done outside of raw mode
+	if p, err := os.FindProcess(os.Getpid()); err == nil {
+		_ = p.Signal(os.Interrupt)
+	}
+}
+
+// view renders the dashboard as lines no wider than width.
+func (d *dashboard) view(width int, interactive bool) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var (
+		lines  []string
+		uptime = time.Since(d.start).Round(time.Second)
+	)
+
+	lines = append(lines, fmt.Sprintf("oneshot dashboard - up %s", uptime))
+	if d.address != "" {
+		lines = append(lines, "listening on "+d.address)
+		for _, addr := range d.others {
+			lines = append(lines, "          and "+addr)
+		}
+	} else {
+		lines = append(lines, "starting ...")
+	}
+	lines = append(lines, d.qr...)
+	if interactive && d.controls.Deadline != nil {
+		if deadline, ok := d.controls.Deadline(); ok {
+			left := time.Until(deadline).Round(time.Second)
+			lines = append(lines, fmt.Sprintf("timing out in %s", left))
+		}
+	}
+	lines = append(lines, "")
+
+	buf := bytes.NewBuffer(nil)
+	tw := tabwriter.NewWriter(buf, 4, 2, 2, ' ', 0)
+	fmt.Fprintln(tw, "  CLIENT\tUSER\tUSER AGENT\tREQUEST\tTRANSFERRED\tRATE\tETA\tSTATUS")
+	for i, c := range d.clients {
+		cursor := " "
+		if interactive && i == d.selected {
+			cursor = ">"
+		}
+		fmt.Fprintf(tw, "%s %s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			cursor,
+			c.addr,
+			orDash(c.user),
+			orDash(truncate(c.userAgent, 32)),
+			orDash(c.request),
+			c.transferred(),
+			c.prettyRate(),
+			c.eta(),
+			c.status,
+		)
+	}
+	tw.Flush()
+	lines = append(lines, strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")...)
+	if len(d.clients) == 0 {
+		lines = append(lines, "  waiting for clients ...")
+	}
+
+	if 0 < len(d.rejected) {
+		lines = append(lines, "", "rejected requests")
+		for _, r := range d.rejected {
+			lines = append(lines, fmt.Sprintf("  %s  %s  %s %s",
+				r.time.Format(time.TimeOnly), r.addr, r.method, r.path))
+		}
+	}
+
+	if d.reason != "" {
+		lines = append(lines, "", "session ended: "+d.reason)
+	}
+
+	if interactive {
+		lines = append(lines, "")
+		if d.keys {
+			lines = append(lines, "up/down select  x kick client  + extend timeout  q end session")
+		}
+		if d.message != "" {
+			lines = append(lines, d.message)
+		}
+	}
+
+	for i, l := range lines {
+		lines[i] = truncate(l, width)
+	}
+	return lines
+}
+
+func (c *dashboardClient) transferred() string {
+	if c.total <= 0 {
+		return oneshotfmt.PrettySize(c.bytes)
+	}
+	return fmt.Sprintf("%s (%s)", oneshotfmt.PrettySize(c.bytes), oneshotfmt.PrettyPercent(c.bytes, c.total))
+}
+
+func (c *dashboardClient) prettyRate() string {
+	if c.status != clientTransferring {
+		return "-"
+	}
+	return oneshotfmt.PrettyRate(float64(c.rate))
+}
+
+func (c *dashboardClient) eta() string {
+	if c.status != clientTransferring || c.total <= 0 || c.rate <= 0 || c.total < c.bytes {
+		return "-"
+	}
+	return (time.Duration((c.total-c.bytes)/c.rate) * time.Second).String()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// truncate shortens s to at most n runes.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if n <= 0 || len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
+
+// runTUI draws the dashboard on stderr until events stop.
+func runTUI(ctx context.Context, o *output) {
+	var (
+		log   = zerolog.Ctx(ctx)
+		d     = o.tui
+		te    = d.te
+		stdin = int(os.Stdin.Fd())
+		done  = make(chan struct{})
+	)
+
+	te.AltScreen()
+	te.HideCursor()
+
+	var restoreStdin func()
+	if d.keys && term.IsTerminal(stdin) {
+		state, err := term.MakeRaw(stdin)
+		if err != nil {
+			log.Error().Err(err).
+				Msg("unable to put stdin into raw mode, keyboard controls are disabled")
+			d.keys = false
+		} else {
+			restoreStdin = func() {
+				_ = term.Restore(stdin, state)
+			}
+		}
+	} else {
+		d.keys = false
+	}
+
+	var renderMu sync.Mutex
+	render := func() {
+		renderMu.Lock()
+		defer renderMu.Unlock()
+		drawDashboard(te, d)
+	}
+
+	if d.keys {
+		go func() {
+			buf := make([]byte, 64)
+			for {
+				n, err := os.Stdin.Read(buf)
+				if err != nil {
+					return
+				}
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if d.handleKeys(buf[:n]) {
+					render()
+					d.end()
+					return
+				}
+				render()
+			}
+		}()
+	}
+
+	go func() {
+		ticker := time.NewTicker(dashboardRefresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				render()
+			}
+		}
+	}()
+
+	render()
+	for event := range o.events {
+		d.update(event)
+		_json_handleEvent(o, event)
+		render()
+	}
+	close(done)
+
+	renderMu.Lock()
+	if restoreStdin != nil {
+		restoreStdin()
+	}
+	te.ExitAltScreen()
+	te.ShowCursor()
+	renderMu.Unlock()
+
+	// leave a record of the session behind once the dashboard is gone
+	for _, l := range d.view(0, false) {
+		fmt.Fprintln(os.Stderr, l)
+	}
+
+	_json_handleContextDone(ctx, o)
+}
+
+// drawDashboard redraws the whole screen in a single write to avoid flickering.
+// Lines end in \r\n since raw mode stops the terminal from adding the carriage return.
+func drawDashboard(te *termenv.Output, d *dashboard) {
+	width, height, err := term.GetSize(int(os.Stderr.Fd()))
+	if err != nil {
+		width, height = 80, 24
+	}
+
+	lines := d.view(width, true)
+	if 0 < height && height < len(lines) {
+		lines = lines[:height]
+	}
+
+	buf := bytes.NewBuffer(nil)
+	fmt.Fprintf(buf, termenv.CSI+termenv.CursorPositionSeq, 1, 1)
+	for i, l := range lines {
+		buf.WriteString(l)
+		fmt.Fprintf(buf, termenv.CSI+termenv.EraseLineSeq, 0)
+		if i < len(lines)-1 {
+			buf.WriteString("\r\n")
+		}
+	}
+	fmt.Fprintf(buf, termenv.CSI+termenv.EraseDisplaySeq, 0)
+	_, _ = te.Write(buf.Bytes())
+}
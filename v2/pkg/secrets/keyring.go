@@ -0,0 +1,49 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// KeyringFileEnv names a yaml file to look keyring secrets up in instead of the OS keyring.
+// The file maps service/user to the secret, e.g.
+//
+//	oneshot/alice: hunter2
+//
+// This is meant for tests and for machines without a keyring.
+const KeyringFileEnv = "ONESHOT_KEYRING_FILE"
+
+func resolveKeyring(ref string) (string, error) {
+	service, user, ok := strings.Cut(ref, "/")
+	if !ok || service == "" || user == "" {
+		return "", fmt.Errorf("invalid keyring reference %q, expected service/user", ref)
+	}
+
+	if path := os.Getenv(KeyringFileEnv); path != "" {
+		return lookupKeyringFile(path, service, user)
+	}
+
+	return lookupKeyring(service, user)
+}
+
+func lookupKeyringFile(path, service, user string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read keyring file: %w", err)
+	}
+
+	var entries map[string]string
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return "", fmt.Errorf("unable to parse keyring file: %w", err)
+	}
+
+	secret, ok := entries[service+"/"+user]
+	if !ok {
+		return "", fmt.Errorf("no keyring entry for %s/%s", service, user)
+	}
+
+	return secret, nil
+}
@@ -0,0 +1,16 @@
+package secrets
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// lookupKeyring reads a generic password out of the login keychain.
+func lookupKeyring(service, user string) (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", service, "-a", user, "-w").Output()
+	if err != nil {
+		return "", fmt.Errorf("no keychain entry for %s/%s: %w", service, user, err)
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
@@ -0,0 +1,19 @@
+//go:build !darwin && !windows
+
+package secrets
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// lookupKeyring reads a secret out of the Secret Service (e.g. GNOME Keyring or KWallet)
+// using the same service and username attributes other keyring tools store secrets with.
+func lookupKeyring(service, user string) (string, error) {
+	out, err := exec.Command("secret-tool", "lookup", "service", service, "username", user).Output()
+	if err != nil {
+		return "", fmt.Errorf("no keyring entry for %s/%s: %w", service, user, err)
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
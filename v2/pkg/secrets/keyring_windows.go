@@ -0,0 +1,9 @@
+package secrets
+
+import "fmt"
+
+// lookupKeyring is not supported since Windows has no builtin way of reading
+// credentials out of the Credential Manager from the command line.
+func lookupKeyring(service, user string) (string, error) {
+	return "", fmt.Errorf("unable to look up %s/%s: the windows credential manager is not supported, use %s instead", service, user, KeyringFileEnv)
+}
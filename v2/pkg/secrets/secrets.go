@@ -0,0 +1,233 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Redacted is shown in place of a secret.
+const Redacted = "<redacted>"
+
+// secret reference providers
+const (
+	ProviderEnv     = "env"
+	ProviderFile    = "file"
+	ProviderCmd     = "cmd"
+	ProviderKeyring = "keyring"
+	// ProviderLiteral escapes values that would otherwise be taken as a reference,
+	// literal:cmd:x is the secret cmd:x.
+	ProviderLiteral = "literal"
+)
+
+// PathReferenceHelp is the flag help for flags that take a path that may also be a secret reference.
+// file: is left out, a path already names a file.
+const PathReferenceHelp = `A secret reference such as env:VAR, cmd:command or keyring:service/user may be given instead of a path,
+use literal:path for a path that starts with one of these prefixes.`
+
+// ReferenceHelp is the flag help for flags that take a secret that may also be a secret reference.
+// what names the secret, e.g. password.
+func ReferenceHelp(what string) string {
+	return `May be a secret reference such as env:VAR, file:path, cmd:command or keyring:service/user.
+Use literal:value for a ` + what + ` that starts with one of these prefixes.`
+}
+
+var providers = map[string]func(string) (string, error){
+	ProviderEnv:     resolveEnv,
+	ProviderFile:    resolveFile,
+	ProviderCmd:     resolveCmd,
+	ProviderKeyring: resolveKeyring,
+	ProviderLiteral: resolveLiteral,
+}
+
+// IsReference reports whether s refers to a secret rather than being one.
+// References take the form provider:value, e.g. env:VAR, file:path,
+// cmd:pass show oneshot or keyring:service/user.
+// literal:value is a reference to value itself.
+func IsReference(s string) bool {
+	provider, _, ok := strings.Cut(s, ":")
+	if !ok {
+		return false
+	}
+	_, ok = providers[provider]
+	return ok
+}
+
+// Resolve returns the secret that ref refers to.
+// If ref is not a reference then it is returned as is.
+// Every secret returned is registered so that it can be redacted later on.
+func Resolve(ref string) (string, error) {
+	if !IsReference(ref) {
+		Register(ref)
+		return ref, nil
+	}
+
+	provider, value, _ := strings.Cut(ref, ":")
+	secret, err := providers[provider](value)
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve %s secret: %w", provider, err)
+	}
+	Register(secret)
+
+	return secret, nil
+}
+
+// ResolvePath is the same as Resolve except that a ref that is not a reference
+// is taken to be the path to a file holding the secret.
+// This keeps the older *File and *Path configuration values working.
+// literal:path escapes a path that would otherwise be taken as a reference.
+func ResolvePath(ref string) (string, error) {
+	if path, ok := strings.CutPrefix(ref, ProviderLiteral+":"); ok {
+		ref = ProviderFile + ":" + path
+	} else if !IsReference(ref) {
+		ref = ProviderFile + ":" + ref
+	}
+	return Resolve(ref)
+}
+
+func resolveEnv(name string) (string, error) {
+	secret, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return secret, nil
+}
+
+func resolveFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func resolveLiteral(secret string) (string, error) {
+	return secret, nil
+}
+
+func resolveCmd(command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	// stdout holds the secret so only stderr makes it into errors
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %w: %s", command, err, msg)
+		}
+		return "", fmt.Errorf("%s: %w", command, err)
+	}
+
+	// password managers end their output with a newline
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+var (
+	mu    sync.RWMutex
+	known = map[string]struct{}{}
+)
+
+// Register marks secret as a value that must not be shown.
+func Register(secret string) {
+	if secret == "" {
+		return
+	}
+	mu.Lock()
+	known[secret] = struct{}{}
+	mu.Unlock()
+}
+
+// Unregister stops secret from being redacted, for secrets that are no longer of any use.
+func Unregister(secret string) {
+	mu.Lock()
+	delete(known, secret)
+	mu.Unlock()
+}
+
+// IsSecret reports whether s is a registered secret.
+func IsSecret(s string) bool {
+	if s == "" {
+		return false
+	}
+	mu.RLock()
+	_, ok := known[s]
+	mu.RUnlock()
+	return ok
+}
+
+// RedactHeader replaces registered secrets in the header values of h.
+// Credentials of the form "<scheme> <token>", basic auth credentials
+// whose password is a secret and cookies whose value is a secret are redacted as well.
+func RedactHeader(h map[string][]string) map[string][]string {
+	for _, values := range h {
+		for i, v := range values {
+			values[i] = redactHeaderValue(v)
+		}
+	}
+	return h
+}
+
+// redactCookies redacts the secret values of a Cookie or Set-Cookie header value,
+// a list of name=value pairs separated by semicolons.
+// It reports whether any were redacted.
+func redactCookies(v string) (string, bool) {
+	var (
+		pairs    = strings.Split(v, ";")
+		redacted bool
+	)
+	for i, pair := range pairs {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		if IsSecret(strings.Trim(strings.TrimSpace(value), `"`)) {
+			pairs[i] = name + "=" + Redacted
+			redacted = true
+		}
+	}
+	if !redacted {
+		return v, false
+	}
+	return strings.Join(pairs, ";"), true
+}
+
+func redactHeaderValue(v string) string {
+	if IsSecret(v) {
+		return Redacted
+	}
+
+	if redacted, ok := redactCookies(v); ok {
+		return redacted
+	}
+
+	scheme, token, ok := strings.Cut(v, " ")
+	if !ok {
+		return v
+	}
+	if IsSecret(token) {
+		return scheme + " " + Redacted
+	}
+	if !strings.EqualFold(scheme, "basic") {
+		return v
+	}
+
+	creds, err := base64.StdEncoding.DecodeString(token)
+	if err != nil {
+		return v
+	}
+	if _, password, ok := strings.Cut(string(creds), ":"); ok && IsSecret(password) {
+		return scheme + " " + Redacted
+	}
+
+	return v
+}
@@ -9,7 +9,10 @@ var (
 	License    = "Apache License 2.0"
 )
 
-func init() {
+// Check panics if the build did not set the version information.
+// It is left to the oneshot binary rather than run on import so that
+// the packages it uses can be imported by other programs.
+func Check() {
 	if os.Getenv("ONESHOT_SKIP_INIT_CHECKS") != "" {
 		return
 	}